@@ -17,7 +17,7 @@ package cmd
 
 import (
 	"github.com/DopplerHQ/cli/pkg/configuration"
-	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
@@ -30,12 +30,28 @@ var activityCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		jsonFlag := utils.OutputJSON
 		localConfig := configuration.LocalConfig(cmd)
-		// number := utils.GetIntFlag(cmd, "number", 16)
+		number, page := logsPageFlags(cmd)
+		timeRange := logsTimeRangeFlags(cmd)
 
 		utils.RequireValue("token", localConfig.Token.Value)
 
-		activity, err := http.GetActivityLogs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value)
-		if !err.IsNil() {
+		pageOptions, skip := logsPageOptions(number, page)
+		it := apiClient(localConfig).ActivityLogs(cliContext, pageOptions)
+		var activity []models.ActivityLog
+		for len(activity) < number && it.Next() {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			include, done := timeRange.filter(it.Log().CreatedAt)
+			if done {
+				break
+			}
+			if include {
+				activity = append(activity, it.Log())
+			}
+		}
+		if err := it.Err(); !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
 
@@ -59,7 +75,7 @@ var activityGetCmd = &cobra.Command{
 		}
 		utils.RequireValue("log", log)
 
-		activity, err := http.GetActivityLog(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, log)
+		activity, err := apiClient(localConfig).GetActivityLog(cliContext, log)
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
@@ -72,7 +88,9 @@ func init() {
 	activityGetCmd.Flags().String("log", "", "activity log id")
 	activityCmd.AddCommand(activityGetCmd)
 
-	// TODO: hide this flag until the api supports it
-	// activityCmd.Flags().IntP("number", "n", 5, "max number of logs to display")
+	activityCmd.Flags().IntP("number", "n", 20, "max number of logs to display")
+	activityCmd.Flags().Int("page", 1, "the page of logs to start at, where each page contains --number logs")
+	activityCmd.Flags().String("since", "", "only display logs created after this time (e.g. 2020-01-02, 2020-01-02T15:04:05Z, or 24h)")
+	activityCmd.Flags().String("until", "", "only display logs created before this time (e.g. 2020-01-02, 2020-01-02T15:04:05Z, or 24h)")
 	rootCmd.AddCommand(activityCmd)
 }
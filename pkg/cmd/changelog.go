@@ -30,7 +30,7 @@ var changelogCmd = &cobra.Command{
 		number := utils.GetIntFlag(cmd, "number", 16)
 		jsonFlag := utils.OutputJSON
 
-		changes, apiError := controllers.CLIChangeLog()
+		changes, apiError := controllers.CLIChangeLog(cliContext)
 		if !apiError.IsNil() {
 			utils.HandleError(apiError.Unwrap(), apiError.Message)
 		}
@@ -16,35 +16,444 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/DopplerHQ/cli/pkg/configuration"
+	"github.com/DopplerHQ/cli/pkg/controllers"
+	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
 // completionCmd represents the completion command
 var completionCmd = &cobra.Command{
-	Use:   "completion",
-	Short: "Generates bash completion scripts",
-	Long: `To load completion run
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate shell completion scripts",
+	Long: `Generate a shell completion script. Defaults to bash.
 
-. <(doppler completion)
+Project, config, and secret names, service token slugs, and log IDs are completed from the Doppler API.
+Completion values are cached for a few minutes, and the cache is used when the API is unreachable.
 
-To configure your bash shell to load completions for each session add to your bashrc
+Bash:
+  # ~/.bashrc or ~/.profile
+  . <(doppler completion bash)
 
-# ~/.bashrc or ~/.profile
-. <(doppler completion)
+Zsh:
+  # ~/.zshrc
+  . <(doppler completion zsh)
+
+Fish:
+  $ doppler completion fish > ~/.config/fish/completions/doppler.fish
+
+PowerShell:
+  # $PROFILE
+  doppler completion powershell | Out-String | Invoke-Expression
 `,
-	Args: cobra.NoArgs,
+	ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+	Args:      cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		err := rootCmd.GenBashCompletion(os.Stdout)
+		shell := "bash"
+		if len(args) > 0 {
+			shell = args[0]
+		}
+
+		var err error
+		switch shell {
+		case "bash":
+			err = rootCmd.GenBashCompletion(os.Stdout)
+		case "zsh":
+			_, err = io.WriteString(os.Stdout, zshCompletion)
+		case "fish":
+			err = rootCmd.GenFishCompletion(os.Stdout, true)
+		case "powershell":
+			_, err = io.WriteString(os.Stdout, powerShellCompletion)
+		default:
+			utils.HandleError(utils.NewError(utils.ErrorKindUsage, fmt.Errorf("Invalid shell %s", shell)), "Valid shells are bash, zsh, fish, and powershell")
+		}
 		if err != nil {
-			utils.HandleError(err, "Unable to generate bash completion")
+			utils.HandleError(err, fmt.Sprintf("Unable to generate %s completion", shell))
 		}
 	},
 }
 
+// zshCompletion requests completions from the CLI's hidden __complete command, like the bash and fish scripts generated by cobra.
+// cobra v1.0's zsh and PowerShell generators only complete static values; switch to GenZshCompletion and
+// GenPowerShellCompletion when upgrading to cobra v1.1+, which use __complete.
+const zshCompletion = `#compdef doppler
+
+_doppler() {
+  local shellCompDirectiveError=1
+  local shellCompDirectiveNoSpace=2
+  local shellCompDirectiveNoFileComp=4
+
+  local lastParam flagPrefix requestComp out directive comp lastLine noSpace
+  local -a completions
+
+  # only pass the words up to the cursor
+  words=("${=words[1,CURRENT]}")
+  lastParam=${words[-1]}
+
+  # when completing --flag=value, zsh must prefix each completion with the flag
+  setopt local_options BASH_REMATCH
+  if [[ "${lastParam}" =~ '-.*=' ]]; then
+    flagPrefix="-P ${BASH_REMATCH}"
+  fi
+
+  requestComp="${words[1]} __complete ${words[2,-1]}"
+  # an empty last word means a new argument is being completed
+  if [ "${lastParam}" = "" ]; then
+    requestComp="${requestComp} \"\""
+  fi
+
+  out=$(eval ${requestComp} 2>/dev/null)
+
+  # the last line is the directive, preceded by a colon
+  while IFS='\n' read -r line; do
+    lastLine=${line}
+  done < <(printf "%s\n" "${out[@]}")
+  if [ "${lastLine[1]}" = : ]; then
+    directive=${lastLine[2,-1]}
+    local suffix
+    (( suffix=${#lastLine}+2 ))
+    out=${out[1,-$suffix]}
+  else
+    directive=0
+  fi
+
+  if [ $((directive & shellCompDirectiveError)) -ne 0 ]; then
+    return
+  fi
+
+  while IFS='\n' read -r comp; do
+    if [ -n "$comp" ]; then
+      # _describe separates completions and their descriptions with a colon rather than a tab
+      comp=${comp//:/\\:}
+      local tab=$(printf '\t')
+      comp=${comp//$tab/:}
+      completions+=${comp}
+    fi
+  done < <(printf "%s\n" "${out[@]}")
+
+  if [ $((directive & shellCompDirectiveNoSpace)) -ne 0 ]; then
+    noSpace="-S ''"
+  fi
+
+  _describe "completions" completions $(echo $flagPrefix) $(echo $noSpace)
+  if [ $? -ne 0 ] && [ $((directive & shellCompDirectiveNoFileComp)) -eq 0 ]; then
+    _arguments '*:filename:_files'
+  fi
+}
+
+if [ "$funcstack[1]" = "_doppler" ]; then
+  _doppler
+else
+  compdef _doppler doppler
+fi
+`
+
+// powerShellCompletion requests completions from the CLI's hidden __complete command. see zshCompletion.
+const powerShellCompletion = `Register-ArgumentCompleter -Native -CommandName 'doppler' -ScriptBlock {
+  param($WordToComplete, $CommandAst, $CursorPosition)
+
+  # only pass the words up to the cursor
+  $Command = "$CommandAst"
+  if ($Command.Length -gt $CursorPosition) {
+    $Command = $Command.Substring(0, $CursorPosition)
+  }
+  $Program, $Arguments = $Command.Split(" ", 2)
+  $RequestComp = "$Program __complete $Arguments"
+  # an empty word means a new argument is being completed
+  if ($WordToComplete -eq "") {
+    $RequestComp = "$RequestComp" + ' ` + "`\"`\"" + `'
+  }
+
+  $Out = @(Invoke-Expression -Command "$RequestComp" 2>$null)
+  if ($Out.Length -eq 0) {
+    return
+  }
+
+  # the last line is the directive, preceded by a colon
+  $Directive = 0
+  if ($Out[-1] -match '^:(\d+)$') {
+    $Directive = [int]$Matches[1]
+    $Out = @($Out | Select-Object -SkipLast 1)
+  }
+  if (($Directive -band 1) -ne 0) {
+    return
+  }
+
+  # --flag=value completions must include the flag
+  $Prefix = ""
+  if ($WordToComplete -match '^(-.*=)') {
+    $Prefix = $Matches[1]
+  }
+
+  foreach ($Line in $Out) {
+    if ($Line -eq "") {
+      continue
+    }
+    $Name, $Description = $Line.Split("` + "`t" + `", 2)
+    if (-not $Description) {
+      $Description = $Name
+    }
+    [System.Management.Automation.CompletionResult]::new("$Prefix$Name", "$Name", 'ParameterValue', "$Description")
+  }
+}
+`
+
+// completionFunc completes a flag or argument
+type completionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// isCompletionRequest whether the command is cobra's hidden command that shell completion scripts call
+func isCompletionRequest(command string) bool {
+	return command == cobra.ShellCompRequestCmd || command == cobra.ShellCompNoDescRequestCmd
+}
+
+// registerCompletions adds dynamic completion of flags and arguments whose values come from the Doppler API
+func registerCompletions(root *cobra.Command) {
+	// every command with a --project or --config flag
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		registerFlagCompletion(cmd, "project", completeProjects)
+		registerFlagCompletion(cmd, "config", completeConfigs)
+		for _, child := range cmd.Commands() {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	setArgCompletion(completeProjects, projectsGetCmd, projectsDeleteCmd, projectsUpdateCmd, enclaveProjectsGetCmd, enclaveProjectsDeleteCmd, enclaveProjectsUpdateCmd)
+	setArgCompletion(completeEnvironments, environmentsGetCmd, enclaveEnvironmentsGetCmd)
+	setArgCompletion(completeConfigs, configsGetCmd, configsDeleteCmd, configsUpdateCmd, configsLockCmd, configsUnlockCmd, configsCloneCmd,
+		enclaveConfigsGetCmd, enclaveConfigsDeleteCmd, enclaveConfigsUpdateCmd, enclaveConfigsLockCmd, enclaveConfigsUnlockCmd)
+	setArgCompletion(completeConfigLogs, configsLogsGetCmd, configsLogsRollbackCmd, enclaveConfigsLogsGetCmd, enclaveConfigsLogsRollbackCmd)
+	setArgCompletion(completeServiceTokens, configsTokensGetCmd, configsTokensRotateCmd, configsTokensRevokeCmd, enclaveConfigsTokensGetCmd, enclaveConfigsTokensRevokeCmd)
+	setArgCompletion(completeActivityLogs, activityGetCmd)
+	for _, cmd := range []*cobra.Command{secretsGetCmd, secretsDeleteCmd, enclaveSecretsGetCmd, enclaveSecretsDeleteCmd} {
+		cmd.ValidArgsFunction = completeSecretNames
+	}
+	for _, cmd := range []*cobra.Command{secretsSetCmd, enclaveSecretsSetCmd} {
+		cmd.ValidArgsFunction = completeSecretAssignments
+	}
+
+	for _, cmd := range []*cobra.Command{configsLogsGetCmd, configsLogsRollbackCmd, enclaveConfigsLogsGetCmd, enclaveConfigsLogsRollbackCmd} {
+		registerFlagCompletion(cmd, "log", completeConfigLogs)
+	}
+	for _, cmd := range []*cobra.Command{configsTokensGetCmd, configsTokensRotateCmd, configsTokensRevokeCmd, enclaveConfigsTokensGetCmd, enclaveConfigsTokensRevokeCmd} {
+		registerFlagCompletion(cmd, "slug", completeServiceTokens)
+	}
+	registerFlagCompletion(activityGetCmd, "log", completeActivityLogs)
+
+	// profiles are stored locally, so no API request is needed
+	setArgCompletion(completeProfiles, profileUseCmd, profileDeleteCmd)
+	if err := root.RegisterFlagCompletionFunc("profile", completeProfiles); err != nil {
+		utils.LogDebugError(err)
+	}
+}
+
+// registerFlagCompletion completes the command's flag, if the command defines it
+func registerFlagCompletion(cmd *cobra.Command, name string, f completionFunc) {
+	if cmd.LocalNonPersistentFlags().Lookup(name) == nil {
+		return
+	}
+	if err := cmd.RegisterFlagCompletionFunc(name, f); err != nil {
+		utils.LogDebugError(err)
+	}
+}
+
+// setArgCompletion completes the first argument of each command
+func setArgCompletion(f completionFunc, cmds ...*cobra.Command) {
+	complete := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return f(cmd, args, toComplete)
+	}
+	for _, cmd := range cmds {
+		cmd.ValidArgsFunction = complete
+	}
+}
+
+// completionValues fetches completion values of the kind (e.g. projects), using the cache when possible
+func completionValues(cmd *cobra.Command, kind string, fetch func(localConfig models.ScopedOptions, client *http.Client) ([]string, http.Error)) []string {
+	// resolving the token (e.g. via the keyring or a token helper) is slow, so the cache is keyed by the unresolved token
+	unresolvedConfig := configuration.UnresolvedLocalConfig(cmd)
+	if unresolvedConfig.Token.Value == "" {
+		return nil
+	}
+
+	key := []string{unresolvedConfig.APIHost.Value, unresolvedConfig.Token.Value, kind, unresolvedConfig.EnclaveProject.Value, unresolvedConfig.EnclaveConfig.Value}
+	return controllers.CompletionValues(key, func() ([]string, error) {
+		localConfig := configuration.LocalConfig(cmd)
+		// completion should fail fast rather than block the shell
+		client := apiClient(localConfig, http.WithTimeout(3*time.Second), http.WithRetryPolicy(http.RetryPolicy{Attempts: 1}))
+		values, err := fetch(localConfig, client)
+		if !err.IsNil() {
+			if err.Unwrap() != nil {
+				return nil, err.Unwrap()
+			}
+			return nil, errors.New(err.Message)
+		}
+		sort.Strings(values)
+		return values, nil
+	})
+}
+
+// completionWithDescription a completion value followed by its description, which shells display alongside the value
+func completionWithDescription(value string, description string) string {
+	// descriptions must be a single line
+	description = strings.Join(strings.Fields(description), " ")
+	if description == "" || description == value {
+		return value
+	}
+	return fmt.Sprintf("%s\t%s", value, description)
+}
+
+// filterCompletions the values that start with the text being completed, excluding those already specified. values may contain a tab-separated description.
+func filterCompletions(values []string, toComplete string, exclude []string) []string {
+	excluded := map[string]bool{}
+	for _, name := range exclude {
+		excluded[name] = true
+	}
+
+	var completions []string
+	for _, value := range values {
+		name := strings.SplitN(value, "\t", 2)[0]
+		if strings.HasPrefix(name, toComplete) && !excluded[name] {
+			completions = append(completions, value)
+		}
+	}
+	return completions
+}
+
+func completeProjects(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	values := completionValues(cmd, "projects", func(localConfig models.ScopedOptions, client *http.Client) ([]string, http.Error) {
+		projects, err := client.GetProjects(cliContext)
+		var values []string
+		for _, project := range projects {
+			values = append(values, completionWithDescription(project.ID, project.Name))
+		}
+		return values, err
+	})
+	return filterCompletions(values, toComplete, nil), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeEnvironments(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	values := completionValues(cmd, "environments", func(localConfig models.ScopedOptions, client *http.Client) ([]string, http.Error) {
+		environments, err := client.GetEnvironments(cliContext, localConfig.EnclaveProject.Value)
+		var values []string
+		for _, environment := range environments {
+			values = append(values, completionWithDescription(environment.ID, environment.Name))
+		}
+		return values, err
+	})
+	return filterCompletions(values, toComplete, nil), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeConfigs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	values := completionValues(cmd, "configs", func(localConfig models.ScopedOptions, client *http.Client) ([]string, http.Error) {
+		configs, err := client.GetConfigs(cliContext, localConfig.EnclaveProject.Value)
+		var values []string
+		for _, config := range configs {
+			values = append(values, config.Name)
+		}
+		return values, err
+	})
+	return filterCompletions(values, toComplete, nil), cobra.ShellCompDirectiveNoFileComp
+}
+
+func secretNames(cmd *cobra.Command) []string {
+	return completionValues(cmd, "secrets", func(localConfig models.ScopedOptions, client *http.Client) ([]string, http.Error) {
+		response, err := client.GetSecrets(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
+		if !err.IsNil() {
+			return nil, err
+		}
+		secrets, parseErr := models.ParseSecrets(response)
+		if parseErr != nil {
+			return nil, http.Error{Err: parseErr, Message: "Unable to parse API response"}
+		}
+		// only the names are cached, never the values
+		var values []string
+		for name := range secrets {
+			values = append(values, name)
+		}
+		return values, http.Error{}
+	})
+}
+
+func completeSecretNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return filterCompletions(secretNames(cmd), toComplete, args), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeSecretAssignments completes secret names followed by '=', so that a value can be typed (e.g. API_KEY=123)
+func completeSecretAssignments(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if strings.Contains(toComplete, "=") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var assigned []string
+	for _, arg := range args {
+		assigned = append(assigned, strings.SplitN(arg, "=", 2)[0])
+	}
+
+	var completions []string
+	for _, name := range filterCompletions(secretNames(cmd), toComplete, assigned) {
+		completions = append(completions, name+"=")
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+func completeServiceTokens(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	values := completionValues(cmd, "service-tokens", func(localConfig models.ScopedOptions, client *http.Client) ([]string, http.Error) {
+		tokens, err := client.ConfigServiceTokens(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, http.PageOptions{}).All()
+		var values []string
+		for _, token := range tokens {
+			values = append(values, completionWithDescription(token.Slug, token.Name))
+		}
+		return values, err
+	})
+	return filterCompletions(values, toComplete, nil), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeConfigLogs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	values := completionValues(cmd, "config-logs", func(localConfig models.ScopedOptions, client *http.Client) ([]string, http.Error) {
+		// only the most recent logs
+		logs, _, err := client.GetConfigLogs(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, http.PageOptions{Page: 1})
+		var values []string
+		for _, log := range logs {
+			values = append(values, completionWithDescription(log.ID, log.Text))
+		}
+		return values, err
+	})
+	return filterCompletions(values, toComplete, nil), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeActivityLogs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	values := completionValues(cmd, "activity-logs", func(localConfig models.ScopedOptions, client *http.Client) ([]string, http.Error) {
+		// only the most recent logs
+		logs, _, err := client.GetActivityLogs(cliContext, http.PageOptions{Page: 1})
+		var values []string
+		for _, log := range logs {
+			values = append(values, completionWithDescription(log.ID, log.Text))
+		}
+		return values, err
+	})
+	return filterCompletions(values, toComplete, nil), cobra.ShellCompDirectiveNoFileComp
+}
+
 func init() {
-	// TODO re-enable
-	// rootCmd.AddCommand(completionCmd)
+	controllers.CompletionCacheDir = filepath.Join(configuration.UserConfigDir, "completion")
+	rootCmd.AddCommand(completionCmd)
+}
+
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return filterCompletions(configuration.ProfileNames(), toComplete, nil), cobra.ShellCompDirectiveNoFileComp
 }
@@ -0,0 +1,105 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DopplerHQ/cli/pkg/controllers"
+)
+
+func TestFilterCompletions(t *testing.T) {
+	values := []string{"backend\tBackend API", "billing", "frontend\tWeb app", "b2"}
+
+	tests := []struct {
+		toComplete string
+		exclude    []string
+		expected   []string
+	}{
+		{"", nil, values},
+		{"b", nil, []string{"backend\tBackend API", "billing", "b2"}},
+		{"ba", nil, []string{"backend\tBackend API"}},
+		// descriptions aren't matched
+		{"Web", nil, nil},
+		{"b", []string{"billing", "b2"}, []string{"backend\tBackend API"}},
+		// names with descriptions can be excluded
+		{"", []string{"backend", "frontend"}, []string{"billing", "b2"}},
+	}
+	for _, test := range tests {
+		if actual := filterCompletions(values, test.toComplete, test.exclude); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("filterCompletions(%q, %v) = %q, expected %q", test.toComplete, test.exclude, actual, test.expected)
+		}
+	}
+}
+
+func TestCompletionWithDescription(t *testing.T) {
+	tests := []struct {
+		value       string
+		description string
+		expected    string
+	}{
+		{"backend", "", "backend"},
+		{"backend", "backend", "backend"},
+		{"backend", "Backend API", "backend\tBackend API"},
+		{"log_1", "Added  API_KEY\nRemoved\tDB_URL", "log_1\tAdded API_KEY Removed DB_URL"},
+	}
+	for _, test := range tests {
+		if actual := completionWithDescription(test.value, test.description); actual != test.expected {
+			t.Errorf("completionWithDescription(%q, %q) = %q, expected %q", test.value, test.description, actual, test.expected)
+		}
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	for name, script := range map[string]string{"zsh": zshCompletion, "powershell": powerShellCompletion} {
+		if !strings.Contains(script, "__complete") {
+			t.Errorf("Expected the %s script to request completions from the CLI", name)
+		}
+	}
+}
+
+func TestCompletionUsesCacheBeforeTokenHelper(t *testing.T) {
+	home, _ := testHome(t)
+	defer os.RemoveAll(home)
+
+	// the token helper fails, so completion only succeeds if the cached values are used without resolving the token
+	configDir := filepath.Join(home, ".doppler")
+	config := "scoped:\n  /:\n    token-helper: false\n"
+	if err := ioutil.WriteFile(filepath.Join(configDir, ".doppler.yaml"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := controllers.CompletionCacheDir
+	controllers.CompletionCacheDir = filepath.Join(configDir, "completion")
+	defer func() { controllers.CompletionCacheDir = previous }()
+	key := []string{"https://api.doppler.com", "token-helper:false", "projects", "", ""}
+	controllers.CompletionValues(key, func() ([]string, error) {
+		return []string{"backend\tBackend API", "frontend"}, nil
+	})
+
+	out, err := helperCommand(home, "__complete", "projects", "get", "b").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Completion failed: %s\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "backend\tBackend API\n") || strings.Contains(string(out), "frontend") {
+		t.Errorf("Expected the cached completion, got:\n%s", out)
+	}
+}
@@ -21,7 +21,6 @@ import (
 	"strings"
 
 	"github.com/DopplerHQ/cli/pkg/configuration"
-	"github.com/DopplerHQ/cli/pkg/http"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
@@ -89,7 +88,7 @@ func configs(cmd *cobra.Command, args []string) {
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
-	configs, err := http.GetConfigs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value)
+	configs, err := apiClient(localConfig).GetConfigs(cliContext, localConfig.EnclaveProject.Value)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -108,7 +107,7 @@ func getConfigs(cmd *cobra.Command, args []string) {
 		config = args[0]
 	}
 
-	configInfo, err := http.GetConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config)
+	configInfo, err := apiClient(localConfig).GetConfig(cliContext, localConfig.EnclaveProject.Value, config)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -129,7 +128,7 @@ func createConfigs(cmd *cobra.Command, args []string) {
 	}
 
 	if name == "" {
-		utils.HandleError(errors.New("you must specify a name"))
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("you must specify a name")))
 	}
 
 	if environment == "" && strings.Index(name, "_") != -1 {
@@ -137,10 +136,10 @@ func createConfigs(cmd *cobra.Command, args []string) {
 	}
 
 	if environment == "" {
-		utils.HandleError(errors.New("you must specify an environment"))
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("you must specify an environment")))
 	}
 
-	info, err := http.CreateConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, name, environment)
+	info, err := apiClient(localConfig).CreateConfig(cliContext, localConfig.EnclaveProject.Value, name, environment)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -168,13 +167,13 @@ func deleteConfigs(cmd *cobra.Command, args []string) {
 	}
 
 	if yes || utils.ConfirmationPrompt(prompt, false) {
-		err := http.DeleteConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config)
+		err := apiClient(localConfig).DeleteConfig(cliContext, localConfig.EnclaveProject.Value, config)
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
 
 		if !utils.Silent {
-			configs, err := http.GetConfigs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value)
+			configs, err := apiClient(localConfig).GetConfigs(cliContext, localConfig.EnclaveProject.Value)
 			if !err.IsNil() {
 				utils.HandleError(err.Unwrap(), err.Message)
 			}
@@ -197,7 +196,7 @@ func updateConfigs(cmd *cobra.Command, args []string) {
 		config = args[0]
 	}
 
-	info, err := http.UpdateConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config, name)
+	info, err := apiClient(localConfig).UpdateConfig(cliContext, localConfig.EnclaveProject.Value, config, name)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -225,7 +224,7 @@ func lockConfigs(cmd *cobra.Command, args []string) {
 	}
 
 	if yes || utils.ConfirmationPrompt(prompt, false) {
-		configInfo, err := http.LockConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config)
+		configInfo, err := apiClient(localConfig).LockConfig(cliContext, localConfig.EnclaveProject.Value, config)
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
@@ -254,7 +253,7 @@ func unlockConfigs(cmd *cobra.Command, args []string) {
 	}
 
 	if yes || utils.ConfirmationPrompt(prompt, false) {
-		configInfo, err := http.UnlockConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config)
+		configInfo, err := apiClient(localConfig).UnlockConfig(cliContext, localConfig.EnclaveProject.Value, config)
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
@@ -276,7 +275,7 @@ func cloneConfigs(cmd *cobra.Command, args []string) {
 		config = args[0]
 	}
 
-	configInfo, err := http.CloneConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config)
+	configInfo, err := apiClient(localConfig).CloneConfig(cliContext, localConfig.EnclaveProject.Value, config)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
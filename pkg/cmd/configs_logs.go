@@ -16,8 +16,12 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
+	"time"
+
 	"github.com/DopplerHQ/cli/pkg/configuration"
 	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
@@ -44,21 +48,103 @@ var configsLogsRollbackCmd = &cobra.Command{
 	Run:   rollbackConfigsLogs,
 }
 
+// maxLogsPerPage the max number of logs to request per page
+const maxLogsPerPage = 100
+
 func configsLogs(cmd *cobra.Command, args []string) {
 	jsonFlag := utils.OutputJSON
 	localConfig := configuration.LocalConfig(cmd)
-	// number := utils.GetIntFlag(cmd, "number", 16)
+	number, page := logsPageFlags(cmd)
+	timeRange := logsTimeRangeFlags(cmd)
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
-	logs, err := http.GetConfigLogs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
-	if !err.IsNil() {
+	pageOptions, skip := logsPageOptions(number, page)
+	it := apiClient(localConfig).ConfigLogs(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, pageOptions)
+	var logs []models.ConfigLog
+	for len(logs) < number && it.Next() {
+		if skip > 0 {
+			skip--
+			continue
+		}
+		include, done := timeRange.filter(it.Log().CreatedAt)
+		if done {
+			break
+		}
+		if include {
+			logs = append(logs, it.Log())
+		}
+	}
+	if err := it.Err(); !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
 
 	printer.ConfigLogs(logs, len(logs), jsonFlag)
 }
 
+// logsPageFlags gets the max number of logs to display and the page to start at
+func logsPageFlags(cmd *cobra.Command) (int, int) {
+	number := utils.GetIntFlag(cmd, "number", 16)
+	if number < 1 {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("--number must be at least 1")))
+	}
+
+	page := utils.GetIntFlag(cmd, "page", 16)
+	if page < 1 {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("--page must be at least 1")))
+	}
+
+	return number, page
+}
+
+// logsPageOptions the page options to use when displaying up to number logs, where each page contains number logs,
+// and the number of fetched logs to skip. pages larger than the API's max are fetched in smaller pieces, starting at
+// the piece containing the page's first log.
+func logsPageOptions(number int, page int) (http.PageOptions, int) {
+	if number <= maxLogsPerPage {
+		return http.PageOptions{Page: page, PerPage: number}, 0
+	}
+
+	first := (page - 1) * number
+	return http.PageOptions{Page: first/maxLogsPerPage + 1, PerPage: maxLogsPerPage}, first % maxLogsPerPage
+}
+
+// logsTimeRange the time range of logs to display. a zero time is unbounded
+type logsTimeRange struct {
+	since time.Time
+	until time.Time
+}
+
+func logsTimeRangeFlags(cmd *cobra.Command) logsTimeRange {
+	timeRange := logsTimeRange{since: utils.GetTimeFlag(cmd, "since"), until: utils.GetTimeFlag(cmd, "until")}
+	if !timeRange.since.IsZero() && !timeRange.until.IsZero() && timeRange.until.Before(timeRange.since) {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("--until must be after --since")))
+	}
+	return timeRange
+}
+
+// filter whether to include a log created at the specified time, and whether all remaining logs are older than the range.
+// logs are returned by the API newest first
+func (r logsTimeRange) filter(createdAt string) (bool, bool) {
+	if r.since.IsZero() && r.until.IsZero() {
+		return true, false
+	}
+
+	created, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		utils.LogDebug("Unable to parse log creation time " + createdAt)
+		return true, false
+	}
+
+	if !r.since.IsZero() && created.Before(r.since) {
+		return false, true
+	}
+	if !r.until.IsZero() && created.After(r.until) {
+		return false, false
+	}
+	return true, false
+}
+
 func getConfigsLogs(cmd *cobra.Command, args []string) {
 	jsonFlag := utils.OutputJSON
 	localConfig := configuration.LocalConfig(cmd)
@@ -71,7 +157,7 @@ func getConfigsLogs(cmd *cobra.Command, args []string) {
 	}
 	utils.RequireValue("log", log)
 
-	configLog, err := http.GetConfigLog(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, log)
+	configLog, err := apiClient(localConfig).GetConfigLog(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, log)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -91,7 +177,7 @@ func rollbackConfigsLogs(cmd *cobra.Command, args []string) {
 	}
 	utils.RequireValue("log", log)
 
-	configLog, err := http.RollbackConfigLog(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, log)
+	configLog, err := apiClient(localConfig).RollbackConfigLog(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, log)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -104,8 +190,10 @@ func rollbackConfigsLogs(cmd *cobra.Command, args []string) {
 func init() {
 	configsLogsCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	configsLogsCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
-	// TODO: hide this flag until the api supports it
-	// configsLogsCmd.Flags().IntP("number", "n", 5, "max number of logs to display")
+	configsLogsCmd.Flags().IntP("number", "n", 20, "max number of logs to display")
+	configsLogsCmd.Flags().Int("page", 1, "the page of logs to start at, where each page contains --number logs")
+	configsLogsCmd.Flags().String("since", "", "only display logs created after this time (e.g. 2020-01-02, 2020-01-02T15:04:05Z, or 24h)")
+	configsLogsCmd.Flags().String("until", "", "only display logs created before this time (e.g. 2020-01-02, 2020-01-02T15:04:05Z, or 24h)")
 	configsCmd.AddCommand(configsLogsCmd)
 
 	configsLogsGetCmd.Flags().String("log", "", "audit log id")
@@ -0,0 +1,99 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestLogsPageOptions(t *testing.T) {
+	tests := []struct {
+		number  int
+		page    int
+		apiPage int
+		perPage int
+		skip    int
+	}{
+		{20, 1, 1, 20, 0},
+		{20, 3, 3, 20, 0},
+		{100, 2, 2, 100, 0},
+		{150, 1, 1, 100, 0},
+		{150, 2, 2, 100, 50},
+		{150, 3, 4, 100, 0},
+		{250, 2, 3, 100, 50},
+	}
+
+	for _, test := range tests {
+		options, skip := logsPageOptions(test.number, test.page)
+		if options.Page != test.apiPage || options.PerPage != test.perPage || skip != test.skip {
+			t.Errorf("logsPageOptions(%d, %d) = page %d, per page %d, skip %d; expected page %d, per page %d, skip %d",
+				test.number, test.page, options.Page, options.PerPage, skip, test.apiPage, test.perPage, test.skip)
+		}
+	}
+}
+
+// logsServer serves total logs, paginated using the page and per_page query params
+func logsServer(total int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if page < 1 || perPage < 1 || perPage > maxLogsPerPage {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		logs := []map[string]interface{}{}
+		for i := (page - 1) * perPage; i < page*perPage && i < total; i++ {
+			logs = append(logs, map[string]interface{}{"id": fmt.Sprintf("log-%d", i)})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"logs": logs, "page": page})
+	}))
+}
+
+// pages larger than the API's max page size start after the previous pages' logs
+func TestLogsLargePages(t *testing.T) {
+	server := logsServer(500)
+	defer server.Close()
+
+	home, _ := testHome(t)
+	defer os.RemoveAll(home)
+
+	for _, command := range [][]string{{"configs", "logs", "--project", "proj", "--config", "dev"}, {"activity"}} {
+		cmd := helperCommand(home, append(command, "--api-host", server.URL, "--token", "dp.st.test", "--no-check-version",
+			"--number", "150", "--page", "2", "--json")...)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%s failed: %v %s", command[0], err, output)
+		}
+
+		var logs []struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(output, &logs); err != nil {
+			t.Fatalf("%s: %s %s", command[0], err, output)
+		}
+		if len(logs) != 150 || logs[0].ID != "log-150" || logs[149].ID != "log-299" {
+			t.Errorf("%s: expected logs 150 to 299, got %d logs", command[0], len(logs))
+		}
+	}
+}
@@ -17,9 +17,13 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"time"
 
 	"github.com/DopplerHQ/cli/pkg/configuration"
 	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
@@ -46,6 +50,19 @@ var configsTokensCreateCmd = &cobra.Command{
 	Run:   createConfigsTokens,
 }
 
+var configsTokensRotateCmd = &cobra.Command{
+	Use:   "rotate [slug]",
+	Short: "Replace a config's service token",
+	Long: `Replace a config's service token
+
+This will create a new token with the same name and access, print it, and then
+revoke the old token once the grace period has elapsed. The grace period gives
+you time to deploy the new token before the old one stops working. If the old
+token isn't revoked (e.g. the CLI is interrupted), the command to revoke it is printed.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  rotateConfigsTokens,
+}
+
 var configsTokensRevokeCmd = &cobra.Command{
 	Use:     "revoke [slug]",
 	Aliases: []string{"delete"},
@@ -60,7 +77,7 @@ func configsTokens(cmd *cobra.Command, args []string) {
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
-	tokens, err := http.GetConfigServiceTokens(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
+	tokens, err := apiClient(localConfig).ConfigServiceTokens(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, http.PageOptions{}).All()
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -80,7 +97,7 @@ func getConfigsTokens(cmd *cobra.Command, args []string) {
 	}
 	utils.RequireValue("slug", slug)
 
-	tokens, err := http.GetConfigServiceTokens(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
+	tokens, err := apiClient(localConfig).ConfigServiceTokens(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, http.PageOptions{}).All()
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -92,7 +109,7 @@ func getConfigsTokens(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	utils.HandleError(errors.New("invalid service token slug"), err.Message)
+	utils.HandleError(utils.NewError(utils.ErrorKindNotFound, errors.New("invalid service token slug")), fmt.Sprintf("Service token %s not found", slug))
 }
 
 func createConfigsTokens(cmd *cobra.Command, args []string) {
@@ -109,7 +126,15 @@ func createConfigsTokens(cmd *cobra.Command, args []string) {
 	}
 	utils.RequireValue("name", name)
 
-	configToken, err := http.CreateConfigServiceToken(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, name)
+	access := cmd.Flag("access").Value.String()
+	validateServiceTokenAccess(access)
+
+	var expireAt time.Time
+	if expireIn := utils.GetDurationFlag(cmd, "expire-in"); expireIn != 0 {
+		expireAt = serviceTokenExpiration(expireIn)
+	}
+
+	configToken, err := apiClient(localConfig).CreateConfigServiceToken(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, name, expireAt, access)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -117,8 +142,11 @@ func createConfigsTokens(cmd *cobra.Command, args []string) {
 	printer.ConfigServiceToken(configToken, jsonFlag, plain, copy)
 }
 
-func revokeConfigsTokens(cmd *cobra.Command, args []string) {
+func rotateConfigsTokens(cmd *cobra.Command, args []string) {
 	jsonFlag := utils.OutputJSON
+	plain := utils.GetBoolFlag(cmd, "plain")
+	copy := utils.GetBoolFlag(cmd, "copy")
+	gracePeriod := utils.GetDurationFlag(cmd, "grace-period")
 	localConfig := configuration.LocalConfig(cmd)
 
 	utils.RequireValue("token", localConfig.Token.Value)
@@ -129,13 +157,110 @@ func revokeConfigsTokens(cmd *cobra.Command, args []string) {
 	}
 	utils.RequireValue("slug", slug)
 
-	err := http.DeleteConfigServiceToken(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, slug)
+	if gracePeriod < 0 {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("--grace-period must not be negative")))
+	}
+
+	client := apiClient(localConfig)
+	tokens, err := client.ConfigServiceTokens(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, http.PageOptions{}).All()
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
 
+	var oldToken *models.ConfigServiceToken
+	for i := range tokens {
+		if tokens[i].Slug == slug {
+			oldToken = &tokens[i]
+			break
+		}
+	}
+	if oldToken == nil {
+		utils.HandleError(utils.NewError(utils.ErrorKindNotFound, errors.New("invalid service token slug")), fmt.Sprintf("Service token %s not found", slug))
+	}
+
+	// the replacement keeps the old token's access and lifetime unless overridden
+	access := oldToken.Access
+	if access == "" {
+		access = models.ServiceTokenAccessRead
+	}
+	access = utils.GetFlagIfChanged(cmd, "access", access)
+	validateServiceTokenAccess(access)
+
+	expireIn := utils.GetDurationFlagIfChanged(cmd, "expire-in", models.ServiceTokenLifetime(*oldToken))
+	var expireAt time.Time
+	if expireIn != 0 {
+		expireAt = serviceTokenExpiration(expireIn)
+	}
+
+	newToken, err := client.CreateConfigServiceToken(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, oldToken.Name, expireAt, access)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+
+	printer.ConfigServiceToken(newToken, jsonFlag, plain, copy)
+
+	// the old token stays valid if it isn't revoked (e.g. the CLI is killed during the grace period), so tell the user how to revoke it
+	revokeCommand := revokeConfigsTokenCommand(localConfig, slug)
+
+	// don't mix status messages into output that's meant to be parsed
+	quiet := plain || jsonFlag
+	if gracePeriod > 0 {
+		notice := fmt.Sprintf("Revoking service token %s in %s. If the CLI exits before then, revoke it via '%s'", slug, gracePeriod, revokeCommand)
+		if quiet {
+			fmt.Fprintln(os.Stderr, notice)
+		} else {
+			utils.Log(notice)
+		}
+
+		select {
+		case <-cliContext.Done():
+			utils.HandleError(cliContext.Err(), fmt.Sprintf("Rotation canceled; service token %s was not revoked. Revoke it via '%s'", slug, revokeCommand))
+		case <-time.After(gracePeriod):
+		}
+	}
+
+	err = client.DeleteConfigServiceToken(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, slug)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), fmt.Sprintf("%s; service token %s was not revoked. Revoke it via '%s'", err.Message, slug, revokeCommand))
+	}
+
+	if !quiet {
+		utils.Log(fmt.Sprintf("Revoked service token %s", slug))
+	}
+}
+
+// revokeConfigsTokenCommand the command that revokes the config's service token
+func revokeConfigsTokenCommand(localConfig models.ScopedOptions, slug string) string {
+	return fmt.Sprintf("doppler configs tokens revoke %s --project %s --config %s", slug, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
+}
+
+func revokeConfigsTokens(cmd *cobra.Command, args []string) {
+	jsonFlag := utils.OutputJSON
+	localConfig := configuration.LocalConfig(cmd)
+
+	utils.RequireValue("token", localConfig.Token.Value)
+
+	slug := cmd.Flag("slug").Value.String()
+	if len(args) > 0 {
+		slug = args[0]
+	}
+
+	if utils.GetBoolFlag(cmd, "all-expired") {
+		if slug != "" {
+			utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("--all-expired cannot be used with a slug")))
+		}
+		revokeExpiredConfigsTokens(localConfig)
+	} else {
+		utils.RequireValue("slug", slug)
+
+		err := apiClient(localConfig).DeleteConfigServiceToken(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, slug)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+	}
+
 	if !utils.Silent {
-		tokens, err := http.GetConfigServiceTokens(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
+		tokens, err := apiClient(localConfig).ConfigServiceTokens(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, http.PageOptions{}).All()
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
@@ -144,6 +269,46 @@ func revokeConfigsTokens(cmd *cobra.Command, args []string) {
 	}
 }
 
+func revokeExpiredConfigsTokens(localConfig models.ScopedOptions) {
+	client := apiClient(localConfig)
+	tokens, err := client.ConfigServiceTokens(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, http.PageOptions{}).All()
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+
+	now := time.Now()
+	revoked := 0
+	for _, token := range tokens {
+		if !models.IsServiceTokenExpired(token, now) {
+			continue
+		}
+
+		err := client.DeleteConfigServiceToken(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, token.Slug)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+		utils.LogDebug(fmt.Sprintf("Revoked expired service token %s", token.Slug))
+		revoked++
+	}
+
+	if !utils.OutputJSON {
+		utils.Log(fmt.Sprintf("Revoked %d expired service token(s)", revoked))
+	}
+}
+
+func validateServiceTokenAccess(access string) {
+	if access != models.ServiceTokenAccessRead && access != models.ServiceTokenAccessReadWrite {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, fmt.Errorf("invalid access %q", access)), fmt.Sprintf("Valid access levels are %s and %s", models.ServiceTokenAccessRead, models.ServiceTokenAccessReadWrite))
+	}
+}
+
+func serviceTokenExpiration(expireIn time.Duration) time.Time {
+	if expireIn < 0 {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("--expire-in must not be negative")))
+	}
+	return time.Now().Add(expireIn)
+}
+
 func init() {
 	configsTokensCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	configsTokensCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
@@ -159,10 +324,23 @@ func init() {
 	configsTokensCreateCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
 	configsTokensCreateCmd.Flags().Bool("plain", false, "print only the token, without formatting")
 	configsTokensCreateCmd.Flags().Bool("copy", false, "copy the token to your clipboard")
+	configsTokensCreateCmd.Flags().Duration("expire-in", 0, "how long until the token expires (e.g. 24h). the token never expires when unset.")
+	configsTokensCreateCmd.Flags().String("access", models.ServiceTokenAccessRead, "the token's access level (read, read/write)")
 	configsTokensCmd.AddCommand(configsTokensCreateCmd)
 
+	configsTokensRotateCmd.Flags().String("slug", "", "service token slug")
+	configsTokensRotateCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
+	configsTokensRotateCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
+	configsTokensRotateCmd.Flags().Bool("plain", false, "print only the new token, without formatting")
+	configsTokensRotateCmd.Flags().Bool("copy", false, "copy the new token to your clipboard")
+	configsTokensRotateCmd.Flags().Duration("expire-in", 0, "how long until the new token expires (e.g. 24h). defaults to the old token's lifetime.")
+	configsTokensRotateCmd.Flags().String("access", "", "the new token's access level (read, read/write). defaults to the old token's access.")
+	configsTokensRotateCmd.Flags().Duration("grace-period", time.Minute, "how long to wait before revoking the old token")
+	configsTokensCmd.AddCommand(configsTokensRotateCmd)
+
 	configsTokensRevokeCmd.Flags().String("slug", "", "service token slug")
 	configsTokensRevokeCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	configsTokensRevokeCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
+	configsTokensRevokeCmd.Flags().Bool("all-expired", false, "revoke all expired service tokens")
 	configsTokensCmd.AddCommand(configsTokensRevokeCmd)
 }
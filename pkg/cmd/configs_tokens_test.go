@@ -0,0 +1,130 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRotateConfigsTokensInterrupted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupting a process isn't supported on Windows")
+	}
+
+	var deletes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`{"tokens": [{"name": "ci", "slug": "old_slug", "access": "read", "created_at": "2020-01-01T00:00:00.000Z"}]}`)) // #nosec G104
+		case http.MethodPost:
+			w.Write([]byte(`{"token": {"name": "ci", "slug": "new_slug", "key": "dp.st.dev.new", "access": "read"}}`)) // #nosec G104
+		case http.MethodDelete:
+			atomic.AddInt32(&deletes, 1)
+			w.Write([]byte(`{"success": true}`)) // #nosec G104
+		}
+	}))
+	defer server.Close()
+
+	home, _ := testHome(t)
+	defer os.RemoveAll(home)
+
+	cmd := helperCommand(home, "configs", "tokens", "rotate", "old_slug", "--api-host", server.URL, "--token", "dp.ct.test",
+		"--project", "proj", "--config", "dev", "--grace-period", "1m", "--plain")
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// interrupt the rotation during the grace period
+	var stderr bytes.Buffer
+	scanner := bufio.NewScanner(stderrPipe)
+	for scanner.Scan() {
+		stderr.WriteString(scanner.Text() + "\n")
+		if strings.HasPrefix(scanner.Text(), "Revoking service token old_slug") {
+			break
+		}
+	}
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		for scanner.Scan() {
+			stderr.WriteString(scanner.Text() + "\n")
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill() // #nosec G104
+		t.Fatal("Expected the rotation to stop when interrupted")
+	}
+	err = cmd.Wait()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 130 {
+		t.Errorf("Expected exit code 130, got %v", err)
+	}
+
+	if strings.TrimSpace(stdout.String()) != "dp.st.dev.new" {
+		t.Errorf("Expected only the new token on stdout, got %q", stdout.String())
+	}
+	revokeCommand := "doppler configs tokens revoke old_slug --project proj --config dev"
+	if strings.Count(stderr.String(), revokeCommand) != 2 {
+		t.Errorf("Expected the revoke command before and after the interruption, got:\n%s", stderr.String())
+	}
+	if atomic.LoadInt32(&deletes) != 0 {
+		t.Error("Expected the old token not to be revoked")
+	}
+}
+
+func TestGetConfigsTokensNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"tokens": []}`)) // #nosec G104
+	}))
+	defer server.Close()
+
+	home, _ := testHome(t)
+	defer os.RemoveAll(home)
+
+	for _, command := range []string{"get", "rotate"} {
+		out, err := helperCommand(home, "configs", "tokens", command, "missing", "--api-host", server.URL, "--token", "dp.ct.test",
+			"--project", "proj", "--config", "dev", "--json").CombinedOutput()
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 7 {
+			t.Errorf("Expected %s to exit with code 7, got %v", command, err)
+		}
+		if !strings.Contains(string(out), `"code":"not_found"`) {
+			t.Errorf("Expected a not_found error from %s, got:\n%s", command, out)
+		}
+	}
+}
@@ -207,6 +207,23 @@ doppler configure unset key otherkey`,
 	},
 }
 
+var configureMigrateTokensCmd = &cobra.Command{
+	Use:   "migrate-tokens",
+	Short: "Move plaintext tokens from the config file to the system keyring",
+	Long: `Move plaintext tokens from the config file to the system keyring.
+
+Tokens are stored in an encrypted file in the config directory when the system keyring is unavailable (e.g. on headless linux).`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		migrated, remaining := configuration.MigrateTokens()
+		if remaining > 0 {
+			utils.HandleError(fmt.Errorf("Unable to migrate %d token(s) to the system keyring", remaining), "Run with --debug for more information")
+		}
+
+		utils.Log(fmt.Sprintf("Migrated %d token(s) to the system keyring", migrated))
+	},
+}
+
 func init() {
 	configureCmd.AddCommand(configureDebugCmd)
 
@@ -220,6 +237,8 @@ func init() {
 
 	configureCmd.AddCommand(configureUnsetCmd)
 
+	configureCmd.AddCommand(configureMigrateTokensCmd)
+
 	configureCmd.Flags().Bool("all", false, "print all saved options")
 	rootCmd.AddCommand(configureCmd)
 }
@@ -0,0 +1,56 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigureSetAbsolutePaths(t *testing.T) {
+	home, _ := testHome(t)
+	defer os.RemoveAll(home)
+
+	dir, err := ioutil.TempDir("", "doppler-cli-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// the temp directory may be a symlink (e.g. on macOS), while the working directory is resolved
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := helperCommand(home, "configure", "set", "ca-cert=certs/ca.pem", "client-cert=./client.pem", "client-key=../client.key", "--no-check-version")
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("configure set failed: %v %s", err, output)
+	}
+
+	// the saved paths don't depend on the directory the CLI is run from
+	cmd = helperCommand(home, "configure", "get", "ca-cert", "client-cert", "client-key", "--plain", "--scope", dir, "--no-check-version")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("configure get failed: %v %s", err, output)
+	}
+	expected := []string{filepath.Join(dir, "certs", "ca.pem"), filepath.Join(dir, "client.pem"), filepath.Join(filepath.Dir(dir), "client.key")}
+	if paths := strings.Split(strings.TrimSpace(string(output)), "\n"); strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected absolute paths %v, got %v", expected, paths)
+	}
+}
@@ -0,0 +1,119 @@
+//go:build dev
+// +build dev
+
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"os"
+	"sync"
+
+	"github.com/DopplerHQ/cli/pkg/fakeapi"
+	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// The dev command and HTTP recording are only included in development builds (e.g. 'make build'),
+// so release binaries can't be made to write responses, which contain secrets and tokens, to disk.
+
+var devCmd = &cobra.Command{
+	Use:    "dev",
+	Short:  "Tools for developing the Doppler CLI",
+	Args:   cobra.NoArgs,
+	Hidden: true,
+}
+
+var devFakeAPICmd = &cobra.Command{
+	Use:   "fake-api",
+	Short: "Run a local fake of the Doppler API",
+	Long: `Run a local fake of the Doppler API with in-memory state.
+
+Point the CLI at the fake API via --api-host (e.g. --api-host=http://127.0.0.1:8080).
+Requests recorded via the DOPPLER_HTTP_RECORD environment variable can be replayed via --replay.`,
+	Args: cobra.NoArgs,
+	Run:  fakeAPI,
+}
+
+func fakeAPI(cmd *cobra.Command, args []string) {
+	address := cmd.Flag("address").Value.String()
+	statePath := cmd.Flag("state").Value.String()
+	replayPath := cmd.Flag("replay").Value.String()
+	autoApprove := !utils.GetBoolFlag(cmd, "no-auto-approve")
+	latency := utils.GetDurationFlag(cmd, "latency")
+
+	options := []fakeapi.Option{fakeapi.WithAutoApprove(autoApprove), fakeapi.WithLatency(latency)}
+	if statePath != "" {
+		state, err := fakeapi.LoadState(statePath)
+		if err != nil {
+			utils.HandleError(err, "Unable to load state file")
+		}
+		options = append(options, fakeapi.WithState(state))
+	}
+	if replayPath != "" {
+		interactions, err := fakeapi.LoadInteractions(replayPath)
+		if err != nil {
+			utils.HandleError(err, "Unable to load replay file")
+		}
+		options = append(options, fakeapi.WithReplay(interactions))
+	}
+
+	server := &nethttp.Server{Addr: address, Handler: fakeapi.New(options...)}
+	go func() {
+		<-cliContext.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	utils.Log(fmt.Sprintf("Fake API listening on http://%s", address))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
+		utils.HandleError(err, "Unable to run fake API")
+	}
+}
+
+var warnRecording sync.Once
+
+// recordClientOptions records each request and response to the file at DOPPLER_HTTP_RECORD, for replaying via 'doppler dev fake-api'
+func recordClientOptions() []http.Option {
+	recordPath := os.Getenv("DOPPLER_HTTP_RECORD")
+	if recordPath == "" {
+		return nil
+	}
+
+	warnRecording.Do(func() {
+		fmt.Fprintf(os.Stderr, "Warning: recording HTTP responses, including secrets and tokens, to %s\n", recordPath)
+	})
+	return []http.Option{http.WithTransportMiddleware(func(next nethttp.RoundTripper) nethttp.RoundTripper {
+		return fakeapi.NewRecorder(next, recordPath)
+	})}
+}
+
+func init() {
+	devClientOptions = recordClientOptions
+
+	devFakeAPICmd.Flags().String("address", "127.0.0.1:8080", "address to listen on")
+	devFakeAPICmd.Flags().String("state", "", "path to a JSON file containing the initial state")
+	devFakeAPICmd.Flags().String("replay", "", "path to a file of recorded requests to replay")
+	devFakeAPICmd.Flags().Bool("no-auto-approve", false, "require visiting the auth URL to approve CLI logins")
+	devFakeAPICmd.Flags().Duration("latency", 0, "delay each response by the specified duration")
+	devCmd.AddCommand(devFakeAPICmd)
+
+	rootCmd.AddCommand(devCmd)
+}
@@ -16,6 +16,7 @@ limitations under the License.
 package cmd
 
 import (
+	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/spf13/cobra"
 )
 
@@ -75,10 +76,13 @@ func init() {
 	enclaveConfigsTokensCreateCmd.Flags().StringP("config", "c", "", "enclave config (e.g. dev)")
 	enclaveConfigsTokensCreateCmd.Flags().Bool("plain", false, "print only the token, without formatting")
 	enclaveConfigsTokensCreateCmd.Flags().Bool("copy", false, "copy the token to your clipboard")
+	enclaveConfigsTokensCreateCmd.Flags().Duration("expire-in", 0, "how long until the token expires (e.g. 24h). the token never expires when unset.")
+	enclaveConfigsTokensCreateCmd.Flags().String("access", models.ServiceTokenAccessRead, "the token's access level (read, read/write)")
 	enclaveConfigsTokensCmd.AddCommand(enclaveConfigsTokensCreateCmd)
 
 	enclaveConfigsTokensRevokeCmd.Flags().String("slug", "", "service token slug")
 	enclaveConfigsTokensRevokeCmd.Flags().StringP("project", "p", "", "enclave project (e.g. backend)")
 	enclaveConfigsTokensRevokeCmd.Flags().StringP("config", "c", "", "enclave config (e.g. dev)")
+	enclaveConfigsTokensRevokeCmd.Flags().Bool("all-expired", false, "revoke all expired service tokens")
 	enclaveConfigsTokensCmd.AddCommand(enclaveConfigsTokensRevokeCmd)
 }
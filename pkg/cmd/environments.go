@@ -17,7 +17,6 @@ package cmd
 
 import (
 	"github.com/DopplerHQ/cli/pkg/configuration"
-	"github.com/DopplerHQ/cli/pkg/http"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
@@ -48,7 +47,7 @@ func environments(cmd *cobra.Command, args []string) {
 		project = args[0]
 	}
 
-	info, err := http.GetEnvironments(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, project)
+	info, err := apiClient(localConfig).GetEnvironments(cliContext, project)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -64,7 +63,7 @@ func getEnvironments(cmd *cobra.Command, args []string) {
 	utils.RequireValue("token", localConfig.Token.Value)
 	utils.RequireValue("environment", environment)
 
-	info, err := http.GetEnvironment(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, environment)
+	info, err := apiClient(localConfig).GetEnvironment(cliContext, localConfig.EnclaveProject.Value, environment)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -25,12 +25,21 @@ import (
 	"github.com/DopplerHQ/cli/pkg/configuration"
 	"github.com/DopplerHQ/cli/pkg/http"
 	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/skratchdot/open-golang/open"
 	"github.com/spf13/cobra"
 	"gopkg.in/gookit/color.v1"
 )
 
+const defaultAuthTimeout = 5 * time.Minute
+
+var defaultAuthPollingInterval = 2 * time.Second
+var maxAuthPollingInterval = 30 * time.Second
+
+// authPollingIntervalUnit the unit of the polling interval provided by the API
+var authPollingIntervalUnit = time.Second
+
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Authenticate to Doppler",
@@ -41,6 +50,12 @@ var loginCmd = &cobra.Command{
 		yes := utils.GetBoolFlag(cmd, "yes")
 		overwrite := utils.GetBoolFlag(cmd, "overwrite")
 		copyAuthCode := !utils.GetBoolFlag(cmd, "no-copy")
+		// json output can't be combined with prompts
+		headless := utils.GetBoolFlag(cmd, "headless") || utils.OutputJSON
+		showQR := utils.GetBoolFlag(cmd, "qr")
+		if showQR && !headless {
+			utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("--qr can only be used with --headless")))
+		}
 		hostname, _ := os.Hostname()
 
 		// Disallow overwriting a token with the same scope (by default)
@@ -48,6 +63,10 @@ var loginCmd = &cobra.Command{
 			prevScope, err1 := filepath.Abs(prevConfig.Token.Scope)
 			newScope, err2 := filepath.Abs(configuration.Scope)
 			if err1 == nil && err2 == nil && prevScope == newScope {
+				if headless {
+					utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("This scope is already authorized from a previous login. Use --overwrite to replace it, or --scope to log in to a different directory")))
+				}
+
 				if cmd.Flags().Changed("scope") {
 					// user specified scope flag, show yes/no override prompt
 					utils.LogWarning("This scope is already authorized from a previous login.")
@@ -72,78 +91,73 @@ var loginCmd = &cobra.Command{
 			}
 		}
 
-		response, err := http.GenerateAuthCode(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), hostname, utils.HostOS(), utils.HostArch())
+		client := apiClient(localConfig)
+		authCode, err := client.GenerateAuthCode(cliContext, hostname, utils.HostOS(), utils.HostArch())
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
-		code := response["code"].(string)
-		authURL := response["auth_url"].(string)
+		code := authCode.Code
+		authURL := authCode.AuthURL
 
-		if copyAuthCode {
-			if err := utils.CopyToClipboard(code); err != nil {
-				utils.LogWarning("Unable to copy to clipboard")
-			}
+		// auth flow must complete before the code expires, or within 5 minutes if the API doesn't say
+		timeout := defaultAuthTimeout
+		if authCode.ExpiresIn > 0 {
+			timeout = time.Duration(authCode.ExpiresIn) * time.Second
 		}
-
-		openBrowser := yes || utils.Silent || utils.ConfirmationPrompt("Open the authorization page in your browser?", true)
-		printURL := !openBrowser
-		if openBrowser {
-			if err := open.Run(authURL); err != nil {
-				if utils.Silent {
-					utils.HandleError(err, "Unable to launch a browser")
-				}
-
-				printURL = true
-				utils.Log("Unable to launch a browser")
-				utils.LogDebugError(err)
-			}
-		}
-
-		if printURL {
-			utils.Log(fmt.Sprintf("Complete authorization at %s", authURL))
-		}
-		utils.Log(fmt.Sprintf("Your auth code is:\n%s\n", color.Green.Render(code)))
-		utils.Log("Waiting...")
-
-		// auth flow must complete within 5 minutes
-		timeout := 5 * time.Minute
 		completeBy := time.Now().Add(timeout)
 		verifyTLS := utils.GetBool(localConfig.VerifyTLS.Value, true)
 
-		response = nil
-		for {
-			// we do not respect --no-timeout here
-			if time.Now().After(completeBy) {
-				utils.HandleError(fmt.Errorf("login timed out after %d minutes", int(timeout.Minutes())))
+		if headless {
+			printHeadlessAuthCode(authCode, completeBy, showQR)
+		} else {
+			if copyAuthCode {
+				if err := utils.CopyToClipboard(code); err != nil {
+					utils.LogWarning("Unable to copy to clipboard")
+				}
 			}
 
-			resp, err := http.GetAuthToken(localConfig.APIHost.Value, verifyTLS, code)
-			if !err.IsNil() {
-				if err.Code == 409 {
-					time.Sleep(2 * time.Second)
-					continue
+			openBrowser := yes || utils.Silent || utils.ConfirmationPrompt("Open the authorization page in your browser?", true)
+			printURL := !openBrowser
+			if openBrowser {
+				if err := open.Run(authURL); err != nil {
+					if utils.Silent {
+						utils.HandleError(err, "Unable to launch a browser")
+					}
+
+					printURL = true
+					utils.Log("Unable to launch a browser")
+					utils.LogDebugError(err)
 				}
-				utils.HandleError(err.Unwrap(), err.Message)
 			}
 
-			response = resp
-			break
+			if printURL {
+				utils.Log(fmt.Sprintf("Complete authorization at %s", authURL))
+			}
+			utils.Log(fmt.Sprintf("Your auth code is:\n%s\n", color.Green.Render(code)))
+			utils.Log("Waiting...")
 		}
 
-		if response == nil {
-			utils.HandleError(errors.New("unexpected API response"))
-		}
+		response := pollAuthToken(client, authCode, completeBy, timeout)
+
+		if response.Error != "" {
+			if utils.OutputJSON {
+				utils.HandleError(errors.New(response.Error), "Authorization was denied")
+			}
 
-		if err, ok := response["error"]; ok {
 			utils.Log("")
-			utils.Log(fmt.Sprint(err))
+			utils.Log(response.Error)
 
 			os.Exit(1)
 		}
 
-		token := response["token"].(string)
-		name := response["name"].(string)
-		dashboard := response["dashboard_url"].(string)
+		if response.Token == "" {
+			utils.HandleError(errors.New("unexpected API response"))
+		}
+
+		token := response.Token
+		utils.RedactFromLogs(token)
+		name := response.Name
+		dashboard := response.DashboardURL
 
 		options := map[string]string{
 			models.ConfigToken.String():         token,
@@ -158,6 +172,9 @@ var loginCmd = &cobra.Command{
 
 		configuration.Set(configuration.Scope, options)
 
+		if utils.OutputJSON {
+			printer.JSON(map[string]interface{}{"status": "authorized", "name": name, "dashboard_url": dashboard, "scope": configuration.Scope})
+		}
 		utils.Log("")
 		utils.Log(fmt.Sprintf("Welcome, %s", name))
 
@@ -166,7 +183,9 @@ var loginCmd = &cobra.Command{
 			newScope, err2 := filepath.Abs(configuration.Scope)
 			if err1 == nil && err2 == nil && prevScope == newScope {
 				utils.LogDebug("Revoking previous token")
-				_, err := http.RevokeAuthToken(prevConfig.APIHost.Value, utils.GetBool(prevConfig.VerifyTLS.Value, verifyTLS), prevConfig.Token.Value)
+				prevOptions := append(transportOptions(prevConfig), clientOptions()...)
+				prevClient := http.NewClient(append(prevOptions, http.WithHost(prevConfig.APIHost.Value), http.WithVerifyTLS(utils.GetBool(prevConfig.VerifyTLS.Value, verifyTLS)))...)
+				err := prevClient.RevokeAuthToken(cliContext, prevConfig.Token.Value)
 				if !err.IsNil() {
 					utils.LogDebug("Failed to revoke token")
 				} else {
@@ -177,6 +196,77 @@ var loginCmd = &cobra.Command{
 	},
 }
 
+// printHeadlessAuthCode prints the URL and code needed to authorize the CLI from another device
+func printHeadlessAuthCode(authCode models.AuthCode, expiresAt time.Time, showQR bool) {
+	verificationURL := authCode.VerificationURL
+	if verificationURL == "" {
+		verificationURL = authCode.AuthURL
+	}
+
+	if utils.OutputJSON {
+		printer.JSON(map[string]interface{}{
+			"status":           "pending",
+			"code":             authCode.Code,
+			"auth_url":         authCode.AuthURL,
+			"verification_url": verificationURL,
+			"expires_at":       expiresAt.UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	utils.Log(fmt.Sprintf("Visit %s on any device and enter the code:\n%s\n", verificationURL, color.Green.Render(authCode.Code)))
+	if showQR {
+		qrCode, err := utils.QRCode(authCode.AuthURL)
+		if err != nil {
+			utils.LogDebugError(err)
+		} else {
+			utils.Log("Or scan this QR code:")
+			utils.Log(qrCode)
+		}
+	}
+	utils.Log("Waiting...")
+}
+
+// pollAuthToken polls until the auth code is approved, waiting the interval provided by the API between attempts.
+// the interval doubles each time the API indicates that the CLI is polling too quickly.
+func pollAuthToken(client *http.Client, authCode models.AuthCode, completeBy time.Time, timeout time.Duration) models.AuthToken {
+	interval := defaultAuthPollingInterval
+	if authCode.PollingInterval > 0 {
+		interval = time.Duration(authCode.PollingInterval) * authPollingIntervalUnit
+	}
+
+	for {
+		// we do not respect --no-timeout here
+		if time.Now().After(completeBy) {
+			utils.HandleError(utils.NewError(utils.ErrorKindTimeout, fmt.Errorf("login timed out after %s", timeout)))
+		}
+
+		response, err := client.GetAuthToken(cliContext, authCode.Code)
+		if err.IsNil() {
+			return response
+		}
+
+		switch err.Code {
+		case 409:
+			// the code hasn't been approved yet
+		case 429:
+			interval *= 2
+			if interval > maxAuthPollingInterval {
+				interval = maxAuthPollingInterval
+			}
+			utils.LogDebug(fmt.Sprintf("Polling too quickly, waiting %s between attempts", interval))
+		default:
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+
+		select {
+		case <-cliContext.Done():
+			utils.HandleError(cliContext.Err(), "Login canceled")
+		case <-time.After(interval):
+		}
+	}
+}
+
 var loginRollCmd = &cobra.Command{
 	Use:   "roll",
 	Short: "Roll your auth token",
@@ -193,12 +283,13 @@ Your saved configuration will be updated.`,
 
 		oldToken := localConfig.Token.Value
 
-		response, err := http.RollAuthToken(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), oldToken)
+		response, err := apiClient(localConfig).RollAuthToken(cliContext, oldToken)
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
 
-		newToken := response["token"].(string)
+		newToken := response.Token
+		utils.RedactFromLogs(newToken)
 
 		if updateConfig {
 			// update token in config
@@ -229,6 +320,8 @@ func init() {
 	loginCmd.Flags().String("scope", "/", "the directory to scope your token to")
 	loginCmd.Flags().Bool("overwrite", false, "overwrite existing token if one exists")
 	loginCmd.Flags().BoolP("yes", "y", false, "open browser without confirmation")
+	loginCmd.Flags().Bool("headless", false, "do not open a browser or prompt. print a URL and code to authorize the CLI from another device. implied by --json, which prints the URL and code as json.")
+	loginCmd.Flags().Bool("qr", false, "also print a QR code of the authorization URL (requires --headless)")
 
 	loginRollCmd.Flags().String("scope", "/", "the directory to scope your token to")
 	loginRollCmd.Flags().Bool("no-update-config", false, "do not update the rolled token in the config file")
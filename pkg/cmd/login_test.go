@@ -0,0 +1,181 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
+)
+
+// authServer responds to authorization attempts with the statuses, in order, then with the token
+type authServer struct {
+	mutex    sync.Mutex
+	statuses []int
+	attempts []time.Time
+}
+
+func (s *authServer) ServeHTTP(w nethttp.ResponseWriter, r *nethttp.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	s.attempts = append(s.attempts, time.Now())
+	if len(s.attempts) <= len(s.statuses) {
+		w.WriteHeader(s.statuses[len(s.attempts)-1])
+		w.Write([]byte(`{"messages": ["Not yet"]}`)) // #nosec G104
+		return
+	}
+	w.Write([]byte(`{"token": "dp.ct.test", "name": "Workplace"}`)) // #nosec G104
+}
+
+// gaps the time between consecutive authorization attempts
+func (s *authServer) gaps() []time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var gaps []time.Duration
+	for i := 1; i < len(s.attempts); i++ {
+		gaps = append(gaps, s.attempts[i].Sub(s.attempts[i-1]))
+	}
+	return gaps
+}
+
+// useAuthPollingIntervals shortens the polling intervals so the test doesn't wait for seconds
+func useAuthPollingIntervals(t *testing.T, defaultInterval time.Duration, maxInterval time.Duration) {
+	previousDefault, previousMax, previousUnit := defaultAuthPollingInterval, maxAuthPollingInterval, authPollingIntervalUnit
+	defaultAuthPollingInterval, maxAuthPollingInterval, authPollingIntervalUnit = defaultInterval, maxInterval, time.Millisecond
+	t.Cleanup(func() {
+		defaultAuthPollingInterval, maxAuthPollingInterval, authPollingIntervalUnit = previousDefault, previousMax, previousUnit
+	})
+}
+
+func pollTestAuthToken(t *testing.T, server *authServer, authCode models.AuthCode) models.AuthToken {
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	client := http.NewClient(http.WithHost(httpServer.URL), http.WithRetryPolicy(http.RetryPolicy{Attempts: 1}))
+	return pollAuthToken(client, authCode, time.Now().Add(time.Minute), time.Minute)
+}
+
+func TestPollAuthTokenBackoff(t *testing.T) {
+	useAuthPollingIntervals(t, time.Second, 60*time.Millisecond)
+
+	// the server's interval is used, and doubled up to the max each time the server says to slow down
+	server := &authServer{statuses: []int{409, 429, 429, 429}}
+	token := pollTestAuthToken(t, server, models.AuthCode{Code: "code", PollingInterval: 20})
+	if token.Token != "dp.ct.test" {
+		t.Fatalf("Expected the token, got %+v", token)
+	}
+
+	gaps := server.gaps()
+	expected := []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 60 * time.Millisecond, 60 * time.Millisecond}
+	if len(gaps) != len(expected) {
+		t.Fatalf("Expected %d attempts, got %d", len(expected)+1, len(gaps)+1)
+	}
+	for i, gap := range gaps {
+		if gap < expected[i] {
+			t.Errorf("Expected attempt %d to wait at least %s, waited %s", i+2, expected[i], gap)
+		}
+	}
+	// without the cap, the last attempt would wait 160ms
+	if gaps[3] >= 150*time.Millisecond {
+		t.Errorf("Expected the interval to be capped, waited %s", gaps[3])
+	}
+}
+
+func TestPollAuthTokenDefaultInterval(t *testing.T) {
+	useAuthPollingIntervals(t, 30*time.Millisecond, time.Second)
+
+	server := &authServer{statuses: []int{409}}
+	if token := pollTestAuthToken(t, server, models.AuthCode{Code: "code"}); token.Token != "dp.ct.test" {
+		t.Fatalf("Expected the token, got %+v", token)
+	}
+	if gaps := server.gaps(); len(gaps) != 1 || gaps[0] < 30*time.Millisecond {
+		t.Errorf("Expected the default interval between attempts, got %v", gaps)
+	}
+}
+
+func TestLoginJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		exitCode int
+		code     string
+	}{
+		// the code expires while the authorization is pending
+		{"timeout", 409, `{"messages": ["Not yet"]}`, 4, "timeout"},
+		{"denied", 200, `{"error": "Authorization was denied by the user"}`, 1, "error"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if r.URL.Path == "/v3/auth/cli/generate" {
+					w.Write([]byte(`{"code": "ABC-123", "auth_url": "https://dashboard.doppler.com/auth/cli", "verification_url": "https://doppler.com/device", "polling_interval": 1, "expires_in": 1}`)) // #nosec G104
+					return
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body)) // #nosec G104
+			}))
+			defer server.Close()
+
+			home, _ := testHome(t)
+			defer os.RemoveAll(home)
+
+			cmd := helperCommand(home, "login", "--json", "--api-host", server.URL)
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+			if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != test.exitCode {
+				t.Fatalf("Expected exit code %d, got %v\n%s%s", test.exitCode, err, stdout.String(), stderr.String())
+			}
+
+			// headless output is printed as json, without prompts or other messages
+			var pending map[string]string
+			if err := json.NewDecoder(&stdout).Decode(&pending); err != nil {
+				t.Fatalf("Expected json output, got %q", stdout.String())
+			}
+			if pending["status"] != "pending" || pending["code"] != "ABC-123" || pending["auth_url"] != "https://dashboard.doppler.com/auth/cli" ||
+				pending["verification_url"] != "https://doppler.com/device" {
+				t.Errorf("Unexpected pending output %v", pending)
+			}
+			if _, err := time.Parse(time.RFC3339, pending["expires_at"]); err != nil {
+				t.Errorf("Expected an expiration, got %q", pending["expires_at"])
+			}
+			if strings.TrimSpace(stdout.String()) != "" {
+				t.Errorf("Expected no other output, got %q", stdout.String())
+			}
+
+			var failure map[string]interface{}
+			if err := json.Unmarshal(stderr.Bytes(), &failure); err != nil || failure["code"] != test.code {
+				t.Errorf("Expected a %s error, got %q", test.code, stderr.String())
+			}
+		})
+	}
+}
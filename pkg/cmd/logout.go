@@ -19,7 +19,7 @@ import (
 	"fmt"
 
 	"github.com/DopplerHQ/cli/pkg/configuration"
-	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/controllers"
 	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
@@ -40,7 +40,6 @@ func revokeToken(cmd *cobra.Command, args []string) {
 	localConfig := configuration.LocalConfig(cmd)
 	updateConfig := !utils.GetBoolFlag(cmd, "no-update-config")
 	updateEnclaveConfig := !utils.GetBoolFlag(cmd, "no-update-enclave-config") && !utils.GetBoolFlag(cmd, "no-update-config-options")
-	verifyTLS := utils.GetBool(localConfig.VerifyTLS.Value, true)
 	yes := utils.GetBoolFlag(cmd, "yes")
 	token := localConfig.Token.Value
 
@@ -50,7 +49,7 @@ func revokeToken(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	_, err := http.RevokeAuthToken(localConfig.APIHost.Value, verifyTLS, token)
+	err := apiClient(localConfig).RevokeAuthToken(cliContext, token)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -73,6 +72,17 @@ func revokeToken(cmd *cobra.Command, args []string) {
 				configuration.Set(scope, updatedConfig)
 			}
 		}
+
+		configuration.ClearProfileTokens(token)
+	}
+
+	if localConfig.Token.Source == models.TokenHelperSource.String() {
+		controllers.ClearTokenHelperCache(localConfig.TokenHelper.Value, configuration.TokenHelperScope(localConfig))
+	}
+
+	// the cache may contain secret names fetched with the revoked token
+	if err := controllers.ClearCompletionCache(); !err.IsNil() {
+		utils.LogDebugError(err.Unwrap())
 	}
 
 	utils.Log("Auth token has been revoked")
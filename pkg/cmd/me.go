@@ -0,0 +1,60 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/DopplerHQ/cli/pkg/configuration"
+	"github.com/DopplerHQ/cli/pkg/printer"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var meCmd = &cobra.Command{
+	Use:   "me",
+	Short: "Get info about the current token",
+	Long: `Get info about the current token, including its type, workplace, and the projects it can access.
+
+The scope and source show where the token was configured (e.g. a flag, an environment variable, or the config file).`,
+	Args: cobra.NoArgs,
+	Run:  me,
+}
+
+var configureWhoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Get info about the current token",
+	Long:  meCmd.Long,
+	Args:  cobra.NoArgs,
+	Run:   me,
+}
+
+func me(cmd *cobra.Command, args []string) {
+	jsonFlag := utils.OutputJSON
+	localConfig := configuration.LocalConfig(cmd)
+
+	utils.RequireValue("token", localConfig.Token.Value)
+
+	info, err := apiClient(localConfig).GetTokenInfo(cliContext)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+
+	printer.TokenInfo(info, localConfig.Token, jsonFlag)
+}
+
+func init() {
+	configureCmd.AddCommand(configureWhoamiCmd)
+	rootCmd.AddCommand(meCmd)
+}
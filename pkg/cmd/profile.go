@@ -0,0 +1,147 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/DopplerHQ/cli/pkg/configuration"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/printer"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage named profiles",
+	Long: `Manage named profiles, which store a token, API host, and other options under a name.
+
+The active profile's options take precedence over options scoped to a directory. Use --profile or DOPPLER_PROFILE to use a profile for a single command.`,
+	Args: cobra.NoArgs,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all profiles",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		printer.Profiles(configuration.Profiles(), configuration.ActiveProfile(), utils.OutputJSON)
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use [name]",
+	Short: "Set the active profile",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if utils.GetBoolFlag(cmd, "clear") {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if utils.GetBoolFlag(cmd, "clear") {
+			configuration.UseProfile("")
+			utils.Log("Profile has been deactivated")
+			return
+		}
+
+		name := args[0]
+		configuration.UseProfile(name)
+		utils.Log(fmt.Sprintf("Using profile %s", name))
+	},
+}
+
+var profileCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a profile",
+	Long: `Create a profile from the specified options.
+
+Ex: create a profile for another workplace:
+doppler profile create work --token dp.ct.xxx --project backend --config dev`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		options := map[string]string{}
+		flags := map[string]string{
+			"token":          models.ConfigToken.String(),
+			"api-host":       models.ConfigAPIHost.String(),
+			"dashboard-host": models.ConfigDashboardHost.String(),
+			"project":        models.ConfigEnclaveProject.String(),
+			"config":         models.ConfigEnclaveConfig.String(),
+		}
+		for flag, option := range flags {
+			if value := utils.GetFlagIfChanged(cmd, flag, ""); value != "" {
+				options[option] = value
+			}
+		}
+		if cmd.Flags().Changed("no-verify-tls") {
+			options[models.ConfigVerifyTLS.String()] = strconv.FormatBool(!utils.GetBoolFlag(cmd, "no-verify-tls"))
+		}
+
+		if len(options) == 0 {
+			utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("You must specify at least one option (e.g. --token)")))
+		}
+
+		configuration.CreateProfile(name, options)
+		if utils.GetBoolFlag(cmd, "use") {
+			configuration.UseProfile(name)
+		}
+
+		if !utils.Silent {
+			printer.Profiles(map[string]models.FileScopedOptions{name: configuration.Profiles()[name]}, configuration.ActiveProfile(), utils.OutputJSON)
+		}
+	},
+}
+
+var profileDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a profile",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		yes := utils.GetBoolFlag(cmd, "yes")
+
+		if !configuration.ProfileExists(name) {
+			utils.HandleError(utils.NewError(utils.ErrorKindUsage, fmt.Errorf("Profile %s doesn't exist", name)))
+		}
+
+		if yes || utils.ConfirmationPrompt(fmt.Sprintf("Delete profile %s?", name), false) {
+			configuration.DeleteProfile(name)
+			utils.Log(fmt.Sprintf("Profile %s has been deleted", name))
+		}
+	},
+}
+
+func init() {
+	profileCmd.AddCommand(profileListCmd)
+
+	profileUseCmd.Flags().Bool("clear", false, "deactivate the active profile")
+	profileCmd.AddCommand(profileUseCmd)
+
+	profileCreateCmd.Flags().StringP("project", "p", "", "enclave project (e.g. backend)")
+	profileCreateCmd.Flags().StringP("config", "c", "", "enclave config (e.g. dev)")
+	profileCreateCmd.Flags().Bool("use", false, "set the profile as the active profile")
+	profileCmd.AddCommand(profileCreateCmd)
+
+	profileDeleteCmd.Flags().BoolP("yes", "y", false, "proceed without confirmation")
+	profileCmd.AddCommand(profileDeleteCmd)
+
+	rootCmd.AddCommand(profileCmd)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+)
+
+func TestProfilePrecedence(t *testing.T) {
+	home, _ := testHome(t)
+	defer os.RemoveAll(home)
+
+	config := `scoped:
+  /:
+    enclave.project: scoped-project
+    enclave.config: scoped-config
+profiles:
+  work:
+    enclave.project: work-project
+  other:
+    enclave.project: other-project
+active-profile: work
+`
+	if err := ioutil.WriteFile(filepath.Join(home, ".doppler", ".doppler.yaml"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		env      []string
+		args     []string
+		expected string
+		scope    string
+	}{
+		{"active profile over scoped value", nil, nil, "work-project", "profile:work"},
+		{"environment variable over profile", []string{"DOPPLER_PROJECT=env-project"}, nil, "env-project", "/"},
+		{"--profile over active profile", nil, []string{"--profile", "other"}, "other-project", "profile:other"},
+		{"DOPPLER_PROFILE over active profile", []string{"DOPPLER_PROFILE=other"}, nil, "other-project", "profile:other"},
+		{"--profile over DOPPLER_PROFILE", []string{"DOPPLER_PROFILE=other"}, []string{"--profile", "work"}, "work-project", "profile:work"},
+	}
+	for _, test := range tests {
+		cmd := helperCommand(home, append([]string{"configure", "debug", "--json"}, test.args...)...)
+		cmd.Env = append(cmd.Env, test.env...)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Errorf("%s: command failed: %s", test.name, err)
+			continue
+		}
+
+		// the config is keyed by scope
+		var result map[string]map[string]string
+		if err := json.Unmarshal(out, &result); err != nil {
+			t.Errorf("%s: unable to parse output %q: %s", test.name, out, err)
+			continue
+		}
+		if project := result[test.scope][models.ConfigEnclaveProject.String()]; project != test.expected {
+			t.Errorf("%s: expected project %q in scope %s, got %v", test.name, test.expected, test.scope, result)
+		}
+		// options the profile doesn't set still come from the scoped config
+		if config := result["/"][models.ConfigEnclaveConfig.String()]; config != "scoped-config" {
+			t.Errorf("%s: expected the scoped config, got %v", test.name, result)
+		}
+	}
+}
+
+func TestProfileDoesNotExist(t *testing.T) {
+	home, _ := testHome(t)
+	defer os.RemoveAll(home)
+
+	out, err := helperCommand(home, "configure", "debug", "--profile", "missing", "--json").CombinedOutput()
+	if err == nil {
+		t.Fatalf("Expected an error for a missing profile, got:\n%s", out)
+	}
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		t.Errorf("Expected a usage error, got %v:\n%s", err, out)
+	}
+}
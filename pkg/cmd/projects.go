@@ -67,7 +67,7 @@ func projects(cmd *cobra.Command, args []string) {
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
-	info, err := http.GetProjects(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value)
+	info, err := apiClient(localConfig).GetProjects(cliContext)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -86,7 +86,7 @@ func getProjects(cmd *cobra.Command, args []string) {
 		project = args[0]
 	}
 
-	info, err := http.GetProject(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, project)
+	info, err := apiClient(localConfig).GetProject(cliContext, project)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -107,7 +107,7 @@ func createProjects(cmd *cobra.Command, args []string) {
 	}
 	utils.RequireValue("name", name)
 
-	info, err := http.CreateProject(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, name, description)
+	info, err := apiClient(localConfig).CreateProject(cliContext, name, description)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -135,13 +135,13 @@ func deleteProjects(cmd *cobra.Command, args []string) {
 	}
 
 	if yes || utils.ConfirmationPrompt(prompt, false) {
-		err := http.DeleteProject(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, project)
+		err := apiClient(localConfig).DeleteProject(cliContext, project)
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
 
 		if !utils.Silent {
-			info, err := http.GetProjects(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value)
+			info, err := apiClient(localConfig).GetProjects(cliContext)
 			if !err.IsNil() {
 				utils.HandleError(err.Unwrap(), err.Message)
 			}
@@ -168,9 +168,9 @@ func updateProjects(cmd *cobra.Command, args []string) {
 	var info models.ProjectInfo
 	var httpErr http.Error
 	if cmd.Flags().Changed("description") {
-		info, httpErr = http.UpdateProject(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, project, name, description)
+		info, httpErr = apiClient(localConfig).UpdateProject(cliContext, project, name, description)
 	} else {
-		info, httpErr = http.UpdateProject(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, project, name)
+		info, httpErr = apiClient(localConfig).UpdateProject(cliContext, project, name)
 	}
 	if !httpErr.IsNil() {
 		utils.HandleError(httpErr.Unwrap(), httpErr.Message)
@@ -16,13 +16,21 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/DopplerHQ/cli/pkg/configuration"
 	"github.com/DopplerHQ/cli/pkg/controllers"
 	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/DopplerHQ/cli/pkg/version"
@@ -51,11 +59,13 @@ var rootCmd = &cobra.Command{
 			fmt.Println("")
 		}
 
+		checkMinimumVersion(cmd.CalledAs())
+
 		plain := utils.GetBoolFlagIfChanged(cmd, "plain", false)
 		// only run version check if we can print the results
 		// --plain doesn't normally affect logging output, but due to legacy reasons it does here
 		if utils.CanLogInfo() && !plain {
-			checkVersion(cmd.CalledAs())
+			checkVersion(cmd)
 		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
@@ -66,9 +76,29 @@ var rootCmd = &cobra.Command{
 	},
 }
 
-func checkVersion(command string) {
-	// disable version checking on the "run" command and "secrets download" command
-	if command == "run" || command == "download" {
+// checkMinimumVersion exits if this CLI is older than the minimum version required by the repo config file (doppler.yaml)
+func checkMinimumVersion(command string) {
+	// these commands must work so that an outdated CLI can be updated
+	if command == "update" || command == "help" || command == "completion" || isCompletionRequest(command) {
+		return
+	}
+
+	repoConfig, controllerErr := controllers.RepoConfig()
+	if !controllerErr.IsNil() {
+		utils.LogDebug(controllerErr.Message)
+		utils.LogDebugError(controllerErr.Unwrap())
+		return
+	}
+	if controllerErr = controllers.CheckMinimumVersion(repoConfig); !controllerErr.IsNil() {
+		utils.HandleError(controllerErr.Unwrap(), controllerErr.Message)
+	}
+}
+
+func checkVersion(cmd *cobra.Command) {
+	command := cmd.CalledAs()
+	// disable version checking on the "run" command, "secrets download" command, "shell-hook export" command, "dev fake-api" command, and shell completion requests.
+	// the shell hook's output is evaluated by the shell, so only its full path is matched rather than any "export" command.
+	if command == "run" || command == "download" || cmd.CommandPath() == "doppler shell-hook export" || command == "fake-api" || isCompletionRequest(command) {
 		return
 	}
 
@@ -76,13 +106,19 @@ func checkVersion(command string) {
 		return
 	}
 
+	// the user chose this version via 'doppler update --version'
+	if pinnedVersion := configuration.PinnedVersion(); pinnedVersion != "" {
+		utils.LogDebug(fmt.Sprintf("Not checking for CLI updates while pinned to %s", pinnedVersion))
+		return
+	}
+
 	prevVersionCheck := configuration.VersionCheck()
 	// don't check more often than every 24 hours
 	if !time.Now().After(prevVersionCheck.CheckedAt.Add(24 * time.Hour)) {
 		return
 	}
 
-	available, versionCheck, err := controllers.NewVersionAvailable(prevVersionCheck)
+	available, versionCheck, err := controllers.NewVersionAvailable(cliContext, prevVersionCheck, configuration.UpdateChannel())
 	if err != nil {
 		// retry on next run
 		return
@@ -98,13 +134,112 @@ func checkVersion(command string) {
 		utils.Log(color.Green.Sprintf("An update is available."))
 		prompt := fmt.Sprintf("Install Doppler CLI %s", versionCheck.LatestVersion)
 		if utils.ConfirmationPrompt(prompt, true) {
-			installCLIUpdate()
+			installCLIUpdate(versionCheck.LatestVersion)
 		}
 	}
 
 	configuration.SetVersionCheck(versionCheck)
 }
 
+// requestTimeout the timeout of each HTTP request, as specified via --timeout and --no-timeout. 0 disables the timeout.
+var requestTimeout = http.DefaultTimeout
+
+// clientOptions options shared by all Doppler API clients
+func clientOptions() []http.Option {
+	return []http.Option{
+		http.WithTimeout(requestTimeout),
+		http.WithRunID(utils.RunID),
+		http.WithRetryNotice(retryNotice),
+	}
+}
+
+// retryNotice tells the user that a request is being retried. it's written to stderr so it can't corrupt output that's meant to be parsed.
+func retryNotice(message string) {
+	if utils.CanLogInfo() {
+		fmt.Fprintln(os.Stderr, message)
+	}
+}
+
+// devClientOptions additional client options, which are only set in development builds (see dev.go)
+var devClientOptions func() []http.Option
+
+// apiClient creates a Doppler API client using the specified configuration. options are applied after those from the configuration.
+func apiClient(localConfig models.ScopedOptions, options ...http.Option) *http.Client {
+	policy, err := retryPolicy(localConfig)
+	if err != nil {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, err), "Invalid retry options")
+	}
+
+	configOptions := append(transportOptions(localConfig), clientOptions()...)
+	configOptions = append(configOptions,
+		http.WithHost(localConfig.APIHost.Value),
+		http.WithVerifyTLS(utils.GetBool(localConfig.VerifyTLS.Value, true)),
+		http.WithToken(localConfig.Token.Value),
+		http.WithRetryPolicy(policy),
+	)
+	if devClientOptions != nil {
+		configOptions = append(configOptions, devClientOptions()...)
+	}
+	utils.RedactFromLogs(localConfig.Token.Value)
+	return http.NewClient(append(configOptions, options...)...)
+}
+
+// retryPolicy parses the retry options. unset options use the default policy's value.
+func retryPolicy(localConfig models.ScopedOptions) (http.RetryPolicy, error) {
+	policy := http.DefaultRetryPolicy
+
+	if value := localConfig.RetryAttempts.Value; value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil || attempts < 1 {
+			return policy, fmt.Errorf("%s must be a positive integer", models.ConfigRetryAttempts.String())
+		}
+		policy.Attempts = attempts
+	}
+
+	durations := []struct {
+		option models.ScopedOption
+		name   string
+		value  *time.Duration
+	}{
+		{localConfig.RetryDelay, models.ConfigRetryDelay.String(), &policy.Delay},
+		{localConfig.RetryMaxDelay, models.ConfigRetryMaxDelay.String(), &policy.MaxDelay},
+		{localConfig.RetryMaxTime, models.ConfigRetryMaxTime.String(), &policy.MaxElapsed},
+	}
+	for _, d := range durations {
+		if d.option.Value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(d.option.Value)
+		if err != nil || duration < 0 {
+			return policy, fmt.Errorf("%s must be a duration (e.g. 500ms)", d.name)
+		}
+		*d.value = duration
+	}
+
+	if value := localConfig.RetryStatusCodes.Value; value != "" {
+		statuses, err := http.ParseStatusRanges(value)
+		if err != nil {
+			return policy, err
+		}
+		policy.RetryableStatuses = statuses
+	}
+
+	return policy, nil
+}
+
+// transportOptions the client's CA bundle, client certificate, and proxy options
+func transportOptions(localConfig models.ScopedOptions) []http.Option {
+	return []http.Option{
+		http.WithCACert(localConfig.CACert.Value),
+		http.WithClientCertificate(localConfig.ClientCert.Value, localConfig.ClientKey.Value),
+		http.WithProxy(http.ProxyOptions{
+			HTTP:    localConfig.HTTPProxy.Value,
+			HTTPS:   localConfig.HTTPSProxy.Value,
+			NoProxy: localConfig.NoProxy.Value,
+		}),
+	}
+}
+
 func loadFlags(cmd *cobra.Command) {
 	var err error
 	scope := cmd.Flag("scope").Value.String()
@@ -113,14 +248,91 @@ func loadFlags(cmd *cobra.Command) {
 	}
 
 	configuration.UserConfigFile = utils.GetPathFlagIfChanged(cmd, "configuration", configuration.UserConfigFile)
-	http.TimeoutDuration = utils.GetDurationFlagIfChanged(cmd, "timeout", http.TimeoutDuration)
-	http.UseTimeout = !utils.GetBoolFlagIfChanged(cmd, "no-timeout", !http.UseTimeout)
+	loadProfileFlag(cmd)
+	requestTimeout = utils.GetDurationFlagIfChanged(cmd, "timeout", requestTimeout)
+	if utils.GetBoolFlagIfChanged(cmd, "no-timeout", false) {
+		requestTimeout = 0
+	}
 	utils.Debug = utils.GetBoolFlagIfChanged(cmd, "debug", utils.Debug)
 	utils.Silent = utils.GetBoolFlagIfChanged(cmd, "silent", utils.Silent)
 	// no-file is used by the 'secrets download' command to output secrets to stdout
 	utils.Silent = utils.GetBoolFlagIfChanged(cmd, "no-file", utils.Silent)
 	utils.OutputJSON = utils.GetBoolFlagIfChanged(cmd, "json", utils.OutputJSON)
+	loadOutputFlags(cmd)
 	version.PerformVersionCheck = !utils.GetBoolFlagIfChanged(cmd, "no-check-version", !version.PerformVersionCheck)
+
+	openLogFile(cmd)
+}
+
+// loadOutputFlags sets the format and fields used when printing command output. --json is equivalent to --output=json.
+func loadOutputFlags(cmd *cobra.Command) {
+	output := utils.GetFlagIfChanged(cmd, "output", "")
+	if utils.OutputJSON {
+		if output != "" && output != printer.FormatJSON {
+			utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("--json can't be used with --output")))
+		}
+		output = printer.FormatJSON
+	}
+
+	options, err := printer.ParseOutputOptions(output, utils.GetFlagIfChanged(cmd, "fields", ""))
+	if err != nil {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, err))
+	}
+	printer.Output = options
+	// json output also causes errors to be printed as json
+	utils.OutputJSON = options.Format == printer.FormatJSON
+}
+
+// loadProfileFlag sets the profile specified via --profile or the DOPPLER_PROFILE environment variable
+func loadProfileFlag(cmd *cobra.Command) {
+	profile := ""
+	if !utils.GetBoolFlag(cmd, "no-read-env") {
+		profile = os.Getenv("DOPPLER_PROFILE")
+	}
+	configuration.Profile = utils.GetFlagIfChanged(cmd, "profile", profile)
+}
+
+// openLogFile writes structured logs to the file specified via --log-file or the DOPPLER_LOG_FILE environment variable
+func openLogFile(cmd *cobra.Command) {
+	readEnv := !utils.GetBoolFlag(cmd, "no-read-env")
+	option := func(flag string, envVar string) string {
+		value := ""
+		if readEnv {
+			value = os.Getenv(envVar)
+		}
+		return utils.GetFlagIfChanged(cmd, flag, value)
+	}
+
+	path := option("log-file", "DOPPLER_LOG_FILE")
+	if path == "" {
+		return
+	}
+	if path != utils.LogFileStderr {
+		var err error
+		if path, err = utils.ParsePath(path); err != nil {
+			utils.HandleError(utils.NewError(utils.ErrorKindUsage, err), "Unable to parse log file path")
+		}
+	}
+
+	level := utils.LogLevelInfo
+	if value := option("log-level", "DOPPLER_LOG_LEVEL"); value != "" {
+		var err error
+		if level, err = utils.ParseLogLevel(value); err != nil {
+			utils.HandleError(utils.NewError(utils.ErrorKindUsage, err))
+		}
+	}
+
+	err := utils.OpenLogFile(utils.LogFileOptions{
+		Path:       path,
+		Level:      level,
+		Format:     option("log-format", "DOPPLER_LOG_FORMAT"),
+		MaxSize:    utils.DefaultLogFileMaxSize,
+		MaxBackups: utils.DefaultLogFileMaxBackups,
+	})
+	if err != nil {
+		utils.HandleError(err, "Unable to open log file")
+	}
+	utils.LogEntry(utils.LogLevelDebug, "Running command", utils.LogField{Key: "command", Value: cmd.CommandPath()}, utils.LogField{Key: "version", Value: version.ProgramVersion})
 }
 
 func deprecatedCommand(newCommand string) {
@@ -131,9 +343,39 @@ func deprecatedCommand(newCommand string) {
 	}
 }
 
+// cliContext is canceled when the CLI receives an interrupt, aborting in-flight requests, retries, and token helpers
+var cliContext = context.Background()
+
+// interruptGracePeriod how long a command has to return after an interrupt before the CLI exits
+var interruptGracePeriod = 2 * time.Second
+
+// cancelOnInterrupt cancels the CLI context on the first interrupt. A second interrupt exits immediately.
+// Commands that don't check the context (e.g. interactive prompts) are exited after a grace period,
+// unless a child process is running (e.g. via 'doppler run'), which handles the interrupt itself.
+func cancelOnInterrupt(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	utils.LogDebug("Received interrupt, canceling pending requests")
+	cancel()
+	// restore the default handler so another interrupt terminates the process
+	signal.Stop(signals)
+
+	time.Sleep(interruptGracePeriod)
+	if utils.IsChildProcessRunning() {
+		return
+	}
+	utils.HandleError(utils.NewError(utils.ErrorKindCanceled, context.Canceled), "Interrupted")
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cliContext = ctx
+	go cancelOnInterrupt(cancel)
+
 	// catch any panics in non-dev builds
 	defer func() {
 		if !version.IsDevelopment() {
@@ -144,12 +386,17 @@ func Execute() {
 		}
 	}()
 
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	registerCompletions(rootCmd)
+
+	// cobra has already printed the error, which is due to invalid flags or args
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		os.Exit(utils.ErrorKindUsage.ExitCode)
 	}
 }
 
 func init() {
+	controllers.KeyringFile = filepath.Join(configuration.UserConfigDir, "keyring")
+
 	rootCmd.Version = version.ProgramVersion
 	rootCmd.SetVersionTemplate(rootCmd.Version + "\n")
 	rootCmd.Flags().BoolP("version", "v", false, "Get the version of the Doppler CLI")
@@ -159,14 +406,25 @@ func init() {
 	rootCmd.PersistentFlags().String("dashboard-host", "https://dashboard.doppler.com", "The host address for the Doppler Dashboard")
 	rootCmd.PersistentFlags().Bool("no-check-version", !version.PerformVersionCheck, "disable checking for Doppler CLI updates")
 	rootCmd.PersistentFlags().Bool("no-verify-tls", false, "do not verify the validity of TLS certificates on HTTP requests (not recommended)")
-	rootCmd.PersistentFlags().Bool("no-timeout", !http.UseTimeout, "disable http timeout")
-	rootCmd.PersistentFlags().Duration("timeout", http.TimeoutDuration, "max http request duration")
+	rootCmd.PersistentFlags().Bool("no-timeout", false, "disable http timeout")
+	rootCmd.PersistentFlags().Duration("timeout", http.DefaultTimeout, "max http request duration")
+	rootCmd.PersistentFlags().Int("retry-attempts", http.DefaultRetryPolicy.Attempts, "max number of attempts for each http request, including the initial request")
+	rootCmd.PersistentFlags().Duration("retry-delay", http.DefaultRetryPolicy.Delay, "delay before retrying a failed http request, which doubles after each attempt")
+	rootCmd.PersistentFlags().Duration("retry-max-delay", http.DefaultRetryPolicy.MaxDelay, "max delay between http request attempts. set to 0 to disable.")
+	rootCmd.PersistentFlags().Duration("retry-max-time", http.DefaultRetryPolicy.MaxElapsed, "max time to spend retrying an http request. set to 0 to disable.")
+	rootCmd.PersistentFlags().String("retry-status-codes", http.FormatStatusRanges(http.DefaultRetryableStatuses), "comma-separated http status codes and classes to retry (e.g. 429,5xx)")
 
 	rootCmd.PersistentFlags().Bool("no-read-env", false, "do not read config from the environment")
 	rootCmd.PersistentFlags().String("scope", configuration.Scope, "the directory to scope your config to")
 	rootCmd.PersistentFlags().String("configuration", configuration.UserConfigFile, "config file")
+	rootCmd.PersistentFlags().String("profile", "", "the profile to use, overriding the active profile")
 	rootCmd.PersistentFlags().Bool("json", utils.OutputJSON, "output json")
+	rootCmd.PersistentFlags().StringP("output", "o", "", fmt.Sprintf("output format (%s)", strings.Join(printer.OutputFormats, ", ")))
+	rootCmd.PersistentFlags().String("fields", "", "comma-separated fields to output (e.g. id,name)")
 	rootCmd.PersistentFlags().Bool("debug", utils.Debug, "output additional information")
 	rootCmd.PersistentFlags().Bool("print-config", false, "output active configuration")
 	rootCmd.PersistentFlags().Bool("silent", utils.Silent, "disable output of info messages")
+	rootCmd.PersistentFlags().String("log-file", "", "write structured logs to the file, which is rotated at 10MB. use '-' to write to stderr.")
+	rootCmd.PersistentFlags().String("log-level", utils.LogLevelInfo.String(), "min level of structured logs (debug, info, warn, error)")
+	rootCmd.PersistentFlags().String("log-format", utils.LogFormatLogfmt, "format of structured logs (logfmt, json)")
 }
@@ -0,0 +1,101 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// commands that don't check the CLI context, like reading a file, exit after the grace period
+func TestInterruptGracePeriod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupting a process isn't supported on Windows")
+	}
+
+	home, _ := testHome(t)
+	defer os.RemoveAll(home)
+
+	// reading a fifo without a writer blocks indefinitely
+	fifo := filepath.Join(home, "secrets.env")
+	if output, err := exec.Command("mkfifo", fifo).CombinedOutput(); err != nil {
+		t.Skipf("Unable to create fifo: %s %s", err, output)
+	}
+
+	cmd := helperCommand(home, "secrets", "upload", fifo, "--token", "dp.st.test", "--no-check-version")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// give the command time to start reading
+	time.Sleep(500 * time.Millisecond)
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 130 {
+			t.Errorf("Expected exit code 130, got %v", err)
+		}
+	case <-time.After(interruptGracePeriod + 5*time.Second):
+		cmd.Process.Kill() // #nosec G104
+		t.Fatal("Expected the command to exit after the grace period")
+	}
+}
+
+// the CLI waits for a child process that's still running, which handles the interrupt itself
+func TestInterruptWithChildProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupting a process isn't supported on Windows")
+	}
+
+	home, _ := testHome(t)
+	defer os.RemoveAll(home)
+	httpServer := httptest.NewServer(&secretsServer{version: 1})
+	defer httpServer.Close()
+
+	cmd := helperCommand(home, "run", "--api-host", httpServer.URL, "--token", "dp.st.test", "--project", "proj", "--config", "dev",
+		"--no-check-version", "--command", "echo started; sleep 4")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if scanner.Text() == "started" {
+			break
+		}
+	}
+
+	// only the CLI is interrupted, so the child keeps running and exits successfully
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("Expected the child's exit code, got %v", err)
+	}
+}
@@ -16,9 +16,11 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -30,6 +32,7 @@ import (
 	"github.com/DopplerHQ/cli/pkg/crypto"
 	"github.com/DopplerHQ/cli/pkg/http"
 	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
 	"gopkg.in/gookit/color.v1"
@@ -38,6 +41,17 @@ import (
 var defaultFallbackDir string
 
 const defaultFallbackFileMaxAge = 14 * 24 * time.Hour // 14 days
+const defaultOfflineFirstMaxAge = 24 * time.Hour
+
+// refreshWriteTimeout how long to wait, once the command exits, for the background refresh to finish writing the fallback file
+const refreshWriteTimeout = 500 * time.Millisecond
+
+// fallbackVerifiedResolution how often the metadata file records that the fallback file is still current.
+// the metadata file isn't rewritten when the secrets are confirmed more often than this.
+const fallbackVerifiedResolution = time.Minute
+
+// secrets that are never injected into the environment
+var excludedSecretNames = []string{"PATH", "PS1", "HOME"}
 
 var runCmd = &cobra.Command{
 	Use:   "run [command]",
@@ -72,6 +86,11 @@ doppler run --command "YOUR_COMMAND && YOUR_OTHER_COMMAND"`,
 		fallbackOnly := utils.GetBoolFlag(cmd, "fallback-only")
 		exitOnWriteFailure := !utils.GetBoolFlag(cmd, "no-exit-on-write-failure")
 		preserveEnv := utils.GetBoolFlag(cmd, "preserve-env")
+		forwardDopplerEnv := utils.GetBoolFlag(cmd, "forward-doppler-env")
+		offlineFirst := utils.GetBoolFlag(cmd, "offline-first")
+		fetchDeadline := utils.GetDurationFlag(cmd, "fetch-deadline")
+		redactEncoded := utils.GetBoolFlag(cmd, "redact-encoded")
+		redact := utils.GetBoolFlag(cmd, "redact") || redactEncoded
 		localConfig := configuration.LocalConfig(cmd)
 
 		utils.RequireValue("token", localConfig.Token.Value)
@@ -88,11 +107,11 @@ doppler run --command "YOUR_COMMAND && YOUR_OTHER_COMMAND"`,
 
 		passphrase := getPassphrase(cmd, "passphrase", localConfig)
 		if passphrase == "" {
-			utils.HandleError(errors.New("invalid passphrase"))
+			utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("invalid passphrase")))
 		}
 
 		if !enableFallback {
-			flags := []string{"fallback", "fallback-only", "fallback-readonly", "no-exit-on-write-failure", "passphrase"}
+			flags := []string{"fallback", "fallback-only", "fallback-readonly", "no-exit-on-write-failure", "passphrase", "offline-first", "max-fallback-age"}
 			for _, flag := range flags {
 				if cmd.Flags().Changed(flag) {
 					utils.LogWarning(fmt.Sprintf("--%s has no effect when the fallback file is disabled", flag))
@@ -100,13 +119,35 @@ doppler run --command "YOUR_COMMAND && YOUR_OTHER_COMMAND"`,
 			}
 		}
 
-		secrets := fetchSecrets(localConfig, enableCache, enableFallback, fallbackPath, legacyFallbackPath, metadataPath, fallbackReadonly, fallbackOnly, exitOnWriteFailure, passphrase)
+		var secrets map[string]string
+		// held while the background refresh writes the fallback file
+		var refreshWrite backgroundWrite
+		if offlineFirst && enableFallback && !fallbackOnly {
+			maxAge := utils.GetDurationFlag(cmd, "max-fallback-age")
+			if fallbackSecrets, ok := readFreshFallbackFile(fallbackPath, metadataPath, maxAge, passphrase); ok {
+				utils.LogDebug("Using secrets from fallback file, refreshing fallback file in the background")
+				secrets = fallbackSecrets
+
+				if !fallbackReadonly {
+					refreshWrite = newBackgroundWrite()
+					go refreshFallbackFile(localConfig, enableCache, fallbackPath, legacyFallbackPath, metadataPath, passphrase, fetchDeadline, refreshWrite)
+				}
+			}
+		}
+
+		if secrets == nil {
+			secrets = fetchSecrets(localConfig, enableCache, enableFallback, fallbackPath, legacyFallbackPath, metadataPath, fallbackReadonly, fallbackOnly, exitOnWriteFailure, passphrase, fetchDeadline)
+		}
 
 		if preserveEnv {
 			utils.LogWarning("Ignoring Doppler secrets already defined in the environment due to --preserve-env flag")
 		}
 
 		env := os.Environ()
+		if !forwardDopplerEnv {
+			// the CLI's own config (e.g. DOPPLER_TOKEN) shouldn't leak into the command's environment
+			env = stripDopplerEnv(env)
+		}
 		existingEnvKeys := map[string]bool{}
 		for _, envVar := range env {
 			// key=value format
@@ -115,10 +156,10 @@ doppler run --command "YOUR_COMMAND && YOUR_OTHER_COMMAND"`,
 			existingEnvKeys[key] = true
 		}
 
-		excludedKeys := []string{"PATH", "PS1", "HOME"}
+		var injectedValues []string
 		for name, value := range secrets {
 			useSecret := true
-			for _, excludedKey := range excludedKeys {
+			for _, excludedKey := range excludedSecretNames {
 				if excludedKey == name {
 					useSecret = false
 					break
@@ -135,37 +176,71 @@ doppler run --command "YOUR_COMMAND && YOUR_OTHER_COMMAND"`,
 
 			if useSecret {
 				env = append(env, fmt.Sprintf("%s=%s", name, value))
+				if !isConfigIdentifier(name) {
+					injectedValues = append(injectedValues, value)
+				}
 			}
 		}
 
+		var stdout, stderr io.Writer = os.Stdout, os.Stderr
+		var redactors []*utils.Redactor
+		if redact {
+			values := utils.RedactedForms(injectedValues, redactEncoded)
+			redactors = []*utils.Redactor{utils.NewRedactor(os.Stdout, values), utils.NewRedactor(os.Stderr, values)}
+			stdout, stderr = redactors[0], redactors[1]
+		}
+
 		exitCode := 0
 		var err error
 
 		if cmd.Flags().Changed("command") {
 			command := cmd.Flag("command").Value.String()
-			exitCode, err = utils.RunCommandString(command, env, os.Stdin, os.Stdout, os.Stderr)
+			exitCode, err = utils.RunCommandString(command, env, os.Stdin, stdout, stderr)
 		} else {
-			exitCode, err = utils.RunCommand(args, env, os.Stdin, os.Stdout, os.Stderr)
+			exitCode, err = utils.RunCommand(args, env, os.Stdin, stdout, stderr)
 		}
 
 		if err != nil {
 			utils.LogDebugError(err)
 		}
 
+		for _, redactor := range redactors {
+			if closeErr := redactor.Close(); closeErr != nil {
+				utils.LogDebugError(closeErr)
+			}
+		}
+
+		// the command's exit isn't delayed by a refresh that's still fetching secrets, only by one that's writing them
+		if refreshWrite != nil && !refreshWrite.wait(refreshWriteTimeout) {
+			utils.LogDebug("Timed out waiting for the fallback file to refresh")
+		}
+
 		os.Exit(exitCode)
 	},
 }
 
 var runCleanCmd = &cobra.Command{
-	Use:     "clean",
-	Short:   "Delete old fallback files",
-	Long:    `Delete fallback files older than the max age from the default directory`,
-	Example: `doppler run clean --max-age=24h`,
-	Args:    cobra.NoArgs,
+	Use:   "clean",
+	Short: "Delete old fallback files",
+	Long: `Delete fallback files older than the max age from the default directory.
+
+Files can also be deleted by project and config, or when their fallback file no longer exists.
+Run 'doppler run list' to see which files exist.`,
+	Example: `doppler run clean --max-age=24h
+doppler run clean --project=backend --config=dev
+doppler run clean --orphaned-metadata`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		maxAge := utils.GetDurationFlag(cmd, "max-age")
 		dryRun := utils.GetBoolFlag(cmd, "dry-run")
 		all := utils.GetBoolFlag(cmd, "all")
+		project := cmd.Flag("project").Value.String()
+		config := cmd.Flag("config").Value.String()
+		orphanedMetadata := utils.GetBoolFlag(cmd, "orphaned-metadata")
+
+		filter := project != "" || config != "" || orphanedMetadata
+		// the default max age only applies when no filter is specified
+		checkAge := !all && (!filter || cmd.Flags().Changed("max-age"))
 
 		utils.LogDebug(fmt.Sprintf("Using fallback directory %s", defaultFallbackDir))
 
@@ -184,6 +259,34 @@ var runCleanCmd = &cobra.Command{
 			utils.HandleError(err, "Unable to read fallback directory")
 		}
 
+		// files matching the filters, as determined by the fallback index
+		var matchingFiles map[string]bool
+		if filter {
+			files, err := controllers.FallbackFiles(defaultFallbackDir)
+			if !err.IsNil() {
+				utils.HandleError(err.Unwrap(), err.Message)
+			}
+
+			matchingFiles = map[string]bool{}
+			for _, file := range files {
+				if orphanedMetadata && !file.OrphanedMetadata {
+					continue
+				}
+				if project != "" && (!file.Indexed || file.Project != project) {
+					continue
+				}
+				if config != "" && (!file.Indexed || file.Config != config) {
+					continue
+				}
+
+				matchingFiles[file.Name] = true
+				// a fallback file and its metadata file share the same hash
+				if file.HasMetadata {
+					matchingFiles[strings.Replace(file.Name, ".secrets-", ".metadata-", 1)] = true
+				}
+			}
+		}
+
 		deleted := 0
 		now := time.Now()
 
@@ -193,18 +296,24 @@ var runCleanCmd = &cobra.Command{
 		}
 
 		for _, entry := range entries {
+			// this skips the locks directory, whose lock files may be held by other processes
 			if entry.IsDir() {
 				continue
 			}
 
-			delete := false
-			if all {
-				delete = true
-			} else {
+			// the index is only removed when deleting all files
+			if !all && controllers.IsFallbackIndexFile(entry.Name()) {
+				continue
+			}
+
+			if filter && !matchingFiles[entry.Name()] {
+				continue
+			}
+
+			delete := true
+			if checkAge {
 				validUntil := entry.ModTime().Add(maxAge)
-				if validUntil.Before(now) {
-					delete = true
-				}
+				delete = validUntil.Before(now)
 			}
 
 			if delete {
@@ -227,6 +336,13 @@ var runCleanCmd = &cobra.Command{
 			}
 		}
 
+		if !dryRun && deleted > 0 {
+			if err := controllers.PruneFallbackIndex(); !err.IsNil() {
+				utils.LogDebugError(err.Unwrap())
+				utils.LogDebug(err.Message)
+			}
+		}
+
 		if deleted == 1 {
 			utils.Log(fmt.Sprintf("%s %d fallback file\n", action, deleted))
 		} else {
@@ -235,11 +351,32 @@ var runCleanCmd = &cobra.Command{
 	},
 }
 
+var runListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List fallback files",
+	Long: `List fallback files and metadata files, along with the project and config they belong to.
+
+Files written by older versions of the CLI may not be associated with a project or config.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		jsonFlag := utils.OutputJSON
+
+		utils.LogDebug(fmt.Sprintf("Using fallback directory %s", defaultFallbackDir))
+
+		files, err := controllers.FallbackFiles(defaultFallbackDir)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+
+		printer.FallbackFiles(files, jsonFlag)
+	},
+}
+
 // fetchSecrets fetches secrets, including all reading and writing of fallback files
-func fetchSecrets(localConfig models.ScopedOptions, enableCache bool, enableFallback bool, fallbackPath string, legacyFallbackPath string, metadataPath string, fallbackReadonly bool, fallbackOnly bool, exitOnWriteFailure bool, passphrase string) map[string]string {
+func fetchSecrets(localConfig models.ScopedOptions, enableCache bool, enableFallback bool, fallbackPath string, legacyFallbackPath string, metadataPath string, fallbackReadonly bool, fallbackOnly bool, exitOnWriteFailure bool, passphrase string, fetchDeadline time.Duration) map[string]string {
 	if fallbackOnly {
 		if !enableFallback {
-			utils.HandleError(errors.New("Conflict: unable to specify --no-fallback with --fallback-only"))
+			utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("Conflict: unable to specify --no-fallback with --fallback-only")))
 		}
 		return readFallbackFile(fallbackPath, legacyFallbackPath, passphrase)
 	}
@@ -247,12 +384,19 @@ func fetchSecrets(localConfig models.ScopedOptions, enableCache bool, enableFall
 	// this scenario likely isn't possible, but just to be safe, disable using cache when there's no metadata file
 	enableCache = enableCache && metadataPath != ""
 	etag := ""
+	// the fallback file's contents at the time the etag was read, guaranteeing a consistent pair
+	var cacheContents []byte
 	if enableCache {
-		etag = getCacheFileETag(metadataPath, fallbackPath)
+		etag, cacheContents = getCacheFileETag(metadataPath, fallbackPath)
 	}
 
-	statusCode, respHeaders, response, httpErr := http.DownloadSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, models.JSON, etag)
+	resp, httpErr := requestSecrets(localConfig, etag, fetchDeadline)
+	statusCode, response := resp.StatusCode, resp.Body
 	if !httpErr.IsNil() {
+		// the user canceled the request, so don't silently continue with the fallback file
+		if cliContext.Err() != nil {
+			utils.HandleError(httpErr.Unwrap(), httpErr.Message)
+		}
 		if enableFallback {
 			utils.Log("Unable to fetch secrets from the Doppler API")
 			utils.LogError(httpErr.Unwrap())
@@ -263,7 +407,7 @@ func fetchSecrets(localConfig models.ScopedOptions, enableCache bool, enableFall
 
 	if enableCache && statusCode == 304 {
 		utils.LogDebug("Using cached secrets from fallback file")
-		cache, err := controllers.SecretsCacheFile(fallbackPath, passphrase)
+		cache, err := controllers.DecryptSecretsCache(cacheContents, passphrase)
 		if !err.IsNil() {
 			utils.LogDebugError(err.Unwrap())
 			utils.LogDebug(err.Message)
@@ -272,6 +416,9 @@ func fetchSecrets(localConfig models.ScopedOptions, enableCache bool, enableFall
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
 
+		if !fallbackReadonly {
+			markFallbackFileVerified(fallbackPath, metadataPath)
+		}
 		return cache
 	}
 
@@ -290,47 +437,9 @@ func fetchSecrets(localConfig models.ScopedOptions, enableCache bool, enableFall
 
 	writeFallbackFile := enableFallback && !fallbackReadonly
 	if writeFallbackFile {
-		utils.LogDebug("Encrypting secrets")
-		encryptedResponse, err := crypto.Encrypt(passphrase, response)
-		if err != nil {
+		if err := writeFallbackFiles(localConfig, response, resp.ETag(), enableCache, fallbackPath, legacyFallbackPath, metadataPath, passphrase, exitOnWriteFailure); err != nil {
 			utils.HandleError(err, "Unable to encrypt your secrets. No fallback file has been written.")
 		}
-
-		utils.LogDebug(fmt.Sprintf("Writing to fallback file %s", fallbackPath))
-		if err := utils.WriteFile(fallbackPath, []byte(encryptedResponse), utils.RestrictedFilePerms()); err != nil {
-			utils.Log("Unable to write to fallback file")
-			if exitOnWriteFailure {
-				utils.HandleError(err, "", strings.Join(writeFailureMessage(), "\n"))
-			} else {
-				utils.LogDebugError(err)
-			}
-		}
-
-		// TODO remove this when releasing CLI v4 (DPLR-435)
-		if legacyFallbackPath != "" && localConfig.EnclaveProject.Value != "" && localConfig.EnclaveConfig.Value != "" {
-			utils.LogDebug(fmt.Sprintf("Writing to legacy fallback file %s", legacyFallbackPath))
-			if err := utils.WriteFile(legacyFallbackPath, []byte(encryptedResponse), utils.RestrictedFilePerms()); err != nil {
-				utils.Log("Unable to write to legacy fallback file")
-				if exitOnWriteFailure {
-					utils.HandleError(err, "", strings.Join(writeFailureMessage(), "\n"))
-				} else {
-					utils.LogDebugError(err)
-				}
-			}
-		}
-
-		if enableCache {
-			if etag := respHeaders.Get("etag"); etag != "" {
-				hash := crypto.Hash(encryptedResponse)
-
-				if err := controllers.WriteMetadataFile(metadataPath, etag, hash); !err.IsNil() {
-					utils.LogDebugError(err.Unwrap())
-					utils.LogDebug(err.Message)
-				}
-			} else {
-				utils.LogDebug("API response does not contain ETag")
-			}
-		}
 	}
 
 	return secrets
@@ -373,15 +482,23 @@ func readFallbackFile(path string, legacyPath string, passphrase string) map[str
 				return readFallbackFile(legacyPath, "", passphrase)
 			}
 
-			utils.HandleError(errors.New("The fallback file does not exist"))
+			utils.HandleError(utils.NewError(utils.ErrorKindFallback, errors.New("The fallback file does not exist")))
 		}
 
-		utils.HandleError(err, "Unable to read fallback file")
+		utils.HandleError(utils.NewError(utils.ErrorKindFallback, err), "Unable to read fallback file")
 	}
 
+	lock, lockErr := controllers.LockFallbackFile(path, false)
+	if !lockErr.IsNil() {
+		utils.LogDebugError(lockErr.Unwrap())
+		utils.LogDebug(lockErr.Message)
+	}
 	response, err := ioutil.ReadFile(path) // #nosec G304
+	if unlockErr := lock.Unlock(); unlockErr != nil {
+		utils.LogDebugError(unlockErr)
+	}
 	if err != nil {
-		utils.HandleError(err, "Unable to read fallback file")
+		utils.HandleError(utils.NewError(utils.ErrorKindFallback, err), "Unable to read fallback file")
 	}
 
 	utils.LogDebug("Decrypting fallback file")
@@ -403,23 +520,280 @@ func readFallbackFile(path string, legacyPath string, passphrase string) map[str
 		msg = append(msg, "Run 'doppler run --help' for more info.")
 		msg = append(msg, "")
 
-		utils.HandleError(err, "Unable to decrypt fallback file", strings.Join(msg, "\n"))
+		utils.HandleError(utils.NewError(utils.ErrorKindFallback, err), "Unable to decrypt fallback file", strings.Join(msg, "\n"))
 	}
 
 	secrets, err := parseSecrets([]byte(decryptedSecrets))
 	if err != nil {
-		utils.HandleError(err, "Unable to parse fallback file")
+		utils.HandleError(utils.NewError(utils.ErrorKindFallback, err), "Unable to parse fallback file")
 	}
 
 	return secrets
 }
 
+// requestSecrets fetches secrets from the API. the request is canceled once the deadline is exceeded, if specified.
+func requestSecrets(localConfig models.ScopedOptions, etag string, deadline time.Duration) (http.DownloadSecretsResponse, http.Error) {
+	ctx := cliContext
+	if deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(cliContext, deadline)
+		defer cancel()
+	}
+
+	req := http.DownloadSecretsRequest{Project: localConfig.EnclaveProject.Value, Config: localConfig.EnclaveConfig.Value, Format: models.JSON, ETag: etag}
+	// many instances of the CLI may fetch secrets concurrently (e.g. when spawning processes via 'doppler run'),
+	// so don't keep connections open. this adds a negligible performance penalty as only one request is made
+	resp, err := apiClient(localConfig, http.WithKeepAlives(false)).DownloadSecrets(ctx, req)
+	if !err.IsNil() && ctx.Err() == context.DeadlineExceeded {
+		err.Err = utils.NewError(utils.ErrorKindTimeout, fmt.Errorf("Request exceeded the fetch deadline of %s", deadline))
+	}
+	return resp, err
+}
+
+// writeFallbackFiles encrypts the API response and writes it to the fallback file, along with its metadata.
+// an error is only returned if encryption fails; write failures exit when exitOnWriteFailure is set.
+func writeFallbackFiles(localConfig models.ScopedOptions, response []byte, etag string, enableCache bool, fallbackPath string, legacyFallbackPath string, metadataPath string, passphrase string, exitOnWriteFailure bool) error {
+	utils.LogDebug("Encrypting secrets")
+	encryptedResponse, err := crypto.Encrypt(passphrase, response)
+	if err != nil {
+		return err
+	}
+
+	// hold the lock while writing the fallback and metadata files so other processes never see a mismatched pair
+	lock, lockErr := controllers.LockFallbackFile(fallbackPath, true)
+	if !lockErr.IsNil() {
+		utils.LogDebugError(lockErr.Unwrap())
+		utils.LogDebug(lockErr.Message)
+	}
+
+	utils.LogDebug(fmt.Sprintf("Writing to fallback file %s", fallbackPath))
+	if err := utils.WriteFile(fallbackPath, []byte(encryptedResponse), utils.RestrictedFilePerms()); err != nil {
+		utils.Log("Unable to write to fallback file")
+		if exitOnWriteFailure {
+			utils.HandleError(err, "", strings.Join(writeFailureMessage(), "\n"))
+		} else {
+			utils.LogDebugError(err)
+		}
+	}
+
+	// TODO remove this when releasing CLI v4 (DPLR-435)
+	if legacyFallbackPath != "" && localConfig.EnclaveProject.Value != "" && localConfig.EnclaveConfig.Value != "" {
+		utils.LogDebug(fmt.Sprintf("Writing to legacy fallback file %s", legacyFallbackPath))
+		if err := utils.WriteFile(legacyFallbackPath, []byte(encryptedResponse), utils.RestrictedFilePerms()); err != nil {
+			utils.Log("Unable to write to legacy fallback file")
+			if exitOnWriteFailure {
+				utils.HandleError(err, "", strings.Join(writeFailureMessage(), "\n"))
+			} else {
+				utils.LogDebugError(err)
+			}
+		}
+	}
+
+	if enableCache {
+		if etag != "" {
+			hash := crypto.Hash(encryptedResponse)
+
+			if err := controllers.WriteMetadataFile(metadataPath, etag, hash); !err.IsNil() {
+				utils.LogDebugError(err.Unwrap())
+				utils.LogDebug(err.Message)
+			}
+		} else {
+			utils.LogDebug("API response does not contain ETag")
+		}
+	}
+
+	if err := lock.Unlock(); err != nil {
+		utils.LogDebugError(err)
+	}
+
+	indexEntry := models.FallbackIndexEntry{
+		Path:       fallbackPath,
+		Project:    localConfig.EnclaveProject.Value,
+		Config:     localConfig.EnclaveConfig.Value,
+		TokenScope: localConfig.Token.Scope,
+		ETag:       etag,
+		UpdatedAt:  time.Now(),
+	}
+	if enableCache && etag != "" {
+		indexEntry.MetadataPath = metadataPath
+	}
+	if err := controllers.UpdateFallbackIndex(indexEntry); !err.IsNil() {
+		utils.LogDebugError(err.Unwrap())
+		utils.LogDebug(err.Message)
+	}
+
+	return nil
+}
+
+// backgroundWrite lets the CLI exit without waiting for a background refresh to fetch secrets, while ensuring it doesn't exit
+// partway through writing them
+type backgroundWrite chan struct{}
+
+func newBackgroundWrite() backgroundWrite {
+	return make(chan struct{}, 1)
+}
+
+// begin starts a write, returning false if the CLI is exiting
+func (w backgroundWrite) begin() bool {
+	select {
+	case w <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w backgroundWrite) end() {
+	<-w
+}
+
+// wait waits up to timeout for a write in progress to finish, and prevents new writes from starting
+func (w backgroundWrite) wait(timeout time.Duration) bool {
+	select {
+	case w <- struct{}{}:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// refreshFallbackFile fetches the latest secrets and updates the fallback file. failures are logged rather than causing an exit.
+func refreshFallbackFile(localConfig models.ScopedOptions, enableCache bool, fallbackPath string, legacyFallbackPath string, metadataPath string, passphrase string, fetchDeadline time.Duration, write backgroundWrite) {
+	etag := ""
+	if enableCache {
+		etag, _ = getCacheFileETag(metadataPath, fallbackPath)
+	}
+
+	resp, httpErr := requestSecrets(localConfig, etag, fetchDeadline)
+	if !httpErr.IsNil() {
+		utils.LogDebugError(httpErr.Unwrap())
+		utils.LogDebug("Unable to refresh fallback file")
+		return
+	}
+
+	if !write.begin() {
+		utils.LogDebug("Command exited before the fallback file was refreshed")
+		return
+	}
+	defer write.end()
+
+	if enableCache && resp.StatusCode == 304 {
+		utils.LogDebug("Fallback file is already current")
+		markFallbackFileVerified(fallbackPath, metadataPath)
+		return
+	}
+
+	// ensure the response can be parsed before proceeding
+	if _, err := parseSecrets(resp.Body); err != nil {
+		utils.LogDebugError(err)
+		utils.LogDebug("Unable to parse the Doppler API response")
+		return
+	}
+
+	if err := writeFallbackFiles(localConfig, resp.Body, resp.ETag(), enableCache, fallbackPath, legacyFallbackPath, metadataPath, passphrase, false); err != nil {
+		utils.LogDebugError(err)
+		utils.LogDebug("Unable to encrypt your secrets. No fallback file has been written.")
+	}
+}
+
+// readFreshFallbackFile reads secrets from the fallback file, provided they were verified within the max age (if specified)
+func readFreshFallbackFile(path string, metadataPath string, maxAge time.Duration, passphrase string) (map[string]string, bool) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		utils.LogDebugError(err)
+		return nil, false
+	}
+
+	lock, lockErr := controllers.LockFallbackFile(path, false)
+	if !lockErr.IsNil() {
+		utils.LogDebugError(lockErr.Unwrap())
+		utils.LogDebug(lockErr.Message)
+	}
+	contents, err := ioutil.ReadFile(path) // #nosec G304
+	var verifiedAt time.Time
+	if err == nil {
+		verifiedAt = controllers.FallbackFileVerifiedAt(metadataPath, contents, stat.ModTime())
+	}
+	if unlockErr := lock.Unlock(); unlockErr != nil {
+		utils.LogDebugError(unlockErr)
+	}
+	if err != nil {
+		utils.LogDebugError(err)
+		return nil, false
+	}
+
+	if maxAge > 0 && verifiedAt.Add(maxAge).Before(time.Now()) {
+		utils.LogDebug("Fallback file exceeds the max age, fetching secrets from the Doppler API")
+		return nil, false
+	}
+
+	secrets, Err := controllers.DecryptSecretsCache(contents, passphrase)
+	if !Err.IsNil() {
+		utils.LogDebugError(Err.Unwrap())
+		utils.LogDebug(Err.Message)
+		return nil, false
+	}
+
+	return secrets, true
+}
+
+// markFallbackFileVerified records in the metadata file that the fallback file's secrets are still current
+func markFallbackFileVerified(fallbackPath string, metadataPath string) {
+	if metadata, err := controllers.MetadataFile(metadataPath); err.IsNil() && time.Since(metadata.VerifiedAt) < fallbackVerifiedResolution {
+		utils.LogDebug("Fallback file was recently verified")
+		return
+	}
+
+	lock, lockErr := controllers.LockFallbackFile(fallbackPath, true)
+	if !lockErr.IsNil() {
+		utils.LogDebugError(lockErr.Unwrap())
+		utils.LogDebug(lockErr.Message)
+		return
+	}
+	defer lock.Unlock()
+
+	if err := controllers.MarkMetadataVerified(metadataPath); !err.IsNil() {
+		utils.LogDebugError(err.Unwrap())
+		utils.LogDebug(err.Message)
+	}
+}
+
 func parseSecrets(response []byte) (map[string]string, error) {
 	secrets := map[string]string{}
 	err := json.Unmarshal(response, &secrets)
+	redactSecretsFromLogs(secrets)
 	return secrets, err
 }
 
+// redactSecretsFromLogs prevents the secrets' values from appearing in structured logs
+func redactSecretsFromLogs(secrets map[string]string) {
+	var values []string
+	for name, value := range secrets {
+		if !isConfigIdentifier(name) {
+			values = append(values, value)
+		}
+	}
+	utils.RedactFromLogs(values...)
+}
+
+// stripDopplerEnv removes DOPPLER_ variables from the environment. Secrets with these names are injected afterwards.
+func stripDopplerEnv(env []string) []string {
+	var stripped []string
+	for _, envVar := range env {
+		if strings.HasPrefix(envVar, "DOPPLER_") {
+			utils.LogDebug(fmt.Sprintf("Removing %s from the command's environment", strings.SplitN(envVar, "=", 2)[0]))
+			continue
+		}
+		stripped = append(stripped, envVar)
+	}
+	return stripped
+}
+
+// isConfigIdentifier whether the secret identifies the config, rather than containing a sensitive value
+func isConfigIdentifier(name string) bool {
+	return name == "DOPPLER_PROJECT" || name == "DOPPLER_ENVIRONMENT" || name == "DOPPLER_CONFIG"
+}
+
 // legacyFallbackFile deprecated file path used by early versions of CLI v3
 func legacyFallbackFile(project string, config string) string {
 	name := fmt.Sprintf("%s:%s", project, config)
@@ -472,7 +846,8 @@ func initFallbackDir(cmd *cobra.Command, config models.ScopedOptions, exitOnWrit
 
 		if !utils.Exists(defaultFallbackDir) {
 			err := os.Mkdir(defaultFallbackDir, 0700)
-			if err != nil {
+			// the directory may have been created by a concurrent process
+			if err != nil && !os.IsExist(err) {
 				utils.LogDebug("Unable to create directory for fallback file")
 				if exitOnWriteFailure {
 					utils.HandleError(err, "Unable to create directory for fallback file", strings.Join(writeFailureMessage(), "\n"))
@@ -494,32 +869,40 @@ func initFallbackDir(cmd *cobra.Command, config models.ScopedOptions, exitOnWrit
 	return fallbackPath, legacyFallbackPath
 }
 
-func getCacheFileETag(metadataPath string, cachePath string) string {
+// getCacheFileETag reads the metadata file's etag, along with the contents of the cache file it describes
+func getCacheFileETag(metadataPath string, cachePath string) (string, []byte) {
+	lock, lockErr := controllers.LockFallbackFile(cachePath, false)
+	if !lockErr.IsNil() {
+		utils.LogDebugError(lockErr.Unwrap())
+		utils.LogDebug(lockErr.Message)
+	}
+	defer lock.Unlock()
+
 	metadata, Err := controllers.MetadataFile(metadataPath)
 	if !Err.IsNil() {
 		utils.LogDebugError(Err.Unwrap())
 		utils.LogDebug(Err.Message)
-		return ""
+		return "", nil
+	}
+
+	cacheFileBytes, err := ioutil.ReadFile(cachePath) // #nosec G304
+	if err != nil {
+		utils.LogDebugError(err)
+		return "", nil
 	}
 
 	if metadata.Hash == "" {
-		return metadata.ETag
+		return metadata.ETag, cacheFileBytes
 	}
 
 	// verify hash
-	cacheFileBytes, err := ioutil.ReadFile(cachePath) // #nosec G304
-	if err == nil {
-		cacheFileContents := string(cacheFileBytes)
-		hash := crypto.Hash(cacheFileContents)
-
-		if hash == metadata.Hash {
-			return metadata.ETag
-		}
-
-		utils.LogDebug("Fallback file failed hash check, ignoring cached secrets")
+	hash := crypto.Hash(string(cacheFileBytes))
+	if hash == metadata.Hash {
+		return metadata.ETag, cacheFileBytes
 	}
 
-	return ""
+	utils.LogDebug("Fallback file failed hash check, ignoring cached secrets")
+	return "", nil
 }
 
 func init() {
@@ -529,6 +912,7 @@ func init() {
 	runCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	runCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
 	runCmd.Flags().String("command", "", "command to execute (e.g. \"echo hi\")")
+	runCmd.Flags().Bool("forward-doppler-env", false, "pass DOPPLER_ environment variables (e.g. DOPPLER_TOKEN) from the CLI's environment to the command. by default, they're removed.")
 	runCmd.Flags().Bool("preserve-env", false, "ignore any Doppler secrets that are already defined in the environment. this has potential security implications, use at your own risk.")
 	// fallback flags
 	runCmd.Flags().String("fallback", "", "path to the fallback file. encrypted secrets are written to this file after each successful fetch. secrets will be read from this file if subsequent connections are unsuccessful.")
@@ -539,6 +923,11 @@ func init() {
 	runCmd.Flags().Bool("fallback-readonly", false, "disable modifying the fallback file. secrets can still be read from the file.")
 	runCmd.Flags().Bool("fallback-only", false, "read all secrets directly from the fallback file, without contacting Doppler. secrets will not be updated. (implies --fallback-readonly)")
 	runCmd.Flags().Bool("no-exit-on-write-failure", false, "do not exit if unable to write the fallback file")
+	runCmd.Flags().Bool("offline-first", false, "start the command immediately using secrets from the fallback file, provided it's newer than --max-fallback-age. the fallback file is refreshed in the background for subsequent runs, unless the command exits first.")
+	runCmd.Flags().Duration("max-fallback-age", defaultOfflineFirstMaxAge, "the max age of a fallback file used by --offline-first. set to 0 to allow any age.")
+	runCmd.Flags().Bool("redact", false, "replace secret values in the command's stdout and stderr with ***. the command's output is piped, so it won't detect a terminal (e.g. it may disable colors, prompts, or progress bars).")
+	runCmd.Flags().Bool("redact-encoded", false, "also redact the base64 and URL-encoded forms of secret values (implies --redact)")
+	runCmd.Flags().Duration("fetch-deadline", 0, "the max time to spend fetching secrets, including all retries (unlike --timeout, which applies to each request). when exceeded, secrets are read from the fallback file. set to 0 to disable.")
 
 	// deprecated
 	runCmd.Flags().Bool("silent-exit", false, "disable error output if the supplied command exits non-zero")
@@ -554,5 +943,10 @@ func init() {
 	runCleanCmd.Flags().Duration("max-age", defaultFallbackFileMaxAge, "delete fallback files that exceed this age")
 	runCleanCmd.Flags().Bool("dry-run", false, "do not delete anything, print what would have happened")
 	runCleanCmd.Flags().Bool("all", false, "delete all fallback files")
+	runCleanCmd.Flags().StringP("project", "p", "", "only delete fallback files for this project. --max-age is ignored unless specified.")
+	runCleanCmd.Flags().StringP("config", "c", "", "only delete fallback files for this config. --max-age is ignored unless specified.")
+	runCleanCmd.Flags().Bool("orphaned-metadata", false, "only delete metadata files whose fallback file no longer exists. --max-age is ignored unless specified.")
 	runCmd.AddCommand(runCleanCmd)
+
+	runCmd.AddCommand(runListCmd)
 }
@@ -0,0 +1,506 @@
+/*
+Copyright © 2020 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/crypto"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+const helperProcessEnv = "DOPPLER_CLI_TEST_HELPER_PROCESS"
+
+// TestHelperProcess isn't a real test. It's used as a stand-in for the CLI binary by tests that need to run
+// multiple CLI processes. Arguments following "--" are passed to the root command.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv(helperProcessEnv) != "1" {
+		return
+	}
+
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+
+	rootCmd.SetArgs(args)
+	Execute()
+	os.Exit(0)
+}
+
+func helperCommand(home string, args ...string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], append([]string{"-test.run=TestHelperProcess", "--"}, args...)...) // #nosec G204
+
+	// don't let the user's own config leak into the child process
+	env := []string{fmt.Sprintf("%s=1", helperProcessEnv), fmt.Sprintf("HOME=%s", home)}
+	for _, value := range os.Environ() {
+		if strings.HasPrefix(value, "DOPPLER_") || strings.HasPrefix(value, "HOME=") {
+			continue
+		}
+		env = append(env, value)
+	}
+	cmd.Env = env
+	return cmd
+}
+
+// testHome creates a home directory for helper processes, returning it and its fallback directory
+func testHome(t *testing.T) (string, string) {
+	home, err := ioutil.TempDir("", "doppler-cli-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// store the fallback index key in a file so the test doesn't touch the system keyring
+	fallbackDir := filepath.Join(home, ".doppler", "fallback")
+	if err := os.MkdirAll(fallbackDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(fallbackDir, ".index.key"), []byte("test-key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return home, fallbackDir
+}
+
+func TestConcurrentRunFallbackConsistency(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+
+	home, fallbackDir := testHome(t)
+	defer os.RemoveAll(home)
+
+	// secrets change every few requests so processes frequently race to write different fallback files
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := atomic.AddInt64(&requests, 1) / 3
+		body, _ := json.Marshal(map[string]string{"SECRET": fmt.Sprintf("value-%d", version)})
+		etag := fmt.Sprintf(`"%d"`, version)
+
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	token := "dp.st.test"
+	project := "proj"
+	config := "dev"
+
+	processes := 24
+	var wg sync.WaitGroup
+	errs := make(chan error, processes)
+	for i := 0; i < processes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			cmd := helperCommand(home, "run", "--api-host", server.URL, "--token", token, "--project", project, "--config", config,
+				"--no-check-version", "--command", `printf "%s" "$SECRET"`)
+			output, err := cmd.Output()
+			if err != nil {
+				errs <- fmt.Errorf("run failed: %v %s", err, output)
+				return
+			}
+			if !strings.HasPrefix(string(output), "value-") {
+				errs <- fmt.Errorf("unexpected output %q", output)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	name := crypto.Hash(fmt.Sprintf("%s:%s:%s", token, project, config))
+	fallbackContents, err := ioutil.ReadFile(filepath.Join(fallbackDir, fmt.Sprintf(".secrets-%s.json", name))) // #nosec G304
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadataContents, err := ioutil.ReadFile(filepath.Join(fallbackDir, fmt.Sprintf(".metadata-%s.json", name))) // #nosec G304
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var metadata models.SecretsFileMetadata
+	if err := yaml.Unmarshal(metadataContents, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if hash := crypto.Hash(string(fallbackContents)); hash != metadata.Hash {
+		t.Errorf("Metadata hash %s does not match fallback file hash %s", metadata.Hash, hash)
+	}
+
+	decrypted, err := crypto.Decrypt(fmt.Sprintf("%s:%s:%s", token, project, config), fallbackContents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var secrets map[string]string
+	if err := json.Unmarshal([]byte(decrypted), &secrets); err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprintf("value-%s", strings.Trim(metadata.ETag, `"`)); secrets["SECRET"] != expected {
+		t.Errorf("Metadata ETag %s does not match fallback file contents %s", metadata.ETag, secrets["SECRET"])
+	}
+}
+
+func TestRunCleanKeepsLockFiles(t *testing.T) {
+	home, fallbackDir := testHome(t)
+	defer os.RemoveAll(home)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"SECRET":"value"}`))
+	}))
+	defer server.Close()
+
+	customFallback := filepath.Join(home, "fallback.json")
+	for _, args := range [][]string{{}, {"--fallback", customFallback}} {
+		cmd := helperCommand(home, append([]string{"run", "--api-host", server.URL, "--token", "dp.st.test", "--project", "proj", "--config", "dev",
+			"--no-check-version", "--command", "true"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("run failed: %v %s", err, output)
+		}
+	}
+
+	if _, err := os.Stat(customFallback + ".lock"); !os.IsNotExist(err) {
+		t.Error("Expected no lock file next to the custom fallback file")
+	}
+	locks, err := ioutil.ReadDir(filepath.Join(fallbackDir, "locks"))
+	if err != nil || len(locks) == 0 {
+		t.Fatalf("Expected lock files in the fallback directory: %v", err)
+	}
+
+	cmd := helperCommand(home, "run", "clean", "--max-age", "0s", "--no-check-version")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("clean failed: %v %s", err, output)
+	}
+
+	entries, err := ioutil.ReadDir(fallbackDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".secrets-") {
+			t.Errorf("Expected fallback file %s to be deleted", entry.Name())
+		}
+	}
+	if remaining, _ := ioutil.ReadDir(filepath.Join(fallbackDir, "locks")); len(remaining) != len(locks) {
+		t.Errorf("Expected %d lock files to be kept, got %d", len(locks), len(remaining))
+	}
+}
+
+func TestRunListAndCleanFilters(t *testing.T) {
+	home, fallbackDir := testHome(t)
+	defer os.RemoveAll(home)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// metadata files are only written for responses with an ETag
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"SECRET":"value"}`))
+	}))
+	defer server.Close()
+
+	token := "dp.st.test"
+	for _, config := range []string{"dev", "prd"} {
+		cmd := helperCommand(home, "run", "--api-host", server.URL, "--token", token, "--project", "proj", "--config", config,
+			"--no-check-version", "--command", "true")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("run failed: %v %s", err, output)
+		}
+	}
+
+	listFiles := func() map[string]models.FallbackFile {
+		output, err := helperCommand(home, "run", "list", "--json", "--no-check-version").Output()
+		if err != nil {
+			t.Fatalf("list failed: %v %s", err, output)
+		}
+		var files []models.FallbackFile
+		if err := json.Unmarshal(output, &files); err != nil {
+			t.Fatalf("Unable to parse %s: %v", output, err)
+		}
+		byConfig := map[string]models.FallbackFile{}
+		for _, file := range files {
+			// legacy fallback files aren't associated with a config
+			if file.Config != "" {
+				byConfig[file.Config] = file
+			}
+		}
+		return byConfig
+	}
+
+	files := listFiles()
+	if len(files) != 2 {
+		t.Fatalf("Expected fallback files for 2 configs, got %+v", files)
+	}
+	for _, config := range []string{"dev", "prd"} {
+		if file := files[config]; file.Project != "proj" || !file.HasMetadata {
+			t.Errorf("Unexpected fallback file for %s: %+v", config, file)
+		}
+	}
+
+	// filters ignore the default max age, which the new files don't exceed
+	if output, err := helperCommand(home, "run", "clean", "--config", "dev", "--no-check-version").CombinedOutput(); err != nil {
+		t.Fatalf("clean failed: %v %s", err, output)
+	}
+
+	files = listFiles()
+	if _, ok := files["dev"]; ok || len(files) != 1 {
+		t.Errorf("Expected only the prd fallback file to remain, got %+v", files)
+	}
+	name := crypto.Hash(fmt.Sprintf("%s:%s:%s", token, "proj", "dev"))
+	if _, err := os.Stat(filepath.Join(fallbackDir, fmt.Sprintf(".metadata-%s.json", name))); !os.IsNotExist(err) {
+		t.Error("Expected the dev metadata file to be deleted")
+	}
+}
+
+// secretsServer serves a secret whose value changes when version is incremented. responses include an ETag, so unchanged
+// secrets are confirmed via a 304. requests are delayed while slow is set.
+type secretsServer struct {
+	version  int64
+	requests int64
+	slow     int32
+}
+
+func (s *secretsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&s.requests, 1)
+	if atomic.LoadInt32(&s.slow) == 1 {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		return
+	}
+
+	version := atomic.LoadInt64(&s.version)
+	etag := fmt.Sprintf(`"%d"`, version)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = fmt.Fprintf(w, `{"SECRET":"value-%d"}`, version)
+}
+
+// refreshCommand prints the secret, after giving the background refresh of --offline-first time to complete
+const refreshCommand = `sleep 1; printf "%s" "$SECRET"`
+
+// runSecret runs a command that prints the secret, returning the last line of output
+func runSecret(t *testing.T, home string, serverURL string, args ...string) string {
+	cmd := helperCommand(home, append([]string{"run", "--api-host", serverURL, "--token", "dp.st.test", "--project", "proj", "--config", "dev",
+		"--no-check-version", "--command", `printf "%s" "$SECRET"`}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("run failed: %v %s", err, output)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return lines[len(lines)-1]
+}
+
+// fallbackPaths the default fallback and metadata files of the test config
+func fallbackPaths(fallbackDir string) (string, string) {
+	name := crypto.Hash("dp.st.test:proj:dev")
+	return filepath.Join(fallbackDir, fmt.Sprintf(".secrets-%s.json", name)), filepath.Join(fallbackDir, fmt.Sprintf(".metadata-%s.json", name))
+}
+
+// ageFallbackFile makes the fallback file look like it was last written and verified at the time
+func ageFallbackFile(t *testing.T, fallbackDir string, at time.Time) {
+	fallbackPath, metadataPath := fallbackPaths(fallbackDir)
+	if err := os.Chtimes(fallbackPath, at, at); err != nil {
+		t.Fatal(err)
+	}
+	metadata := readMetadata(t, metadataPath)
+	metadata.VerifiedAt = at
+	contents, err := yaml.Marshal(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(metadataPath, contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readMetadata(t *testing.T, path string) models.SecretsFileMetadata {
+	contents, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metadata models.SecretsFileMetadata
+	if err := yaml.Unmarshal(contents, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	return metadata
+}
+
+func TestRunOfflineFirst(t *testing.T) {
+	home, _ := testHome(t)
+	defer os.RemoveAll(home)
+	server := &secretsServer{version: 1}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	if value := runSecret(t, home, httpServer.URL); value != "value-1" {
+		t.Fatalf("Expected the secret from the API, got %q", value)
+	}
+
+	// the fallback file is used immediately, and refreshed in the background for the next run
+	atomic.StoreInt64(&server.version, 2)
+	if value := runSecret(t, home, httpServer.URL, "--offline-first", "--command", refreshCommand); value != "value-1" {
+		t.Errorf("Expected the secret from the fallback file, got %q", value)
+	}
+	if value := runSecret(t, home, httpServer.URL, "--offline-first", "--command", refreshCommand); value != "value-2" {
+		t.Errorf("Expected the refreshed fallback file, got %q", value)
+	}
+	if requests := atomic.LoadInt64(&server.requests); requests != 3 {
+		t.Errorf("Expected each run to fetch secrets once, got %d requests", requests)
+	}
+}
+
+func TestRunMaxFallbackAge(t *testing.T) {
+	home, fallbackDir := testHome(t)
+	defer os.RemoveAll(home)
+	server := &secretsServer{version: 1}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	runSecret(t, home, httpServer.URL)
+	fallbackPath, metadataPath := fallbackPaths(fallbackDir)
+	if verifiedAt := readMetadata(t, metadataPath).VerifiedAt; time.Since(verifiedAt) > time.Minute {
+		t.Fatalf("Expected the metadata to record when the secrets were fetched, got %s", verifiedAt)
+	}
+
+	// a stale fallback file isn't used, so the secrets are fetched before running the command
+	ageFallbackFile(t, fallbackDir, time.Now().Add(-2*time.Hour))
+	atomic.StoreInt64(&server.version, 2)
+	if value := runSecret(t, home, httpServer.URL, "--offline-first", "--max-fallback-age", "1h"); value != "value-2" {
+		t.Errorf("Expected the secret from the API, got %q", value)
+	}
+
+	// any age is allowed when the max age is 0
+	ageFallbackFile(t, fallbackDir, time.Now().Add(-2*time.Hour))
+	atomic.StoreInt64(&server.version, 3)
+	if value := runSecret(t, home, httpServer.URL, "--offline-first", "--max-fallback-age", "0", "--command", refreshCommand); value != "value-2" {
+		t.Errorf("Expected the secret from the fallback file, got %q", value)
+	}
+
+	// secrets confirmed via a 304 are fresh again, without modifying the fallback file
+	old := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	ageFallbackFile(t, fallbackDir, old)
+	if value := runSecret(t, home, httpServer.URL); value != "value-3" {
+		t.Fatalf("Expected the cached secret, got %q", value)
+	}
+	if verifiedAt := readMetadata(t, metadataPath).VerifiedAt; time.Since(verifiedAt) > time.Minute {
+		t.Errorf("Expected the 304 to be recorded in the metadata, got %s", verifiedAt)
+	}
+	if stat, err := os.Stat(fallbackPath); err != nil || !stat.ModTime().Equal(old) {
+		t.Errorf("Expected the fallback file's modification time to be unchanged: %v", err)
+	}
+	requests := atomic.LoadInt64(&server.requests)
+	atomic.StoreInt64(&server.version, 4)
+	if value := runSecret(t, home, httpServer.URL, "--offline-first", "--max-fallback-age", "1h", "--command", refreshCommand); value != "value-3" {
+		t.Errorf("Expected the verified fallback file to be used, got %q", value)
+	}
+	if atomic.LoadInt64(&server.requests) != requests+1 {
+		t.Error("Expected only the background refresh to fetch secrets")
+	}
+}
+
+func TestRunOfflineFirstDoesNotWaitForRefresh(t *testing.T) {
+	home, _ := testHome(t)
+	defer os.RemoveAll(home)
+	server := &secretsServer{version: 1}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	runSecret(t, home, httpServer.URL)
+
+	// the command exits without waiting for the background refresh to fetch secrets
+	atomic.StoreInt32(&server.slow, 1)
+	start := time.Now()
+	if value := runSecret(t, home, httpServer.URL, "--offline-first"); value != "value-1" {
+		t.Errorf("Expected the secret from the fallback file, got %q", value)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the run to exit without waiting for the refresh, took %s", elapsed)
+	}
+}
+
+func TestRunRecentlyVerified(t *testing.T) {
+	home, fallbackDir := testHome(t)
+	defer os.RemoveAll(home)
+	server := &secretsServer{version: 1}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	runSecret(t, home, httpServer.URL)
+	_, metadataPath := fallbackPaths(fallbackDir)
+	verifiedAt := readMetadata(t, metadataPath).VerifiedAt
+
+	// confirming the secrets again right away doesn't rewrite the metadata file
+	runSecret(t, home, httpServer.URL)
+	if !readMetadata(t, metadataPath).VerifiedAt.Equal(verifiedAt) {
+		t.Error("Expected the metadata file not to be rewritten")
+	}
+}
+
+func TestRunFetchDeadline(t *testing.T) {
+	home, _ := testHome(t)
+	defer os.RemoveAll(home)
+	server := &secretsServer{version: 1}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	runSecret(t, home, httpServer.URL)
+
+	// the fallback file is used once the deadline passes, rather than waiting for the API
+	atomic.StoreInt32(&server.slow, 1)
+	start := time.Now()
+	if value := runSecret(t, home, httpServer.URL, "--fetch-deadline", "200ms"); value != "value-1" {
+		t.Errorf("Expected the secret from the fallback file, got %q", value)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Expected the fetch to stop at the deadline, took %s", elapsed)
+	}
+
+	// without a fallback file, exceeding the deadline is an error
+	cmd := helperCommand(home, "run", "--api-host", httpServer.URL, "--token", "dp.st.test", "--project", "proj", "--config", "dev",
+		"--no-check-version", "--fetch-deadline", "200ms", "--no-fallback", "--command", "true")
+	if output, err := cmd.CombinedOutput(); err == nil {
+		t.Errorf("Expected the run to fail, got %s", output)
+	}
+}
@@ -108,6 +108,25 @@ $ doppler secrets download --format=env --no-file`,
 	Run:  downloadSecrets,
 }
 
+var secretsDecryptCmd = &cobra.Command{
+	Use:   "decrypt [filepath]",
+	Short: "Decrypt a secrets file or fallback file",
+	Long: `Decrypt a secrets file written by 'doppler secrets download' or a fallback file written by 'doppler run'.
+
+When no file is specified, the fallback file for your current configuration is used.
+The file can be decrypted without contacting Doppler.`,
+	Example: `Print the fallback file of your current configuration in env format
+$ doppler secrets decrypt --format=env
+
+Decrypt a file downloaded with a custom passphrase
+$ doppler secrets decrypt /root/secrets.json --passphrase=123
+
+View the fallback file's age, size, and whether it matches its metadata file
+$ doppler secrets decrypt --metadata`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  decryptSecrets,
+}
+
 func secrets(cmd *cobra.Command, args []string) {
 	jsonFlag := utils.OutputJSON
 	raw := utils.GetBoolFlag(cmd, "raw")
@@ -116,7 +135,7 @@ func secrets(cmd *cobra.Command, args []string) {
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
-	response, err := http.GetSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
+	response, err := apiClient(localConfig).GetSecrets(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -124,6 +143,7 @@ func secrets(cmd *cobra.Command, args []string) {
 	if parseErr != nil {
 		utils.HandleError(parseErr, "Unable to parse API response")
 	}
+	redactComputedSecretsFromLogs(secrets)
 
 	if onlyNames {
 		printer.SecretsNames(secrets, jsonFlag)
@@ -141,7 +161,7 @@ func getSecrets(cmd *cobra.Command, args []string) {
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
-	response, err := http.GetSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
+	response, err := apiClient(localConfig).GetSecrets(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -149,10 +169,22 @@ func getSecrets(cmd *cobra.Command, args []string) {
 	if parseErr != nil {
 		utils.HandleError(parseErr, "Unable to parse API response")
 	}
+	redactComputedSecretsFromLogs(secrets)
 
 	printer.Secrets(secrets, args, jsonFlag, plain, raw, copy)
 }
 
+// redactComputedSecretsFromLogs prevents the secrets' raw and computed values from appearing in structured logs
+func redactComputedSecretsFromLogs(secrets map[string]models.ComputedSecret) {
+	var values []string
+	for name, secret := range secrets {
+		if !isConfigIdentifier(name) {
+			values = append(values, secret.RawValue, secret.ComputedValue)
+		}
+	}
+	utils.RedactFromLogs(values...)
+}
+
 func setSecrets(cmd *cobra.Command, args []string) {
 	jsonFlag := utils.OutputJSON
 	raw := utils.GetBoolFlag(cmd, "raw")
@@ -182,7 +214,7 @@ func setSecrets(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	response, err := http.SetSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, secrets)
+	response, err := apiClient(localConfig).SetSecrets(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, secrets)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
@@ -214,7 +246,7 @@ func uploadSecrets(cmd *cobra.Command, args []string) {
 		utils.HandleError(err, "Unable to read upload file")
 	}
 
-	response, httpErr := http.UploadSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, string(file))
+	response, httpErr := apiClient(localConfig).UploadSecrets(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, string(file))
 	if !httpErr.IsNil() {
 		utils.HandleError(httpErr.Unwrap(), httpErr.Message)
 	}
@@ -238,7 +270,7 @@ func deleteSecrets(cmd *cobra.Command, args []string) {
 			secrets[arg] = nil
 		}
 
-		response, err := http.SetSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, secrets)
+		response, err := apiClient(localConfig).SetSecrets(cliContext, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, secrets)
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
@@ -269,28 +301,12 @@ func downloadSecrets(cmd *cobra.Command, args []string) {
 	}
 
 	if formatString != "" {
-		isValid := false
-
-		for _, val := range models.SecretsFormatList {
-			if val.String() == formatString {
-				format = val
-				isValid = true
-				break
-			}
-		}
-
-		if !isValid {
-			validFormatList := []string{}
-			for _, format := range models.SecretsFormatList {
-				validFormatList = append(validFormatList, format.String())
-			}
-			utils.HandleError(fmt.Errorf("invalid format. Valid formats are %s", strings.Join(validFormatList, ", ")))
-		}
+		format = parseSecretsFormat(formatString)
 	}
 
 	fallbackPassphrase := getPassphrase(cmd, "fallback-passphrase", localConfig)
 	if fallbackPassphrase == "" {
-		utils.HandleError(errors.New("invalid fallback file passphrase"))
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("invalid fallback file passphrase")))
 	}
 
 	var body []byte
@@ -304,7 +320,7 @@ func downloadSecrets(cmd *cobra.Command, args []string) {
 		if enableCache {
 			metadataPath = controllers.MetadataFilePath(localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
 		}
-		secrets := fetchSecrets(localConfig, enableCache, enableFallback, fallbackPath, legacyFallbackPath, metadataPath, fallbackReadonly, fallbackOnly, exitOnWriteFailure, fallbackPassphrase)
+		secrets := fetchSecrets(localConfig, enableCache, enableFallback, fallbackPath, legacyFallbackPath, metadataPath, fallbackReadonly, fallbackOnly, exitOnWriteFailure, fallbackPassphrase, 0)
 
 		var err error
 		body, err = json.Marshal(secrets)
@@ -322,11 +338,12 @@ func downloadSecrets(cmd *cobra.Command, args []string) {
 			}
 		}
 
-		var apiError http.Error
-		_, _, body, apiError = http.DownloadSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, format, "")
+		req := http.DownloadSecretsRequest{Project: localConfig.EnclaveProject.Value, Config: localConfig.EnclaveConfig.Value, Format: format}
+		resp, apiError := apiClient(localConfig).DownloadSecrets(cliContext, req)
 		if !apiError.IsNil() {
 			utils.HandleError(apiError.Unwrap(), apiError.Message)
 		}
+		body = resp.Body
 	}
 
 	if !saveFile {
@@ -349,7 +366,7 @@ func downloadSecrets(cmd *cobra.Command, args []string) {
 
 	passphrase := getPassphrase(cmd, "passphrase", localConfig)
 	if passphrase == "" {
-		utils.HandleError(errors.New("invalid passphrase"))
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("invalid passphrase")))
 	}
 
 	encryptedBody, err := crypto.Encrypt(passphrase, body)
@@ -364,6 +381,98 @@ func downloadSecrets(cmd *cobra.Command, args []string) {
 	utils.Log(fmt.Sprintf("Downloaded secrets to %s", filePath))
 }
 
+func decryptSecrets(cmd *cobra.Command, args []string) {
+	jsonFlag := utils.OutputJSON
+	showMetadata := utils.GetBoolFlag(cmd, "metadata")
+	localConfig := configuration.LocalConfig(cmd)
+
+	var filePath string
+	metadataPath := ""
+	if len(args) > 0 {
+		var err error
+		filePath, err = utils.GetFilePath(args[0])
+		if err != nil {
+			utils.HandleError(err, "Unable to parse secrets file path")
+		}
+	} else {
+		// default to the fallback file of the current configuration
+		utils.RequireValue("token", localConfig.Token.Value)
+		filePath = defaultFallbackFile(localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
+		metadataPath = controllers.MetadataFilePath(localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
+	}
+
+	if cmd.Flags().Changed("metadata-file") {
+		var err error
+		metadataPath, err = utils.GetFilePath(cmd.Flag("metadata-file").Value.String())
+		if err != nil {
+			utils.HandleError(err, "Unable to parse metadata file path")
+		}
+	}
+
+	if showMetadata {
+		info, err := controllers.SecretsFileInfo(filePath, metadataPath)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+
+		printer.SecretsFileInfo(info, jsonFlag)
+		return
+	}
+
+	if !cmd.Flags().Changed("passphrase") {
+		utils.RequireValue("token", localConfig.Token.Value)
+	}
+	passphrase := getPassphrase(cmd, "passphrase", localConfig)
+	if passphrase == "" {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("invalid passphrase")))
+	}
+
+	decrypted, err := controllers.DecryptSecretsFile(filePath, passphrase)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+
+	formatChanged := cmd.Flags().Changed("format")
+	format := models.JSON
+	if formatChanged {
+		format = parseSecretsFormat(cmd.Flag("format").Value.String())
+	}
+
+	secrets, parseErr := parseSecrets([]byte(decrypted))
+	if parseErr != nil {
+		// files written by 'secrets download' may already be in env or yaml format
+		if formatChanged {
+			utils.HandleError(parseErr, "Unable to convert secrets file. Only files saved in json format can be converted.")
+		}
+		utils.LogDebug("Secrets file is not in json format, printing contents as-is")
+		fmt.Println(decrypted)
+		return
+	}
+
+	body, err := controllers.FormatSecrets(secrets, format)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+
+	fmt.Println(string(body))
+}
+
+// parseSecretsFormat parses a secrets format, exiting if the format is invalid
+func parseSecretsFormat(value string) models.SecretsFormat {
+	for _, format := range models.SecretsFormatList {
+		if format.String() == value {
+			return format
+		}
+	}
+
+	validFormatList := []string{}
+	for _, format := range models.SecretsFormatList {
+		validFormatList = append(validFormatList, format.String())
+	}
+	utils.HandleError(utils.NewError(utils.ErrorKindUsage, fmt.Errorf("invalid format. Valid formats are %s", strings.Join(validFormatList, ", "))))
+	return models.JSON
+}
+
 func init() {
 	secretsCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	secretsCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
@@ -408,5 +517,13 @@ func init() {
 	secretsDownloadCmd.Flags().Bool("no-exit-on-write-failure", false, "do not exit if unable to write the fallback file")
 	secretsCmd.AddCommand(secretsDownloadCmd)
 
+	secretsDecryptCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
+	secretsDecryptCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
+	secretsDecryptCmd.Flags().String("format", models.JSON.String(), "output format. one of [json, env, yaml]")
+	secretsDecryptCmd.Flags().String("passphrase", "", "passphrase used to encrypt the file. the default passphrase is computed using your current configuration.")
+	secretsDecryptCmd.Flags().Bool("metadata", false, "print info about the file instead of its contents, including whether it matches its metadata file")
+	secretsDecryptCmd.Flags().String("metadata-file", "", "path to the metadata file. defaults to the metadata file of the current configuration's fallback file.")
+	secretsCmd.AddCommand(secretsDecryptCmd)
+
 	rootCmd.AddCommand(secretsCmd)
 }
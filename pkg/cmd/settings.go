@@ -19,7 +19,6 @@ import (
 	"errors"
 
 	"github.com/DopplerHQ/cli/pkg/configuration"
-	"github.com/DopplerHQ/cli/pkg/http"
 	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
@@ -36,7 +35,7 @@ var settingsCmd = &cobra.Command{
 
 		utils.RequireValue("token", localConfig.Token.Value)
 
-		info, err := http.GetWorkplaceSettings(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value)
+		info, err := apiClient(localConfig).GetWorkplaceSettings(cliContext)
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
@@ -73,7 +72,7 @@ var settingsUpdateCmd = &cobra.Command{
 
 		settings := models.WorkplaceSettings{Name: name, BillingEmail: email}
 
-		info, err := http.SetWorkplaceSettings(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, settings)
+		info, err := apiClient(localConfig).SetWorkplaceSettings(cliContext, settings)
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
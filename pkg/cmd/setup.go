@@ -22,7 +22,6 @@ import (
 
 	"github.com/DopplerHQ/cli/pkg/configuration"
 	"github.com/DopplerHQ/cli/pkg/controllers"
-	"github.com/DopplerHQ/cli/pkg/http"
 	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
@@ -85,7 +84,7 @@ func setup(cmd *cobra.Command, args []string) {
 			break
 		}
 
-		projects, httpErr := http.GetProjects(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value)
+		projects, httpErr := apiClient(localConfig).GetProjects(cliContext)
 		if !httpErr.IsNil() {
 			utils.HandleError(httpErr.Unwrap(), httpErr.Message)
 		}
@@ -120,7 +119,7 @@ func setup(cmd *cobra.Command, args []string) {
 			break
 		}
 
-		configs, apiError := http.GetConfigs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, selectedProject)
+		configs, apiError := apiClient(localConfig).GetConfigs(cliContext, selectedProject)
 		if !apiError.IsNil() {
 			utils.HandleError(apiError.Unwrap(), apiError.Message)
 		}
@@ -184,7 +183,7 @@ func selectProject(projects []models.ProjectInfo, prevConfiguredProject string,
 	}
 
 	if !promptUser {
-		utils.HandleError(errors.New("project must be specified via --project flag, DOPPLER_PROJECT environment variable, or repo config file when using --no-prompt"))
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("project must be specified via --project flag, DOPPLER_PROJECT environment variable, or repo config file when using --no-prompt")))
 	}
 
 	selectedProject := utils.SelectPrompt("Select a project:", options, defaultOption)
@@ -220,7 +219,7 @@ func selectConfig(configs []models.ConfigInfo, selectedConfiguredProject bool, p
 	}
 
 	if !promptUser {
-		utils.HandleError(errors.New("config must be specified via --config flag, DOPPLER_CONFIG environment variable, or repo config file when using --no-prompt"))
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, errors.New("config must be specified via --config flag, DOPPLER_CONFIG environment variable, or repo config file when using --no-prompt")))
 	}
 
 	selectedConfig := utils.SelectPrompt("Select a config:", options, defaultOption)
@@ -0,0 +1,257 @@
+/*
+Copyright © 2020 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/configuration"
+	"github.com/DopplerHQ/cli/pkg/controllers"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+const defaultShellHookMaxAge = 5 * time.Minute
+const defaultShellHookFetchDeadline = 5 * time.Second
+
+var shellHookCmd = &cobra.Command{
+	Use:   "shell-hook [bash|zsh|fish]",
+	Short: "Load secrets into your shell when changing directories",
+	Long: `Print a hook that loads secrets into your shell whenever you change directories.
+
+Secrets are loaded for the project and config of the current directory, and the
+secrets from the previous directory are unset. Secrets are only loaded for directories
+you've allowed; you'll be asked the first time you enter a new directory.
+
+Add the hook to your shell's startup file:
+
+bash (~/.bashrc):
+  eval "$(doppler shell-hook bash)"
+
+zsh (~/.zshrc):
+  eval "$(doppler shell-hook zsh)"
+
+fish (~/.config/fish/config.fish):
+  doppler shell-hook fish | source`,
+	Args:      cobra.ExactValidArgs(1),
+	ValidArgs: controllers.ShellHookShells,
+	Run: func(cmd *cobra.Command, args []string) {
+		executable, err := os.Executable()
+		if err != nil {
+			utils.HandleError(err, "Unable to determine the path of the Doppler CLI")
+		}
+
+		script, Err := controllers.ShellHookScript(args[0], executable)
+		if !Err.IsNil() {
+			utils.HandleError(Err.Unwrap(), Err.Message)
+		}
+
+		fmt.Print(script)
+	},
+}
+
+var shellHookExportCmd = &cobra.Command{
+	Use:       "export [bash|zsh|fish]",
+	Short:     "Print the shell commands that load secrets for the current directory",
+	Hidden:    true,
+	Args:      cobra.ExactValidArgs(1),
+	ValidArgs: controllers.ShellHookShells,
+	Run: func(cmd *cobra.Command, args []string) {
+		shell := args[0]
+
+		// the shell evaluates everything written to stdout, so send all other output to stderr
+		stdout := os.Stdout
+		os.Stdout = os.Stderr
+
+		// secrets previously exported by the hook must not be mistaken for configuration (e.g. DOPPLER_PROJECT)
+		var previousKeys []string
+		if keys := os.Getenv(controllers.ShellHookKeysEnv); keys != "" {
+			previousKeys = strings.Split(keys, ",")
+		}
+		for _, key := range previousKeys {
+			if err := os.Unsetenv(key); err != nil {
+				utils.LogDebugError(err)
+			}
+		}
+
+		localConfig := configuration.LocalConfig(cmd)
+		scope := shellHookScope(localConfig)
+
+		// the state tracks whether the directory was allowed so that allowing it later causes the secrets to load
+		state := ""
+		allowed := configuration.ShellHookAllowed(scope)
+		if localConfig.Token.Value != "" {
+			state = shellHookState(scope, localConfig, allowed)
+		}
+		previousState := os.Getenv(controllers.ShellHookScopeEnv)
+		if state == previousState {
+			utils.LogDebug("Scope has not changed")
+			return
+		}
+
+		secrets := map[string]string{}
+		if state != "" {
+			// only ask once when entering a directory, rather than before every prompt
+			if !allowed && previousState != shellHookState(scope, localConfig, false) && promptShellHookAllowed(scope) {
+				allowed = true
+				state = shellHookState(scope, localConfig, allowed)
+			}
+
+			if allowed {
+				secrets = shellHookSecrets(cmd, localConfig)
+			} else {
+				utils.Log(fmt.Sprintf("Doppler secrets were not loaded. Run 'doppler shell-hook allow %s' to load them.", scope))
+			}
+		}
+
+		exports, Err := controllers.ShellHookExports(shell, state, previousKeys, secrets)
+		if !Err.IsNil() {
+			utils.HandleError(Err.Unwrap(), Err.Message)
+		}
+
+		fmt.Fprint(stdout, exports)
+	},
+}
+
+var shellHookAllowCmd = &cobra.Command{
+	Use:   "allow [directory]",
+	Short: "Allow the shell hook to load secrets in a directory",
+	Long: `Allow the shell hook to load secrets in a directory and its subdirectories.
+
+Defaults to the directory where the current project and config are set up.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := shellHookScope(configuration.LocalConfig(cmd))
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		configuration.AllowShellHook(dir)
+		utils.Log(fmt.Sprintf("Allowed loading secrets in %s", dir))
+	},
+}
+
+var shellHookDenyCmd = &cobra.Command{
+	Use:   "deny [directory]",
+	Short: "Stop the shell hook from loading secrets in a directory",
+	Long: `Remove a directory from the shell hook's allowed directories.
+
+Defaults to the directory where the current project and config are set up.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := shellHookScope(configuration.LocalConfig(cmd))
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		if !configuration.DisallowShellHook(dir) {
+			utils.HandleError(errors.New("Directory is not allowed"), fmt.Sprintf("Allowed directories: %s", strings.Join(configuration.ShellHookAllowList(), ", ")))
+		}
+		utils.Log(fmt.Sprintf("Stopped loading secrets in %s", dir))
+	},
+}
+
+// shellHookScope the directory whose configuration provides the token, project, and config
+func shellHookScope(localConfig models.ScopedOptions) string {
+	scope := ""
+	for _, option := range []models.ScopedOption{localConfig.Token, localConfig.EnclaveProject, localConfig.EnclaveConfig} {
+		if len(option.Scope) > len(scope) {
+			scope = option.Scope
+		}
+	}
+
+	if scope == "" {
+		return configuration.Scope
+	}
+	return scope
+}
+
+// shellHookState identifies the secrets loaded into the shell
+func shellHookState(scope string, localConfig models.ScopedOptions, allowed bool) string {
+	return fmt.Sprintf("%s:%s:%s:%t", scope, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, allowed)
+}
+
+// promptShellHookAllowed asks the user whether secrets may be loaded for the scope, when possible
+func promptShellHookAllowed(scope string) bool {
+	if !utils.IsTerminal(os.Stdin) || !utils.IsTerminal(os.Stderr) {
+		return false
+	}
+
+	if !utils.ConfirmationPromptStderr(fmt.Sprintf("Load Doppler secrets into your shell for %s?", scope), false) {
+		return false
+	}
+
+	configuration.AllowShellHook(scope)
+	return true
+}
+
+// shellHookSecrets fetches the secrets to export, preferring a recent fallback file for speed
+func shellHookSecrets(cmd *cobra.Command, localConfig models.ScopedOptions) map[string]string {
+	fallbackPath, legacyFallbackPath := initFallbackDir(cmd, localConfig, false)
+	metadataPath := controllers.MetadataFilePath(localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value)
+	passphrase := getPassphrase(cmd, "passphrase", localConfig)
+
+	maxAge := utils.GetDurationFlag(cmd, "max-fallback-age")
+	secrets, ok := readFreshFallbackFile(fallbackPath, metadataPath, maxAge, passphrase)
+	if !ok {
+		fetchDeadline := utils.GetDurationFlag(cmd, "fetch-deadline")
+		secrets = fetchSecrets(localConfig, true, true, fallbackPath, legacyFallbackPath, metadataPath, false, false, false, passphrase, fetchDeadline)
+	}
+
+	excluded := map[string]bool{}
+	for _, name := range excludedSecretNames {
+		excluded[name] = true
+	}
+
+	filtered := map[string]string{}
+	for name, value := range secrets {
+		if excluded[name] {
+			continue
+		}
+
+		if err := controllers.ValidShellHookSecretName(name); err != nil {
+			utils.LogDebug(fmt.Sprintf("Ignoring Doppler secret %s: %s", name, err))
+			continue
+		}
+
+		// never overwrite variables the user defined. variables previously exported by the hook have already been unset.
+		if _, exists := os.LookupEnv(name); exists {
+			utils.LogDebug(fmt.Sprintf("Ignoring Doppler secret %s, it's already defined in the environment", name))
+			continue
+		}
+
+		filtered[name] = value
+	}
+
+	return filtered
+}
+
+func init() {
+	shellHookExportCmd.Flags().Duration("max-fallback-age", defaultShellHookMaxAge, "use the fallback file without contacting Doppler when it's newer than this age")
+	shellHookExportCmd.Flags().Duration("fetch-deadline", defaultShellHookFetchDeadline, "the max time to spend fetching secrets before using the fallback file")
+	shellHookExportCmd.Flags().String("passphrase", "", "passphrase to use for encrypting the fallback file. the default passphrase is computed using your current configuration.")
+	shellHookCmd.AddCommand(shellHookExportCmd)
+
+	shellHookCmd.AddCommand(shellHookAllowCmd)
+	shellHookCmd.AddCommand(shellHookDenyCmd)
+
+	rootCmd.AddCommand(shellHookCmd)
+}
@@ -17,25 +17,59 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/DopplerHQ/cli/pkg/configuration"
 	"github.com/DopplerHQ/cli/pkg/controllers"
 	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/DopplerHQ/cli/pkg/version"
 	"github.com/spf13/cobra"
 )
 
 var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "update the Doppler CLI",
-	Args:  cobra.NoArgs,
+	Long: `Update the Doppler CLI to the latest version.
+
+The release is downloaded from GitHub, and its checksum and signature are verified before it's installed.
+The previous version is kept, and can be restored via --rollback.
+
+Updates are installed from the stable channel by default. Use --channel beta to also receive prereleases;
+the channel is saved and used by future updates. Use --version to install a specific version, including older ones.
+The version is pinned, so you won't be prompted to update, until you run 'doppler update' without --version.`,
+	Example: `doppler update
+doppler update --channel beta
+doppler update --version v3.20.0`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		if utils.IsWindows() {
-			utils.HandleError(fmt.Errorf("this command is not yet implemented for your operating system"))
+		if utils.GetBoolFlag(cmd, "rollback") {
+			rollbackCLIUpdate()
+			return
+		}
+
+		if cmd.Flags().Changed("channel") {
+			channel := cmd.Flag("channel").Value.String()
+			if !version.IsValidChannel(channel) {
+				utils.HandleError(utils.NewError(utils.ErrorKindUsage, fmt.Errorf("Invalid channel %s", channel)), fmt.Sprintf("Valid channels are %s", strings.Join(version.ReleaseChannels, ", ")))
+			}
+			configuration.SetUpdateChannel(channel)
+			utils.LogDebug(fmt.Sprintf("Using the %s update channel", channel))
 		}
 
 		force := utils.GetBoolFlag(cmd, "force")
-		available, _, err := controllers.NewVersionAvailable(models.VersionCheck{})
+		if pinnedVersion := cmd.Flag("version").Value.String(); pinnedVersion != "" {
+			installPinnedCLIVersion(pinnedVersion, force)
+			return
+		}
+
+		if pinnedVersion := configuration.PinnedVersion(); pinnedVersion != "" {
+			configuration.SetPinnedVersion("")
+			utils.Log(fmt.Sprintf("Unpinned CLI version %s", pinnedVersion))
+		}
+
+		available, versionCheck, err := controllers.NewVersionAvailable(cliContext, models.VersionCheck{}, configuration.UpdateChannel())
 		if err != nil {
 			utils.HandleError(err, "Unable to check for CLI updates")
 		}
@@ -49,13 +83,44 @@ var updateCmd = &cobra.Command{
 			}
 		}
 
-		installCLIUpdate()
+		installCLIUpdate(versionCheck.LatestVersion)
 	},
 }
 
-func installCLIUpdate() {
+// installPinnedCLIVersion installs the specified version, which may be older than the current version, and pins it
+func installPinnedCLIVersion(pinnedVersion string, force bool) {
+	targetVersion, err := version.ParseVersion(version.Normalize(pinnedVersion))
+	if err != nil {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, err), "Invalid version")
+	}
+
+	if currentVersion, err := version.ParseVersion(version.ProgramVersion); err == nil {
+		switch version.CompareVersions(currentVersion, targetVersion) {
+		case 0:
+			if !force {
+				configuration.SetPinnedVersion(targetVersion.String())
+				utils.Log(fmt.Sprintf("You are already running %s", targetVersion.String()))
+				return
+			}
+			utils.Log(fmt.Sprintf("Already running %s but proceeding anyway due to --force flag", targetVersion.String()))
+		case -1:
+			utils.Log(fmt.Sprintf("Downgrading from %s to %s", currentVersion.String(), targetVersion.String()))
+		}
+	}
+
+	utils.Log("Updating...")
+	_, installedVersion, controllerErr := controllers.InstallUpdate(cliContext, targetVersion.String())
+	if !controllerErr.IsNil() {
+		utils.HandleError(controllerErr.Unwrap(), controllerErr.Message)
+	}
+	configuration.SetPinnedVersion(targetVersion.String())
+	utils.Log(fmt.Sprintf("Installed CLI %s", installedVersion))
+	utils.Log("Updates won't be offered while this version is pinned. Run 'doppler update' to unpin it and install the latest version.")
+}
+
+func installCLIUpdate(latestVersion string) {
 	utils.Log("Updating...")
-	wasUpdated, installedVersion, controllerErr := controllers.RunInstallScript()
+	wasUpdated, installedVersion, controllerErr := controllers.InstallUpdate(cliContext, latestVersion)
 	if !controllerErr.IsNil() {
 		utils.HandleError(controllerErr.Unwrap(), controllerErr.Message)
 	}
@@ -63,7 +128,7 @@ func installCLIUpdate() {
 	if wasUpdated {
 		utils.Log(fmt.Sprintf("Installed CLI %s", installedVersion))
 
-		if changes, apiError := controllers.CLIChangeLog(); apiError.IsNil() {
+		if changes, apiError := controllers.CLIChangeLog(cliContext); apiError.IsNil() {
 			utils.Log("\nWhat's new:")
 			printer.ChangeLog(changes, 1, false)
 			utils.Log("\nTip: run 'doppler changelog' to see all latest changes")
@@ -76,7 +141,17 @@ func installCLIUpdate() {
 
 }
 
+func rollbackCLIUpdate() {
+	if controllerErr := controllers.RollbackUpdate(); !controllerErr.IsNil() {
+		utils.HandleError(controllerErr.Unwrap(), controllerErr.Message)
+	}
+	utils.Log("Restored the previous version of the CLI")
+}
+
 func init() {
 	updateCmd.Flags().BoolP("force", "f", false, "install the latest CLI regardless of whether there's an update available")
+	updateCmd.Flags().String("version", "", "install and pin the specified CLI version (e.g. v3.20.0), even if it's older than the current version")
+	updateCmd.Flags().String("channel", "", fmt.Sprintf("the release channel to install updates from and use for future updates (%s)", strings.Join(version.ReleaseChannels, ", ")))
+	updateCmd.Flags().Bool("rollback", false, "restore the version of the CLI that was replaced by the last update")
 	rootCmd.AddCommand(updateCmd)
 }
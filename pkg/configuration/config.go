@@ -16,6 +16,7 @@ limitations under the License.
 package configuration
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -28,6 +29,7 @@ import (
 	"github.com/DopplerHQ/cli/pkg/controllers"
 	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/DopplerHQ/cli/pkg/version"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
@@ -60,7 +62,7 @@ func Setup() {
 	if !utils.Exists(UserConfigDir) {
 		utils.LogDebug(fmt.Sprintf("Creating the config directory %s", UserConfigDir))
 		err := os.Mkdir(UserConfigDir, 0700)
-		if err != nil {
+		if err != nil && !os.IsExist(err) {
 			utils.HandleError(err, fmt.Sprintf("Unable to create config directory %s", UserConfigDir))
 		}
 	}
@@ -107,8 +109,116 @@ func SetVersionCheck(version models.VersionCheck) {
 	writeConfig(configContents)
 }
 
+// UpdateChannel the release channel that CLI updates are installed from
+func UpdateChannel() string {
+	if configContents.UpdateChannel == "" {
+		return version.StableChannel
+	}
+	return configContents.UpdateChannel
+}
+
+// SetUpdateChannel the release channel that CLI updates are installed from
+func SetUpdateChannel(channel string) {
+	if channel == UpdateChannel() {
+		return
+	}
+	configContents.UpdateChannel = channel
+	// the last version check was for the previous channel
+	configContents.VersionCheck = models.VersionCheck{}
+	writeConfig(configContents)
+}
+
+// PinnedVersion the CLI version installed via 'doppler update --version', if any. updates aren't offered while a version is pinned.
+func PinnedVersion() string {
+	return configContents.PinnedVersion
+}
+
+// SetPinnedVersion the CLI version installed via 'doppler update --version'. an empty version removes the pin.
+func SetPinnedVersion(pinnedVersion string) {
+	if pinnedVersion == configContents.PinnedVersion {
+		return
+	}
+	configContents.PinnedVersion = pinnedVersion
+	writeConfig(configContents)
+}
+
+// ShellHookAllowed whether the shell hook may load secrets for the directory. Allowing a directory also allows its subdirectories.
+func ShellHookAllowed(dir string) bool {
+	normalizedDir, err := NormalizeScope(dir)
+	if err != nil {
+		utils.HandleError(err, fmt.Sprintf("Invalid directory: %s", dir))
+	}
+	if !strings.HasSuffix(normalizedDir, string(filepath.Separator)) {
+		normalizedDir = normalizedDir + string(filepath.Separator)
+	}
+
+	for _, allowed := range configContents.ShellHook.Allowed {
+		// both paths must end in / to prevent partial match (e.g. /test matching /test123)
+		if !strings.HasSuffix(allowed, string(filepath.Separator)) {
+			allowed = allowed + string(filepath.Separator)
+		}
+
+		if strings.HasPrefix(normalizedDir, allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowShellHook adds the directory to the shell hook's allow list
+func AllowShellHook(dir string) {
+	normalizedDir, err := NormalizeScope(dir)
+	if err != nil {
+		utils.HandleError(err, fmt.Sprintf("Invalid directory: %s", dir))
+	}
+
+	for _, allowed := range configContents.ShellHook.Allowed {
+		if allowed == normalizedDir {
+			return
+		}
+	}
+
+	configContents.ShellHook.Allowed = append(configContents.ShellHook.Allowed, normalizedDir)
+	sort.Strings(configContents.ShellHook.Allowed)
+	writeConfig(configContents)
+}
+
+// DisallowShellHook removes the directory from the shell hook's allow list. Returns false if the directory wasn't allowed.
+func DisallowShellHook(dir string) bool {
+	normalizedDir, err := NormalizeScope(dir)
+	if err != nil {
+		utils.HandleError(err, fmt.Sprintf("Invalid directory: %s", dir))
+	}
+
+	var allowed []string
+	for _, allowedDir := range configContents.ShellHook.Allowed {
+		if allowedDir != normalizedDir {
+			allowed = append(allowed, allowedDir)
+		}
+	}
+
+	if len(allowed) == len(configContents.ShellHook.Allowed) {
+		return false
+	}
+
+	configContents.ShellHook.Allowed = allowed
+	writeConfig(configContents)
+	return true
+}
+
+// ShellHookAllowList the directories whose secrets may be loaded by the shell hook
+func ShellHookAllowList() []string {
+	return configContents.ShellHook.Allowed
+}
+
 // Get the config at the specified scope
 func Get(scope string) models.ScopedOptions {
+	return get(scope, true)
+}
+
+// get the config at the specified scope. unless resolveToken is set, a token stored in the system keyring remains a reference to its keyring entry.
+func get(scope string, resolveToken bool) models.ScopedOptions {
 	var normalizedScope string
 	var err error
 	if normalizedScope, err = NormalizeScope(scope); err != nil {
@@ -144,7 +254,10 @@ func Get(scope string) models.ScopedOptions {
 		}
 	}
 
-	if controllers.IsKeyringSecret(scopedConfig.Token.Value) {
+	// profile options take precedence over scoped options
+	applyProfile(&scopedConfig)
+
+	if resolveToken && controllers.IsKeyringSecret(scopedConfig.Token.Value) {
 		utils.LogDebug(fmt.Sprintf("Retrieving %s from system keyring", models.ConfigToken.String()))
 		token, err := controllers.GetKeyring(scopedConfig.Token.Value)
 		if !err.IsNil() {
@@ -157,10 +270,26 @@ func Get(scope string) models.ScopedOptions {
 	return scopedConfig
 }
 
+// TokenHelperScope identifies where the token helper's token is used. helpers may print a different token
+// for each config scope, API host, or profile, so their tokens are cached separately.
+func TokenHelperScope(config models.ScopedOptions) string {
+	return strings.Join([]string{config.TokenHelper.Scope, config.APIHost.Value, CurrentProfile()}, "\n")
+}
+
 // LocalConfig retrieves the config for the scoped directory
 func LocalConfig(cmd *cobra.Command) models.ScopedOptions {
+	return localConfig(cmd, true)
+}
+
+// UnresolvedLocalConfig retrieves the config for the scoped directory without resolving the token, which may remain a reference to
+// its keyring entry or token helper. resolving the token can be slow, so this suits lookups that only need to identify it (e.g. caches).
+func UnresolvedLocalConfig(cmd *cobra.Command) models.ScopedOptions {
+	return localConfig(cmd, false)
+}
+
+func localConfig(cmd *cobra.Command, resolveToken bool) models.ScopedOptions {
 	// config file (lowest priority)
-	localConfig := Get(Scope)
+	localConfig := get(Scope, resolveToken)
 
 	// environment variables
 	if !utils.GetBoolFlag(cmd, "no-read-env") {
@@ -186,28 +315,50 @@ func LocalConfig(cmd *cobra.Command) models.ScopedOptions {
 		}
 	}
 
-	// individual flags (highest priority)
-	flagSet := cmd.Flags().Changed("token")
-	if flagSet || localConfig.Token.Value == "" {
-		localConfig.Token.Value = cmd.Flag("token").Value.String()
-		localConfig.Token.Scope = "/"
+	// the API host flag is read before the token helper runs, as the helper's tokens are cached per API host
+	flagSet := cmd.Flags().Changed("api-host")
+	if flagSet || localConfig.APIHost.Value == "" {
+		localConfig.APIHost.Value = cmd.Flag("api-host").Value.String()
+		localConfig.APIHost.Scope = "/"
 
 		if flagSet {
-			localConfig.Token.Source = models.FlagSource.String()
+			localConfig.APIHost.Source = models.FlagSource.String()
 		} else {
-			localConfig.Token.Source = models.DefaultValueSource.String()
+			localConfig.APIHost.Source = models.DefaultValueSource.String()
 		}
 	}
 
-	flagSet = cmd.Flags().Changed("api-host")
-	if flagSet || localConfig.APIHost.Value == "" {
-		localConfig.APIHost.Value = cmd.Flag("api-host").Value.String()
-		localConfig.APIHost.Scope = "/"
+	// token helper, used when the token isn't specified via flag or environment variable
+	flagSet = cmd.Flags().Changed("token")
+	if !flagSet && localConfig.TokenHelper.Value != "" && localConfig.Token.Source != models.EnvironmentSource.String() {
+		token := "token-helper:" + localConfig.TokenHelper.Value
+		if resolveToken {
+			var err controllers.Error
+			// the command's context is canceled on interrupt, which kills the helper
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			token, err = controllers.TokenFromHelper(ctx, localConfig.TokenHelper.Value, TokenHelperScope(localConfig))
+			if !err.IsNil() {
+				utils.HandleError(err.Unwrap(), err.Message)
+			}
+		}
+
+		localConfig.Token.Value = token
+		localConfig.Token.Scope = localConfig.TokenHelper.Scope
+		localConfig.Token.Source = models.TokenHelperSource.String()
+	}
+
+	// individual flags (highest priority)
+	if flagSet || localConfig.Token.Value == "" {
+		localConfig.Token.Value = cmd.Flag("token").Value.String()
+		localConfig.Token.Scope = "/"
 
 		if flagSet {
-			localConfig.APIHost.Source = models.FlagSource.String()
+			localConfig.Token.Source = models.FlagSource.String()
 		} else {
-			localConfig.APIHost.Source = models.DefaultValueSource.String()
+			localConfig.Token.Source = models.DefaultValueSource.String()
 		}
 	}
 
@@ -236,6 +387,66 @@ func LocalConfig(cmd *cobra.Command) models.ScopedOptions {
 		}
 	}
 
+	flagSet = cmd.Flags().Changed("retry-attempts")
+	if flagSet || localConfig.RetryAttempts.Value == "" {
+		localConfig.RetryAttempts.Value = cmd.Flag("retry-attempts").Value.String()
+		localConfig.RetryAttempts.Scope = "/"
+
+		if flagSet {
+			localConfig.RetryAttempts.Source = models.FlagSource.String()
+		} else {
+			localConfig.RetryAttempts.Source = models.DefaultValueSource.String()
+		}
+	}
+
+	flagSet = cmd.Flags().Changed("retry-delay")
+	if flagSet || localConfig.RetryDelay.Value == "" {
+		localConfig.RetryDelay.Value = cmd.Flag("retry-delay").Value.String()
+		localConfig.RetryDelay.Scope = "/"
+
+		if flagSet {
+			localConfig.RetryDelay.Source = models.FlagSource.String()
+		} else {
+			localConfig.RetryDelay.Source = models.DefaultValueSource.String()
+		}
+	}
+
+	flagSet = cmd.Flags().Changed("retry-max-delay")
+	if flagSet || localConfig.RetryMaxDelay.Value == "" {
+		localConfig.RetryMaxDelay.Value = cmd.Flag("retry-max-delay").Value.String()
+		localConfig.RetryMaxDelay.Scope = "/"
+
+		if flagSet {
+			localConfig.RetryMaxDelay.Source = models.FlagSource.String()
+		} else {
+			localConfig.RetryMaxDelay.Source = models.DefaultValueSource.String()
+		}
+	}
+
+	flagSet = cmd.Flags().Changed("retry-max-time")
+	if flagSet || localConfig.RetryMaxTime.Value == "" {
+		localConfig.RetryMaxTime.Value = cmd.Flag("retry-max-time").Value.String()
+		localConfig.RetryMaxTime.Scope = "/"
+
+		if flagSet {
+			localConfig.RetryMaxTime.Source = models.FlagSource.String()
+		} else {
+			localConfig.RetryMaxTime.Source = models.DefaultValueSource.String()
+		}
+	}
+
+	flagSet = cmd.Flags().Changed("retry-status-codes")
+	if flagSet || localConfig.RetryStatusCodes.Value == "" {
+		localConfig.RetryStatusCodes.Value = cmd.Flag("retry-status-codes").Value.String()
+		localConfig.RetryStatusCodes.Scope = "/"
+
+		if flagSet {
+			localConfig.RetryStatusCodes.Source = models.FlagSource.String()
+		} else {
+			localConfig.RetryStatusCodes.Source = models.DefaultValueSource.String()
+		}
+	}
+
 	// these flags below do not have a default value and should only be used if specified by the user (or will cause invalid memory access)
 	flagSet = cmd.Flags().Changed("project")
 	if flagSet {
@@ -302,28 +513,16 @@ func Set(scope string, options map[string]string) {
 		}
 
 		if key == models.ConfigToken.String() {
-			utils.LogDebug(fmt.Sprintf("Saving %s to system keyring", key))
-			uuid, err := utils.UUID()
+			value = saveToken(value, previousToken)
+		}
+
+		// relative paths would otherwise be resolved against whichever directory the CLI is later run from
+		if IsPathConfigOption(key) && value != "" {
+			path, err := utils.ParsePath(value)
 			if err != nil {
-				utils.HandleError(err, "Unable to generate UUID for keyring")
-			}
-			id := controllers.GenerateKeyringID(uuid)
-
-			if controllerError := controllers.SetKeyring(id, value); !controllerError.IsNil() {
-				utils.LogDebugError(controllerError.Unwrap())
-				utils.LogDebug(controllerError.Message)
-			} else {
-				value = id
-
-				utils.LogDebug("Removing previous token from system keyring")
-				// remove old token from keyring
-				if controllers.IsKeyringSecret(previousToken) {
-					if controllerError := controllers.DeleteKeyring(previousToken); !controllerError.IsNil() {
-						utils.LogDebugError(controllerError.Unwrap())
-						utils.LogDebug(controllerError.Message)
-					}
-				}
+				utils.HandleError(utils.NewError(utils.ErrorKindUsage, err), fmt.Sprintf("Invalid path for %s", key))
 			}
+			value = path
 		}
 
 		SetConfigValue(&config, key, value)
@@ -333,6 +532,72 @@ func Set(scope string, options map[string]string) {
 	writeConfig(configContents)
 }
 
+// saveToken saves the token to the system keyring, returning the keyring ID to store in the config file.
+// the token itself is returned if the keyring is unavailable. the previous token is removed from the keyring.
+func saveToken(token string, previousToken string) string {
+	utils.LogDebug(fmt.Sprintf("Saving %s to system keyring", models.ConfigToken.String()))
+	uuid, err := utils.UUID()
+	if err != nil {
+		utils.HandleError(err, "Unable to generate UUID for keyring")
+	}
+	id := controllers.GenerateKeyringID(uuid)
+
+	if controllerError := controllers.SetKeyring(id, token); !controllerError.IsNil() {
+		utils.LogDebugError(controllerError.Unwrap())
+		utils.LogDebug(controllerError.Message)
+		return token
+	}
+
+	deleteToken(previousToken)
+	return id
+}
+
+// MigrateTokens moves plaintext tokens in the config file, including those of profiles, to the system keyring.
+// Returns the number of tokens migrated and the number that remain in plaintext.
+func MigrateTokens() (int, int) {
+	migrated := 0
+	remaining := 0
+	migrate := func(options *models.FileScopedOptions) {
+		if options.Token == "" || controllers.IsKeyringSecret(options.Token) {
+			return
+		}
+
+		token := saveToken(options.Token, "")
+		if !controllers.IsKeyringSecret(token) {
+			remaining++
+			return
+		}
+
+		options.Token = token
+		migrated++
+	}
+
+	for scope, options := range configContents.Scoped {
+		migrate(&options)
+		configContents.Scoped[scope] = options
+	}
+	for name, options := range configContents.Profiles {
+		migrate(&options)
+		configContents.Profiles[name] = options
+	}
+
+	if migrated > 0 {
+		writeConfig(configContents)
+	}
+	return migrated, remaining
+}
+
+// deleteToken removes the token from the system keyring, if it's stored there
+func deleteToken(token string) {
+	if controllers.IsKeyringSecret(token) {
+		utils.LogDebug("Removing previous token from system keyring")
+		if controllerError := controllers.DeleteKeyring(token); !controllerError.IsNil() {
+			utils.LogDebugError(controllerError.Unwrap())
+			utils.LogDebug(controllerError.Message)
+		}
+	}
+}
+
 // Unset a local config
 func Unset(scope string, options []string) {
 	var normalizedScope string
@@ -353,14 +618,7 @@ func Unset(scope string, options []string) {
 		config := configContents.Scoped[normalizedScope]
 
 		if key == models.ConfigToken.String() {
-			previousToken := config.Token
-			// remove old token from keyring
-			if controllers.IsKeyringSecret(previousToken) {
-				if controllerError := controllers.DeleteKeyring(previousToken); !controllerError.IsNil() {
-					utils.LogDebugError(controllerError.Unwrap())
-					utils.LogDebug(controllerError.Message)
-				}
-			}
+			deleteToken(config.Token)
 		}
 
 		SetConfigValue(&config, key, "")
@@ -437,6 +695,42 @@ func readConfig() models.ConfigFile {
 		if options.VerifyTLS != "" {
 			scopedOption.VerifyTLS = options.VerifyTLS
 		}
+		if options.CACert != "" {
+			scopedOption.CACert = options.CACert
+		}
+		if options.ClientCert != "" {
+			scopedOption.ClientCert = options.ClientCert
+		}
+		if options.ClientKey != "" {
+			scopedOption.ClientKey = options.ClientKey
+		}
+		if options.HTTPProxy != "" {
+			scopedOption.HTTPProxy = options.HTTPProxy
+		}
+		if options.HTTPSProxy != "" {
+			scopedOption.HTTPSProxy = options.HTTPSProxy
+		}
+		if options.NoProxy != "" {
+			scopedOption.NoProxy = options.NoProxy
+		}
+		if options.RetryAttempts != "" {
+			scopedOption.RetryAttempts = options.RetryAttempts
+		}
+		if options.RetryDelay != "" {
+			scopedOption.RetryDelay = options.RetryDelay
+		}
+		if options.RetryMaxDelay != "" {
+			scopedOption.RetryMaxDelay = options.RetryMaxDelay
+		}
+		if options.RetryMaxTime != "" {
+			scopedOption.RetryMaxTime = options.RetryMaxTime
+		}
+		if options.RetryStatusCodes != "" {
+			scopedOption.RetryStatusCodes = options.RetryStatusCodes
+		}
+		if options.TokenHelper != "" {
+			scopedOption.TokenHelper = options.TokenHelper
+		}
 
 		normalizedOptions[normalizedScope] = scopedOption
 	}
@@ -448,18 +742,35 @@ func readConfig() models.ConfigFile {
 // IsValidConfigOption whether the specified key is a valid config option
 func IsValidConfigOption(key string) bool {
 	configOptions := map[string]interface{}{
-		models.ConfigToken.String():          nil,
-		models.ConfigAPIHost.String():        nil,
-		models.ConfigDashboardHost.String():  nil,
-		models.ConfigVerifyTLS.String():      nil,
-		models.ConfigEnclaveProject.String(): nil,
-		models.ConfigEnclaveConfig.String():  nil,
+		models.ConfigToken.String():            nil,
+		models.ConfigAPIHost.String():          nil,
+		models.ConfigDashboardHost.String():    nil,
+		models.ConfigVerifyTLS.String():        nil,
+		models.ConfigEnclaveProject.String():   nil,
+		models.ConfigEnclaveConfig.String():    nil,
+		models.ConfigCACert.String():           nil,
+		models.ConfigClientCert.String():       nil,
+		models.ConfigClientKey.String():        nil,
+		models.ConfigHTTPProxy.String():        nil,
+		models.ConfigHTTPSProxy.String():       nil,
+		models.ConfigNoProxy.String():          nil,
+		models.ConfigRetryAttempts.String():    nil,
+		models.ConfigRetryDelay.String():       nil,
+		models.ConfigRetryMaxDelay.String():    nil,
+		models.ConfigRetryMaxTime.String():     nil,
+		models.ConfigRetryStatusCodes.String(): nil,
+		models.ConfigTokenHelper.String():      nil,
 	}
 
 	_, exists := configOptions[key]
 	return exists
 }
 
+// IsPathConfigOption whether the option's value is a file path, which is saved as an absolute path
+func IsPathConfigOption(key string) bool {
+	return key == models.ConfigCACert.String() || key == models.ConfigClientCert.String() || key == models.ConfigClientKey.String()
+}
+
 // IsTranslatableConfigOption checks whether the key can be translated to a valid config option
 func IsTranslatableConfigOption(key string) bool {
 	// TODO remove this function when releasing CLI v4 (DPLR-435)
@@ -508,6 +819,30 @@ func SetConfigValue(conf *models.FileScopedOptions, key string, value string) {
 		(*conf).EnclaveProject = value
 	} else if key == models.ConfigEnclaveConfig.String() {
 		(*conf).EnclaveConfig = value
+	} else if key == models.ConfigCACert.String() {
+		(*conf).CACert = value
+	} else if key == models.ConfigClientCert.String() {
+		(*conf).ClientCert = value
+	} else if key == models.ConfigClientKey.String() {
+		(*conf).ClientKey = value
+	} else if key == models.ConfigHTTPProxy.String() {
+		(*conf).HTTPProxy = value
+	} else if key == models.ConfigHTTPSProxy.String() {
+		(*conf).HTTPSProxy = value
+	} else if key == models.ConfigNoProxy.String() {
+		(*conf).NoProxy = value
+	} else if key == models.ConfigRetryAttempts.String() {
+		(*conf).RetryAttempts = value
+	} else if key == models.ConfigRetryDelay.String() {
+		(*conf).RetryDelay = value
+	} else if key == models.ConfigRetryMaxDelay.String() {
+		(*conf).RetryMaxDelay = value
+	} else if key == models.ConfigRetryMaxTime.String() {
+		(*conf).RetryMaxTime = value
+	} else if key == models.ConfigRetryStatusCodes.String() {
+		(*conf).RetryStatusCodes = value
+	} else if key == models.ConfigTokenHelper.String() {
+		(*conf).TokenHelper = value
 	}
 }
 
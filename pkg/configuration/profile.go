@@ -0,0 +1,202 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configuration
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/DopplerHQ/cli/pkg/controllers"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+)
+
+// Profile the profile to use, as specified via --profile or DOPPLER_PROFILE. The active profile is used when empty.
+var Profile string
+
+var profileNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ProfileScope the scope reported for options set by a profile
+func ProfileScope(name string) string {
+	return "profile:" + name
+}
+
+// CurrentProfile the name of the profile in use, if any
+func CurrentProfile() string {
+	if Profile != "" {
+		return Profile
+	}
+	return configContents.ActiveProfile
+}
+
+// ActiveProfile the name of the profile set via 'doppler profile use'
+func ActiveProfile() string {
+	return configContents.ActiveProfile
+}
+
+// ProfileExists whether a profile with the specified name exists
+func ProfileExists(name string) bool {
+	_, ok := configContents.Profiles[name]
+	return ok
+}
+
+// IsValidProfileName whether the name may be used for a profile
+func IsValidProfileName(name string) bool {
+	return profileNameRegex.MatchString(name)
+}
+
+// IsValidProfileOption whether the option may be set by a profile
+func IsValidProfileOption(key string) bool {
+	for _, option := range models.ProfileOptions {
+		if option == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfileNames the names of all profiles, sorted alphabetically
+func ProfileNames() []string {
+	var names []string
+	for name := range configContents.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profiles all profiles. Tokens are retrieved from the system keyring.
+func Profiles() map[string]models.FileScopedOptions {
+	all := map[string]models.FileScopedOptions{}
+	for name, profile := range configContents.Profiles {
+		options := profile
+
+		if controllers.IsKeyringSecret(options.Token) {
+			utils.LogDebug(fmt.Sprintf("Retrieving %s from system keyring", models.ConfigToken.String()))
+			token, err := controllers.GetKeyring(options.Token)
+			if !err.IsNil() {
+				utils.HandleError(err.Unwrap(), err.Message)
+			}
+
+			options.Token = token
+		}
+
+		all[name] = options
+	}
+	return all
+}
+
+// CreateProfile creates a profile with the specified options
+func CreateProfile(name string, options map[string]string) {
+	if !IsValidProfileName(name) {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, fmt.Errorf("Invalid profile name %s. Names may only contain letters, numbers, '_', '.', and '-'", name)))
+	}
+	if ProfileExists(name) {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, fmt.Errorf("Profile %s already exists", name)))
+	}
+
+	var profile models.FileScopedOptions
+	for key, value := range options {
+		if !IsValidProfileOption(key) {
+			utils.HandleError(utils.NewError(utils.ErrorKindUsage, fmt.Errorf("Invalid profile option %s", key)))
+		}
+
+		if key == models.ConfigToken.String() {
+			value = saveToken(value, "")
+		}
+
+		SetConfigValue(&profile, key, value)
+	}
+
+	if configContents.Profiles == nil {
+		configContents.Profiles = map[string]models.FileScopedOptions{}
+	}
+	configContents.Profiles[name] = profile
+	writeConfig(configContents)
+}
+
+// DeleteProfile deletes the profile and removes its token from the system keyring.
+// The profile is deactivated if it's the active profile.
+func DeleteProfile(name string) {
+	profile, ok := configContents.Profiles[name]
+	if !ok {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, fmt.Errorf("Profile %s doesn't exist", name)))
+	}
+
+	deleteToken(profile.Token)
+	delete(configContents.Profiles, name)
+	if configContents.ActiveProfile == name {
+		configContents.ActiveProfile = ""
+	}
+	writeConfig(configContents)
+}
+
+// UseProfile sets the active profile. An empty name deactivates the active profile.
+func UseProfile(name string) {
+	if name != "" && !ProfileExists(name) {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, fmt.Errorf("Profile %s doesn't exist", name)))
+	}
+
+	configContents.ActiveProfile = name
+	writeConfig(configContents)
+}
+
+// ClearProfileTokens removes the token from all profiles that use it
+func ClearProfileTokens(token string) {
+	changed := false
+	for name, profile := range Profiles() {
+		if profile.Token != token {
+			continue
+		}
+
+		deleteToken(configContents.Profiles[name].Token)
+		stored := configContents.Profiles[name]
+		stored.Token = ""
+		configContents.Profiles[name] = stored
+		changed = true
+	}
+
+	if changed {
+		writeConfig(configContents)
+	}
+}
+
+// applyProfile layers the current profile's options over the scoped config
+func applyProfile(scopedConfig *models.ScopedOptions) {
+	name := CurrentProfile()
+	if name == "" {
+		return
+	}
+
+	profile, ok := configContents.Profiles[name]
+	if !ok {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, fmt.Errorf("Profile %s doesn't exist. Run 'doppler profile list' to view all profiles", name)))
+	}
+
+	utils.LogDebug(fmt.Sprintf("Using profile %s", name))
+	scopedPairs := models.ScopedPairs(scopedConfig)
+	for key, value := range models.Pairs(profile) {
+		if value == "" {
+			continue
+		}
+
+		scopedPair := scopedPairs[key]
+		scopedPair.Value = value
+		scopedPair.Scope = ProfileScope(name)
+		scopedPair.Source = models.ProfileSource.String()
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+)
+
+// CompletionCacheDir the directory in which shell completion values are cached
+var CompletionCacheDir string
+
+// CompletionCacheTTL how long cached completion values are used before they're fetched again
+var CompletionCacheTTL = 5 * time.Minute
+
+// completionCachePath the cache file for the key. the key is hashed as it contains the auth token.
+func completionCachePath(key []string) string {
+	hash := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	return filepath.Join(CompletionCacheDir, fmt.Sprintf("%s.json", hex.EncodeToString(hash[:])))
+}
+
+func readCompletionCache(path string) (models.CompletionCacheEntry, bool) {
+	var entry models.CompletionCacheEntry
+	contents, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return entry, false
+	}
+	if err := json.Unmarshal(contents, &entry); err != nil {
+		utils.LogDebug(fmt.Sprintf("Unable to parse completion cache file %s", path))
+		return entry, false
+	}
+	return entry, true
+}
+
+func writeCompletionCache(path string, entry models.CompletionCacheEntry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(CompletionCacheDir, 0700); err != nil {
+		return err
+	}
+	return utils.WriteFile(path, body, utils.RestrictedFilePerms())
+}
+
+// CompletionValues returns shell completion values for the key, which identifies the values' source (e.g. API host, token, and resource).
+// values are read from the cache when fresh, and fetched otherwise. stale values are used if the fetch fails (e.g. while offline).
+func CompletionValues(key []string, fetch func() ([]string, error)) []string {
+	path := completionCachePath(key)
+	entry, cached := readCompletionCache(path)
+	if cached && time.Since(entry.FetchedAt) < CompletionCacheTTL {
+		return entry.Values
+	}
+
+	values, err := fetch()
+	if err != nil {
+		utils.LogDebug("Unable to fetch completion values")
+		utils.LogDebugError(err)
+		if cached {
+			return entry.Values
+		}
+		return nil
+	}
+
+	if err := writeCompletionCache(path, models.CompletionCacheEntry{FetchedAt: time.Now(), Values: values}); err != nil {
+		utils.LogDebug("Unable to write completion cache")
+		utils.LogDebugError(err)
+	}
+	return values
+}
+
+// ClearCompletionCache deletes all cached completion values
+func ClearCompletionCache() Error {
+	if err := os.RemoveAll(CompletionCacheDir); err != nil {
+		return Error{Err: err, Message: "Unable to delete completion cache"}
+	}
+	return Error{}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+)
+
+// useCompletionCacheDir points the completion cache at an empty temp directory
+func useCompletionCacheDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "doppler-completion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := CompletionCacheDir
+	CompletionCacheDir = dir
+	t.Cleanup(func() {
+		CompletionCacheDir = previous
+		os.RemoveAll(dir)
+	})
+}
+
+// countingFetch returns the values and counts how often it's called
+func countingFetch(values []string, err error, calls *int) func() ([]string, error) {
+	return func() ([]string, error) {
+		*calls++
+		return values, err
+	}
+}
+
+func TestCompletionValuesCached(t *testing.T) {
+	useCompletionCacheDir(t)
+	key := []string{"https://api.doppler.com", "dp.st.token", "projects"}
+
+	calls := 0
+	values := CompletionValues(key, countingFetch([]string{"backend", "frontend"}, nil, &calls))
+	if !reflect.DeepEqual(values, []string{"backend", "frontend"}) || calls != 1 {
+		t.Fatalf("Expected fetched values, got %v after %d fetches", values, calls)
+	}
+
+	// fresh values are read from the cache
+	values = CompletionValues(key, countingFetch([]string{"other"}, nil, &calls))
+	if !reflect.DeepEqual(values, []string{"backend", "frontend"}) || calls != 1 {
+		t.Errorf("Expected cached values, got %v after %d fetches", values, calls)
+	}
+
+	// other keys have their own entries
+	values = CompletionValues([]string{"https://api.doppler.com", "dp.st.other", "projects"}, countingFetch([]string{"other"}, nil, &calls))
+	if !reflect.DeepEqual(values, []string{"other"}) || calls != 2 {
+		t.Errorf("Expected values for the other key, got %v after %d fetches", values, calls)
+	}
+}
+
+func TestCompletionValuesExpired(t *testing.T) {
+	useCompletionCacheDir(t)
+	key := []string{"https://api.doppler.com", "dp.st.token", "projects"}
+	entry := models.CompletionCacheEntry{FetchedAt: time.Now().Add(-CompletionCacheTTL - time.Second), Values: []string{"stale"}}
+	if err := writeCompletionCache(completionCachePath(key), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	values := CompletionValues(key, countingFetch([]string{"fresh"}, nil, &calls))
+	if !reflect.DeepEqual(values, []string{"fresh"}) || calls != 1 {
+		t.Fatalf("Expected expired values to be fetched again, got %v after %d fetches", values, calls)
+	}
+
+	cached, ok := readCompletionCache(completionCachePath(key))
+	if !ok || !reflect.DeepEqual(cached.Values, []string{"fresh"}) || time.Since(cached.FetchedAt) > time.Minute {
+		t.Errorf("Expected the cache to be updated, got %+v", cached)
+	}
+}
+
+func TestCompletionValuesStaleOnError(t *testing.T) {
+	useCompletionCacheDir(t)
+	key := []string{"https://api.doppler.com", "dp.st.token", "projects"}
+	fetchErr := errors.New("offline")
+
+	// without a cached entry, nothing can be completed
+	calls := 0
+	if values := CompletionValues(key, countingFetch(nil, fetchErr, &calls)); values != nil {
+		t.Errorf("Expected no values, got %v", values)
+	}
+
+	entry := models.CompletionCacheEntry{FetchedAt: time.Now().Add(-time.Hour), Values: []string{"stale"}}
+	if err := writeCompletionCache(completionCachePath(key), entry); err != nil {
+		t.Fatal(err)
+	}
+	values := CompletionValues(key, countingFetch(nil, fetchErr, &calls))
+	if !reflect.DeepEqual(values, []string{"stale"}) || calls != 2 {
+		t.Errorf("Expected stale values when the fetch fails, got %v after %d fetches", values, calls)
+	}
+
+	// the stale entry isn't refreshed, so the next completion tries to fetch again
+	cached, _ := readCompletionCache(completionCachePath(key))
+	if !cached.FetchedAt.Equal(entry.FetchedAt) {
+		t.Errorf("Expected the stale entry to be unchanged, got %+v", cached)
+	}
+}
+
+func TestClearCompletionCache(t *testing.T) {
+	useCompletionCacheDir(t)
+	key := []string{"https://api.doppler.com", "dp.st.token", "projects"}
+	calls := 0
+	CompletionValues(key, countingFetch([]string{"backend"}, nil, &calls))
+
+	if err := ClearCompletionCache(); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if _, ok := readCompletionCache(completionCachePath(key)); ok {
+		t.Error("Expected the cache to be cleared")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/DopplerHQ/cli/pkg/crypto"
 	"github.com/DopplerHQ/cli/pkg/models"
@@ -48,6 +49,37 @@ func MetadataFilePath(token string, project string, config string) string {
 	return path
 }
 
+// fallbackLockDirName the directory within the fallback directory that contains lock files
+const fallbackLockDirName = "locks"
+
+// FallbackLockPath the path of the lock file guarding a fallback file and its metadata file. Lock files are kept in the
+// fallback directory, keyed by a hash of the fallback file's path, so none are left next to custom fallback paths.
+// 'run clean' never deletes them, as a lock file that's deleted while held no longer excludes other processes.
+func FallbackLockPath(fallbackPath string) string {
+	if absPath, err := filepath.Abs(fallbackPath); err == nil {
+		fallbackPath = absPath
+	}
+	return filepath.Join(DefaultMetadataDir, fallbackLockDirName, fmt.Sprintf("%s.lock", crypto.Hash(fallbackPath)))
+}
+
+// LockFallbackFile acquires an advisory lock on the fallback file. Writers must hold an exclusive lock
+// so the fallback file and metadata file are updated together, while readers hold a shared lock.
+func LockFallbackFile(fallbackPath string, exclusive bool) (*utils.FileLock, Error) {
+	lock, err := lockFallbackPath(fallbackPath, exclusive)
+	if err != nil {
+		return nil, Error{Err: err, Message: "Unable to lock fallback file"}
+	}
+	return lock, Error{}
+}
+
+func lockFallbackPath(path string, exclusive bool) (*utils.FileLock, error) {
+	lockPath := FallbackLockPath(path)
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0700); err != nil {
+		return nil, err
+	}
+	return utils.LockFile(lockPath, exclusive)
+}
+
 // MetadataFile reads the contents of the metadata file
 func MetadataFile(path string) (models.SecretsFileMetadata, Error) {
 	utils.LogDebug(fmt.Sprintf("Using metadata file %s", path))
@@ -77,16 +109,41 @@ func MetadataFile(path string) (models.SecretsFileMetadata, Error) {
 	return metadata, Error{}
 }
 
-// WriteMetadataFile writes the contents of the metadata file
+// WriteMetadataFile writes the contents of the metadata file. the secrets are recorded as verified now.
 func WriteMetadataFile(path string, etag string, hash string) Error {
 	utils.LogDebug(fmt.Sprintf("Writing ETag to metadata file %s", path))
 
-	metadata := models.SecretsFileMetadata{
-		Version: "1",
-		ETag:    etag,
-		Hash:    hash,
+	return writeMetadataFile(path, models.SecretsFileMetadata{
+		Version:    "1",
+		ETag:       etag,
+		Hash:       hash,
+		VerifiedAt: time.Now(),
+	})
+}
+
+// MarkMetadataVerified records that the API confirmed the fallback file's secrets are current (e.g. via a 304).
+// the caller must hold an exclusive lock on the fallback file.
+func MarkMetadataVerified(path string) Error {
+	metadata, err := MetadataFile(path)
+	if !err.IsNil() {
+		return err
 	}
 
+	metadata.VerifiedAt = time.Now()
+	return writeMetadataFile(path, metadata)
+}
+
+// FallbackFileVerifiedAt when the API last confirmed the fallback file's secrets are current. the file's modification time
+// is used when its metadata doesn't record this (e.g. caching is disabled, or the metadata describes different contents).
+func FallbackFileVerifiedAt(metadataPath string, contents []byte, modTime time.Time) time.Time {
+	metadata, err := MetadataFile(metadataPath)
+	if !err.IsNil() || metadata.VerifiedAt.IsZero() || metadata.Hash != crypto.Hash(string(contents)) {
+		return modTime
+	}
+	return metadata.VerifiedAt
+}
+
+func writeMetadataFile(path string, metadata models.SecretsFileMetadata) Error {
 	metadataBytes, err := yaml.Marshal(metadata)
 	if err != nil {
 		return Error{Err: err, Message: "Unable to marshal metadata to YAML"}
@@ -112,8 +169,13 @@ func SecretsCacheFile(path string, passphrase string) (map[string]string, Error)
 		return nil, Error{Err: err, Message: "Unable to read cache file"}
 	}
 
+	return DecryptSecretsCache(response, passphrase)
+}
+
+// DecryptSecretsCache decrypts and parses the contents of a cache file
+func DecryptSecretsCache(contents []byte, passphrase string) (map[string]string, Error) {
 	utils.LogDebug("Decrypting cache file")
-	decryptedSecrets, err := crypto.Decrypt(passphrase, response)
+	decryptedSecrets, err := crypto.Decrypt(passphrase, contents)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to decrypt cache file"}
 	}
@@ -126,3 +188,47 @@ func SecretsCacheFile(path string, passphrase string) (map[string]string, Error)
 
 	return secrets, Error{}
 }
+
+// SecretsFileInfo reads info about an encrypted secrets file, comparing its hash against the metadata file (if specified)
+func SecretsFileInfo(path string, metadataPath string) (models.SecretsFileInfo, Error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return models.SecretsFileInfo{}, Error{Err: err, Message: "Secrets file does not exist"}
+		}
+		return models.SecretsFileInfo{}, Error{Err: err, Message: "Unable to read secrets file"}
+	}
+
+	contents, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return models.SecretsFileInfo{}, Error{Err: err, Message: "Unable to read secrets file"}
+	}
+
+	info := models.SecretsFileInfo{
+		Path:       path,
+		Size:       stat.Size(),
+		ModifiedAt: stat.ModTime(),
+		Hash:       crypto.Hash(string(contents)),
+	}
+
+	if metadataPath == "" {
+		return info, Error{}
+	}
+
+	metadata, metadataErr := MetadataFile(metadataPath)
+	if !metadataErr.IsNil() {
+		// a missing metadata file is expected when the cache is disabled
+		if os.IsNotExist(metadataErr.Unwrap()) {
+			utils.LogDebug(metadataErr.Message)
+			return info, Error{}
+		}
+		return info, metadataErr
+	}
+
+	info.MetadataPath = metadataPath
+	info.ETag = metadata.ETag
+	info.MetadataHash = metadata.Hash
+	info.HashMatches = metadata.Hash == info.Hash
+
+	return info, Error{}
+}
@@ -0,0 +1,323 @@
+/*
+Copyright © 2020 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/crypto"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/zalando/go-keyring"
+)
+
+const fallbackIndexFileName = ".index.json"
+const fallbackIndexKeyFileName = ".index.key"
+
+var secretsFileRegex = regexp.MustCompile(`^\.secrets-([0-9a-f]{64})\.json$`)
+var metadataFileRegex = regexp.MustCompile(`^\.metadata-([0-9a-f]{64})\.json$`)
+var legacyFallbackFileRegex = regexp.MustCompile(`^\.run-([0-9a-f]{64})\.json$`)
+
+// FallbackIndexPath the path of the fallback index file
+func FallbackIndexPath() string {
+	return filepath.Join(DefaultMetadataDir, fallbackIndexFileName)
+}
+
+// IsFallbackIndexFile whether the file name belongs to the fallback index
+func IsFallbackIndexFile(name string) bool {
+	return name == fallbackIndexFileName || name == fallbackIndexKeyFileName
+}
+
+// lockFallbackIndex acquires an exclusive lock on the index so concurrent updates aren't lost
+func lockFallbackIndex() *utils.FileLock {
+	lock, err := lockFallbackPath(FallbackIndexPath(), true)
+	if err != nil {
+		// the index only exists for informational purposes, so proceed without the lock
+		utils.LogDebugError(err)
+		utils.LogDebug("Unable to lock fallback index")
+		return nil
+	}
+	return lock
+}
+
+// fallbackIndexPassphraseCache the passphrase, which is cached to avoid accessing the keyring for each read and write
+var fallbackIndexPassphraseCache string
+var fallbackIndexPassphraseMutex sync.Mutex
+
+// fallbackIndexPassphrase retrieves the passphrase used to encrypt the index, generating one if necessary.
+// the passphrase is stored in the system keyring when available, and in a file only readable by the user otherwise.
+func fallbackIndexPassphrase() (string, Error) {
+	fallbackIndexPassphraseMutex.Lock()
+	defer fallbackIndexPassphraseMutex.Unlock()
+	if fallbackIndexPassphraseCache != "" {
+		return fallbackIndexPassphraseCache, Error{}
+	}
+
+	passphrase, err := readFallbackIndexPassphrase()
+	if err.IsNil() {
+		fallbackIndexPassphraseCache = passphrase
+	}
+	return passphrase, err
+}
+
+func readFallbackIndexPassphrase() (string, Error) {
+	keyFile := filepath.Join(DefaultMetadataDir, fallbackIndexKeyFileName)
+	if utils.Exists(keyFile) {
+		passphrase, err := ioutil.ReadFile(keyFile) // #nosec G304
+		if err != nil {
+			return "", Error{Err: err, Message: "Unable to read fallback index key file"}
+		}
+		return string(passphrase), Error{}
+	}
+
+	id := GenerateKeyringID("fallback-index")
+	passphrase, keyringErr := GetKeyring(id)
+	if keyringErr.IsNil() {
+		return passphrase, Error{}
+	}
+	// a new passphrase is only generated when there isn't one. otherwise a transient error (e.g. a locked keychain)
+	// would replace the passphrase, and the existing index could never be decrypted. when there's no index yet,
+	// there's nothing to lose, which lets the first run on a system without a keyring (e.g. headless linux) save the key to a file.
+	if keyringErr.Unwrap() != keyring.ErrNotFound && utils.Exists(FallbackIndexPath()) {
+		return "", Error{Err: keyringErr.Unwrap(), Message: "Unable to read fallback index key from keyring"}
+	}
+
+	passphrase = utils.RandomBase64String(32)
+	if setErr := SetKeyring(id, passphrase); setErr.IsNil() {
+		return passphrase, Error{}
+	}
+
+	utils.LogDebug("System keyring is unavailable, saving fallback index key to file")
+	if err := utils.WriteFile(keyFile, []byte(passphrase), utils.RestrictedFilePerms()); err != nil {
+		return "", Error{Err: err, Message: "Unable to write fallback index key file"}
+	}
+	return passphrase, Error{}
+}
+
+// FallbackIndex reads the fallback index. A missing index is not considered an error.
+func FallbackIndex() (models.FallbackIndex, Error) {
+	index := models.FallbackIndex{Version: "1", Files: map[string]models.FallbackIndexEntry{}}
+
+	path := FallbackIndexPath()
+	if !utils.Exists(path) {
+		return index, Error{}
+	}
+
+	utils.LogDebug(fmt.Sprintf("Reading fallback index %s", path))
+	contents, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return index, Error{Err: err, Message: "Unable to read fallback index"}
+	}
+
+	passphrase, passphraseErr := fallbackIndexPassphrase()
+	if !passphraseErr.IsNil() {
+		return index, passphraseErr
+	}
+
+	decrypted, err := crypto.Decrypt(passphrase, contents)
+	if err != nil {
+		return index, Error{Err: err, Message: "Unable to decrypt fallback index"}
+	}
+
+	var parsed models.FallbackIndex
+	if err := json.Unmarshal([]byte(decrypted), &parsed); err != nil {
+		return index, Error{Err: err, Message: "Unable to parse fallback index"}
+	}
+
+	for path, entry := range parsed.Files {
+		index.Files[path] = entry
+	}
+	return index, Error{}
+}
+
+func writeFallbackIndex(index models.FallbackIndex) Error {
+	body, err := json.Marshal(index)
+	if err != nil {
+		return Error{Err: err, Message: "Unable to marshal fallback index"}
+	}
+
+	passphrase, passphraseErr := fallbackIndexPassphrase()
+	if !passphraseErr.IsNil() {
+		return passphraseErr
+	}
+
+	encrypted, err := crypto.Encrypt(passphrase, body)
+	if err != nil {
+		return Error{Err: err, Message: "Unable to encrypt fallback index"}
+	}
+
+	path := FallbackIndexPath()
+	utils.LogDebug(fmt.Sprintf("Writing fallback index %s", path))
+	if err := utils.WriteFile(path, []byte(encrypted), utils.RestrictedFilePerms()); err != nil {
+		return Error{Err: err, Message: "Unable to write fallback index"}
+	}
+
+	return Error{}
+}
+
+// UpdateFallbackIndex adds or replaces the index entry for a fallback file
+func UpdateFallbackIndex(entry models.FallbackIndexEntry) Error {
+	lock := lockFallbackIndex()
+	defer lock.Unlock()
+	index, err := FallbackIndex()
+	if !err.IsNil() {
+		// the index only exists for informational purposes, so start over rather than fail
+		utils.LogDebugError(err.Unwrap())
+		utils.LogDebug(err.Message)
+	}
+
+	// avoid rewriting the index when only the time has changed
+	if existing, ok := index.Files[entry.Path]; ok && sameFallbackIndexEntry(existing, entry) {
+		return Error{}
+	}
+
+	index.Files[entry.Path] = entry
+	return writeFallbackIndex(index)
+}
+
+// sameFallbackIndexEntry whether the entries are equal, ignoring when they were updated
+func sameFallbackIndexEntry(a models.FallbackIndexEntry, b models.FallbackIndexEntry) bool {
+	a.UpdatedAt = time.Time{}
+	b.UpdatedAt = time.Time{}
+	return a == b
+}
+
+// PruneFallbackIndex removes entries whose fallback file and metadata file no longer exist
+func PruneFallbackIndex() Error {
+	lock := lockFallbackIndex()
+	defer lock.Unlock()
+	index, err := FallbackIndex()
+	if !err.IsNil() {
+		return err
+	}
+
+	pruned := false
+	for path, entry := range index.Files {
+		if !utils.Exists(path) && (entry.MetadataPath == "" || !utils.Exists(entry.MetadataPath)) {
+			delete(index.Files, path)
+			pruned = true
+		}
+	}
+
+	if !pruned {
+		return Error{}
+	}
+	return writeFallbackIndex(index)
+}
+
+// FallbackFiles lists the fallback and metadata files in the specified directory, as well as indexed fallback files stored elsewhere
+func FallbackFiles(dir string) ([]models.FallbackFile, Error) {
+	index, indexErr := FallbackIndex()
+	if !indexErr.IsNil() {
+		utils.LogDebugError(indexErr.Unwrap())
+		utils.LogDebug(indexErr.Message)
+	}
+
+	entriesByMetadata := map[string]models.FallbackIndexEntry{}
+	for _, entry := range index.Files {
+		if entry.MetadataPath != "" {
+			entriesByMetadata[entry.MetadataPath] = entry
+		}
+	}
+
+	dirEntries, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, Error{Err: err, Message: "Unable to read fallback directory"}
+	}
+
+	secretsHashes := map[string]bool{}
+	metadataHashes := map[string]bool{}
+	for _, dirEntry := range dirEntries {
+		if matches := secretsFileRegex.FindStringSubmatch(dirEntry.Name()); matches != nil {
+			secretsHashes[matches[1]] = true
+		} else if matches := metadataFileRegex.FindStringSubmatch(dirEntry.Name()); matches != nil {
+			metadataHashes[matches[1]] = true
+		}
+	}
+
+	var files []models.FallbackFile
+	listed := map[string]bool{}
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+
+		name := dirEntry.Name()
+		path := filepath.Join(dir, name)
+		if absPath, err := filepath.Abs(path); err == nil {
+			path = absPath
+		}
+
+		file := models.FallbackFile{Name: name, ModifiedAt: dirEntry.ModTime()}
+		file.Path = path
+
+		if matches := secretsFileRegex.FindStringSubmatch(name); matches != nil {
+			file.HasMetadata = metadataHashes[matches[1]]
+			if entry, ok := index.Files[path]; ok {
+				file.FallbackIndexEntry = entry
+				file.Indexed = true
+			}
+		} else if matches := metadataFileRegex.FindStringSubmatch(name); matches != nil {
+			if secretsHashes[matches[1]] {
+				// listed alongside its fallback file
+				continue
+			}
+
+			file.OrphanedMetadata = true
+			if entry, ok := entriesByMetadata[path]; ok {
+				file.FallbackIndexEntry = entry
+				file.Indexed = true
+			}
+			file.Path = path
+		} else if !legacyFallbackFileRegex.MatchString(name) {
+			continue
+		}
+
+		listed[path] = true
+		files = append(files, file)
+	}
+
+	// include fallback files written to a custom location
+	for path, entry := range index.Files {
+		if listed[path] {
+			continue
+		}
+
+		stat, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		file := models.FallbackFile{FallbackIndexEntry: entry, Name: filepath.Base(path), ModifiedAt: stat.ModTime(), Indexed: true}
+		file.HasMetadata = entry.MetadataPath != "" && utils.Exists(entry.MetadataPath)
+		files = append(files, file)
+	}
+
+	// most recently modified first
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].ModifiedAt.After(files[b].ModifiedAt)
+	})
+
+	return files, Error{}
+}
@@ -0,0 +1,218 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+)
+
+// useFallbackDir points the fallback directory at an empty temp directory
+func useFallbackDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "doppler-fallback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := DefaultMetadataDir
+	DefaultMetadataDir = dir
+	fallbackIndexPassphraseCache = ""
+	t.Cleanup(func() {
+		DefaultMetadataDir = previous
+		fallbackIndexPassphraseCache = ""
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func TestFallbackIndexPassphrase(t *testing.T) {
+	dir := useFallbackDir(t)
+	keyring := NewMemoryKeyring()
+	useKeyring(t, keyring)
+
+	passphrase, err := fallbackIndexPassphrase()
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+
+	// the passphrase is cached, so the keyring isn't accessed again
+	useKeyring(t, unavailableKeyring{})
+	if cached, err := fallbackIndexPassphrase(); !err.IsNil() || cached != passphrase {
+		t.Errorf("Expected the cached passphrase, got %q %v", cached, err.Unwrap())
+	}
+
+	useKeyring(t, keyring)
+	fallbackIndexPassphraseCache = ""
+	if stored, err := fallbackIndexPassphrase(); !err.IsNil() || stored != passphrase {
+		t.Errorf("Expected the passphrase stored in the keyring, got %q %v", stored, err.Unwrap())
+	}
+	if fileExists(filepath.Join(dir, fallbackIndexKeyFileName)) {
+		t.Error("Expected no key file when the keyring is available")
+	}
+}
+
+func TestFallbackIndexPassphraseKeyringError(t *testing.T) {
+	dir := useFallbackDir(t)
+	useKeyring(t, unavailableKeyring{})
+	if err := ioutil.WriteFile(FallbackIndexPath(), []byte("encrypted"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new passphrase would make the existing index unreadable
+	if _, err := fallbackIndexPassphrase(); err.IsNil() {
+		t.Fatal("Expected an error when the keyring can't be read")
+	}
+	if fileExists(filepath.Join(dir, fallbackIndexKeyFileName)) {
+		t.Error("Expected no key file to be written")
+	}
+}
+
+func TestFallbackIndexPassphraseWithoutKeyring(t *testing.T) {
+	dir := useFallbackDir(t)
+	useKeyring(t, fallbackKeyring{primary: unavailableKeyring{}, fallback: unavailableKeyring{}})
+
+	// without an index there's nothing to lose, so the passphrase is saved to the key file
+	passphrase, err := fallbackIndexPassphrase()
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	contents, readErr := ioutil.ReadFile(filepath.Join(dir, fallbackIndexKeyFileName))
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	if string(contents) != passphrase {
+		t.Error("Expected the passphrase to be saved to the key file")
+	}
+}
+
+func TestUpdateFallbackIndex(t *testing.T) {
+	dir := useFallbackDir(t)
+	useKeyring(t, NewMemoryKeyring())
+
+	entry := models.FallbackIndexEntry{Path: filepath.Join(dir, "fallback.json"), Project: "backend", Config: "dev", ETag: "1", UpdatedAt: time.Now()}
+	if err := UpdateFallbackIndex(entry); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	written := readIndex(t)
+
+	// only the time changed
+	entry.UpdatedAt = entry.UpdatedAt.Add(time.Minute)
+	if err := UpdateFallbackIndex(entry); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if !bytes.Equal(readIndex(t), written) {
+		t.Error("Expected the index not to be rewritten for an unchanged entry")
+	}
+
+	entry.ETag = "2"
+	if err := UpdateFallbackIndex(entry); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	index, err := FallbackIndex()
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if indexed := index.Files[entry.Path]; indexed.ETag != "2" || indexed.Project != "backend" {
+		t.Errorf("Expected the updated entry, got %+v", indexed)
+	}
+}
+
+func TestFallbackFiles(t *testing.T) {
+	dir := useFallbackDir(t)
+	useKeyring(t, NewMemoryKeyring())
+
+	hash := strings.Repeat("a", 64)
+	orphanHash := strings.Repeat("b", 64)
+	for _, name := range []string{
+		fmt.Sprintf(".secrets-%s.json", hash),
+		fmt.Sprintf(".metadata-%s.json", hash),
+		fmt.Sprintf(".metadata-%s.json", orphanHash),
+		fmt.Sprintf(".run-%s.json", hash),
+		"unrelated.txt",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a fallback file written to a custom location via --fallback
+	custom := filepath.Join(dir, "custom", "fallback.json")
+	if err := os.MkdirAll(filepath.Dir(custom), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(custom, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	secretsPath := filepath.Join(dir, fmt.Sprintf(".secrets-%s.json", hash))
+	for _, entry := range []models.FallbackIndexEntry{
+		{Path: secretsPath, Project: "backend", Config: "dev"},
+		{Path: custom, Project: "frontend", Config: "prd"},
+		// the file no longer exists
+		{Path: filepath.Join(dir, "deleted.json"), Project: "backend", Config: "stg"},
+	} {
+		if err := UpdateFallbackIndex(entry); !err.IsNil() {
+			t.Fatal(err.Unwrap())
+		}
+	}
+
+	files, err := FallbackFiles(dir)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+
+	byName := map[string]models.FallbackFile{}
+	for _, file := range files {
+		byName[file.Name] = file
+	}
+	if len(files) != 4 {
+		t.Errorf("Expected 4 files, got %d: %+v", len(files), files)
+	}
+
+	secrets := byName[fmt.Sprintf(".secrets-%s.json", hash)]
+	if !secrets.Indexed || !secrets.HasMetadata || secrets.Project != "backend" || secrets.Config != "dev" {
+		t.Errorf("Unexpected fallback file %+v", secrets)
+	}
+	if orphan := byName[fmt.Sprintf(".metadata-%s.json", orphanHash)]; !orphan.OrphanedMetadata || orphan.Indexed {
+		t.Errorf("Unexpected orphaned metadata file %+v", orphan)
+	}
+	if legacy, ok := byName[fmt.Sprintf(".run-%s.json", hash)]; !ok || legacy.Indexed {
+		t.Errorf("Unexpected legacy fallback file %+v", legacy)
+	}
+	if customFile := byName["fallback.json"]; !customFile.Indexed || customFile.Project != "frontend" || customFile.Path != custom {
+		t.Errorf("Unexpected custom fallback file %+v", customFile)
+	}
+}
+
+func readIndex(t *testing.T) []byte {
+	contents, err := ioutil.ReadFile(FallbackIndexPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return contents
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
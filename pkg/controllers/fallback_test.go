@@ -0,0 +1,60 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/crypto"
+	"github.com/DopplerHQ/cli/pkg/models"
+)
+
+func TestFallbackFileVerifiedAt(t *testing.T) {
+	dir := useFallbackDir(t)
+	metadataPath := filepath.Join(dir, ".metadata-test.json")
+	contents := []byte("encrypted secrets")
+	modTime := time.Now().Add(-24 * time.Hour)
+
+	// without metadata, the file's modification time is used
+	if verifiedAt := FallbackFileVerifiedAt(metadataPath, contents, modTime); !verifiedAt.Equal(modTime) {
+		t.Errorf("Expected the modification time, got %s", verifiedAt)
+	}
+
+	verified := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := writeMetadataFile(metadataPath, models.SecretsFileMetadata{ETag: "1", Hash: crypto.Hash(string(contents)), VerifiedAt: verified}); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if verifiedAt := FallbackFileVerifiedAt(metadataPath, contents, modTime); !verifiedAt.Equal(verified) {
+		t.Errorf("Expected the metadata's verification time, got %s", verifiedAt)
+	}
+	// metadata describing other contents doesn't apply
+	if verifiedAt := FallbackFileVerifiedAt(metadataPath, []byte("other"), modTime); !verifiedAt.Equal(modTime) {
+		t.Errorf("Expected the modification time for other contents, got %s", verifiedAt)
+	}
+
+	if err := MarkMetadataVerified(metadataPath); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	metadata, err := MetadataFile(metadataPath)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if metadata.ETag != "1" || metadata.Hash != crypto.Hash(string(contents)) || time.Since(metadata.VerifiedAt) > time.Minute {
+		t.Errorf("Expected only the verification time to change, got %+v", metadata)
+	}
+}
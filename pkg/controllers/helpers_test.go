@@ -13,12 +13,18 @@ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
 See the License for the specific language governing permissions and
 limitations under the License.
 */
-package http
+package controllers
 
-import "time"
-
-// UseTimeout whether to timeout long-running requests
-var UseTimeout = true
-
-// TimeoutDuration how long to wait for a request to complete before timing out
-var TimeoutDuration = 10 * time.Second
+// shellMetacharacterValues values that would be interpreted by the shell if they weren't quoted or escaped correctly
+var shellMetacharacterValues = map[string]string{
+	"SIMPLE":      "value",
+	"SINGLE":      "it's",
+	"DOUBLE":      `say "hi"`,
+	"DOLLAR":      "$HOME ${HOME}",
+	"SUBSHELL":    "$(echo injected) `echo injected`",
+	"BACKSLASH":   `C:\path\ \' \\`,
+	"NEWLINE":     "line1\nline2\n",
+	"SEMICOLON":   "a; echo injected",
+	"FISH_ESCAPE": `\'; echo injected; '`,
+	"EMPTY":       "",
+}
@@ -18,13 +18,126 @@ package controllers
 import (
 	"fmt"
 	"strings"
+	"sync"
 
+	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/zalando/go-keyring"
 )
 
 const keyringService = "doppler-cli"
 const keyringSecretPrefix = "secret"
 
+// KeyringProvider stores secrets outside of the config file
+type KeyringProvider interface {
+	Get(service string, user string) (string, error)
+	Set(service string, user string, password string) error
+	Delete(service string, user string) error
+}
+
+// Keyring the provider used to store secrets. The system keyring is used when available, and an encrypted file otherwise.
+var Keyring KeyringProvider = fallbackKeyring{primary: systemKeyring{}, fallback: fileKeyring{}}
+
+// systemKeyring the OS keyring (e.g. macOS Keychain, Windows Credential Manager, Secret Service on Linux)
+type systemKeyring struct{}
+
+func (systemKeyring) Get(service string, user string) (string, error) {
+	return keyring.Get(service, user)
+}
+
+func (systemKeyring) Set(service string, user string, password string) error {
+	return keyring.Set(service, user, password)
+}
+
+func (systemKeyring) Delete(service string, user string) error {
+	return keyring.Delete(service, user)
+}
+
+// fallbackKeyring uses the primary keyring when available, and the fallback keyring otherwise
+type fallbackKeyring struct {
+	primary  KeyringProvider
+	fallback KeyringProvider
+}
+
+// Get reads the value from the primary keyring, and from the fallback keyring when the primary doesn't have it.
+// When the primary keyring fails (e.g. a locked keychain or a D-Bus timeout) and the fallback doesn't have the value,
+// the primary's error is returned rather than ErrNotFound, so callers don't mistake the failure for a missing value.
+func (k fallbackKeyring) Get(service string, user string) (string, error) {
+	value, err := k.primary.Get(service, user)
+	if err == nil {
+		return value, nil
+	}
+
+	value, fallbackErr := k.fallback.Get(service, user)
+	if fallbackErr == nil {
+		return value, nil
+	}
+	if err == keyring.ErrNotFound {
+		return "", fallbackErr
+	}
+	return "", err
+}
+
+func (k fallbackKeyring) Set(service string, user string, password string) error {
+	err := k.primary.Set(service, user, password)
+	if err == nil {
+		return nil
+	}
+
+	utils.LogDebugError(err)
+	utils.LogDebug("System keyring is unavailable, saving value to encrypted keyring file")
+	return k.fallback.Set(service, user, password)
+}
+
+func (k fallbackKeyring) Delete(service string, user string) error {
+	err := k.primary.Delete(service, user)
+	if fallbackErr := k.fallback.Delete(service, user); fallbackErr == nil {
+		return nil
+	}
+	return err
+}
+
+// MemoryKeyring a keyring that stores secrets in memory, for use in tests
+type MemoryKeyring struct {
+	mutex   sync.Mutex
+	secrets map[string]string
+}
+
+// NewMemoryKeyring creates an empty in-memory keyring
+func NewMemoryKeyring() *MemoryKeyring {
+	return &MemoryKeyring{secrets: map[string]string{}}
+}
+
+// Get a secret from the keyring
+func (k *MemoryKeyring) Get(service string, user string) (string, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	value, ok := k.secrets[keyringFileKey(service, user)]
+	if !ok {
+		return "", keyring.ErrNotFound
+	}
+	return value, nil
+}
+
+// Set a secret in the keyring
+func (k *MemoryKeyring) Set(service string, user string, password string) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.secrets[keyringFileKey(service, user)] = password
+	return nil
+}
+
+// Delete a secret from the keyring
+func (k *MemoryKeyring) Delete(service string, user string) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	key := keyringFileKey(service, user)
+	if _, ok := k.secrets[key]; !ok {
+		return keyring.ErrNotFound
+	}
+	delete(k.secrets, key)
+	return nil
+}
+
 // IsKeyringSecret checks whether the secret is stored in keyring
 func IsKeyringSecret(value string) bool {
 	return strings.HasPrefix(value, fmt.Sprintf("%s-", keyringSecretPrefix))
@@ -37,7 +150,7 @@ func GenerateKeyringID(id string) string {
 
 // GetKeyring fetches a secret from the keyring
 func GetKeyring(id string) (string, Error) {
-	value, err := keyring.Get(keyringService, id)
+	value, err := Keyring.Get(keyringService, id)
 	if err != nil {
 		if err == keyring.ErrUnsupportedPlatform {
 			return "", Error{Err: err, Message: "Your OS does not support keyring"}
@@ -53,7 +166,7 @@ func GetKeyring(id string) (string, Error) {
 
 // SetKeyring saves a value to the keyring
 func SetKeyring(key string, value string) Error {
-	if err := keyring.Set(keyringService, key, value); err != nil {
+	if err := Keyring.Set(keyringService, key, value); err != nil {
 		if err == keyring.ErrUnsupportedPlatform {
 			return Error{Err: err, Message: "Your OS does not support keyring"}
 		} else {
@@ -66,7 +179,7 @@ func SetKeyring(key string, value string) Error {
 
 // DeleteKeyring removes a value from the keyring
 func DeleteKeyring(key string) Error {
-	if err := keyring.Delete(keyringService, key); err != nil {
+	if err := Keyring.Delete(keyringService, key); err != nil {
 		return Error{Err: err, Message: "Unable to remove value from keyring"}
 	}
 
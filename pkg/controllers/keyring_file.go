@@ -0,0 +1,190 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DopplerHQ/cli/pkg/crypto"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/zalando/go-keyring"
+)
+
+// KeyringFile the encrypted file used to store secrets when the system keyring is unavailable (e.g. on headless linux)
+var KeyringFile string
+
+// machineIDFiles files containing a unique ID for the machine, on systems that have one
+var machineIDFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// fileKeyring stores secrets in a file encrypted with a key derived from a machine secret.
+// the machine secret combines the machine ID with a random key that's only readable by the user,
+// so the file can't be decrypted after being copied to another machine.
+type fileKeyring struct{}
+
+func keyringFileKey(service string, user string) string {
+	return fmt.Sprintf("%s/%s", service, user)
+}
+
+func keyringKeyFile() string {
+	return fmt.Sprintf("%s.key", KeyringFile)
+}
+
+// machineSecret retrieves the secret used to encrypt the keyring file, generating its random key if necessary
+func machineSecret() (string, error) {
+	keyFile := keyringKeyFile()
+	var key string
+	if utils.Exists(keyFile) {
+		contents, err := ioutil.ReadFile(keyFile) // #nosec G304
+		if err != nil {
+			return "", err
+		}
+		key = string(contents)
+	} else {
+		utils.LogDebug(fmt.Sprintf("Generating keyring file key %s", keyFile))
+		key = utils.RandomBase64String(32)
+		if err := utils.WriteFile(keyFile, []byte(key), utils.RestrictedFilePerms()); err != nil {
+			return "", err
+		}
+	}
+
+	machineID := ""
+	for _, file := range machineIDFiles {
+		if contents, err := ioutil.ReadFile(file); err == nil { // #nosec G304
+			machineID = strings.TrimSpace(string(contents))
+			break
+		}
+	}
+
+	return crypto.Hash(machineID + key), nil
+}
+
+func (fileKeyring) read() (map[string]string, error) {
+	secrets := map[string]string{}
+	if !utils.Exists(KeyringFile) {
+		return secrets, nil
+	}
+
+	contents, err := ioutil.ReadFile(KeyringFile) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+
+	passphrase, err := machineSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := crypto.Decrypt(passphrase, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(decrypted), &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func (fileKeyring) write(secrets map[string]string) error {
+	body, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := machineSecret()
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := crypto.Encrypt(passphrase, body)
+	if err != nil {
+		return err
+	}
+
+	utils.LogDebug(fmt.Sprintf("Writing keyring file %s", KeyringFile))
+	return utils.WriteFile(KeyringFile, []byte(encrypted), utils.RestrictedFilePerms())
+}
+
+// update modifies the keyring file while holding an exclusive lock on it
+func (k fileKeyring) update(modify func(secrets map[string]string) error) error {
+	lock, err := utils.LockFile(KeyringFile+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock() // #nosec G307
+
+	secrets, err := k.read()
+	if err != nil {
+		return err
+	}
+	if err := modify(secrets); err != nil {
+		return err
+	}
+	return k.write(secrets)
+}
+
+func (k fileKeyring) Get(service string, user string) (string, error) {
+	if KeyringFile == "" {
+		return "", errors.New("Keyring file is not configured")
+	}
+
+	secrets, err := k.read()
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := secrets[keyringFileKey(service, user)]
+	if !ok {
+		return "", keyring.ErrNotFound
+	}
+	return value, nil
+}
+
+func (k fileKeyring) Set(service string, user string, password string) error {
+	if KeyringFile == "" {
+		return errors.New("Keyring file is not configured")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(KeyringFile), 0700); err != nil {
+		return err
+	}
+
+	return k.update(func(secrets map[string]string) error {
+		secrets[keyringFileKey(service, user)] = password
+		return nil
+	})
+}
+
+func (k fileKeyring) Delete(service string, user string) error {
+	if KeyringFile == "" || !utils.Exists(KeyringFile) {
+		return keyring.ErrNotFound
+	}
+
+	return k.update(func(secrets map[string]string) error {
+		key := keyringFileKey(service, user)
+		if _, ok := secrets[key]; !ok {
+			return keyring.ErrNotFound
+		}
+		delete(secrets, key)
+		return nil
+	})
+}
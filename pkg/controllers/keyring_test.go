@@ -0,0 +1,185 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+var errUnavailable = errors.New("keyring unavailable")
+
+// unavailableKeyring simulates a system keyring that can't be reached (e.g. no secret service on headless linux)
+type unavailableKeyring struct{}
+
+func (unavailableKeyring) Get(service string, user string) (string, error) {
+	return "", errUnavailable
+}
+
+func (unavailableKeyring) Set(service string, user string, password string) error {
+	return errUnavailable
+}
+
+func (unavailableKeyring) Delete(service string, user string) error {
+	return errUnavailable
+}
+
+func useKeyring(t *testing.T, provider KeyringProvider) {
+	previous := Keyring
+	Keyring = provider
+	t.Cleanup(func() { Keyring = previous })
+}
+
+func useKeyringFile(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "doppler-keyring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := KeyringFile
+	KeyringFile = filepath.Join(dir, "keyring")
+	t.Cleanup(func() {
+		KeyringFile = previous
+		os.RemoveAll(dir)
+	})
+	return KeyringFile
+}
+
+func TestMemoryKeyring(t *testing.T) {
+	useKeyring(t, NewMemoryKeyring())
+
+	id := GenerateKeyringID("test")
+	if _, err := GetKeyring(id); err.Unwrap() != keyring.ErrNotFound {
+		t.Fatalf("expected not found error, got %v", err.Unwrap())
+	}
+
+	if err := SetKeyring(id, "dp.ct.123"); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	value, err := GetKeyring(id)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if value != "dp.ct.123" {
+		t.Errorf("expected dp.ct.123, got %s", value)
+	}
+
+	if err := DeleteKeyring(id); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if _, err := GetKeyring(id); err.Unwrap() != keyring.ErrNotFound {
+		t.Errorf("expected not found error after delete, got %v", err.Unwrap())
+	}
+}
+
+func TestFallbackToKeyringFile(t *testing.T) {
+	path := useKeyringFile(t)
+	useKeyring(t, fallbackKeyring{primary: unavailableKeyring{}, fallback: fileKeyring{}})
+
+	id := GenerateKeyringID("test")
+	if err := SetKeyring(id, "dp.ct.123"); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(contents), "dp.ct.123") {
+		t.Error("expected keyring file to be encrypted")
+	}
+
+	value, getErr := GetKeyring(id)
+	if !getErr.IsNil() {
+		t.Fatal(getErr.Unwrap())
+	}
+	if value != "dp.ct.123" {
+		t.Errorf("expected dp.ct.123, got %s", value)
+	}
+
+	if err := DeleteKeyring(id); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	// the system keyring can't be read, so its error is reported rather than not found
+	if _, err := GetKeyring(id); err.Unwrap() != errUnavailable {
+		t.Errorf("expected the system keyring's error after delete, got %v", err.Unwrap())
+	}
+}
+
+// failingKeyring a system keyring that's present but fails to read values (e.g. a locked keychain)
+type failingKeyring struct {
+	*MemoryKeyring
+}
+
+func (failingKeyring) Get(service string, user string) (string, error) {
+	return "", errUnavailable
+}
+
+func TestKeyringErrorNotHidden(t *testing.T) {
+	useKeyringFile(t)
+	useKeyring(t, fallbackKeyring{primary: failingKeyring{NewMemoryKeyring()}, fallback: fileKeyring{}})
+
+	id := GenerateKeyringID("test")
+	if err := SetKeyring(id, "dp.ct.123"); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+
+	// the value isn't in the keyring file, but it may still be in the system keyring, so it's not reported as missing
+	if _, err := GetKeyring(id); err.Unwrap() != errUnavailable {
+		t.Errorf("expected the system keyring's error, got %v", err.Unwrap())
+	}
+}
+
+func TestPrimaryKeyringPreferred(t *testing.T) {
+	path := useKeyringFile(t)
+	primary := NewMemoryKeyring()
+	useKeyring(t, fallbackKeyring{primary: primary, fallback: fileKeyring{}})
+
+	id := GenerateKeyringID("test")
+	if err := SetKeyring(id, "dp.ct.123"); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+
+	if _, err := primary.Get(keyringService, id); err != nil {
+		t.Errorf("expected value in primary keyring, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected keyring file to not be created")
+	}
+}
+
+func TestKeyringFileRequiresMachineSecret(t *testing.T) {
+	useKeyringFile(t)
+	useKeyring(t, fileKeyring{})
+
+	id := GenerateKeyringID("test")
+	if err := SetKeyring(id, "dp.ct.123"); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+
+	// a different key can't decrypt the file
+	if err := os.Remove(keyringKeyFile()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetKeyring(id); err.IsNil() {
+		t.Error("expected error when decrypting with a different key")
+	}
+}
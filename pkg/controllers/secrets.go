@@ -0,0 +1,87 @@
+/*
+Copyright © 2020 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/DopplerHQ/cli/pkg/crypto"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// DecryptSecretsFile reads and decrypts a secrets file written by the CLI
+func DecryptSecretsFile(path string, passphrase string) (string, Error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", Error{Err: err, Message: "Secrets file does not exist"}
+		}
+		return "", Error{Err: err, Message: "Unable to read secrets file"}
+	}
+
+	contents, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return "", Error{Err: err, Message: "Unable to read secrets file"}
+	}
+
+	utils.LogDebug(fmt.Sprintf("Decrypting secrets file %s", path))
+	decrypted, err := crypto.Decrypt(passphrase, contents)
+	if err != nil {
+		return "", Error{Err: err, Message: "Unable to decrypt secrets file. The default passphrase is computed using your token, project, and config."}
+	}
+
+	return decrypted, Error{}
+}
+
+// FormatSecrets converts secrets to the specified format
+func FormatSecrets(secrets map[string]string, format models.SecretsFormat) ([]byte, Error) {
+	switch format {
+	case models.JSON:
+		body, err := json.Marshal(secrets)
+		if err != nil {
+			return nil, Error{Err: err, Message: "Unable to convert secrets to JSON"}
+		}
+		return body, Error{}
+	case models.YAML:
+		body, err := yaml.Marshal(secrets)
+		if err != nil {
+			return nil, Error{Err: err, Message: "Unable to convert secrets to YAML"}
+		}
+		return body, Error{}
+	case models.ENV:
+		var names []string
+		for name := range secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		// values are double-quoted, so characters the shell would expand are escaped too
+		replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, `$`, `\$`, "`", "\\`")
+		var lines []string
+		for _, name := range names {
+			lines = append(lines, fmt.Sprintf("%s=\"%s\"", name, replacer.Replace(secrets[name])))
+		}
+		return []byte(strings.Join(lines, "\n")), Error{}
+	}
+
+	return nil, Error{Err: fmt.Errorf("unsupported format %s", format)}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DopplerHQ/cli/pkg/crypto"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFormatSecrets(t *testing.T) {
+	secrets := map[string]string{"B": "two", "A": `say "hi"`}
+
+	body, err := FormatSecrets(secrets, models.JSON)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	var parsed map[string]string
+	if jsonErr := json.Unmarshal(body, &parsed); jsonErr != nil || !reflect.DeepEqual(parsed, secrets) {
+		t.Errorf("Expected json secrets, got %s", body)
+	}
+
+	body, err = FormatSecrets(secrets, models.YAML)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	parsed = nil
+	if yamlErr := yaml.Unmarshal(body, &parsed); yamlErr != nil || !reflect.DeepEqual(parsed, secrets) {
+		t.Errorf("Expected yaml secrets, got %s", body)
+	}
+
+	if _, err := FormatSecrets(secrets, models.SecretsFormat(-1)); err.IsNil() {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
+
+func TestFormatSecretsEnv(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"value", `"value"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\path`, `"C:\\path"`},
+		{"line1\nline2", `"line1\nline2"`},
+		{"$HOME ${HOME}", `"\$HOME \${HOME}"`},
+		{"$(id) `id`", "\"\\$(id) \\`id\\`\""},
+		{"it's", `"it's"`},
+	}
+	for _, test := range tests {
+		body, err := FormatSecrets(map[string]string{"SECRET": test.value}, models.ENV)
+		if !err.IsNil() {
+			t.Fatal(err.Unwrap())
+		}
+		if expected := "SECRET=" + test.expected; string(body) != expected {
+			t.Errorf("Expected %q to be formatted as %s, got %s", test.value, expected, body)
+		}
+	}
+
+	// names are sorted
+	body, _ := FormatSecrets(map[string]string{"B": "2", "A": "1"}, models.ENV)
+	if string(body) != "A=\"1\"\nB=\"2\"" {
+		t.Errorf("Expected sorted names, got %q", body)
+	}
+}
+
+func TestFormatSecretsEnvSourced(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	// newlines are escaped for dotenv parsers, which the shell doesn't unescape
+	secrets := map[string]string{}
+	for name, value := range shellMetacharacterValues {
+		if !strings.Contains(value, "\n") {
+			secrets[name] = value
+		}
+	}
+	body, err := FormatSecrets(secrets, models.ENV)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+
+	for name, value := range secrets {
+		script := string(body) + "\nprintf '%s' \"$" + name + "\""
+		output, err := exec.Command("sh", "-c", script).Output() // #nosec G204
+		if err != nil {
+			t.Fatalf("Unable to source secrets: %s", err)
+		}
+		if string(output) != value {
+			t.Errorf("%s: expected %q, got %q", name, value, output)
+		}
+	}
+}
+
+func TestSecretsFileInfo(t *testing.T) {
+	dir := useFallbackDir(t)
+	path := filepath.Join(dir, ".secrets-test.json")
+	metadataPath := filepath.Join(dir, ".metadata-test.json")
+
+	if _, err := SecretsFileInfo(path, metadataPath); err.IsNil() || !os.IsNotExist(err.Unwrap()) {
+		t.Errorf("Expected an error for a missing secrets file, got %v", err.Unwrap())
+	}
+
+	contents := []byte("encrypted secrets")
+	if err := ioutil.WriteFile(path, contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+	hash := crypto.Hash(string(contents))
+
+	// the metadata file is optional
+	info, err := SecretsFileInfo(path, metadataPath)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if info.Path != path || info.Size != int64(len(contents)) || info.Hash != hash || info.MetadataPath != "" || info.HashMatches {
+		t.Errorf("Unexpected info without metadata %+v", info)
+	}
+
+	if err := WriteMetadataFile(metadataPath, `"1"`, hash); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	info, err = SecretsFileInfo(path, metadataPath)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if info.MetadataPath != metadataPath || info.ETag != `"1"` || info.MetadataHash != hash || !info.HashMatches {
+		t.Errorf("Unexpected info with metadata %+v", info)
+	}
+
+	if err := WriteMetadataFile(metadataPath, `"2"`, "other"); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if info, _ = SecretsFileInfo(path, metadataPath); info.HashMatches {
+		t.Errorf("Expected the hash not to match %+v", info)
+	}
+
+	if err := ioutil.WriteFile(metadataPath, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SecretsFileInfo(path, metadataPath); err.IsNil() {
+		t.Error("Expected an error for an invalid metadata file")
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright © 2020 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ShellHookScopeEnv environment variable tracking the scope whose secrets are loaded into the shell
+const ShellHookScopeEnv = "DOPPLER_SHELL_HOOK_SCOPE"
+
+// ShellHookKeysEnv environment variable tracking the names of the secrets loaded into the shell
+const ShellHookKeysEnv = "DOPPLER_SHELL_HOOK_KEYS"
+
+// ShellHookShells the shells supported by the shell hook
+var ShellHookShells = []string{"bash", "zsh", "fish"}
+
+var envVarNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// the hooks run before each prompt so that changes to the directory or its configuration are picked up.
+// the export command exits early when nothing has changed.
+const bashHook = `_doppler_hook() {
+  local previous_exit_status=$?
+  eval "$(%[1]s shell-hook export bash)"
+  return $previous_exit_status
+}
+if [[ ";${PROMPT_COMMAND[*]:-};" != *";_doppler_hook;"* ]]; then
+  PROMPT_COMMAND="_doppler_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`
+
+const zshHook = `_doppler_hook() {
+  eval "$(%[1]s shell-hook export zsh)"
+}
+typeset -ag precmd_functions
+if (( ! ${precmd_functions[(I)_doppler_hook]} )); then
+  precmd_functions=(_doppler_hook $precmd_functions)
+fi
+`
+
+const fishHook = `function __doppler_hook --on-event fish_prompt
+    %[1]s shell-hook export fish | source
+end
+`
+
+// ShellHookScript the script that loads secrets whenever the shell's directory changes
+func ShellHookScript(shell string, executable string) (string, Error) {
+	var script string
+	switch shell {
+	case "bash":
+		script = bashHook
+	case "zsh":
+		script = zshHook
+	case "fish":
+		script = fishHook
+	default:
+		return "", Error{Err: fmt.Errorf("Unsupported shell %s", shell), Message: fmt.Sprintf("Supported shells: %s", strings.Join(ShellHookShells, ", "))}
+	}
+
+	return fmt.Sprintf(script, quoteShellValue(shell, executable)), Error{}
+}
+
+// ShellHookExports the shell commands that unset the previously loaded secrets and export the new ones.
+// The names of the exported secrets are tracked so they can be unset when the scope changes.
+func ShellHookExports(shell string, scope string, previousKeys []string, secrets map[string]string) (string, Error) {
+	if !isShellHookShell(shell) {
+		return "", Error{Err: fmt.Errorf("Unsupported shell %s", shell), Message: fmt.Sprintf("Supported shells: %s", strings.Join(ShellHookShells, ", "))}
+	}
+
+	var lines []string
+	for _, key := range previousKeys {
+		if _, exists := secrets[key]; exists || !envVarNameRegex.MatchString(key) {
+			continue
+		}
+		lines = append(lines, unsetStatement(shell, key))
+	}
+
+	var keys []string
+	for key := range secrets {
+		// names are never quoted, so anything other than a valid name could inject commands
+		if ValidShellHookSecretName(key) != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		lines = append(lines, exportStatement(shell, key, secrets[key]))
+	}
+
+	lines = append(lines, exportStatement(shell, ShellHookScopeEnv, scope))
+	if len(keys) > 0 {
+		lines = append(lines, exportStatement(shell, ShellHookKeysEnv, strings.Join(keys, ",")))
+	} else {
+		lines = append(lines, unsetStatement(shell, ShellHookKeysEnv))
+	}
+
+	return strings.Join(lines, "\n") + "\n", Error{}
+}
+
+// ValidShellHookSecretName whether the secret can be exported as an environment variable
+func ValidShellHookSecretName(name string) error {
+	if !envVarNameRegex.MatchString(name) {
+		return errors.New("Secret name is not a valid environment variable name")
+	}
+	if name == ShellHookScopeEnv || name == ShellHookKeysEnv {
+		return errors.New("Secret name is reserved by the shell hook")
+	}
+	return nil
+}
+
+func isShellHookShell(shell string) bool {
+	for _, s := range ShellHookShells {
+		if s == shell {
+			return true
+		}
+	}
+	return false
+}
+
+func exportStatement(shell string, key string, value string) string {
+	if shell == "fish" {
+		return fmt.Sprintf("set -gx %s %s;", key, quoteShellValue(shell, value))
+	}
+	return fmt.Sprintf("export %s=%s;", key, quoteShellValue(shell, value))
+}
+
+func unsetStatement(shell string, key string) string {
+	if shell == "fish" {
+		return fmt.Sprintf("set -e %s;", key)
+	}
+	return fmt.Sprintf("unset %s;", key)
+}
+
+// quoteShellValue single quotes the value so that it's interpreted literally
+func quoteShellValue(shell string, value string) string {
+	if shell == "fish" {
+		// fish allows escaping backslashes and single quotes within single quotes
+		value = strings.Replace(value, `\`, `\\`, -1)
+		value = strings.Replace(value, `'`, `\'`, -1)
+		return fmt.Sprintf("'%s'", value)
+	}
+
+	// bash and zsh don't allow escaping within single quotes, so close the quote, add an escaped quote, and reopen it
+	return fmt.Sprintf("'%s'", strings.Replace(value, `'`, `'\''`, -1))
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestQuoteShellValue(t *testing.T) {
+	tests := []struct {
+		shell    string
+		value    string
+		expected string
+	}{
+		{"bash", "value", `'value'`},
+		{"bash", "it's", `'it'\''s'`},
+		{"bash", "$HOME `id` \\", "'$HOME `id` \\'"},
+		{"bash", "a\nb", "'a\nb'"},
+		{"zsh", "it's", `'it'\''s'`},
+		{"fish", "value", `'value'`},
+		{"fish", "it's", `'it\'s'`},
+		{"fish", `C:\path`, `'C:\\path'`},
+		{"fish", `\'`, `'\\\''`},
+		{"fish", "$HOME `id`", "'$HOME `id`'"},
+	}
+
+	for _, test := range tests {
+		if quoted := quoteShellValue(test.shell, test.value); quoted != test.expected {
+			t.Errorf("%s: expected %q to be quoted as %s, got %s", test.shell, test.value, test.expected, quoted)
+		}
+	}
+}
+
+func TestShellHookExports(t *testing.T) {
+	secrets := map[string]string{"API_KEY": "123", "INVALID-NAME": "x", "$(echo injected)": "x", ShellHookScopeEnv: "x"}
+	exports, err := ShellHookExports("bash", "/project", []string{"OLD_KEY", "API_KEY", "bad;name"}, secrets)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+
+	expected := strings.Join([]string{
+		"unset OLD_KEY;",
+		"export API_KEY='123';",
+		"export DOPPLER_SHELL_HOOK_SCOPE='/project';",
+		"export DOPPLER_SHELL_HOOK_KEYS='API_KEY';",
+	}, "\n") + "\n"
+	if exports != expected {
+		t.Errorf("Expected exports:\n%s\ngot:\n%s", expected, exports)
+	}
+
+	exports, err = ShellHookExports("fish", "", []string{"API_KEY"}, map[string]string{})
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if expected := "set -e API_KEY;\nset -gx DOPPLER_SHELL_HOOK_SCOPE '';\nset -e DOPPLER_SHELL_HOOK_KEYS;\n"; exports != expected {
+		t.Errorf("Expected exports:\n%s\ngot:\n%s", expected, exports)
+	}
+
+	if _, err := ShellHookExports("powershell", "", nil, secrets); err.IsNil() {
+		t.Error("Expected an error for an unsupported shell")
+	}
+}
+
+// TestShellHookExportsEvaluated evaluates the exports in each installed shell, verifying every value is exported literally
+func TestShellHookExportsEvaluated(t *testing.T) {
+	for _, shell := range ShellHookShells {
+		path, err := exec.LookPath(shell)
+		if err != nil {
+			t.Logf("Skipping %s, which isn't installed", shell)
+			continue
+		}
+
+		exports, exportsErr := ShellHookExports(shell, "/project", nil, shellMetacharacterValues)
+		if !exportsErr.IsNil() {
+			t.Fatal(exportsErr.Unwrap())
+		}
+
+		for name, value := range shellMetacharacterValues {
+			// printf avoids echo's shell-specific handling of backslashes
+			script := exports + "\nprintf '%s' \"$" + name + "\""
+			output, err := exec.Command(path, "-c", script).Output() // #nosec G204
+			if err != nil {
+				t.Errorf("%s: unable to evaluate exports: %v", shell, err)
+				continue
+			}
+			if string(output) != value {
+				t.Errorf("%s: expected %s to be %q, got %q", shell, name, value, output)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/crypto"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+)
+
+// TokenHelperCacheTTL how long a token printed by a token helper is cached, unless the helper specifies when it expires
+var TokenHelperCacheTTL = 5 * time.Minute
+
+// TokenHelperTimeout the max time to wait for a token helper to exit
+var TokenHelperTimeout = 30 * time.Second
+
+// tokenHelperCacheID the keyring ID of the helper's cached token for the scope
+func tokenHelperCacheID(helper string, scope string) string {
+	return GenerateKeyringID(fmt.Sprintf("token-helper-%s", crypto.Hash(helper+"\n"+scope)))
+}
+
+// TokenFromHelper retrieves a token from the token helper, an executable that prints a token to stdout.
+// The helper may instead print JSON containing the token and when it expires (e.g. {"token": "dp.st.xxx", "expires_in": 3600}).
+// Tokens are cached in the system keyring until they expire. The helper may print a different token depending on where it's used,
+// so tokens are cached separately for each scope (e.g. the config scope, API host, and profile). The helper is killed if ctx is canceled.
+func TokenFromHelper(ctx context.Context, helper string, scope string) (string, Error) {
+	id := tokenHelperCacheID(helper, scope)
+	if cached, err := GetKeyring(id); err.IsNil() {
+		var entry models.TokenHelperCacheEntry
+		if jsonErr := json.Unmarshal([]byte(cached), &entry); jsonErr == nil && entry.Token != "" && time.Now().Before(entry.ExpiresAt) {
+			utils.LogDebug("Using cached token from token helper")
+			return entry.Token, Error{}
+		}
+	}
+
+	entry, err := runTokenHelper(ctx, helper)
+	if !err.IsNil() {
+		return "", err
+	}
+	utils.RedactFromLogs(entry.Token)
+
+	if body, jsonErr := json.Marshal(entry); jsonErr == nil {
+		if keyringErr := SetKeyring(id, string(body)); !keyringErr.IsNil() {
+			// the token can still be used, it just won't be cached
+			utils.LogDebugError(keyringErr.Unwrap())
+			utils.LogDebug(keyringErr.Message)
+		}
+	}
+
+	return entry.Token, Error{}
+}
+
+// ClearTokenHelperCache removes the helper's cached token for the scope
+func ClearTokenHelperCache(helper string, scope string) {
+	if err := DeleteKeyring(tokenHelperCacheID(helper, scope)); !err.IsNil() {
+		utils.LogDebugError(err.Unwrap())
+	}
+}
+
+func runTokenHelper(parent context.Context, helper string) (models.TokenHelperCacheEntry, Error) {
+	shell := []string{"sh", "-c"}
+	if utils.IsWindows() {
+		shell = []string{"cmd", "/C"}
+	}
+
+	ctx, cancel := context.WithTimeout(parent, TokenHelperTimeout)
+	defer cancel()
+
+	utils.LogDebug(fmt.Sprintf("Running token helper %s", helper))
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, shell[0], shell[1], helper) // #nosec G204
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if parent.Err() == context.Canceled {
+			return models.TokenHelperCacheEntry{}, Error{Err: utils.NewError(utils.ErrorKindCanceled, err), Message: "Token helper was canceled"}
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return models.TokenHelperCacheEntry{}, Error{Err: utils.NewError(utils.ErrorKindTimeout, err), Message: fmt.Sprintf("Token helper did not exit within %s", TokenHelperTimeout)}
+		}
+		return models.TokenHelperCacheEntry{}, Error{Err: err, Message: "Token helper failed"}
+	}
+
+	return parseTokenHelperOutput(stdout.String(), time.Now())
+}
+
+// parseTokenHelperOutput parses either a bare token or JSON containing the token and its expiration
+func parseTokenHelperOutput(output string, now time.Time) (models.TokenHelperCacheEntry, Error) {
+	output = strings.TrimSpace(output)
+	entry := models.TokenHelperCacheEntry{Token: output, ExpiresAt: now.Add(TokenHelperCacheTTL)}
+
+	if strings.HasPrefix(output, "{") {
+		var parsed models.TokenHelperOutput
+		if err := json.Unmarshal([]byte(output), &parsed); err != nil {
+			return entry, Error{Err: err, Message: "Unable to parse token helper output"}
+		}
+
+		entry.Token = strings.TrimSpace(parsed.Token)
+		if parsed.ExpiresAt != "" {
+			expiresAt, err := time.Parse(time.RFC3339, parsed.ExpiresAt)
+			if err != nil {
+				return entry, Error{Err: err, Message: "Unable to parse token helper expires_at, which must be an RFC 3339 timestamp"}
+			}
+			entry.ExpiresAt = expiresAt
+		} else if parsed.ExpiresIn > 0 {
+			entry.ExpiresAt = now.Add(time.Duration(parsed.ExpiresIn) * time.Second)
+		}
+	}
+
+	if entry.Token == "" || strings.ContainsAny(entry.Token, " \n") {
+		return entry, Error{Err: errors.New("invalid token"), Message: "Token helper must print a token or JSON containing a token"}
+	}
+	return entry, Error{}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/utils"
+)
+
+func TestParseTokenHelperOutput(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		output    string
+		token     string
+		expiresAt time.Time
+		err       bool
+	}{
+		{name: "bare token", output: "dp.st.123\n", token: "dp.st.123", expiresAt: now.Add(TokenHelperCacheTTL)},
+		{name: "expires_in", output: `{"token": "dp.st.123", "expires_in": 60}`, token: "dp.st.123", expiresAt: now.Add(time.Minute)},
+		{name: "expires_at", output: `{"token": "dp.st.123", "expires_at": "2021-01-02T00:00:00Z"}`, token: "dp.st.123", expiresAt: now.Add(24 * time.Hour)},
+		{name: "empty", output: "\n", err: true},
+		{name: "multiple lines", output: "dp.st.123\ndp.st.456", err: true},
+		{name: "missing token", output: `{"expires_in": 60}`, err: true},
+		{name: "invalid expires_at", output: `{"token": "dp.st.123", "expires_at": "tomorrow"}`, err: true},
+	}
+
+	for _, test := range tests {
+		entry, err := parseTokenHelperOutput(test.output, now)
+		if test.err {
+			if err.IsNil() {
+				t.Errorf("%s: expected error", test.name)
+			}
+			continue
+		}
+
+		if !err.IsNil() {
+			t.Errorf("%s: unexpected error %v", test.name, err.Unwrap())
+			continue
+		}
+		if entry.Token != test.token {
+			t.Errorf("%s: expected token %s, got %s", test.name, test.token, entry.Token)
+		}
+		if !entry.ExpiresAt.Equal(test.expiresAt) {
+			t.Errorf("%s: expected expiration %s, got %s", test.name, test.expiresAt, entry.ExpiresAt)
+		}
+	}
+}
+
+func TestTokenFromHelperCachesToken(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("helper script requires sh")
+	}
+	useKeyring(t, NewMemoryKeyring())
+
+	dir, err := ioutil.TempDir("", "doppler-token-helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the helper records each invocation so caching can be verified
+	invocations := filepath.Join(dir, "invocations")
+	helper := "echo run >> " + invocations + " && echo dp.st.123"
+
+	for i := 0; i < 2; i++ {
+		token, err := TokenFromHelper(context.Background(), helper, "/")
+		if !err.IsNil() {
+			t.Fatal(err.Unwrap())
+		}
+		if token != "dp.st.123" {
+			t.Errorf("expected dp.st.123, got %s", token)
+		}
+	}
+
+	contents, err := ioutil.ReadFile(invocations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := strings.Count(string(contents), "run"); count != 1 {
+		t.Errorf("expected helper to run once, ran %d times", count)
+	}
+
+	ClearTokenHelperCache(helper, "/")
+	if _, err := TokenFromHelper(context.Background(), helper, "/"); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	contents, _ = ioutil.ReadFile(invocations)
+	if count := strings.Count(string(contents), "run"); count != 2 {
+		t.Errorf("expected helper to run again after clearing the cache, ran %d times", count)
+	}
+}
+
+func TestTokenFromHelperCachedPerScope(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("helper script requires sh")
+	}
+	useKeyring(t, NewMemoryKeyring())
+
+	dir, err := ioutil.TempDir("", "doppler-token-helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the helper prints a different token each time it runs, like a helper whose token depends on where it's used
+	invocations := filepath.Join(dir, "invocations")
+	helper := "echo run >> " + invocations + " && echo dp.st.$(wc -l < " + invocations + " | tr -d ' ')"
+
+	tokens := map[string]string{}
+	for _, scope := range []string{"/a", "/b", "/a"} {
+		token, err := TokenFromHelper(context.Background(), helper, scope)
+		if !err.IsNil() {
+			t.Fatal(err.Unwrap())
+		}
+		if cached, ok := tokens[scope]; ok && cached != token {
+			t.Errorf("expected cached token %s for scope %s, got %s", cached, scope, token)
+		}
+		tokens[scope] = token
+	}
+
+	if tokens["/a"] == tokens["/b"] {
+		t.Errorf("expected each scope to get its own token, got %s for both", tokens["/a"])
+	}
+}
+
+func TestTokenFromHelperFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("helper script requires sh")
+	}
+	useKeyring(t, NewMemoryKeyring())
+
+	if _, err := TokenFromHelper(context.Background(), "exit 1", "/"); err.IsNil() {
+		t.Error("expected error when helper exits non-zero")
+	}
+}
+
+func TestTokenFromHelperCanceled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("helper script requires sh")
+	}
+	useKeyring(t, NewMemoryKeyring())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := TokenFromHelper(ctx, "exec sleep 10", "/")
+	if err.IsNil() {
+		t.Fatal("expected error when the context is canceled")
+	}
+	if kind := utils.ErrorDetails(err.Unwrap()).Kind; kind != utils.ErrorKindCanceled {
+		t.Errorf("expected a canceled error, got %s", kind.Name)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the helper to be killed when the context is canceled, waited %s", elapsed)
+	}
+}
@@ -16,15 +16,14 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"regexp"
-	"strings"
 
 	"github.com/DopplerHQ/cli/pkg/http"
 	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/updater"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/DopplerHQ/cli/pkg/version"
 )
@@ -41,44 +40,24 @@ func (e *Error) Unwrap() error { return e.Err }
 // IsNil whether the error is nil
 func (e *Error) IsNil() bool { return e.Err == nil && e.Message == "" }
 
-// RunInstallScript downloads and executes the CLI install scriptm, returning true if an update was installed
-func RunInstallScript() (bool, string, Error) {
-	// download script
-	script, apiErr := http.GetCLIInstallScript()
-	if !apiErr.IsNil() {
-		return false, "", Error{Err: apiErr.Unwrap(), Message: apiErr.Message}
-	}
+// Kind the kind of error, which determines the CLI's exit code
+func (e *Error) Kind() utils.ErrorKind { return utils.ErrorDetails(e.Err).Kind }
 
-	// write script to temp file
-	tmpFile, err := utils.WriteTempFile("install.sh", script, 0555)
-	// clean up temp file once we're done with it
-	defer os.Remove(tmpFile)
-
-	// execute script
-	utils.LogDebug("Executing install script")
-	command := []string{tmpFile, "--debug"}
-	out, err := exec.Command(command[0], command[1:]...).CombinedOutput() // #nosec G204
-	strOut := string(out)
-	// log output before checking error
-	utils.LogDebug(fmt.Sprintf("Executing \"%s\"", strings.Join(command, " ")))
-	if utils.Debug {
-		fmt.Println(strOut)
-	}
+// InstallUpdate downloads, verifies, and installs the specified CLI version, returning true if the installed version is newer
+func InstallUpdate(ctx context.Context, latestVersion string) (bool, string, Error) {
+	newVersion, err := version.ParseVersion(version.Normalize(latestVersion))
 	if err != nil {
-		return false, "", Error{Err: err, Message: "Unable to install the latest Doppler CLI"}
+		return false, "", Error{Err: utils.NewError(utils.ErrorKindUsage, err), Message: "Unable to parse new CLI version"}
 	}
 
-	// find installed version within script output
-	// Ex: `Installed Doppler CLI v3.7.1`
-	re := regexp.MustCompile(`Installed Doppler CLI v(\d+\.\d+\.\d+)`)
-	matches := re.FindStringSubmatch(strOut)
-	if matches == nil || len(matches) != 2 {
-		return false, "", Error{Err: errors.New("Unable to determine new CLI version")}
-	}
-	// parse latest version string
-	newVersion, err := version.ParseVersion(matches[1])
-	if err != nil {
-		return false, "", Error{Err: err, Message: "Unable to parse new CLI version"}
+	if err := updater.Install(ctx, newVersion.String(), updater.Options{}); err != nil {
+		message := fmt.Sprintf("Unable to install Doppler CLI %s", newVersion.String())
+		if utils.ErrorDetails(err).Kind == utils.ErrorKindNotFound {
+			message = fmt.Sprintf("Doppler CLI %s doesn't exist or isn't available for this platform", newVersion.String())
+		} else if errors.Is(err, os.ErrPermission) {
+			message = "Unable to replace the Doppler CLI binary. Try again as an administrator (e.g. via sudo), or update via the package manager you installed the CLI with."
+		}
+		return false, "", Error{Err: err, Message: message}
 	}
 
 	wasUpdated := false
@@ -98,9 +77,17 @@ func RunInstallScript() (bool, string, Error) {
 	return wasUpdated, newVersion.String(), Error{}
 }
 
+// RollbackUpdate restores the CLI binary that was replaced by the last update
+func RollbackUpdate() Error {
+	if err := updater.Rollback(updater.Options{}); err != nil {
+		return Error{Err: err, Message: "Unable to roll back the Doppler CLI"}
+	}
+	return Error{}
+}
+
 // CLIChangeLog fetches the latest changelog
-func CLIChangeLog() (map[string]models.ChangeLog, http.Error) {
-	response, apiError := http.GetChangelog()
+func CLIChangeLog(ctx context.Context) (map[string]models.ChangeLog, http.Error) {
+	response, apiError := http.GetChangelog(ctx)
 	if !apiError.IsNil() {
 		return nil, apiError
 
@@ -16,6 +16,8 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/DopplerHQ/cli/pkg/http"
@@ -24,10 +26,10 @@ import (
 	"github.com/DopplerHQ/cli/pkg/version"
 )
 
-// NewVersionAvailable checks whether a CLI version is available that's newer than this CLI
-func NewVersionAvailable(prevVersionCheck models.VersionCheck) (bool, models.VersionCheck, error) {
+// NewVersionAvailable checks whether a CLI version is available on the release channel that's newer than this CLI
+func NewVersionAvailable(ctx context.Context, prevVersionCheck models.VersionCheck, channel string) (bool, models.VersionCheck, error) {
 	now := time.Now()
-	check, err := http.GetLatestCLIVersion()
+	check, err := http.GetLatestCLIVersion(ctx, channel)
 	if err != nil {
 		utils.LogDebug("Unable to fetch latest CLI version")
 		utils.LogDebugError(err)
@@ -63,3 +65,29 @@ func NewVersionAvailable(prevVersionCheck models.VersionCheck) (bool, models.Ver
 
 	return false, versionCheck, nil
 }
+
+// CheckMinimumVersion verifies that this CLI satisfies the minimum version required by the repo config file (doppler.yaml)
+func CheckMinimumVersion(repoConfig models.RepoConfig) Error {
+	minimum := repoConfig.Policy.MinimumCLIVersion
+	if minimum == "" || version.IsDevelopment() {
+		return Error{}
+	}
+
+	minimumVersion, err := version.ParseVersion(version.Normalize(minimum))
+	if err != nil {
+		return Error{Err: err, Message: "Invalid policy.minimum-cli-version in repo config file (doppler.yaml)"}
+	}
+
+	currentVersion, err := version.ParseVersion(version.ProgramVersion)
+	if err != nil {
+		utils.LogDebug("Unable to parse current CLI version")
+		utils.LogDebugError(err)
+		return Error{}
+	}
+
+	if version.CompareVersions(currentVersion, minimumVersion) == 1 {
+		err := fmt.Errorf("Doppler CLI %s is older than %s, the minimum version required by this repo", currentVersion.String(), minimumVersion.String())
+		return Error{Err: utils.NewError(utils.ErrorKindUsage, err), Message: "Run 'doppler update' to update the CLI"}
+	}
+	return Error{}
+}
@@ -0,0 +1,363 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package fakeapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server a fake Doppler API with in-memory state, for testing the CLI without network access.
+// A Server implements http.Handler and is safe for concurrent use.
+type Server struct {
+	mutex       sync.Mutex
+	state       State
+	autoApprove bool
+	latency     time.Duration
+	// auth codes and whether they've been approved
+	authCodes map[string]bool
+	// the secrets after each change, used to roll back config logs
+	snapshots map[string]map[string]string
+	failures  []Failure
+	replay    []Interaction
+	requests  []Request
+	nextID    int
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithState sets the server's initial state
+func WithState(state State) Option {
+	return func(s *Server) { s.state = state }
+}
+
+// WithAutoApprove sets whether CLI auth codes are approved immediately, rather than by visiting the auth URL
+func WithAutoApprove(autoApprove bool) Option {
+	return func(s *Server) { s.autoApprove = autoApprove }
+}
+
+// WithLatency delays each response by the specified duration
+func WithLatency(latency time.Duration) Option {
+	return func(s *Server) { s.latency = latency }
+}
+
+// WithReplay serves the recorded interactions, in order, before falling back to the server's state
+func WithReplay(interactions []Interaction) Option {
+	return func(s *Server) { s.replay = append([]Interaction{}, interactions...) }
+}
+
+// Failure a failure to inject into the server's responses
+type Failure struct {
+	// Path the request path to fail (e.g. /v3/configs/config/secrets/download). An empty Path fails any request.
+	Path string
+	// Status the response's status code
+	Status int
+	// RetryAfter the value of the response's Retry-After header, if any
+	RetryAfter string
+	// Count the number of requests to fail
+	Count int
+}
+
+// Request a request received by the server
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	// Status the response's status code
+	Status int
+}
+
+// New creates a fake API server. By default the server contains DefaultState() and auto approves auth codes.
+func New(options ...Option) *Server {
+	s := &Server{
+		state:       DefaultState(),
+		autoApprove: true,
+		authCodes:   map[string]bool{},
+		snapshots:   map[string]map[string]string{},
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// InjectFailure fails the next matching requests
+func (s *Server) InjectFailure(failure Failure) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = append(s.failures, failure)
+}
+
+// Requests the requests received by the server
+func (s *Server) Requests() []Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+// State a copy of the server's current state
+func (s *Server) State() State {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state.copy()
+}
+
+// SetSecret sets the value of a secret, as if it had been changed via the dashboard
+func (s *Server) SetSecret(project string, config string, name string, value string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, c := s.state.config(project, config)
+	if c == nil {
+		return fmt.Errorf("config %s not found in project %s", config, project)
+	}
+	s.updateSecrets(project, c, map[string]*string{name: &value})
+	return nil
+}
+
+// ApproveAuthCode approves a CLI auth code, as if the user had visited the auth URL
+func (s *Server) ApproveAuthCode(code string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.authCodes[code]; ok {
+		s.authCodes[code] = true
+	}
+}
+
+// ServeHTTP handles a request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.latency > 0 {
+		select {
+		case <-time.After(s.latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	recorder.Header().Set("X-Request-Id", s.id("request"))
+	s.serve(recorder, r)
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Status: recorder.status})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// the routes that don't require authentication
+var unauthenticatedRoutes = map[string]bool{
+	"GET /v3/auth/cli/generate":   true,
+	"POST /v3/auth/cli/authorize": true,
+	"POST /v3/auth/cli/roll":      true,
+	"POST /v3/auth/cli/revoke":    true,
+	"GET /auth/cli":               true,
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if s.injectFailure(w, r) || s.replayInteraction(w, r) {
+		return
+	}
+
+	route := r.Method + " " + r.URL.Path
+	if strings.HasPrefix(r.URL.Path, "/logs/v1/") && r.Method == "GET" {
+		route = "GET /logs/v1/log"
+	}
+
+	if !unauthenticatedRoutes[route] && !s.authenticated(r) {
+		writeError(w, http.StatusUnauthorized, "Invalid auth token")
+		return
+	}
+
+	handler, ok := routes[route]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Unknown endpoint %s", route))
+		return
+	}
+	handler(s, w, r)
+}
+
+func (s *Server) injectFailure(w http.ResponseWriter, r *http.Request) bool {
+	for i, failure := range s.failures {
+		if failure.Count <= 0 || (failure.Path != "" && failure.Path != r.URL.Path) {
+			continue
+		}
+
+		s.failures[i].Count--
+		if failure.RetryAfter != "" {
+			w.Header().Set("Retry-After", failure.RetryAfter)
+		}
+		writeError(w, failure.Status, "Injected failure")
+		return true
+	}
+	return false
+}
+
+func (s *Server) replayInteraction(w http.ResponseWriter, r *http.Request) bool {
+	for i, interaction := range s.replay {
+		if !interaction.matches(r) {
+			continue
+		}
+
+		s.replay = append(s.replay[:i], s.replay[i+1:]...)
+		for name, value := range interaction.Headers {
+			w.Header().Set(name, value)
+		}
+		w.WriteHeader(interaction.Status)
+		_, _ = w.Write([]byte(interaction.Body))
+		return true
+	}
+	return false
+}
+
+func (s *Server) authenticated(r *http.Request) bool {
+	token, _, ok := r.BasicAuth()
+	if !ok || token == "" {
+		return false
+	}
+
+	// service tokens are checked first so expired tokens are rejected even before tokens are issued
+	for _, p := range s.state.Projects {
+		for _, c := range p.Configs {
+			for i := range c.ServiceTokens {
+				serviceToken := &c.ServiceTokens[i]
+				if serviceToken.Token != token {
+					continue
+				}
+				if serviceToken.ExpiresAt != "" && serviceToken.ExpiresAt <= now() {
+					return false
+				}
+				serviceToken.LastSeenAt = now()
+				return true
+			}
+		}
+	}
+
+	// any token is accepted until tokens are issued. revoking every issued token doesn't reset this.
+	if s.state.Tokens == nil {
+		return true
+	}
+	for _, t := range s.state.Tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) id(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s_%d", prefix, s.nextID)
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// fakeUser the user that makes all changes
+var fakeUser = map[string]interface{}{
+	"email":             "cli@example.com",
+	"name":              "Fake User",
+	"username":          "fake",
+	"profile_image_url": "",
+}
+
+func writeJSON(w http.ResponseWriter, status int, body map[string]interface{}) {
+	if _, ok := body["success"]; !ok {
+		body["success"] = status < 400
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"messages": []string{message}, "success": false})
+}
+
+func readBody(r *http.Request, v interface{}) error {
+	if r.Body == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(v); err != nil && err.Error() != "EOF" {
+		return err
+	}
+	return nil
+}
+
+// etag computes the ETag of a response body
+func etag(body []byte) string {
+	hash := sha256.Sum256(body)
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(hash[:16]))
+}
+
+// page the requested page of n results, as start and end indexes
+func page(r *http.Request, n int) (int, int, int) {
+	pageNumber := intParam(r, "page", 1)
+	perPage := intParam(r, "per_page", 20)
+	if pageNumber < 1 {
+		pageNumber = 1
+	}
+	if perPage < 1 {
+		perPage = 20
+	}
+
+	start := (pageNumber - 1) * perPage
+	if start > n {
+		start = n
+	}
+	end := start + perPage
+	if end > n {
+		end = n
+	}
+	return pageNumber, start, end
+}
+
+func intParam(r *http.Request, name string, def int) int {
+	var value int
+	if _, err := fmt.Sscanf(r.URL.Query().Get(name), "%d", &value); err != nil {
+		return def
+	}
+	return value
+}
+
+func sortedNames(secrets map[string]string) []string {
+	var names []string
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,332 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package fakeapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/fakeapi"
+	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+)
+
+var noRetries = http.RetryPolicy{Attempts: 1}
+
+func newClient(server *httptest.Server, options ...http.Option) *http.Client {
+	options = append([]http.Option{http.WithHost(server.URL), http.WithToken("dp.ct.test"), http.WithRetryPolicy(noRetries)}, options...)
+	return http.NewClient(options...)
+}
+
+func TestSecrets(t *testing.T) {
+	server := httptest.NewServer(fakeapi.New())
+	defer server.Close()
+	client := newClient(server)
+	ctx := context.Background()
+
+	secrets, err := client.SetSecrets(ctx, "example", "dev", map[string]interface{}{"GREETING": "hello ${API_KEY}", "API_KEY": "key"})
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if secrets["GREETING"].ComputedValue != "hello key" {
+		t.Errorf("expected computed value 'hello key', got %q", secrets["GREETING"].ComputedValue)
+	}
+
+	response, err := client.GetSecrets(ctx, "example", "dev")
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	parsed, parseErr := models.ParseSecrets(response)
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+	if parsed["GREETING"].RawValue != "hello ${API_KEY}" {
+		t.Errorf("expected raw value 'hello ${API_KEY}', got %q", parsed["GREETING"].RawValue)
+	}
+
+	if _, err := client.GetSecrets(ctx, "example", "nonexistent"); err.Code != 404 {
+		t.Errorf("expected status 404 for a nonexistent config, got %d", err.Code)
+	}
+}
+
+func TestDownloadETag(t *testing.T) {
+	fake := fakeapi.New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := newClient(server)
+	ctx := context.Background()
+
+	req := http.DownloadSecretsRequest{Project: "example", Config: "dev", Format: models.JSON}
+	resp, err := client.DownloadSecrets(ctx, req)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	var secrets map[string]string
+	if err := json.Unmarshal(resp.Body, &secrets); err != nil {
+		t.Fatal(err)
+	}
+	if secrets["DOPPLER_CONFIG"] != "dev" {
+		t.Errorf("expected DOPPLER_CONFIG 'dev', got %q", secrets["DOPPLER_CONFIG"])
+	}
+
+	req.ETag = resp.ETag()
+	resp, err = client.DownloadSecrets(ctx, req)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if resp.StatusCode != 304 {
+		t.Errorf("expected status 304 for unchanged secrets, got %d", resp.StatusCode)
+	}
+
+	if err := fake.SetSecret("example", "dev", "API_KEY", "rotated"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.DownloadSecrets(ctx, req)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("expected status 200 for changed secrets, got %d", resp.StatusCode)
+	}
+}
+
+func TestConfigLogs(t *testing.T) {
+	server := httptest.NewServer(fakeapi.New())
+	defer server.Close()
+	client := newClient(server)
+	ctx := context.Background()
+
+	for _, value := range []string{"1", "2", "3"} {
+		if _, err := client.SetSecrets(ctx, "example", "dev", map[string]interface{}{"VERSION": value}); !err.IsNil() {
+			t.Fatal(err.Unwrap())
+		}
+	}
+
+	logs, page, err := client.GetConfigLogs(ctx, "example", "dev", http.PageOptions{Page: 1, PerPage: 2})
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if len(logs) != 2 || !page.HasMore {
+		t.Fatalf("expected a full first page, got %d logs (has more: %t)", len(logs), page.HasMore)
+	}
+
+	var all []models.ConfigLog
+	it := client.ConfigLogs(ctx, "example", "dev", http.PageOptions{PerPage: 2})
+	for it.Next() {
+		all = append(all, it.Log())
+	}
+	if err := it.Err(); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 logs, got %d", len(all))
+	}
+
+	// roll back to the first change
+	if _, err := client.RollbackConfigLog(ctx, "example", "dev", all[2].ID); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	response, err := client.GetSecrets(ctx, "example", "dev")
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	secrets, parseErr := models.ParseSecrets(response)
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+	if secrets["VERSION"].RawValue != "1" {
+		t.Errorf("expected VERSION '1' after rollback, got %q", secrets["VERSION"].RawValue)
+	}
+}
+
+func TestAuthFlow(t *testing.T) {
+	fake := fakeapi.New(fakeapi.WithAutoApprove(false))
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := newClient(server)
+	ctx := context.Background()
+
+	code, err := client.GenerateAuthCode(ctx, "host", "linux", "amd64")
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if _, err := client.GetAuthToken(ctx, code.Code); err.Code != 409 {
+		t.Fatalf("expected status 409 before the code is approved, got %d", err.Code)
+	}
+
+	fake.ApproveAuthCode(code.Code)
+	token, err := client.GetAuthToken(ctx, code.Code)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+
+	// once a token has been issued, only issued tokens are accepted
+	if _, err := client.GetProjects(ctx); err.Code != 401 {
+		t.Errorf("expected status 401 for an unissued token, got %d", err.Code)
+	}
+	authed := newClient(server, http.WithToken(token.Token))
+	if _, err := authed.GetProjects(ctx); !err.IsNil() {
+		t.Error(err.Unwrap())
+	}
+
+	if err := authed.RevokeAuthToken(ctx, token.Token); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if _, err := authed.GetProjects(ctx); err.Code != 401 {
+		t.Errorf("expected status 401 for a revoked token, got %d", err.Code)
+	}
+}
+
+func TestServiceTokens(t *testing.T) {
+	server := httptest.NewServer(fakeapi.New())
+	defer server.Close()
+	client := newClient(server)
+	ctx := context.Background()
+
+	created, err := client.CreateConfigServiceToken(ctx, "example", "prd", "ci", time.Time{}, "")
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if created.Token == "" {
+		t.Error("expected the created token to include its value")
+	}
+	if created.Access != models.ServiceTokenAccessRead {
+		t.Errorf("expected access to default to %q, got %q", models.ServiceTokenAccessRead, created.Access)
+	}
+
+	serviceClient := newClient(server, http.WithToken(created.Token))
+	if _, err := serviceClient.GetSecrets(ctx, "example", "prd"); !err.IsNil() {
+		t.Error(err.Unwrap())
+	}
+
+	expired, err := client.CreateConfigServiceToken(ctx, "example", "prd", "old", time.Now().Add(-time.Minute), models.ServiceTokenAccessReadWrite)
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if expired.ExpiresAt == "" || expired.Access != models.ServiceTokenAccessReadWrite {
+		t.Errorf("expected expiration and access to be set, got %+v", expired)
+	}
+	expiredClient := newClient(server, http.WithToken(expired.Token))
+	if _, err := expiredClient.GetSecrets(ctx, "example", "prd"); err.Code != 401 {
+		t.Errorf("expected status 401 for an expired token, got %d", err.Code)
+	}
+	if err := client.DeleteConfigServiceToken(ctx, "example", "prd", expired.Slug); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+
+	if err := client.DeleteConfigServiceToken(ctx, "example", "prd", created.Slug); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	tokens, err := client.ConfigServiceTokens(ctx, "example", "prd", http.PageOptions{}).All()
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if len(tokens) != 0 {
+		t.Errorf("expected no tokens after deletion, got %d", len(tokens))
+	}
+}
+
+func TestInjectedFailure(t *testing.T) {
+	fake := fakeapi.New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	ctx := context.Background()
+
+	fake.InjectFailure(fakeapi.Failure{Path: "/v3/configs/config/secrets", Status: 503, Count: 2})
+	policy := http.RetryPolicy{Attempts: 3, Delay: time.Millisecond, RetryableStatuses: http.DefaultRetryableStatuses}
+	client := newClient(server, http.WithRetryPolicy(policy))
+	if _, err := client.GetSecrets(ctx, "example", "dev"); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 3 || requests[0].Status != 503 || requests[2].Status != 200 {
+		t.Errorf("expected two failures followed by a success, got %+v", requests)
+	}
+
+	fake.InjectFailure(fakeapi.Failure{Status: 500, Count: 1})
+	if _, err := newClient(server).GetSecrets(ctx, "example", "dev"); err.Code != 500 {
+		t.Errorf("expected status 500, got %d", err.Code)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fakeapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "recording.jsonl")
+
+	recorded := httptest.NewServer(fakeapi.New())
+	defer recorded.Close()
+	recorder := http.WithTransportMiddleware(func(next nethttp.RoundTripper) nethttp.RoundTripper {
+		return fakeapi.NewRecorder(next, path)
+	})
+	ctx := context.Background()
+	if _, err := newClient(recorded, recorder).SetSecrets(ctx, "example", "dev", map[string]interface{}{"RECORDED": "yes"}); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	expected, apiErr := newClient(recorded, recorder).GetSecrets(ctx, "example", "dev")
+	if !apiErr.IsNil() {
+		t.Fatal(apiErr.Unwrap())
+	}
+
+	interactions, err := fakeapi.LoadInteractions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(interactions) != 2 {
+		t.Fatalf("expected 2 recorded interactions, got %d", len(interactions))
+	}
+
+	// the replaying server doesn't contain the recorded secret in its state
+	replayed := httptest.NewServer(fakeapi.New(fakeapi.WithReplay(interactions)))
+	defer replayed.Close()
+	actual, apiErr := newClient(replayed).GetSecrets(ctx, "example", "dev")
+	if !apiErr.IsNil() {
+		t.Fatal(apiErr.Unwrap())
+	}
+	if string(actual) != string(expected) {
+		t.Errorf("expected replayed response %s, got %s", expected, actual)
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	server := httptest.NewServer(fakeapi.New())
+	defer server.Close()
+
+	_, err := newClient(server).GetSecrets(context.Background(), "example", "nonexistent")
+	if err.Kind() != utils.ErrorKindNotFound {
+		t.Errorf("expected kind %s, got %s", utils.ErrorKindNotFound.Name, err.Kind().Name)
+	}
+
+	details := utils.ErrorDetails(err.Unwrap())
+	if details.Status != 404 || details.RequestID == "" {
+		t.Errorf("expected the response's status and request ID, got %+v", details)
+	}
+	if details.Error() != "Could not find requested config" {
+		t.Errorf("expected the API's error message, got '%s'", details.Error())
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package fakeapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// recordedHeaders the response headers that are saved with an interaction
+var recordedHeaders = []string{"Content-Type", "ETag", "Retry-After"}
+
+// Interaction a recorded HTTP request and its response
+type Interaction struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   string            `json:"query,omitempty"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+}
+
+// matches whether the interaction was recorded from an equivalent request. An interaction without a query matches any query.
+func (i Interaction) matches(r *http.Request) bool {
+	if i.Method != r.Method || i.Path != r.URL.Path {
+		return false
+	}
+	return i.Query == "" || i.Query == r.URL.Query().Encode()
+}
+
+// Recorder an http.RoundTripper that saves each interaction to a file
+type Recorder struct {
+	next  http.RoundTripper
+	path  string
+	mutex sync.Mutex
+}
+
+// NewRecorder creates a Recorder that performs requests with next and appends the interactions to the file at path
+func NewRecorder(next http.RoundTripper, path string) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next, path: path}
+}
+
+// RoundTrip performs the request and records the interaction
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rec.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Method:  req.Method,
+		Path:    req.URL.Path,
+		Query:   req.URL.Query().Encode(),
+		Status:  resp.StatusCode,
+		Headers: map[string]string{},
+		Body:    string(body),
+	}
+	for _, name := range recordedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			interaction.Headers[name] = value
+		}
+	}
+
+	if err := rec.save(interaction); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (rec *Recorder) save(interaction Interaction) error {
+	line, err := json.Marshal(interaction)
+	if err != nil {
+		return err
+	}
+
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	// recordings contain secrets, so restrict access to the current user
+	file, err := os.OpenFile(rec.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// LoadInteractions reads the interactions saved by a Recorder
+func LoadInteractions(path string) ([]Interaction, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var interactions []Interaction
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var interaction Interaction
+		if err := json.Unmarshal(line, &interaction); err != nil {
+			return nil, err
+		}
+		interactions = append(interactions, interaction)
+	}
+	return interactions, scanner.Err()
+}
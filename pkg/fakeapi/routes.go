@@ -0,0 +1,809 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package fakeapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+type handler func(s *Server, w http.ResponseWriter, r *http.Request)
+
+var routes = map[string]handler{
+	"GET /v3/auth/cli/generate":                 (*Server).generateAuthCode,
+	"GET /auth/cli":                             (*Server).approveAuthCode,
+	"POST /v3/auth/cli/authorize":               (*Server).authorize,
+	"POST /v3/auth/cli/roll":                    (*Server).rollToken,
+	"POST /v3/auth/cli/revoke":                  (*Server).revokeToken,
+	"GET /v3/me":                                (*Server).getTokenInfo,
+	"GET /workplace/v1":                         (*Server).getWorkplace,
+	"POST /workplace/v1":                        (*Server).updateWorkplace,
+	"GET /v3/projects":                          (*Server).getProjects,
+	"POST /v3/projects":                         (*Server).createProject,
+	"GET /v3/projects/project":                  (*Server).getProject,
+	"POST /v3/projects/project":                 (*Server).updateProject,
+	"DELETE /v3/projects/project":               (*Server).deleteProject,
+	"GET /v3/environments":                      (*Server).getEnvironments,
+	"GET /v3/environments/environment":          (*Server).getEnvironment,
+	"GET /v3/configs":                           (*Server).getConfigs,
+	"POST /v3/configs":                          (*Server).createConfig,
+	"GET /v3/configs/config":                    (*Server).getConfig,
+	"POST /v3/configs/config":                   (*Server).updateConfig,
+	"DELETE /v3/configs/config":                 (*Server).deleteConfig,
+	"POST /v3/configs/config/lock":              (*Server).lockConfig,
+	"POST /v3/configs/config/unlock":            (*Server).unlockConfig,
+	"POST /v3/configs/config/clone":             (*Server).cloneConfig,
+	"GET /v3/configs/config/secrets":            (*Server).getSecrets,
+	"POST /v3/configs/config/secrets":           (*Server).setSecrets,
+	"POST /v3/configs/config/secrets/upload":    (*Server).uploadSecrets,
+	"GET /v3/configs/config/secrets/download":   (*Server).downloadSecrets,
+	"GET /v3/configs/config/logs":               (*Server).getConfigLogs,
+	"GET /v3/configs/config/logs/log":           (*Server).getConfigLog,
+	"POST /v3/configs/config/logs/log/rollback": (*Server).rollbackConfigLog,
+	"GET /v3/configs/config/tokens":             (*Server).getServiceTokens,
+	"POST /v3/configs/config/tokens":            (*Server).createServiceToken,
+	"DELETE /v3/configs/config/tokens/token":    (*Server).deleteServiceToken,
+	"GET /logs/v1":                              (*Server).getActivityLogs,
+	"GET /logs/v1/log":                          (*Server).getActivityLog,
+}
+
+// auth
+
+func (s *Server) generateAuthCode(w http.ResponseWriter, r *http.Request) {
+	code := s.id("code")
+	s.authCodes[code] = s.autoApprove
+
+	verificationURL := fmt.Sprintf("http://%s/auth/cli", r.Host)
+	authURL := fmt.Sprintf("%s?code=%s", verificationURL, code)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": code, "auth_url": authURL, "verification_url": verificationURL, "polling_interval": 1, "expires_in": 300})
+}
+
+func (s *Server) approveAuthCode(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+	if _, ok := s.authCodes[code]; !ok {
+		writeError(w, http.StatusNotFound, "Invalid auth code")
+		return
+	}
+
+	s.authCodes[code] = true
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte("The CLI has been authorized. You may close this page.\n"))
+}
+
+func (s *Server) issueToken() string {
+	token := s.id("dp.ct.fake")
+	s.state.Tokens = append(s.state.Tokens, token)
+	return token
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := readBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	approved, ok := s.authCodes[body.Code]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Invalid auth code")
+		return
+	}
+	if !approved {
+		// the CLI polls until the code is approved
+		writeError(w, http.StatusConflict, "Auth code has not been approved")
+		return
+	}
+
+	delete(s.authCodes, body.Code)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"token": s.issueToken(), "name": s.state.Workplace.Name, "dashboard_url": "http://" + r.Host})
+}
+
+func (s *Server) removeToken(token string) bool {
+	for i, t := range s.state.Tokens {
+		if t == token {
+			s.state.Tokens = append(s.state.Tokens[:i], s.state.Tokens[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) rollToken(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := readBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !s.removeToken(body.Token) {
+		writeError(w, http.StatusUnauthorized, "Invalid auth token")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"token": s.issueToken(), "name": s.state.Workplace.Name, "dashboard_url": "http://" + r.Host})
+}
+
+func (s *Server) revokeToken(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := readBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.removeToken(body.Token)
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// workplace
+
+func (s *Server) getTokenInfo(w http.ResponseWriter, r *http.Request) {
+	token, _, _ := r.BasicAuth()
+	for _, p := range s.state.Projects {
+		for _, c := range p.Configs {
+			for _, serviceToken := range c.ServiceTokens {
+				if serviceToken.Token == token {
+					writeJSON(w, http.StatusOK, map[string]interface{}{"token": models.TokenInfo{
+						Type:        models.TokenTypeService,
+						Name:        serviceToken.Name,
+						Slug:        serviceToken.Slug,
+						CreatedAt:   serviceToken.CreatedAt,
+						Workplace:   s.state.Workplace,
+						Projects:    []string{p.ID},
+						Environment: serviceToken.Environment,
+						Config:      serviceToken.Config,
+					}})
+					return
+				}
+			}
+		}
+	}
+
+	projects := []string{}
+	for _, p := range s.state.Projects {
+		projects = append(projects, p.ID)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"token": models.TokenInfo{Type: models.TokenTypeCLI, Name: "fake", Workplace: s.state.Workplace, Projects: projects}})
+}
+
+func (s *Server) getWorkplace(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"workplace": s.state.Workplace})
+}
+
+func (s *Server) updateWorkplace(w http.ResponseWriter, r *http.Request) {
+	var body models.WorkplaceSettings
+	if err := readBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if body.Name != "" {
+		s.state.Workplace.Name = body.Name
+	}
+	if body.BillingEmail != "" {
+		s.state.Workplace.BillingEmail = body.BillingEmail
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"workplace": s.state.Workplace})
+}
+
+// projects
+
+// requireProject gets the project specified by the request's "project" param, writing an error if it doesn't exist
+func (s *Server) requireProject(w http.ResponseWriter, r *http.Request) *Project {
+	p := s.state.project(r.URL.Query().Get("project"))
+	if p == nil {
+		writeError(w, http.StatusNotFound, "Could not find requested project")
+	}
+	return p
+}
+
+// requireConfig gets the config specified by the request's "project" and "config" params, writing an error if it doesn't exist
+func (s *Server) requireConfig(w http.ResponseWriter, r *http.Request) (*Project, *Config) {
+	p, c := s.state.config(r.URL.Query().Get("project"), r.URL.Query().Get("config"))
+	if p == nil {
+		writeError(w, http.StatusNotFound, "Could not find requested project")
+		return nil, nil
+	}
+	if c == nil {
+		writeError(w, http.StatusNotFound, "Could not find requested config")
+		return nil, nil
+	}
+	return p, c
+}
+
+func (s *Server) getProjects(w http.ResponseWriter, r *http.Request) {
+	projects := []models.ProjectInfo{}
+	for _, p := range s.state.Projects {
+		projects = append(projects, p.ProjectInfo)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"projects": projects})
+}
+
+func (s *Server) createProject(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := readBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if body.Name == "" {
+		writeError(w, http.StatusBadRequest, "Project name is required")
+		return
+	}
+	if s.state.project(body.Name) != nil {
+		writeError(w, http.StatusConflict, "A project with this name already exists")
+		return
+	}
+
+	p := &Project{ProjectInfo: models.ProjectInfo{ID: body.Name, Name: body.Name, Description: body.Description, CreatedAt: now()}}
+	s.state.Projects = append(s.state.Projects, p)
+	s.addActivityLog("Created project "+p.Name, p.ID, "", "")
+	writeJSON(w, http.StatusOK, map[string]interface{}{"project": p.ProjectInfo})
+}
+
+func (s *Server) getProject(w http.ResponseWriter, r *http.Request) {
+	if p := s.requireProject(w, r); p != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"project": p.ProjectInfo})
+	}
+}
+
+func (s *Server) updateProject(w http.ResponseWriter, r *http.Request) {
+	p := s.requireProject(w, r)
+	if p == nil {
+		return
+	}
+
+	var body struct {
+		Name        string  `json:"name"`
+		Description *string `json:"description"`
+	}
+	if err := readBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if body.Name != "" {
+		p.Name = body.Name
+	}
+	if body.Description != nil {
+		p.Description = *body.Description
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"project": p.ProjectInfo})
+}
+
+func (s *Server) deleteProject(w http.ResponseWriter, r *http.Request) {
+	p := s.requireProject(w, r)
+	if p == nil {
+		return
+	}
+
+	for i, other := range s.state.Projects {
+		if other == p {
+			s.state.Projects = append(s.state.Projects[:i], s.state.Projects[i+1:]...)
+			break
+		}
+	}
+	s.addActivityLog("Deleted project "+p.Name, p.ID, "", "")
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// environments
+
+func (s *Server) getEnvironments(w http.ResponseWriter, r *http.Request) {
+	p := s.requireProject(w, r)
+	if p == nil {
+		return
+	}
+
+	environments := append([]models.EnvironmentInfo{}, p.Environments...)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"environments": environments})
+}
+
+func (s *Server) getEnvironment(w http.ResponseWriter, r *http.Request) {
+	p := s.requireProject(w, r)
+	if p == nil {
+		return
+	}
+
+	environment := p.environment(r.URL.Query().Get("environment"))
+	if environment == nil {
+		writeError(w, http.StatusNotFound, "Could not find requested environment")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"environment": environment})
+}
+
+// configs
+
+func (s *Server) getConfigs(w http.ResponseWriter, r *http.Request) {
+	p := s.requireProject(w, r)
+	if p == nil {
+		return
+	}
+
+	configs := []models.ConfigInfo{}
+	for _, c := range p.Configs {
+		configs = append(configs, c.ConfigInfo)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"configs": configs})
+}
+
+func (s *Server) createConfig(w http.ResponseWriter, r *http.Request) {
+	p := s.requireProject(w, r)
+	if p == nil {
+		return
+	}
+
+	var body struct {
+		Name        string `json:"name"`
+		Environment string `json:"environment"`
+	}
+	if err := readBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	environment := p.environment(body.Environment)
+	if environment == nil {
+		writeError(w, http.StatusNotFound, "Could not find requested environment")
+		return
+	}
+	if _, existing := s.state.config(p.ID, body.Name); existing != nil {
+		writeError(w, http.StatusConflict, "A config with this name already exists")
+		return
+	}
+
+	c := &Config{
+		ConfigInfo: models.ConfigInfo{Name: body.Name, Environment: environment.ID, Project: p.ID, CreatedAt: now()},
+		Secrets:    map[string]string{},
+	}
+	p.Configs = append(p.Configs, c)
+	s.addActivityLog("Created config "+c.Name, p.ID, c.Environment, c.Name)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"config": c.ConfigInfo})
+}
+
+func (s *Server) getConfig(w http.ResponseWriter, r *http.Request) {
+	if _, c := s.requireConfig(w, r); c != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"config": c.ConfigInfo})
+	}
+}
+
+func (s *Server) updateConfig(w http.ResponseWriter, r *http.Request) {
+	_, c := s.requireConfig(w, r)
+	if c == nil {
+		return
+	}
+
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := readBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if c.Locked {
+		writeError(w, http.StatusBadRequest, "Config is locked")
+		return
+	}
+	if body.Name != "" {
+		c.Name = body.Name
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"config": c.ConfigInfo})
+}
+
+func (s *Server) deleteConfig(w http.ResponseWriter, r *http.Request) {
+	p, c := s.requireConfig(w, r)
+	if c == nil {
+		return
+	}
+
+	if c.Locked {
+		writeError(w, http.StatusBadRequest, "Config is locked")
+		return
+	}
+	for i, other := range p.Configs {
+		if other == c {
+			p.Configs = append(p.Configs[:i], p.Configs[i+1:]...)
+			break
+		}
+	}
+	s.addActivityLog("Deleted config "+c.Name, p.ID, c.Environment, c.Name)
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (s *Server) lockConfig(w http.ResponseWriter, r *http.Request) {
+	if _, c := s.requireConfig(w, r); c != nil {
+		c.Locked = true
+		writeJSON(w, http.StatusOK, map[string]interface{}{"config": c.ConfigInfo})
+	}
+}
+
+func (s *Server) unlockConfig(w http.ResponseWriter, r *http.Request) {
+	if _, c := s.requireConfig(w, r); c != nil {
+		c.Locked = false
+		writeJSON(w, http.StatusOK, map[string]interface{}{"config": c.ConfigInfo})
+	}
+}
+
+func (s *Server) cloneConfig(w http.ResponseWriter, r *http.Request) {
+	p, c := s.requireConfig(w, r)
+	if c == nil {
+		return
+	}
+
+	name := c.Name + "_copy"
+	for i := 2; ; i++ {
+		if _, existing := s.state.config(p.ID, name); existing == nil {
+			break
+		}
+		name = fmt.Sprintf("%s_copy%d", c.Name, i)
+	}
+
+	clone := &Config{
+		ConfigInfo: models.ConfigInfo{Name: name, Environment: c.Environment, Project: p.ID, CreatedAt: now()},
+		Secrets:    map[string]string{},
+	}
+	for key, value := range c.Secrets {
+		clone.Secrets[key] = value
+	}
+	p.Configs = append(p.Configs, clone)
+	s.addActivityLog("Cloned config "+c.Name, p.ID, clone.Environment, clone.Name)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"config": clone.ConfigInfo})
+}
+
+// secrets
+
+func secretsResponse(c *Config) map[string]interface{} {
+	computed := c.computed()
+	secrets := map[string]interface{}{}
+	for name, value := range c.Secrets {
+		secrets[name] = map[string]string{"raw": value, "computed": computed[name]}
+	}
+	return map[string]interface{}{"secrets": secrets}
+}
+
+func (s *Server) getSecrets(w http.ResponseWriter, r *http.Request) {
+	if _, c := s.requireConfig(w, r); c != nil {
+		writeJSON(w, http.StatusOK, secretsResponse(c))
+	}
+}
+
+func (s *Server) setSecrets(w http.ResponseWriter, r *http.Request) {
+	p, c := s.requireConfig(w, r)
+	if c == nil {
+		return
+	}
+
+	var body struct {
+		Secrets map[string]*string `json:"secrets"`
+	}
+	if err := readBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.updateSecrets(p.ID, c, body.Secrets)
+	writeJSON(w, http.StatusOK, secretsResponse(c))
+}
+
+func (s *Server) uploadSecrets(w http.ResponseWriter, r *http.Request) {
+	p, c := s.requireConfig(w, r)
+	if c == nil {
+		return
+	}
+
+	var body struct {
+		File string `json:"file"`
+	}
+	if err := readBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	secrets, err := parseSecretsFile(body.File)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Unable to parse secrets file")
+		return
+	}
+
+	changes := map[string]*string{}
+	for name := range secrets {
+		value := secrets[name]
+		changes[name] = &value
+	}
+	s.updateSecrets(p.ID, c, changes)
+	writeJSON(w, http.StatusOK, secretsResponse(c))
+}
+
+// parseSecretsFile parses a JSON, YAML, or env file
+func parseSecretsFile(contents string) (map[string]string, error) {
+	secrets := map[string]string{}
+	if err := json.Unmarshal([]byte(contents), &secrets); err == nil {
+		return secrets, nil
+	}
+
+	secrets = map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	envFile := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			envFile = false
+			break
+		}
+		value := parts[1]
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		secrets[strings.TrimSpace(parts[0])] = value
+	}
+	if envFile {
+		return secrets, nil
+	}
+
+	secrets = map[string]string{}
+	err := yaml.Unmarshal([]byte(contents), &secrets)
+	return secrets, err
+}
+
+func (s *Server) downloadSecrets(w http.ResponseWriter, r *http.Request) {
+	p, c := s.requireConfig(w, r)
+	if c == nil {
+		return
+	}
+
+	secrets := c.computed()
+	secrets["DOPPLER_PROJECT"] = p.ID
+	secrets["DOPPLER_ENVIRONMENT"] = c.Environment
+	secrets["DOPPLER_CONFIG"] = c.Name
+
+	var body []byte
+	contentType := r.Header.Get("Accept")
+	switch contentType {
+	case models.ENV.MimeType():
+		var lines []string
+		for _, name := range sortedNames(secrets) {
+			lines = append(lines, fmt.Sprintf("%s=%s", name, strconv.Quote(secrets[name])))
+		}
+		body = []byte(strings.Join(lines, "\n"))
+	case models.YAML.MimeType():
+		body, _ = yaml.Marshal(secrets)
+	default:
+		contentType = models.JSON.MimeType()
+		body, _ = json.Marshal(secrets)
+	}
+
+	tag := etag(body)
+	w.Header().Set("ETag", tag)
+	if r.Header.Get("If-None-Match") == tag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(body)
+}
+
+// logs
+
+func (s *Server) getConfigLogs(w http.ResponseWriter, r *http.Request) {
+	_, c := s.requireConfig(w, r)
+	if c == nil {
+		return
+	}
+
+	pageNumber, start, end := page(r, len(c.Logs))
+	logs := append([]models.ConfigLog{}, c.Logs[start:end]...)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"logs": logs, "page": pageNumber})
+}
+
+func (s *Server) findConfigLog(w http.ResponseWriter, r *http.Request) (*Project, *Config, *models.ConfigLog) {
+	p, c := s.requireConfig(w, r)
+	if c == nil {
+		return nil, nil, nil
+	}
+
+	id := r.URL.Query().Get("log")
+	for i, log := range c.Logs {
+		if log.ID == id {
+			return p, c, &c.Logs[i]
+		}
+	}
+	writeError(w, http.StatusNotFound, "Could not find requested log")
+	return nil, nil, nil
+}
+
+func (s *Server) getConfigLog(w http.ResponseWriter, r *http.Request) {
+	if _, _, log := s.findConfigLog(w, r); log != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"log": log})
+	}
+}
+
+func (s *Server) rollbackConfigLog(w http.ResponseWriter, r *http.Request) {
+	p, c, log := s.findConfigLog(w, r)
+	if log == nil {
+		return
+	}
+
+	snapshot, ok := s.snapshots[log.ID]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "This log can't be rolled back")
+		return
+	}
+
+	changes := map[string]*string{}
+	for name := range c.Secrets {
+		changes[name] = nil
+	}
+	for name := range snapshot {
+		value := snapshot[name]
+		changes[name] = &value
+	}
+	s.updateSecrets(p.ID, c, changes)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"log": c.Logs[0]})
+}
+
+func (s *Server) getActivityLogs(w http.ResponseWriter, r *http.Request) {
+	pageNumber, start, end := page(r, len(s.state.ActivityLogs))
+	logs := append([]models.ActivityLog{}, s.state.ActivityLogs[start:end]...)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"logs": logs, "page": pageNumber})
+}
+
+func (s *Server) getActivityLog(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/logs/v1/")
+	for _, log := range s.state.ActivityLogs {
+		if log.ID == id {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"log": log})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Could not find requested log")
+}
+
+// service tokens
+
+// serviceTokenResponse the token as returned by the API, which names the token's value "key"
+func serviceTokenResponse(token models.ConfigServiceToken) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        token.Name,
+		"key":         token.Token,
+		"slug":        token.Slug,
+		"created_at":  token.CreatedAt,
+		"project":     token.Project,
+		"environment": token.Environment,
+		"config":      token.Config,
+		"access":      token.Access,
+		// tokens that never expire or haven't been used are returned as null
+		"expires_at":   nullable(token.ExpiresAt),
+		"last_seen_at": nullable(token.LastSeenAt),
+	}
+}
+
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func (s *Server) getServiceTokens(w http.ResponseWriter, r *http.Request) {
+	_, c := s.requireConfig(w, r)
+	if c == nil {
+		return
+	}
+
+	tokens := []map[string]interface{}{}
+	for _, token := range c.ServiceTokens {
+		response := serviceTokenResponse(token)
+		// the token's value is only returned when it's created
+		delete(response, "key")
+		tokens = append(tokens, response)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tokens": tokens})
+}
+
+func (s *Server) createServiceToken(w http.ResponseWriter, r *http.Request) {
+	p, c := s.requireConfig(w, r)
+	if c == nil {
+		return
+	}
+
+	var body struct {
+		Name     string `json:"name"`
+		ExpireAt *int64 `json:"expire_at"`
+		Access   string `json:"access"`
+	}
+	if err := readBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Name == "" {
+		writeError(w, http.StatusBadRequest, "Service token name is required")
+		return
+	}
+	if body.Access == "" {
+		body.Access = models.ServiceTokenAccessRead
+	}
+	if body.Access != models.ServiceTokenAccessRead && body.Access != models.ServiceTokenAccessReadWrite {
+		writeError(w, http.StatusBadRequest, "Invalid service token access")
+		return
+	}
+
+	token := models.ConfigServiceToken{
+		Name:        body.Name,
+		Token:       s.id("dp.st." + c.Name + ".fake"),
+		Slug:        s.id("token"),
+		CreatedAt:   now(),
+		Project:     p.ID,
+		Environment: c.Environment,
+		Config:      c.Name,
+		Access:      body.Access,
+	}
+	if body.ExpireAt != nil {
+		token.ExpiresAt = time.Unix(*body.ExpireAt, 0).UTC().Format(time.RFC3339)
+	}
+	c.ServiceTokens = append(c.ServiceTokens, token)
+	s.addActivityLog("Created service token "+token.Name, p.ID, c.Environment, c.Name)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"token": serviceTokenResponse(token)})
+}
+
+func (s *Server) deleteServiceToken(w http.ResponseWriter, r *http.Request) {
+	p, c := s.requireConfig(w, r)
+	if c == nil {
+		return
+	}
+
+	slug := r.URL.Query().Get("slug")
+	for i, token := range c.ServiceTokens {
+		if token.Slug == slug {
+			c.ServiceTokens = append(c.ServiceTokens[:i], c.ServiceTokens[i+1:]...)
+			s.addActivityLog("Deleted service token "+token.Name, p.ID, c.Environment, c.Name)
+			writeJSON(w, http.StatusOK, map[string]interface{}{})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Could not find requested service token")
+}
+
+func sortedChanges(changes map[string]*string) []string {
+	var names []string
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,209 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package fakeapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+)
+
+// State the contents of the fake API
+type State struct {
+	Workplace models.WorkplaceSettings `json:"workplace"`
+	Projects  []*Project               `json:"projects"`
+	// Tokens the accepted auth tokens. While Tokens is nil, any token is accepted.
+	Tokens       []string             `json:"tokens,omitempty"`
+	ActivityLogs []models.ActivityLog `json:"activity_logs,omitempty"`
+}
+
+// Project a project and its environments and configs
+type Project struct {
+	models.ProjectInfo
+	Environments []models.EnvironmentInfo `json:"environments"`
+	Configs      []*Config                `json:"configs"`
+}
+
+// Config a config and its secrets, logs, and service tokens
+type Config struct {
+	models.ConfigInfo
+	Secrets       map[string]string           `json:"secrets"`
+	Logs          []models.ConfigLog          `json:"logs,omitempty"`
+	ServiceTokens []models.ConfigServiceToken `json:"service_tokens,omitempty"`
+}
+
+// DefaultState a project named "example" with dev, stg, and prd configs
+func DefaultState() State {
+	project := &Project{ProjectInfo: models.ProjectInfo{ID: "example", Name: "example", Description: "An example project", CreatedAt: now()}}
+
+	for _, environment := range []string{"dev", "stg", "prd"} {
+		project.Environments = append(project.Environments, models.EnvironmentInfo{ID: environment, Name: environment, CreatedAt: now(), Project: project.ID})
+		project.Configs = append(project.Configs, &Config{
+			ConfigInfo: models.ConfigInfo{Name: environment, Root: true, Environment: environment, Project: project.ID, CreatedAt: now()},
+			Secrets: map[string]string{
+				"DATABASE_URL": "postgres://" + environment + ".example.com:5432/app",
+				"API_KEY":      "fake-" + environment + "-api-key",
+			},
+		})
+	}
+
+	return State{
+		Workplace: models.WorkplaceSettings{ID: "workplace", Name: "Example Workplace", BillingEmail: "billing@example.com"},
+		Projects:  []*Project{project},
+	}
+}
+
+// LoadState reads state from a JSON file
+func LoadState(path string) (State, error) {
+	contents, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return State{}, err
+	}
+
+	var state State
+	if err := json.Unmarshal(contents, &state); err != nil {
+		return State{}, err
+	}
+	return state, nil
+}
+
+func (state State) copy() State {
+	// the state only contains JSON-compatible values, so a round trip is a deep copy
+	contents, _ := json.Marshal(state)
+	var copied State
+	_ = json.Unmarshal(contents, &copied)
+	return copied
+}
+
+func (state *State) project(name string) *Project {
+	for _, p := range state.Projects {
+		if p.ID == name || p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (state *State) config(project string, config string) (*Project, *Config) {
+	p := state.project(project)
+	if p == nil {
+		return nil, nil
+	}
+	for _, c := range p.Configs {
+		if c.Name == config {
+			return p, c
+		}
+	}
+	return p, nil
+}
+
+func (p *Project) environment(name string) *models.EnvironmentInfo {
+	for i, e := range p.Environments {
+		if e.ID == name || e.Name == name {
+			return &p.Environments[i]
+		}
+	}
+	return nil
+}
+
+var secretReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// computed the config's secrets with references to other secrets (e.g. ${API_HOST}) expanded
+func (c *Config) computed() map[string]string {
+	computed := map[string]string{}
+	for name, value := range c.Secrets {
+		computed[name] = secretReference.ReplaceAllStringFunc(value, func(reference string) string {
+			return c.Secrets[secretReference.FindStringSubmatch(reference)[1]]
+		})
+	}
+	return computed
+}
+
+// updateSecrets sets each secret, deleting those with a nil value, and logs the change
+func (s *Server) updateSecrets(project string, c *Config, changes map[string]*string) {
+	if c.Secrets == nil {
+		c.Secrets = map[string]string{}
+	}
+
+	var diff []interface{}
+	for _, name := range sortedChanges(changes) {
+		previous, existed := c.Secrets[name]
+		value := changes[name]
+		if value == nil {
+			if !existed {
+				continue
+			}
+			delete(c.Secrets, name)
+			diff = append(diff, map[string]interface{}{"name": name, "removed": previous})
+			continue
+		}
+
+		if existed && previous == *value {
+			continue
+		}
+		c.Secrets[name] = *value
+		d := map[string]interface{}{"name": name, "added": *value}
+		if existed {
+			d["removed"] = previous
+		}
+		diff = append(diff, d)
+	}
+
+	if len(diff) > 0 {
+		s.addConfigLog(project, c, "Updated secrets", diff)
+	}
+}
+
+func (s *Server) addConfigLog(project string, c *Config, text string, diff []interface{}) models.ConfigLog {
+	log := models.ParseConfigLog(map[string]interface{}{
+		"id":          s.id("log"),
+		"text":        text,
+		"html":        "<p>" + text + "</p>",
+		"created_at":  now(),
+		"config":      c.Name,
+		"environment": c.Environment,
+		"project":     project,
+		"user":        fakeUser,
+		"diff":        diff,
+	})
+
+	snapshot := map[string]string{}
+	for name, value := range c.Secrets {
+		snapshot[name] = value
+	}
+	s.snapshots[log.ID] = snapshot
+
+	// logs are ordered newest first
+	c.Logs = append([]models.ConfigLog{log}, c.Logs...)
+	s.addActivityLog(text, project, c.Environment, c.Name)
+	return log
+}
+
+func (s *Server) addActivityLog(text string, project string, environment string, config string) {
+	log := models.ParseActivityLog(map[string]interface{}{
+		"id":                  s.id("activity"),
+		"text":                text,
+		"html":                "<p>" + text + "</p>",
+		"created_at":          now(),
+		"enclave_project":     project,
+		"enclave_environment": environment,
+		"enclave_config":      config,
+		"user":                fakeUser,
+	})
+	s.state.ActivityLogs = append([]models.ActivityLog{log}, s.state.ActivityLogs...)
+}
@@ -16,12 +16,14 @@ limitations under the License.
 package http
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/DopplerHQ/cli/pkg/version"
 )
 
@@ -38,131 +40,169 @@ func (e *Error) Unwrap() error { return e.Err }
 // IsNil whether the error is nil
 func (e *Error) IsNil() bool { return e.Err == nil && e.Message == "" }
 
-func apiKeyHeader(apiKey string) map[string]string {
-	encoded := base64.StdEncoding.EncodeToString([]byte(apiKey + ":"))
-	return map[string]string{"Authorization": fmt.Sprintf("Basic %s", encoded)}
+// Kind the kind of error, which determines the CLI's exit code
+func (e *Error) Kind() utils.ErrorKind { return utils.ErrorDetails(e.Err).Kind }
+
+// missingFieldError an API response that's missing a required field
+func missingFieldError(field string) error {
+	return fmt.Errorf("API response is missing %s", field)
+}
+
+// serviceTokenResponse a service token as returned by the API, which names the token's value "key"
+type serviceTokenResponse struct {
+	models.ConfigServiceToken
+	Key string `json:"key"`
+}
+
+func (t serviceTokenResponse) serviceToken() models.ConfigServiceToken {
+	token := t.ConfigServiceToken
+	token.Token = t.Key
+	return token
 }
 
 // GenerateAuthCode generate an auth code
-func GenerateAuthCode(host string, verifyTLS bool, hostname string, os string, arch string) (map[string]interface{}, Error) {
+func (c *Client) GenerateAuthCode(ctx context.Context, hostname string, os string, arch string) (models.AuthCode, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "hostname", Value: hostname})
 	params = append(params, queryParam{Key: "version", Value: version.ProgramVersion})
 	params = append(params, queryParam{Key: "os", Value: os})
 	params = append(params, queryParam{Key: "arch", Value: arch})
 
-	statusCode, _, response, err := GetRequest(host, verifyTLS, nil, "/v3/auth/cli/generate", params)
+	statusCode, _, response, err := c.perform(ctx, request{method: "GET", uri: "/v3/auth/cli/generate", params: params})
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to fetch auth code", Code: statusCode}
+		return models.AuthCode{}, Error{Err: err, Message: "Unable to fetch auth code", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result models.AuthCode
 	err = json.Unmarshal(response, &result)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
+		return models.AuthCode{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
 	return result, Error{}
 }
 
 // GetAuthToken get an auth token
-func GetAuthToken(host string, verifyTLS bool, code string) (map[string]interface{}, Error) {
+func (c *Client) GetAuthToken(ctx context.Context, code string) (models.AuthToken, Error) {
 	reqBody := map[string]interface{}{}
 	reqBody["code"] = code
 	body, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Invalid auth code"}
+		return models.AuthToken{}, Error{Err: err, Message: "Invalid auth code"}
 	}
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, nil, "/v3/auth/cli/authorize", []queryParam{}, body)
+	statusCode, _, response, err := c.perform(ctx, request{method: "POST", uri: "/v3/auth/cli/authorize", body: body})
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to fetch auth token", Code: statusCode}
+		return models.AuthToken{}, Error{Err: err, Message: "Unable to fetch auth token", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result models.AuthToken
 	err = json.Unmarshal(response, &result)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to fetch auth token", Code: statusCode}
+		return models.AuthToken{}, Error{Err: err, Message: "Unable to fetch auth token", Code: statusCode}
 	}
 
 	return result, Error{}
 }
 
 // RollAuthToken roll an auth token
-func RollAuthToken(host string, verifyTLS bool, token string) (map[string]interface{}, Error) {
+func (c *Client) RollAuthToken(ctx context.Context, token string) (models.AuthToken, Error) {
 	reqBody := map[string]interface{}{}
 	reqBody["token"] = token
 	body, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Invalid auth token"}
+		return models.AuthToken{}, Error{Err: err, Message: "Invalid auth token"}
 	}
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, nil, "/v3/auth/cli/roll", []queryParam{}, body)
+	statusCode, _, response, err := c.perform(ctx, request{method: "POST", uri: "/v3/auth/cli/roll", body: body})
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to roll auth token", Code: statusCode}
+		return models.AuthToken{}, Error{Err: err, Message: "Unable to roll auth token", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result models.AuthToken
 	err = json.Unmarshal(response, &result)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
+		return models.AuthToken{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
 	return result, Error{}
 }
 
 // RevokeAuthToken revoke an auth token
-func RevokeAuthToken(host string, verifyTLS bool, token string) (map[string]interface{}, Error) {
+func (c *Client) RevokeAuthToken(ctx context.Context, token string) Error {
 	reqBody := map[string]interface{}{}
 	reqBody["token"] = token
 	body, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Invalid auth token"}
+		return Error{Err: err, Message: "Invalid auth token"}
 	}
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, nil, "/v3/auth/cli/revoke", []queryParam{}, body)
+	statusCode, _, response, err := c.perform(ctx, request{method: "POST", uri: "/v3/auth/cli/revoke", body: body})
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to revoke auth token", Code: statusCode}
+		return Error{Err: err, Message: "Unable to revoke auth token", Code: statusCode}
 	}
 
 	var result map[string]interface{}
 	err = json.Unmarshal(response, &result)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
+		return Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	return result, Error{}
+	return Error{}
+}
+
+// DownloadSecretsRequest the secrets to download
+type DownloadSecretsRequest struct {
+	Project string
+	Config  string
+	Format  models.SecretsFormat
+	// ETag of previously downloaded secrets. If the secrets haven't changed, the response has status 304 and no body.
+	ETag string
+}
+
+// DownloadSecretsResponse downloaded secrets
+type DownloadSecretsResponse struct {
+	StatusCode int
+	Headers    http.Header
+	Body       []byte
+}
+
+// ETag the ETag of the downloaded secrets
+func (r DownloadSecretsResponse) ETag() string {
+	return r.Headers.Get("etag")
 }
 
 // DownloadSecrets for specified project and config
-func DownloadSecrets(host string, verifyTLS bool, apiKey string, project string, config string, format models.SecretsFormat, etag string) (int, http.Header, []byte, Error) {
+func (c *Client) DownloadSecrets(ctx context.Context, req DownloadSecretsRequest) (DownloadSecretsResponse, Error) {
 	var params []queryParam
-	params = append(params, queryParam{Key: "project", Value: project})
-	params = append(params, queryParam{Key: "config", Value: config})
+	params = append(params, queryParam{Key: "project", Value: req.Project})
+	params = append(params, queryParam{Key: "config", Value: req.Config})
 
-	headers := apiKeyHeader(apiKey)
-	headers["Accept"] = format.MimeType()
-	if etag != "" {
-		headers["If-None-Match"] = etag
+	headers := map[string]string{}
+	headers["Accept"] = req.Format.MimeType()
+	if req.ETag != "" {
+		headers["If-None-Match"] = req.ETag
 	}
 
-	statusCode, respHeaders, response, err := GetRequest(host, verifyTLS, headers, "/v3/configs/config/secrets/download", params)
+	statusCode, respHeaders, response, err := c.get(ctx, "/v3/configs/config/secrets/download", params, headers)
+	resp := DownloadSecretsResponse{StatusCode: statusCode, Headers: respHeaders, Body: response}
 	if err != nil {
-		return statusCode, respHeaders, nil, Error{Err: err, Message: "Unable to download secrets", Code: statusCode}
+		resp.Body = nil
+		return resp, Error{Err: err, Message: "Unable to download secrets", Code: statusCode}
 	}
 
-	return statusCode, respHeaders, response, Error{}
+	return resp, Error{}
 }
 
 // GetSecrets for specified project and config
-func GetSecrets(host string, verifyTLS bool, apiKey string, project string, config string) ([]byte, Error) {
+func (c *Client) GetSecrets(ctx context.Context, project string, config string) ([]byte, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 
-	headers := apiKeyHeader(apiKey)
+	headers := map[string]string{}
 	headers["Accept"] = "application/json"
-	statusCode, _, response, err := GetRequest(host, verifyTLS, headers, "/v3/configs/config/secrets", params)
+	statusCode, _, response, err := c.get(ctx, "/v3/configs/config/secrets", params, headers)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to fetch secrets", Code: statusCode}
 	}
@@ -171,7 +211,7 @@ func GetSecrets(host string, verifyTLS bool, apiKey string, project string, conf
 }
 
 // SetSecrets for specified project and config
-func SetSecrets(host string, verifyTLS bool, apiKey string, project string, config string, secrets map[string]interface{}) (map[string]models.ComputedSecret, Error) {
+func (c *Client) SetSecrets(ctx context.Context, project string, config string, secrets map[string]interface{}) (map[string]models.ComputedSecret, Error) {
 	reqBody := map[string]interface{}{}
 	reqBody["secrets"] = secrets
 	body, err := json.Marshal(reqBody)
@@ -183,28 +223,21 @@ func SetSecrets(host string, verifyTLS bool, apiKey string, project string, conf
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config/secrets", params, body)
+	statusCode, _, response, err := c.post(ctx, "/v3/configs/config/secrets", params, nil, body)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to set secrets", Code: statusCode}
 	}
 
-	var result map[string]interface{}
-	err = json.Unmarshal(response, &result)
+	computed, err := models.ParseSecrets(response)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	computed := map[string]models.ComputedSecret{}
-	for key, secret := range result["secrets"].(map[string]interface{}) {
-		val := secret.(map[string]interface{})
-		computed[key] = models.ComputedSecret{Name: key, RawValue: val["raw"].(string), ComputedValue: val["computed"].(string)}
-	}
-
 	return computed, Error{}
 }
 
 // UploadSecrets for specified project and config
-func UploadSecrets(host string, verifyTLS bool, apiKey string, project string, config string, secrets string) (map[string]models.ComputedSecret, Error) {
+func (c *Client) UploadSecrets(ctx context.Context, project string, config string, secrets string) (map[string]models.ComputedSecret, Error) {
 	reqBody := map[string]interface{}{}
 	reqBody["file"] = secrets
 	body, err := json.Marshal(reqBody)
@@ -216,131 +249,155 @@ func UploadSecrets(host string, verifyTLS bool, apiKey string, project string, c
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config/secrets/upload", params, body)
+	statusCode, _, response, err := c.post(ctx, "/v3/configs/config/secrets/upload", params, nil, body)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to upload secrets", Code: statusCode}
 	}
 
-	var result map[string]interface{}
-	err = json.Unmarshal(response, &result)
+	computed, err := models.ParseSecrets(response)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	computed := map[string]models.ComputedSecret{}
-	for key, secret := range result["secrets"].(map[string]interface{}) {
-		val := secret.(map[string]interface{})
-		computed[key] = models.ComputedSecret{Name: key, RawValue: val["raw"].(string), ComputedValue: val["computed"].(string)}
+	return computed, Error{}
+}
+
+// GetTokenInfo get info about the token used to authenticate
+func (c *Client) GetTokenInfo(ctx context.Context) (models.TokenInfo, Error) {
+	statusCode, _, response, err := c.get(ctx, "/v3/me", []queryParam{}, nil)
+	if err != nil {
+		return models.TokenInfo{}, Error{Err: err, Message: "Unable to fetch token info", Code: statusCode}
 	}
 
-	return computed, Error{}
+	var result struct {
+		Token models.TokenInfo `json:"token"`
+	}
+	err = json.Unmarshal(response, &result)
+	if err != nil {
+		return models.TokenInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
+	}
+
+	return result.Token, Error{}
 }
 
 // GetWorkplaceSettings get specified workplace settings
-func GetWorkplaceSettings(host string, verifyTLS bool, apiKey string) (models.WorkplaceSettings, Error) {
-	statusCode, _, response, err := GetRequest(host, verifyTLS, apiKeyHeader(apiKey), "/workplace/v1", []queryParam{})
+func (c *Client) GetWorkplaceSettings(ctx context.Context) (models.WorkplaceSettings, Error) {
+	statusCode, _, response, err := c.get(ctx, "/workplace/v1", []queryParam{}, nil)
 	if err != nil {
 		return models.WorkplaceSettings{}, Error{Err: err, Message: "Unable to fetch workplace settings", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Workplace *models.WorkplaceSettings `json:"workplace"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Workplace == nil {
+		err = missingFieldError("workplace")
+	}
 	if err != nil {
 		return models.WorkplaceSettings{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	settings := models.ParseWorkplaceSettings(result["workplace"].(map[string]interface{}))
-	return settings, Error{}
+	return *result.Workplace, Error{}
 }
 
 // SetWorkplaceSettings set workplace settings
-func SetWorkplaceSettings(host string, verifyTLS bool, apiKey string, values models.WorkplaceSettings) (models.WorkplaceSettings, Error) {
+func (c *Client) SetWorkplaceSettings(ctx context.Context, values models.WorkplaceSettings) (models.WorkplaceSettings, Error) {
 	body, err := json.Marshal(values)
 	if err != nil {
 		return models.WorkplaceSettings{}, Error{Err: err, Message: "Invalid workplace settings"}
 	}
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, apiKeyHeader(apiKey), "/workplace/v1", []queryParam{}, body)
+	statusCode, _, response, err := c.post(ctx, "/workplace/v1", []queryParam{}, nil, body)
 	if err != nil {
 		return models.WorkplaceSettings{}, Error{Err: err, Message: "Unable to update workplace settings", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Workplace *models.WorkplaceSettings `json:"workplace"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Workplace == nil {
+		err = missingFieldError("workplace")
+	}
 	if err != nil {
 		return models.WorkplaceSettings{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	settings := models.ParseWorkplaceSettings(result["workplace"].(map[string]interface{}))
-	return settings, Error{}
+	return *result.Workplace, Error{}
 }
 
 // GetProjects get projects
-func GetProjects(host string, verifyTLS bool, apiKey string) ([]models.ProjectInfo, Error) {
-	statusCode, _, response, err := GetRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/projects", []queryParam{})
+func (c *Client) GetProjects(ctx context.Context) ([]models.ProjectInfo, Error) {
+	statusCode, _, response, err := c.get(ctx, "/v3/projects", []queryParam{}, nil)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to fetch projects", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Projects []models.ProjectInfo `json:"projects"`
+	}
 	err = json.Unmarshal(response, &result)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	var info []models.ProjectInfo
-	for _, project := range result["projects"].([]interface{}) {
-		projectInfo := models.ParseProjectInfo(project.(map[string]interface{}))
-		info = append(info, projectInfo)
-	}
-	return info, Error{}
+	return result.Projects, Error{}
 }
 
 // GetProject get specified project
-func GetProject(host string, verifyTLS bool, apiKey string, project string) (models.ProjectInfo, Error) {
+func (c *Client) GetProject(ctx context.Context, project string) (models.ProjectInfo, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 
-	statusCode, _, response, err := GetRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/projects/project", params)
+	statusCode, _, response, err := c.get(ctx, "/v3/projects/project", params, nil)
 	if err != nil {
 		return models.ProjectInfo{}, Error{Err: err, Message: "Unable to fetch project", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Project *models.ProjectInfo `json:"project"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Project == nil {
+		err = missingFieldError("project")
+	}
 	if err != nil {
 		return models.ProjectInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	projectInfo := models.ParseProjectInfo(result["project"].(map[string]interface{}))
-	return projectInfo, Error{}
+	return *result.Project, Error{}
 }
 
 // CreateProject create a project
-func CreateProject(host string, verifyTLS bool, apiKey string, name string, description string) (models.ProjectInfo, Error) {
+func (c *Client) CreateProject(ctx context.Context, name string, description string) (models.ProjectInfo, Error) {
 	postBody := map[string]string{"name": name, "description": description}
 	body, err := json.Marshal(postBody)
 	if err != nil {
 		return models.ProjectInfo{}, Error{Err: err, Message: "Invalid project info"}
 	}
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/projects", []queryParam{}, body)
+	statusCode, _, response, err := c.post(ctx, "/v3/projects", []queryParam{}, nil, body)
 	if err != nil {
 		return models.ProjectInfo{}, Error{Err: err, Message: "Unable to create project", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Project *models.ProjectInfo `json:"project"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Project == nil {
+		err = missingFieldError("project")
+	}
 	if err != nil {
 		return models.ProjectInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	projectInfo := models.ParseProjectInfo(result["project"].(map[string]interface{}))
-	return projectInfo, Error{}
+	return *result.Project, Error{}
 }
 
 // UpdateProject update a project's name and (optional) description
-func UpdateProject(host string, verifyTLS bool, apiKey string, project string, name string, description ...string) (models.ProjectInfo, Error) {
+func (c *Client) UpdateProject(ctx context.Context, project string, name string, description ...string) (models.ProjectInfo, Error) {
 	postBody := map[string]string{"name": name}
 	if len(description) > 0 {
 		desc := description[0]
@@ -355,27 +412,31 @@ func UpdateProject(host string, verifyTLS bool, apiKey string, project string, n
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/projects/project", params, body)
+	statusCode, _, response, err := c.post(ctx, "/v3/projects/project", params, nil, body)
 	if err != nil {
 		return models.ProjectInfo{}, Error{Err: err, Message: "Unable to update project", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Project *models.ProjectInfo `json:"project"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Project == nil {
+		err = missingFieldError("project")
+	}
 	if err != nil {
 		return models.ProjectInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	projectInfo := models.ParseProjectInfo(result["project"].(map[string]interface{}))
-	return projectInfo, Error{}
+	return *result.Project, Error{}
 }
 
 // DeleteProject create a project
-func DeleteProject(host string, verifyTLS bool, apiKey string, project string) Error {
+func (c *Client) DeleteProject(ctx context.Context, project string) Error {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 
-	statusCode, _, response, err := DeleteRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/projects/project", params)
+	statusCode, _, response, err := c.delete(ctx, "/v3/projects/project", params, nil)
 	if err != nil {
 		return Error{Err: err, Message: "Unable to delete project", Code: statusCode}
 	}
@@ -390,97 +451,99 @@ func DeleteProject(host string, verifyTLS bool, apiKey string, project string) E
 }
 
 // GetEnvironments get environments
-func GetEnvironments(host string, verifyTLS bool, apiKey string, project string) ([]models.EnvironmentInfo, Error) {
+func (c *Client) GetEnvironments(ctx context.Context, project string) ([]models.EnvironmentInfo, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 
-	statusCode, _, response, err := GetRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/environments", params)
+	statusCode, _, response, err := c.get(ctx, "/v3/environments", params, nil)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to fetch environments", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Environments []models.EnvironmentInfo `json:"environments"`
+	}
 	err = json.Unmarshal(response, &result)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	var info []models.EnvironmentInfo
-	for _, environment := range result["environments"].([]interface{}) {
-		environmentInfo := models.ParseEnvironmentInfo(environment.(map[string]interface{}))
-		info = append(info, environmentInfo)
-	}
-	return info, Error{}
+	return result.Environments, Error{}
 }
 
 // GetEnvironment get specified environment
-func GetEnvironment(host string, verifyTLS bool, apiKey string, project string, environment string) (models.EnvironmentInfo, Error) {
+func (c *Client) GetEnvironment(ctx context.Context, project string, environment string) (models.EnvironmentInfo, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "environment", Value: environment})
 
-	statusCode, _, response, err := GetRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/environments/environment", params)
+	statusCode, _, response, err := c.get(ctx, "/v3/environments/environment", params, nil)
 	if err != nil {
 		return models.EnvironmentInfo{}, Error{Err: err, Message: "Unable to fetch environment", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Environment *models.EnvironmentInfo `json:"environment"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Environment == nil {
+		err = missingFieldError("environment")
+	}
 	if err != nil {
 		return models.EnvironmentInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	info := models.ParseEnvironmentInfo(result["environment"].(map[string]interface{}))
-	return info, Error{}
+	return *result.Environment, Error{}
 }
 
 // GetConfigs get configs
-func GetConfigs(host string, verifyTLS bool, apiKey string, project string) ([]models.ConfigInfo, Error) {
+func (c *Client) GetConfigs(ctx context.Context, project string) ([]models.ConfigInfo, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 
-	statusCode, _, response, err := GetRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs", params)
+	statusCode, _, response, err := c.get(ctx, "/v3/configs", params, nil)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to fetch configs", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Configs []models.ConfigInfo `json:"configs"`
+	}
 	err = json.Unmarshal(response, &result)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	var info []models.ConfigInfo
-	for _, config := range result["configs"].([]interface{}) {
-		configInfo := models.ParseConfigInfo(config.(map[string]interface{}))
-		info = append(info, configInfo)
-	}
-	return info, Error{}
+	return result.Configs, Error{}
 }
 
 // GetConfig get a config
-func GetConfig(host string, verifyTLS bool, apiKey string, project string, config string) (models.ConfigInfo, Error) {
+func (c *Client) GetConfig(ctx context.Context, project string, config string) (models.ConfigInfo, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 
-	statusCode, _, response, err := GetRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config", params)
+	statusCode, _, response, err := c.get(ctx, "/v3/configs/config", params, nil)
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to fetch configs", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Config *models.ConfigInfo `json:"config"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Config == nil {
+		err = missingFieldError("config")
+	}
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	info := models.ParseConfigInfo(result["config"].(map[string]interface{}))
-	return info, Error{}
+	return *result.Config, Error{}
 }
 
 // CreateConfig create a config
-func CreateConfig(host string, verifyTLS bool, apiKey string, project string, name string, environment string) (models.ConfigInfo, Error) {
+func (c *Client) CreateConfig(ctx context.Context, project string, name string, environment string) (models.ConfigInfo, Error) {
 	postBody := map[string]interface{}{"name": name, "environment": environment}
 	body, err := json.Marshal(postBody)
 	if err != nil {
@@ -490,28 +553,32 @@ func CreateConfig(host string, verifyTLS bool, apiKey string, project string, na
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs", params, body)
+	statusCode, _, response, err := c.post(ctx, "/v3/configs", params, nil, body)
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to create config", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Config *models.ConfigInfo `json:"config"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Config == nil {
+		err = missingFieldError("config")
+	}
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	info := models.ParseConfigInfo(result["config"].(map[string]interface{}))
-	return info, Error{}
+	return *result.Config, Error{}
 }
 
 // DeleteConfig delete a config
-func DeleteConfig(host string, verifyTLS bool, apiKey string, project string, config string) Error {
+func (c *Client) DeleteConfig(ctx context.Context, project string, config string) Error {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 
-	statusCode, _, response, err := DeleteRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config", params)
+	statusCode, _, response, err := c.delete(ctx, "/v3/configs/config", params, nil)
 	if err != nil {
 		return Error{Err: err, Message: "Unable to delete config", Code: statusCode}
 	}
@@ -526,70 +593,82 @@ func DeleteConfig(host string, verifyTLS bool, apiKey string, project string, co
 }
 
 // LockConfig lock a config
-func LockConfig(host string, verifyTLS bool, apiKey string, project string, config string) (models.ConfigInfo, Error) {
+func (c *Client) LockConfig(ctx context.Context, project string, config string) (models.ConfigInfo, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config/lock", params, nil)
+	statusCode, _, response, err := c.post(ctx, "/v3/configs/config/lock", params, nil, nil)
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to lock config", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Config *models.ConfigInfo `json:"config"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Config == nil {
+		err = missingFieldError("config")
+	}
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	info := models.ParseConfigInfo(result["config"].(map[string]interface{}))
-	return info, Error{}
+	return *result.Config, Error{}
 }
 
 // UnlockConfig unlock a config
-func UnlockConfig(host string, verifyTLS bool, apiKey string, project string, config string) (models.ConfigInfo, Error) {
+func (c *Client) UnlockConfig(ctx context.Context, project string, config string) (models.ConfigInfo, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config/unlock", params, nil)
+	statusCode, _, response, err := c.post(ctx, "/v3/configs/config/unlock", params, nil, nil)
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to unlock config", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Config *models.ConfigInfo `json:"config"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Config == nil {
+		err = missingFieldError("config")
+	}
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	info := models.ParseConfigInfo(result["config"].(map[string]interface{}))
-	return info, Error{}
+	return *result.Config, Error{}
 }
 
 // CloneConfig clone a config
-func CloneConfig(host string, verifyTLS bool, apiKey string, project string, config string) (models.ConfigInfo, Error) {
+func (c *Client) CloneConfig(ctx context.Context, project string, config string) (models.ConfigInfo, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config/clone", params, nil)
+	statusCode, _, response, err := c.post(ctx, "/v3/configs/config/clone", params, nil, nil)
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to clone config", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Config *models.ConfigInfo `json:"config"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Config == nil {
+		err = missingFieldError("config")
+	}
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	info := models.ParseConfigInfo(result["config"].(map[string]interface{}))
-	return info, Error{}
+	return *result.Config, Error{}
 }
 
 // UpdateConfig update a config
-func UpdateConfig(host string, verifyTLS bool, apiKey string, project string, config string, name string) (models.ConfigInfo, Error) {
+func (c *Client) UpdateConfig(ctx context.Context, project string, config string, name string) (models.ConfigInfo, Error) {
 	postBody := map[string]interface{}{"name": name}
 	body, err := json.Marshal(postBody)
 	if err != nil {
@@ -600,156 +679,179 @@ func UpdateConfig(host string, verifyTLS bool, apiKey string, project string, co
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config", params, body)
+	statusCode, _, response, err := c.post(ctx, "/v3/configs/config", params, nil, body)
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to update config", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Config *models.ConfigInfo `json:"config"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Config == nil {
+		err = missingFieldError("config")
+	}
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	info := models.ParseConfigInfo(result["config"].(map[string]interface{}))
-	return info, Error{}
+	return *result.Config, Error{}
 }
 
-// GetActivityLogs get activity logs
-func GetActivityLogs(host string, verifyTLS bool, apiKey string) ([]models.ActivityLog, Error) {
-	statusCode, _, response, err := GetRequest(host, verifyTLS, apiKeyHeader(apiKey), "/logs/v1", []queryParam{})
+// GetActivityLogs get a page of activity logs
+func (c *Client) GetActivityLogs(ctx context.Context, page PageOptions) ([]models.ActivityLog, PageInfo, Error) {
+	statusCode, _, response, err := c.get(ctx, "/logs/v1", page.params(), nil)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to fetch activity logs", Code: statusCode}
+		return nil, PageInfo{}, Error{Err: err, Message: "Unable to fetch activity logs", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		pageResponse
+		Logs []models.ActivityLog `json:"logs"`
+	}
 	err = json.Unmarshal(response, &result)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
+		return nil, PageInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	var logs []models.ActivityLog
-	for _, log := range result["logs"].([]interface{}) {
-		parsedLog := models.ParseActivityLog(log.(map[string]interface{}))
-		logs = append(logs, parsedLog)
-	}
-	return logs, Error{}
+	return result.Logs, result.pageInfo(page, len(result.Logs)), Error{}
 }
 
 // GetActivityLog get specified activity log
-func GetActivityLog(host string, verifyTLS bool, apiKey string, log string) (models.ActivityLog, Error) {
-	statusCode, _, response, err := GetRequest(host, verifyTLS, apiKeyHeader(apiKey), "/logs/v1/"+log, []queryParam{})
+func (c *Client) GetActivityLog(ctx context.Context, log string) (models.ActivityLog, Error) {
+	statusCode, _, response, err := c.get(ctx, "/logs/v1/"+log, []queryParam{}, nil)
 	if err != nil {
 		return models.ActivityLog{}, Error{Err: err, Message: "Unable to fetch activity log", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Log *models.ActivityLog `json:"log"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Log == nil {
+		err = missingFieldError("log")
+	}
 	if err != nil {
 		return models.ActivityLog{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	parsedLog := models.ParseActivityLog(result["log"].(map[string]interface{}))
-	return parsedLog, Error{}
+	return *result.Log, Error{}
 }
 
-// GetConfigLogs get config audit logs
-func GetConfigLogs(host string, verifyTLS bool, apiKey string, project string, config string) ([]models.ConfigLog, Error) {
+// GetConfigLogs get a page of config audit logs
+func (c *Client) GetConfigLogs(ctx context.Context, project string, config string, page PageOptions) ([]models.ConfigLog, PageInfo, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
+	params = append(params, page.params()...)
 
-	statusCode, _, response, err := GetRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config/logs", params)
+	statusCode, _, response, err := c.get(ctx, "/v3/configs/config/logs", params, nil)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to fetch config logs", Code: statusCode}
+		return nil, PageInfo{}, Error{Err: err, Message: "Unable to fetch config logs", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		pageResponse
+		Logs []models.ConfigLog `json:"logs"`
+	}
 	err = json.Unmarshal(response, &result)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
+		return nil, PageInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	var logs []models.ConfigLog
-	for _, log := range result["logs"].([]interface{}) {
-		parsedLog := models.ParseConfigLog(log.(map[string]interface{}))
-		logs = append(logs, parsedLog)
-	}
-	return logs, Error{}
+	return result.Logs, result.pageInfo(page, len(result.Logs)), Error{}
 }
 
 // GetConfigLog get config audit log
-func GetConfigLog(host string, verifyTLS bool, apiKey string, project string, config string, log string) (models.ConfigLog, Error) {
+func (c *Client) GetConfigLog(ctx context.Context, project string, config string, log string) (models.ConfigLog, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 	params = append(params, queryParam{Key: "log", Value: log})
 
-	statusCode, _, response, err := GetRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config/logs/log", params)
+	statusCode, _, response, err := c.get(ctx, "/v3/configs/config/logs/log", params, nil)
 	if err != nil {
 		return models.ConfigLog{}, Error{Err: err, Message: "Unable to fetch config log", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Log *models.ConfigLog `json:"log"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Log == nil {
+		err = missingFieldError("log")
+	}
 	if err != nil {
 		return models.ConfigLog{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	parsedLog := models.ParseConfigLog(result["log"].(map[string]interface{}))
-	return parsedLog, Error{}
+	return *result.Log, Error{}
 }
 
 // RollbackConfigLog rollback a config log
-func RollbackConfigLog(host string, verifyTLS bool, apiKey string, project string, config string, log string) (models.ConfigLog, Error) {
+func (c *Client) RollbackConfigLog(ctx context.Context, project string, config string, log string) (models.ConfigLog, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 	params = append(params, queryParam{Key: "log", Value: log})
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config/logs/log/rollback", params, nil)
+	statusCode, _, response, err := c.post(ctx, "/v3/configs/config/logs/log/rollback", params, nil, nil)
 	if err != nil {
 		return models.ConfigLog{}, Error{Err: err, Message: "Unable to rollback config log", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Log *models.ConfigLog `json:"log"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Log == nil {
+		err = missingFieldError("log")
+	}
 	if err != nil {
 		return models.ConfigLog{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	parsedLog := models.ParseConfigLog(result["log"].(map[string]interface{}))
-	return parsedLog, Error{}
+	return *result.Log, Error{}
 }
 
-// GetConfigServiceTokens get config service tokens
-func GetConfigServiceTokens(host string, verifyTLS bool, apiKey string, project string, config string) ([]models.ConfigServiceToken, Error) {
+// GetConfigServiceTokens get a page of config service tokens
+func (c *Client) GetConfigServiceTokens(ctx context.Context, project string, config string, page PageOptions) ([]models.ConfigServiceToken, PageInfo, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
+	params = append(params, page.params()...)
 
-	statusCode, _, response, err := GetRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config/tokens", params)
+	statusCode, _, response, err := c.get(ctx, "/v3/configs/config/tokens", params, nil)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to fetch service tokens", Code: statusCode}
+		return nil, PageInfo{}, Error{Err: err, Message: "Unable to fetch service tokens", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		pageResponse
+		Tokens []serviceTokenResponse `json:"tokens"`
+	}
 	err = json.Unmarshal(response, &result)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
+		return nil, PageInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
 	var tokens []models.ConfigServiceToken
-	for _, log := range result["tokens"].([]interface{}) {
-		parsedToken := models.ParseConfigServiceToken(log.(map[string]interface{}))
-		tokens = append(tokens, parsedToken)
+	for _, token := range result.Tokens {
+		tokens = append(tokens, token.serviceToken())
 	}
-	return tokens, Error{}
+	return tokens, result.pageInfo(page, len(tokens)), Error{}
 }
 
 // CreateConfigServiceToken create a config service token
-func CreateConfigServiceToken(host string, verifyTLS bool, apiKey string, project string, config string, name string) (models.ConfigServiceToken, Error) {
+// The token never expires when expireAt is the zero time.
+func (c *Client) CreateConfigServiceToken(ctx context.Context, project string, config string, name string, expireAt time.Time, access string) (models.ConfigServiceToken, Error) {
 	postBody := map[string]interface{}{"name": name}
+	if !expireAt.IsZero() {
+		postBody["expire_at"] = expireAt.Unix()
+	}
+	if access != "" {
+		postBody["access"] = access
+	}
 	body, err := json.Marshal(postBody)
 	if err != nil {
 		return models.ConfigServiceToken{}, Error{Err: err, Message: "Invalid service token info"}
@@ -759,29 +861,33 @@ func CreateConfigServiceToken(host string, verifyTLS bool, apiKey string, projec
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 
-	statusCode, _, response, err := PostRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config/tokens", params, body)
+	statusCode, _, response, err := c.post(ctx, "/v3/configs/config/tokens", params, nil, body)
 	if err != nil {
 		return models.ConfigServiceToken{}, Error{Err: err, Message: "Unable to create service token", Code: statusCode}
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Token *serviceTokenResponse `json:"token"`
+	}
 	err = json.Unmarshal(response, &result)
+	if err == nil && result.Token == nil {
+		err = missingFieldError("token")
+	}
 	if err != nil {
 		return models.ConfigServiceToken{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	info := models.ParseConfigServiceToken(result["token"].(map[string]interface{}))
-	return info, Error{}
+	return result.Token.serviceToken(), Error{}
 }
 
 // DeleteConfigServiceToken delete a config service token
-func DeleteConfigServiceToken(host string, verifyTLS bool, apiKey string, project string, config string, slug string) Error {
+func (c *Client) DeleteConfigServiceToken(ctx context.Context, project string, config string, slug string) Error {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 	params = append(params, queryParam{Key: "slug", Value: slug})
 
-	statusCode, _, response, err := DeleteRequest(host, verifyTLS, apiKeyHeader(apiKey), "/v3/configs/config/tokens/token", params)
+	statusCode, _, response, err := c.delete(ctx, "/v3/configs/config/tokens/token", params, nil)
 	if err != nil {
 		return Error{Err: err, Message: "Unable to delete service token", Code: statusCode}
 	}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// jsonServer responds to every request with the specified body
+func jsonServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+// unexpected API responses are reported as errors rather than panicking
+func TestUnexpectedResponses(t *testing.T) {
+	ctx := context.Background()
+	requests := map[string]func(*Client) Error{
+		"GetWorkplaceSettings": func(c *Client) Error { _, err := c.GetWorkplaceSettings(ctx); return err },
+		"GetProject":           func(c *Client) Error { _, err := c.GetProject(ctx, "p"); return err },
+		"GetEnvironment":       func(c *Client) Error { _, err := c.GetEnvironment(ctx, "p", "e"); return err },
+		"GetConfig":            func(c *Client) Error { _, err := c.GetConfig(ctx, "p", "c"); return err },
+		"GetActivityLog":       func(c *Client) Error { _, err := c.GetActivityLog(ctx, "l"); return err },
+		"GetConfigLog":         func(c *Client) Error { _, err := c.GetConfigLog(ctx, "p", "c", "l"); return err },
+		"SetSecrets":           func(c *Client) Error { _, err := c.SetSecrets(ctx, "p", "c", nil); return err },
+		"CreateConfigServiceToken": func(c *Client) Error {
+			_, err := c.CreateConfigServiceToken(ctx, "p", "c", "n", time.Time{}, "")
+			return err
+		},
+	}
+	bodies := []string{
+		`{"success":true}`,
+		`{"project":null,"config":null,"environment":null,"log":null,"token":null,"workplace":null,"secrets":null}`,
+		`{"project":"p","config":1,"environment":[],"log":true,"token":"t","workplace":"w","secrets":[]}`,
+		`{"secrets":{"A":{"raw":1}}}`,
+	}
+
+	for _, body := range bodies {
+		server := jsonServer(body)
+		client := NewClient(WithHost(server.URL), noRetries())
+		for name, request := range requests {
+			if err := request(client); err.IsNil() {
+				t.Errorf("%s: expected an error for response %s", name, body)
+			}
+		}
+		server.Close()
+	}
+}
+
+func TestUnexpectedListResponses(t *testing.T) {
+	ctx := context.Background()
+	server := jsonServer(`{"projects":[{"id":1}],"configs":{},"logs":"none","tokens":[null, 2]}`)
+	defer server.Close()
+	client := NewClient(WithHost(server.URL), noRetries())
+
+	if _, err := client.GetProjects(ctx); err.IsNil() {
+		t.Error("GetProjects: expected an error")
+	}
+	if _, err := client.GetConfigs(ctx, "p"); err.IsNil() {
+		t.Error("GetConfigs: expected an error")
+	}
+	if _, _, err := client.GetConfigLogs(ctx, "p", "c", PageOptions{}); err.IsNil() {
+		t.Error("GetConfigLogs: expected an error")
+	}
+	if _, _, err := client.GetConfigServiceTokens(ctx, "p", "c", PageOptions{}); err.IsNil() {
+		t.Error("GetConfigServiceTokens: expected an error")
+	}
+}
+
+func TestServiceTokenResponse(t *testing.T) {
+	server := jsonServer(`{"token":{"name":"ci","key":"dp.st.123","slug":"abc","expires_at":null,"access":"read"}}`)
+	defer server.Close()
+	client := NewClient(WithHost(server.URL), noRetries())
+
+	token, err := client.CreateConfigServiceToken(context.Background(), "p", "c", "ci", time.Time{}, "")
+	if !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if token.Token != "dp.st.123" || token.Slug != "abc" || token.ExpiresAt != "" || token.Access != "read" {
+		t.Errorf("Unexpected token %+v", token)
+	}
+}
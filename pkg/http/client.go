@@ -0,0 +1,182 @@
+/*
+Copyright © 2020 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/version"
+)
+
+// DefaultHost the host address of the Doppler API
+const DefaultHost = "https://api.doppler.com"
+
+// DefaultTimeout how long to wait for a request to complete before timing out, unless specified via WithTimeout
+const DefaultTimeout = 10 * time.Second
+
+// TokenSource provides the token used to authenticate requests
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// StaticToken a TokenSource that always provides the same token
+type StaticToken string
+
+// Token the token
+func (t StaticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+// RetryPolicy how failed requests are retried
+type RetryPolicy struct {
+	// Attempts the max number of attempts, including the initial request
+	Attempts int
+	// Delay the delay before the first retry, which doubles after each attempt
+	Delay time.Duration
+	// MaxDelay the max delay between attempts. A MaxDelay of 0 disables the limit.
+	MaxDelay time.Duration
+	// MaxElapsed the max time to spend on an operation, including retries. A MaxElapsed of 0 disables the limit.
+	MaxElapsed time.Duration
+	// RetryableStatuses the HTTP status codes that are retried
+	RetryableStatuses []StatusRange
+}
+
+// DefaultRetryPolicy the retry policy used when none is specified
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:          5,
+	Delay:             100 * time.Millisecond,
+	MaxDelay:          10 * time.Second,
+	MaxElapsed:        60 * time.Second,
+	RetryableStatuses: DefaultRetryableStatuses,
+}
+
+// Client a Doppler API client. A Client is safe for concurrent use.
+type Client struct {
+	host        string
+	verifyTLS   bool
+	timeout     time.Duration
+	retryPolicy RetryPolicy
+	userAgent   string
+	tokenSource TokenSource
+	caCert      string
+	clientCert  string
+	clientKey   string
+	proxy       ProxyOptions
+	keepAlives  bool
+	runID       string
+	retryNotice func(message string)
+	middleware  []func(http.RoundTripper) http.RoundTripper
+	httpClient  *http.Client
+	// an error in the client's configuration
+	err error
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHost sets the API host (e.g. https://api.doppler.com)
+func WithHost(host string) Option {
+	return func(c *Client) { c.host = host }
+}
+
+// WithVerifyTLS sets whether to verify the validity of TLS certificates
+func WithVerifyTLS(verifyTLS bool) Option {
+	return func(c *Client) { c.verifyTLS = verifyTLS }
+}
+
+// WithTimeout sets the timeout of each request. A timeout of 0 disables the timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) { c.timeout = timeout }
+}
+
+// WithRetryPolicy sets how failed requests are retried
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) { c.retryPolicy = policy }
+}
+
+// WithUserAgent sets the user agent of each request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// WithTokenSource sets the source of the token used to authenticate requests
+func WithTokenSource(source TokenSource) Option {
+	return func(c *Client) { c.tokenSource = source }
+}
+
+// WithToken authenticates requests with the specified token
+func WithToken(token string) Option {
+	return WithTokenSource(StaticToken(token))
+}
+
+// WithKeepAlives sets whether connections are reused across requests. Disabling keep alives prevents
+// many concurrent CLI instances from exhausting the OS's available network sockets.
+func WithKeepAlives(keepAlives bool) Option {
+	return func(c *Client) { c.keepAlives = keepAlives }
+}
+
+// WithRunID sets the ID sent with each request, which correlates the requests with the CLI's logs
+func WithRunID(runID string) Option {
+	return func(c *Client) { c.runID = runID }
+}
+
+// WithRetryNotice sets a function that's called with a message for the user when a request is still being retried
+// after several seconds, so the user knows the CLI hasn't frozen. By default the message is only logged for debugging.
+func WithRetryNotice(notice func(message string)) Option {
+	return func(c *Client) { c.retryNotice = notice }
+}
+
+// WithTransportMiddleware wraps the client's transport, e.g. to record or inspect requests
+func WithTransportMiddleware(middleware func(http.RoundTripper) http.RoundTripper) Option {
+	return func(c *Client) { c.middleware = append(c.middleware, middleware) }
+}
+
+// NewClient creates a client. By default the client uses the Doppler API, verifies TLS certificates,
+// reuses connections, and uses DefaultTimeout and DefaultRetryPolicy.
+func NewClient(options ...Option) *Client {
+	c := &Client{
+		host:        DefaultHost,
+		verifyTLS:   true,
+		timeout:     DefaultTimeout,
+		keepAlives:  true,
+		retryPolicy: DefaultRetryPolicy,
+		userAgent:   "doppler-go-cli-" + version.ProgramVersion,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	var transport http.RoundTripper
+	transport, err := c.sharedTransport()
+	if err != nil {
+		// surface the error when a request is performed
+		c.err = err
+		transport = &http.Transport{DisableKeepAlives: true}
+	}
+	for _, middleware := range c.middleware {
+		transport = middleware(transport)
+	}
+	c.httpClient = &http.Client{Transport: transport, Timeout: c.timeout}
+
+	return c
+}
+
+// Host the API host used by the client
+func (c *Client) Host() string {
+	return c.host
+}
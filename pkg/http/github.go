@@ -16,8 +16,11 @@ limitations under the License.
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/DopplerHQ/cli/pkg/models"
@@ -25,11 +28,9 @@ import (
 	"github.com/DopplerHQ/cli/pkg/version"
 )
 
-func getLatestVersion() (string, error) {
-	origTimeout := TimeoutDuration
-	TimeoutDuration = 2 * time.Second
-	_, _, resp, err := GetRequest("https://api.github.com", true, nil, "/repos/DopplerHQ/cli/releases/latest", nil)
-	TimeoutDuration = origTimeout
+func getLatestVersion(ctx context.Context) (string, error) {
+	client := NewClient(WithHost("https://api.github.com"), WithTimeout(2*time.Second))
+	_, _, resp, err := client.get(ctx, "/repos/DopplerHQ/cli/releases/latest", nil, nil)
 	if err != nil {
 		return "", err
 	}
@@ -47,10 +48,56 @@ func getLatestVersion() (string, error) {
 	return "", errors.New("unable to retrieve tag_name of latest release")
 }
 
-// GetLatestCLIVersion fetches the latest CLI version
-func GetLatestCLIVersion() (models.VersionCheck, error) {
-	utils.LogDebug("Checking for latest version of the CLI")
-	tag, err := getLatestVersion()
+// getLatestPrerelease the newest release, including prereleases. GitHub's 'latest' release never includes prereleases.
+func getLatestPrerelease(ctx context.Context) (string, error) {
+	client := NewClient(WithHost("https://api.github.com"), WithTimeout(2*time.Second))
+	params := []queryParam{{Key: "per_page", Value: "30"}}
+	_, _, resp, err := client.get(ctx, "/repos/DopplerHQ/cli/releases", params, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var releases []struct {
+		TagName string `json:"tag_name"`
+		Draft   bool   `json:"draft"`
+	}
+	if err := json.Unmarshal(resp, &releases); err != nil {
+		return "", err
+	}
+
+	latestTag := ""
+	var latest version.Version
+	for _, release := range releases {
+		if release.Draft {
+			continue
+		}
+		v, err := version.ParseVersion(release.TagName)
+		if err != nil {
+			utils.LogDebug(fmt.Sprintf("Ignoring release with invalid version %s", release.TagName))
+			continue
+		}
+		if latestTag == "" || version.CompareVersions(latest, v) == 1 {
+			latest = v
+			latestTag = release.TagName
+		}
+	}
+
+	if latestTag == "" {
+		return "", errors.New("unable to find a release")
+	}
+	return latestTag, nil
+}
+
+// GetLatestCLIVersion fetches the latest CLI version available on the release channel
+func GetLatestCLIVersion(ctx context.Context, channel string) (models.VersionCheck, error) {
+	utils.LogDebug(fmt.Sprintf("Checking for latest version of the CLI on the %s channel", channel))
+	var tag string
+	var err error
+	if channel == version.BetaChannel {
+		tag, err = getLatestPrerelease(ctx)
+	} else {
+		tag, err = getLatestVersion(ctx)
+	}
 	if err != nil {
 		utils.LogDebug("Unable to check for CLI updates")
 		utils.LogDebugError(err)
@@ -61,19 +108,26 @@ func GetLatestCLIVersion() (models.VersionCheck, error) {
 	return versionCheck, nil
 }
 
-// GetCLIInstallScript from cli.doppler.com
-func GetCLIInstallScript() ([]byte, Error) {
-	_, _, resp, err := GetRequest("https://cli.doppler.com", true, nil, "/install.sh", nil)
+// DefaultReleaseHost the host from which CLI releases are downloaded
+const DefaultReleaseHost = "https://github.com"
+
+// DownloadReleaseAsset downloads a file attached to a CLI release (e.g. checksums.txt)
+func DownloadReleaseAsset(ctx context.Context, host string, tag string, name string) ([]byte, Error) {
+	headers := map[string]string{"Accept": "application/octet-stream"}
+	// release archives are much larger than API responses
+	client := NewClient(WithHost(host), WithTimeout(5*time.Minute))
+	_, _, resp, err := client.get(ctx, fmt.Sprintf("/DopplerHQ/cli/releases/download/%s/%s", url.PathEscape(tag), url.PathEscape(name)), nil, headers)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to download CLI install script"}
+		return nil, Error{Err: err, Message: fmt.Sprintf("Unable to download %s", name)}
 	}
 	return resp, Error{}
 }
 
 // GetChangelog of CLI releases
-func GetChangelog() ([]byte, Error) {
+func GetChangelog(ctx context.Context) ([]byte, Error) {
 	headers := map[string]string{"Accept": "application/json"}
-	_, _, resp, err := GetRequest("https://cli.doppler.com", true, headers, "/changes", nil)
+	client := NewClient(WithHost("https://cli.doppler.com"))
+	_, _, resp, err := client.get(ctx, "/changes", nil, headers)
 	if err != nil {
 		return nil, Error{Err: err, Message: "Unable to fetch changelog"}
 	}
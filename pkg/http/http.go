@@ -17,7 +17,8 @@ package http
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -25,6 +26,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,6 +34,9 @@ import (
 	"github.com/DopplerHQ/cli/pkg/version"
 )
 
+// retryNoticeDelay how long a request is retried before the client's retry notice is called
+var retryNoticeDelay = 10 * time.Second
+
 type queryParam struct {
 	Key   string
 	Value string
@@ -42,110 +47,86 @@ type errorResponse struct {
 	Success  bool
 }
 
-// GetRequest perform HTTP GET
-func GetRequest(host string, verifyTLS bool, headers map[string]string, uri string, params []queryParam) (int, http.Header, []byte, error) {
-	url := fmt.Sprintf("%s%s", host, uri)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return 0, nil, nil, err
-	}
-
-	for key, value := range headers {
-		req.Header.Set(key, value)
-	}
-
-	statusCode, respHeaders, body, err := performRequest(req, verifyTLS, params)
-	if err != nil {
-		return statusCode, respHeaders, body, err
-	}
-
-	return statusCode, respHeaders, body, nil
+// request an API request
+type request struct {
+	method  string
+	uri     string
+	params  []queryParam
+	headers map[string]string
+	body    []byte
+	// whether to authenticate the request using the client's token
+	authenticate bool
 }
 
-// PostRequest perform HTTP POST
-func PostRequest(host string, verifyTLS bool, headers map[string]string, uri string, params []queryParam, body []byte) (int, http.Header, []byte, error) {
-	url := fmt.Sprintf("%s%s", host, uri)
-	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
-	if err != nil {
-		return 0, nil, nil, err
-	}
-
-	for key, value := range headers {
-		req.Header.Set(key, value)
-	}
-
-	statusCode, respHeaders, body, err := performRequest(req, verifyTLS, params)
-	if err != nil {
-		return statusCode, respHeaders, body, err
-	}
+func (c *Client) get(ctx context.Context, uri string, params []queryParam, headers map[string]string) (int, http.Header, []byte, error) {
+	return c.perform(ctx, request{method: "GET", uri: uri, params: params, headers: headers, authenticate: true})
+}
 
-	return statusCode, respHeaders, body, nil
+func (c *Client) post(ctx context.Context, uri string, params []queryParam, headers map[string]string, body []byte) (int, http.Header, []byte, error) {
+	return c.perform(ctx, request{method: "POST", uri: uri, params: params, headers: headers, body: body, authenticate: true})
 }
 
-// DeleteRequest perform HTTP DELETE
-func DeleteRequest(host string, verifyTLS bool, headers map[string]string, uri string, params []queryParam) (int, http.Header, []byte, error) {
-	url := fmt.Sprintf("%s%s", host, uri)
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		return 0, nil, nil, err
-	}
+func (c *Client) delete(ctx context.Context, uri string, params []queryParam, headers map[string]string) (int, http.Header, []byte, error) {
+	return c.perform(ctx, request{method: "DELETE", uri: uri, params: params, headers: headers, authenticate: true})
+}
 
-	for key, value := range headers {
-		req.Header.Set(key, value)
+func (c *Client) perform(ctx context.Context, r request) (int, http.Header, []byte, error) {
+	if c.err != nil {
+		return 0, nil, nil, c.err
 	}
 
-	statusCode, respHeaders, body, err := performRequest(req, verifyTLS, params)
+	url := fmt.Sprintf("%s%s", c.host, r.uri)
+	req, err := http.NewRequest(r.method, url, nil)
 	if err != nil {
-		return statusCode, respHeaders, body, err
+		return 0, nil, nil, err
 	}
+	req = req.WithContext(ctx)
 
-	return statusCode, respHeaders, body, nil
-}
-
-func performRequest(req *http.Request, verifyTLS bool, params []queryParam) (int, http.Header, []byte, error) {
 	// set headers
 	req.Header.Set("client-sdk", "go-cli")
 	req.Header.Set("client-version", version.ProgramVersion)
-	req.Header.Set("user-agent", "doppler-go-cli-"+version.ProgramVersion)
-	if req.Header.Get("Accept") == "" {
-		req.Header.Set("Accept", "application/json")
-	}
+	req.Header.Set("user-agent", c.userAgent)
+	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
+	if c.runID != "" {
+		req.Header.Set("x-correlation-id", c.runID)
+	}
+	for key, value := range r.headers {
+		req.Header.Set(key, value)
+	}
+
+	if r.authenticate && c.tokenSource != nil {
+		token, err := c.tokenSource.Token()
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		if token != "" {
+			encoded := base64.StdEncoding.EncodeToString([]byte(token + ":"))
+			req.Header.Set("Authorization", fmt.Sprintf("Basic %s", encoded))
+		}
+	}
 
 	// set url query parameters
 	query := req.URL.Query()
-	for _, param := range params {
+	for _, param := range r.params {
 		query.Add(param.Key, param.Value)
 	}
 	req.URL.RawQuery = query.Encode()
 
 	// close the connection after reading the response, to help prevent socket exhaustion
-	req.Close = true
-
-	client := &http.Client{}
-	// set http timeout
-	if UseTimeout {
-		client.Timeout = TimeoutDuration
-	}
-
-	transport := &http.Transport{
-		// disable keep alives to prevent multiple CLI instances from exhausting the
-		// OS's available network sockets. this adds a negligible performance penalty
-		DisableKeepAlives: true,
-	}
-	// set TLS config
-	// #nosec G402
-	if !verifyTLS {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-	}
-	client.Transport = transport
+	req.Close = !c.keepAlives
 
 	startTime := time.Now()
 	var response *http.Response
 	response = nil
 
-	requestErr := retry(5, 100*time.Millisecond, func() error {
-		resp, err := client.Do(req)
+	requestErr := retry(ctx, c.retryPolicy, func() error {
+		// the body must be reset before each attempt
+		if r.body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(r.body))
+		}
+
+		resp, err := c.httpClient.Do(req)
 		if err != nil {
 			if resp != nil {
 				defer resp.Body.Close()
@@ -163,25 +144,42 @@ func performRequest(req *http.Request, verifyTLS bool, params []queryParam) (int
 
 		response = resp
 
-		utils.LogDebug(fmt.Sprintf("Performing HTTP %s to %s", req.Method, req.URL))
-		if requestID := resp.Header.Get("x-request-id"); requestID != "" {
-			utils.LogDebug(fmt.Sprintf("Request ID %s", requestID))
-		}
+		utils.LogDebugFields(fmt.Sprintf("Performing HTTP %s to %s", req.Method, req.URL),
+			utils.LogField{Key: "status", Value: strconv.Itoa(resp.StatusCode)},
+			utils.LogField{Key: "request_id", Value: resp.Header.Get("x-request-id")},
+		)
 
 		if isSuccess(resp.StatusCode) {
 			return nil
 		}
 
-		if isRetry(resp.StatusCode) {
+		if c.retryPolicy.isRetryable(resp.StatusCode) {
+			utils.LogDebug(fmt.Sprintf("Received HTTP %d, which is retryable", resp.StatusCode))
+			// read the body now so the connection is released before the next attempt, while keeping it for the error
+			body, readErr := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if readErr != nil {
+				utils.LogDebugError(readErr)
+			}
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
 			// start logging retries after 10 seconds so it doesn't feel like we've frozen
 			// we subtract 1 millisecond so that we always win the race against a request that exhausts its full 10 second time out
-			if time.Now().After(startTime.Add(10 * time.Second).Add(-1 * time.Millisecond)) {
-				utils.Log(fmt.Sprintf("Request failed with HTTP %d, retrying", resp.StatusCode))
+			if time.Now().After(startTime.Add(retryNoticeDelay).Add(-1 * time.Millisecond)) {
+				notice := fmt.Sprintf("Request failed with HTTP %d, retrying", resp.StatusCode)
+				utils.LogDebug(notice)
+				if c.retryNotice != nil {
+					c.retryNotice(notice)
+				}
+			}
+			if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				utils.LogDebug(fmt.Sprintf("Server requested a retry after %s", delay))
+				return RetryAfter{errors.New("Request failed"), delay}
 			}
 			return errors.New("Request failed")
 		}
 
 		// we cannot recover from this error code; accept defeat
+		utils.LogDebug(fmt.Sprintf("Received HTTP %d, which is not retryable", resp.StatusCode))
 		return StopRetry{errors.New("Request failed")}
 	})
 
@@ -189,6 +187,11 @@ func performRequest(req *http.Request, verifyTLS bool, params []queryParam) (int
 		defer response.Body.Close()
 	}
 
+	// the operation was canceled or its deadline was exceeded, so any response is incomplete
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return 0, nil, nil, ctxErr
+	}
+
 	if requestErr != nil && response == nil {
 		return 0, nil, nil, requestErr
 	}
@@ -205,29 +208,35 @@ func performRequest(req *http.Request, verifyTLS bool, params []queryParam) (int
 		return response.StatusCode, headers, body, nil
 	}
 
-	// print the response body error messages
+	return response.StatusCode, headers, body, apiError(response, body)
+}
+
+// apiError creates an error from a failed API response, including the API's error messages when available
+func apiError(response *http.Response, body []byte) error {
+	err := &utils.Error{
+		Err:       fmt.Errorf("Request failed with HTTP %d", response.StatusCode),
+		Kind:      utils.ErrorKindForStatus(response.StatusCode),
+		Status:    response.StatusCode,
+		RequestID: response.Header.Get("x-request-id"),
+	}
+
 	if contentType := response.Header.Get("content-type"); strings.HasPrefix(contentType, "application/json") {
 		var errResponse errorResponse
-		err = json.Unmarshal(body, &errResponse)
-		if err != nil {
+		if parseErr := json.Unmarshal(body, &errResponse); parseErr != nil {
 			utils.LogDebug(fmt.Sprintf("Unable to parse response body: \n%s", string(body)))
-			return response.StatusCode, headers, nil, err
+			err.Err = parseErr
+		} else if len(errResponse.Messages) > 0 {
+			err.Err = errors.New(strings.Join(errResponse.Messages, "\n"))
 		}
-
-		return response.StatusCode, headers, body, errors.New(strings.Join(errResponse.Messages, "\n"))
 	}
 
-	return response.StatusCode, headers, nil, fmt.Errorf("Request failed with HTTP %d", response.StatusCode)
+	return err
 }
 
 func isSuccess(statusCode int) bool {
 	return (statusCode >= 200 && statusCode <= 299) || (statusCode >= 300 && statusCode <= 399)
 }
 
-func isRetry(statusCode int) bool {
-	return (statusCode == 429) || (statusCode >= 100 && statusCode <= 199) || (statusCode >= 500 && statusCode <= 599)
-}
-
 func isTimeout(err error) bool {
 	if urlErr, ok := err.(*url.Error); ok {
 		if netErr, ok := urlErr.Err.(net.Error); ok {
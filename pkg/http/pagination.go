@@ -0,0 +1,260 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package http
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+)
+
+// PageOptions the page of results to fetch
+type PageOptions struct {
+	// Page the page number, starting at 1
+	Page int
+	// PerPage the max number of results per page. A PerPage of 0 uses the API's default.
+	PerPage int
+	// Cursor continues from a previous page, when supported by the API. A Cursor takes precedence over Page.
+	Cursor string
+}
+
+// PageInfo info about a fetched page of results
+type PageInfo struct {
+	// Page the page number
+	Page int
+	// NextCursor the cursor of the next page, if the API supports cursors
+	NextCursor string
+	// HasMore whether additional pages may exist
+	HasMore bool
+}
+
+func (page PageOptions) params() []queryParam {
+	var params []queryParam
+	if page.Cursor != "" {
+		params = append(params, queryParam{Key: "cursor", Value: page.Cursor})
+	} else if page.Page > 0 {
+		params = append(params, queryParam{Key: "page", Value: strconv.Itoa(page.Page)})
+	}
+	if page.PerPage > 0 {
+		params = append(params, queryParam{Key: "per_page", Value: strconv.Itoa(page.PerPage)})
+	}
+	return params
+}
+
+// pageResponse the pagination info of an API response
+type pageResponse struct {
+	Page       *int   `json:"page"`
+	NextCursor string `json:"next_cursor"`
+	HasMore    *bool  `json:"has_more"`
+}
+
+// pageInfo reads the pagination info from an API response containing count results.
+// when a paginated response doesn't specify whether more results exist, a full page is assumed to have more.
+// responses without any pagination info are assumed to contain all results.
+func (r pageResponse) pageInfo(page PageOptions, count int) PageInfo {
+	info := PageInfo{Page: page.Page, NextCursor: r.NextCursor}
+	if info.Page < 1 {
+		info.Page = 1
+	}
+	paginated := r.Page != nil
+	if paginated {
+		info.Page = *r.Page
+	}
+
+	if r.HasMore != nil {
+		info.HasMore = *r.HasMore
+	} else if info.NextCursor != "" {
+		info.HasMore = true
+	} else if paginated && page.PerPage > 0 {
+		info.HasMore = count >= page.PerPage
+	} else if paginated {
+		info.HasMore = count > 0
+	}
+
+	return info
+}
+
+// maxPages the max number of pages fetched by an iterator, guarding against APIs that never stop returning results
+var maxPages = 1000
+
+// pager fetches successive pages of results
+type pager struct {
+	page PageOptions
+	// fetch fetches a page, returning the number of results and a function that keeps them
+	fetch   func(PageOptions) (int, PageInfo, func(), Error)
+	fetched int
+	done    bool
+	err     Error
+}
+
+// nextPage fetches the next page, returning false once no results remain
+func (p *pager) nextPage() bool {
+	if p.done {
+		return false
+	}
+	if p.fetched >= maxPages {
+		p.err = Error{Err: fmt.Errorf("Stopped after fetching %d pages", maxPages), Message: "Unable to fetch all results"}
+		p.done = true
+		return false
+	}
+
+	count, info, keep, err := p.fetch(p.page)
+	if !err.IsNil() {
+		p.err = err
+		p.done = true
+		return false
+	}
+
+	// a server that ignores the requested page returns the same page again, so stop rather than repeating results forever
+	if p.fetched > 0 && info.NextCursor == "" && p.page.Cursor == "" && info.Page < p.page.Page {
+		utils.LogDebug(fmt.Sprintf("Requested page %d but received page %d, stopping", p.page.Page, info.Page))
+		p.done = true
+		return false
+	}
+	p.fetched++
+	keep()
+
+	if info.NextCursor != "" {
+		// a cursor that doesn't advance would return this page again
+		if info.NextCursor == p.page.Cursor {
+			p.done = true
+		}
+		p.page.Cursor = info.NextCursor
+	} else {
+		p.page.Page = info.Page + 1
+	}
+
+	if !info.HasMore {
+		p.done = true
+	}
+	return count > 0
+}
+
+// Err the error that stopped the iteration, if any
+func (p *pager) Err() Error {
+	return p.err
+}
+
+// ConfigLogIterator iterates over config logs, lazily fetching pages as needed
+type ConfigLogIterator struct {
+	pager
+	logs []models.ConfigLog
+	log  models.ConfigLog
+}
+
+// ConfigLogs iterates over a config's audit logs, starting at the specified page
+func (c *Client) ConfigLogs(ctx context.Context, project string, config string, page PageOptions) *ConfigLogIterator {
+	it := &ConfigLogIterator{}
+	it.pager = pager{page: page, fetch: func(page PageOptions) (int, PageInfo, func(), Error) {
+		logs, info, err := c.GetConfigLogs(ctx, project, config, page)
+		return len(logs), info, func() { it.logs = append(it.logs, logs...) }, err
+	}}
+	return it
+}
+
+// Next advances to the next log, returning false when no logs remain or an error occurs
+func (it *ConfigLogIterator) Next() bool {
+	if len(it.logs) == 0 && !it.nextPage() {
+		return false
+	}
+
+	it.log = it.logs[0]
+	it.logs = it.logs[1:]
+	return true
+}
+
+// Log the current log
+func (it *ConfigLogIterator) Log() models.ConfigLog {
+	return it.log
+}
+
+// ActivityLogIterator iterates over activity logs, lazily fetching pages as needed
+type ActivityLogIterator struct {
+	pager
+	logs []models.ActivityLog
+	log  models.ActivityLog
+}
+
+// ActivityLogs iterates over the workplace's activity logs, starting at the specified page
+func (c *Client) ActivityLogs(ctx context.Context, page PageOptions) *ActivityLogIterator {
+	it := &ActivityLogIterator{}
+	it.pager = pager{page: page, fetch: func(page PageOptions) (int, PageInfo, func(), Error) {
+		logs, info, err := c.GetActivityLogs(ctx, page)
+		return len(logs), info, func() { it.logs = append(it.logs, logs...) }, err
+	}}
+	return it
+}
+
+// Next advances to the next log, returning false when no logs remain or an error occurs
+func (it *ActivityLogIterator) Next() bool {
+	if len(it.logs) == 0 && !it.nextPage() {
+		return false
+	}
+
+	it.log = it.logs[0]
+	it.logs = it.logs[1:]
+	return true
+}
+
+// Log the current log
+func (it *ActivityLogIterator) Log() models.ActivityLog {
+	return it.log
+}
+
+// ConfigServiceTokenIterator iterates over service tokens, lazily fetching pages as needed
+type ConfigServiceTokenIterator struct {
+	pager
+	tokens []models.ConfigServiceToken
+	token  models.ConfigServiceToken
+}
+
+// ConfigServiceTokens iterates over a config's service tokens, starting at the specified page
+func (c *Client) ConfigServiceTokens(ctx context.Context, project string, config string, page PageOptions) *ConfigServiceTokenIterator {
+	it := &ConfigServiceTokenIterator{}
+	it.pager = pager{page: page, fetch: func(page PageOptions) (int, PageInfo, func(), Error) {
+		tokens, info, err := c.GetConfigServiceTokens(ctx, project, config, page)
+		return len(tokens), info, func() { it.tokens = append(it.tokens, tokens...) }, err
+	}}
+	return it
+}
+
+// Next advances to the next token, returning false when no tokens remain or an error occurs
+func (it *ConfigServiceTokenIterator) Next() bool {
+	if len(it.tokens) == 0 && !it.nextPage() {
+		return false
+	}
+
+	it.token = it.tokens[0]
+	it.tokens = it.tokens[1:]
+	return true
+}
+
+// Token the current token
+func (it *ConfigServiceTokenIterator) Token() models.ConfigServiceToken {
+	return it.token
+}
+
+// All fetches all remaining tokens
+func (it *ConfigServiceTokenIterator) All() ([]models.ConfigServiceToken, Error) {
+	var tokens []models.ConfigServiceToken
+	for it.Next() {
+		tokens = append(tokens, it.Token())
+	}
+	return tokens, it.Err()
+}
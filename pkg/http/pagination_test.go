@@ -0,0 +1,195 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// logsServer serves total config logs, paginated using the page and per_page query params
+func logsServer(total int, includePage bool) (*httptest.Server, *[]string) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if perPage < 1 {
+			perPage = 20
+		}
+
+		logs := []map[string]interface{}{}
+		for i := (page - 1) * perPage; i < page*perPage && i < total; i++ {
+			logs = append(logs, map[string]interface{}{"id": fmt.Sprintf("log-%d", i)})
+		}
+
+		response := map[string]interface{}{"logs": logs, "success": true}
+		if includePage {
+			response["page"] = page
+		}
+		w.Header().Set("content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	return server, &requests
+}
+
+func TestConfigLogIterator(t *testing.T) {
+	server, requests := logsServer(7, true)
+	defer server.Close()
+	client := NewClient(WithHost(server.URL), noRetries())
+
+	it := client.ConfigLogs(context.Background(), "project", "config", PageOptions{Page: 1, PerPage: 3})
+	if !it.Next() || it.Log().ID != "log-0" {
+		t.Fatalf("Expected first log, got %q", it.Log().ID)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("Expected pages to be fetched lazily, got %d requests", len(*requests))
+	}
+
+	ids := []string{it.Log().ID}
+	for it.Next() {
+		ids = append(ids, it.Log().ID)
+	}
+	if err := it.Err(); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if len(ids) != 7 || ids[6] != "log-6" {
+		t.Errorf("Expected 7 logs, got %v", ids)
+	}
+	// the final page isn't full, so no additional page is requested
+	if len(*requests) != 3 {
+		t.Errorf("Expected 3 requests, got %d: %v", len(*requests), *requests)
+	}
+
+	// start at a later page
+	it = client.ConfigLogs(context.Background(), "project", "config", PageOptions{Page: 2, PerPage: 3})
+	if !it.Next() || it.Log().ID != "log-3" {
+		t.Errorf("Expected to start at the second page, got %q", it.Log().ID)
+	}
+}
+
+func TestConfigLogIteratorUnpaginated(t *testing.T) {
+	// responses without pagination info contain all results
+	server, requests := logsServer(5, false)
+	defer server.Close()
+	client := NewClient(WithHost(server.URL), noRetries())
+
+	it := client.ConfigLogs(context.Background(), "project", "config", PageOptions{})
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if count != 5 || len(*requests) != 1 {
+		t.Errorf("Expected 5 logs from 1 request, got %d logs from %d requests", count, len(*requests))
+	}
+}
+
+func TestConfigLogIteratorError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+	}))
+	defer server.Close()
+	client := NewClient(WithHost(server.URL), noRetries())
+
+	it := client.ConfigLogs(context.Background(), "project", "config", PageOptions{})
+	if it.Next() {
+		t.Fatal("Expected iteration to stop")
+	}
+	if err := it.Err(); err.IsNil() || err.Code != 404 {
+		t.Errorf("Expected a 404 error, got %d", err.Code)
+	}
+}
+
+func TestConfigLogIteratorIgnoredPage(t *testing.T) {
+	// the server echoes the page number but always returns the first page, without has_more
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"logs": []map[string]interface{}{{"id": "log-0"}}, "page": 1, "success": true})
+	}))
+	defer server.Close()
+	client := NewClient(WithHost(server.URL), noRetries())
+
+	count := 0
+	it := client.ConfigLogs(context.Background(), "project", "config", PageOptions{})
+	for it.Next() {
+		count++
+	}
+	if err := it.Err(); !err.IsNil() {
+		t.Fatal(err.Unwrap())
+	}
+	if count != 1 || requests != 2 {
+		t.Errorf("Expected 1 log from 2 requests, got %d logs from %d requests", count, requests)
+	}
+}
+
+func TestConfigLogIteratorRepeatedCursor(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"logs": []map[string]interface{}{{"id": fmt.Sprintf("log-%d", requests)}}, "next_cursor": "same", "success": true})
+	}))
+	defer server.Close()
+	client := NewClient(WithHost(server.URL), noRetries())
+
+	count := 0
+	it := client.ConfigLogs(context.Background(), "project", "config", PageOptions{})
+	for it.Next() {
+		count++
+	}
+	// the second page returns the cursor it was requested with
+	if count != 2 || requests != 2 {
+		t.Errorf("Expected 2 logs from 2 requests, got %d logs from %d requests", count, requests)
+	}
+}
+
+func TestConfigLogIteratorMaxPages(t *testing.T) {
+	previous := maxPages
+	maxPages = 3
+	defer func() { maxPages = previous }()
+
+	// the server always has more results
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		w.Header().Set("content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"logs": []map[string]interface{}{{"id": "log"}}, "page": page, "has_more": true, "success": true})
+	}))
+	defer server.Close()
+	client := NewClient(WithHost(server.URL), noRetries())
+
+	count := 0
+	it := client.ConfigLogs(context.Background(), "project", "config", PageOptions{Page: 1})
+	for it.Next() {
+		count++
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 logs, got %d", count)
+	}
+	if err := it.Err(); err.IsNil() {
+		t.Error("Expected an error after reaching the max number of pages")
+	}
+}
@@ -16,36 +16,179 @@ limitations under the License.
 package http
 
 import (
+	"context"
+	"fmt"
 	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/DopplerHQ/cli/pkg/utils"
 )
 
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
-func retry(attempts int, sleep time.Duration, f func() error) error {
-	if err := f(); err != nil {
+// StatusRange an inclusive range of HTTP status codes
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// DefaultRetryableStatuses the status codes that are retried by default: 1xx, 429, and 5xx
+var DefaultRetryableStatuses = []StatusRange{{100, 199}, {429, 429}, {500, 599}}
+
+// ParseStatusRanges parses a comma-separated list of status codes and classes (e.g. "429,5xx")
+func ParseStatusRanges(value string) ([]StatusRange, error) {
+	var ranges []StatusRange
+	for _, code := range strings.Split(value, ",") {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+
+		if len(code) == 3 && strings.HasSuffix(code, "xx") {
+			class, err := strconv.Atoi(code[:1])
+			if err != nil || class < 1 || class > 5 {
+				return nil, fmt.Errorf("Invalid status code class %s", code)
+			}
+			ranges = append(ranges, StatusRange{class * 100, class*100 + 99})
+			continue
+		}
+
+		status, err := strconv.Atoi(code)
+		if err != nil || status < 100 || status > 599 {
+			return nil, fmt.Errorf("Invalid status code %s", code)
+		}
+		ranges = append(ranges, StatusRange{status, status})
+	}
+
+	return ranges, nil
+}
+
+// String formats the range as a status code or class (e.g. "429" or "5xx")
+func (r StatusRange) String() string {
+	if r.Min == r.Max {
+		return strconv.Itoa(r.Min)
+	}
+	if r.Min%100 == 0 && r.Max == r.Min+99 {
+		return fmt.Sprintf("%dxx", r.Min/100)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// FormatStatusRanges formats the ranges as a comma-separated list
+func FormatStatusRanges(ranges []StatusRange) string {
+	var codes []string
+	for _, r := range ranges {
+		codes = append(codes, r.String())
+	}
+	return strings.Join(codes, ",")
+}
+
+func (policy RetryPolicy) isRetryable(statusCode int) bool {
+	for _, r := range policy.RetryableStatuses {
+		if statusCode >= r.Min && statusCode <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// retry calls f until it succeeds, returns StopRetry, or the retry policy is exhausted
+func retry(ctx context.Context, policy RetryPolicy, f func() error) error {
+	startTime := time.Now()
+	delay := policy.Delay
+
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil {
+			return nil
+		}
+
 		if s, ok := err.(StopRetry); ok {
 			// Return the original error for later checking
 			return s.error
 		}
 
-		if attempts--; attempts > 0 {
-			// Add some randomness to prevent creating a Thundering Herd
-			jitter := time.Duration(rand.Int63n(int64(sleep))) // #nosec G404
-			sleep = sleep + jitter/2
+		// the operation was canceled or its deadline was exceeded
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 
-			time.Sleep(sleep)
-			return retry(attempts, 2*sleep, f)
+		if attempt >= policy.Attempts {
+			utils.LogDebug(fmt.Sprintf("Not retrying, reached the max of %d attempts", policy.Attempts))
+			return err
+		}
+
+		var sleep time.Duration
+		if retryAfter, ok := err.(RetryAfter); ok {
+			// the server knows best, so its delay isn't subject to MaxDelay
+			sleep = retryAfter.Delay
+			err = retryAfter.error
+		} else {
+			sleep = delay
+			if delay > 0 {
+				// Add some randomness to prevent creating a Thundering Herd
+				jitter := time.Duration(rand.Int63n(int64(delay))) // #nosec G404
+				sleep = delay + jitter/2
+			}
+			if policy.MaxDelay > 0 && sleep > policy.MaxDelay {
+				sleep = policy.MaxDelay
+			}
+			delay = 2 * delay
+		}
+
+		if policy.MaxElapsed > 0 && time.Since(startTime)+sleep > policy.MaxElapsed {
+			utils.LogDebug(fmt.Sprintf("Not retrying, waiting %s would exceed the max retry time of %s", sleep, policy.MaxElapsed))
+			return err
 		}
-		return err
-	}
 
-	return nil
+		utils.LogDebug(fmt.Sprintf("Retrying in %s (attempt %d of %d)", sleep, attempt+1, policy.Attempts))
+		timer := time.NewTimer(sleep)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
 }
 
 // StopRetry indicates to stop attempting retries. wraps an error
 type StopRetry struct {
 	error
 }
+
+// RetryAfter indicates to retry after the specified delay. wraps an error
+type RetryAfter struct {
+	error
+	Delay time.Duration
+}
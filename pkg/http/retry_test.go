@@ -0,0 +1,227 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		delay time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"Wed, 01 Jan 2020 00:00:10 GMT", 10 * time.Second, true},
+		{"Tue, 31 Dec 2019 23:59:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, test := range tests {
+		delay, ok := parseRetryAfter(test.value, now)
+		if delay != test.delay || ok != test.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %t; expected %s, %t", test.value, delay, ok, test.delay, test.ok)
+		}
+	}
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	ranges, err := ParseStatusRanges("429, 5xx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FormatStatusRanges(ranges) != "429,5xx" {
+		t.Errorf("Unexpected ranges %v", ranges)
+	}
+
+	policy := RetryPolicy{RetryableStatuses: ranges}
+	for status, retryable := range map[int]bool{429: true, 500: true, 599: true, 404: false, 200: false} {
+		if policy.isRetryable(status) != retryable {
+			t.Errorf("Expected isRetryable(%d) to be %t", status, retryable)
+		}
+	}
+
+	for _, invalid := range []string{"abc", "600", "9xx", "42"} {
+		if _, err := ParseStatusRanges(invalid); err == nil {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+}
+
+// statusServer responds with the specified statuses in order, then 200
+func statusServer(retryAfter string, statuses ...int) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= len(statuses) {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(statuses[requests-1])
+			return
+		}
+		okHandler(w, r)
+	}))
+	return server, &requests
+}
+
+func TestRetryStatusCodes(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, Delay: time.Millisecond, RetryableStatuses: []StatusRange{{503, 503}}}
+
+	server, requests := statusServer("", 503, 503)
+	defer server.Close()
+	client := NewClient(WithHost(server.URL), WithRetryPolicy(policy))
+	if _, _, _, err := client.get(context.Background(), "/", nil, nil); err != nil {
+		t.Fatalf("Expected request to succeed after retries: %s", err)
+	}
+	if *requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", *requests)
+	}
+
+	server, requests = statusServer("", 500)
+	defer server.Close()
+	client = NewClient(WithHost(server.URL), WithRetryPolicy(policy))
+	if status, _, _, err := client.get(context.Background(), "/", nil, nil); err == nil || status != 500 {
+		t.Fatalf("Expected request to fail with a non-retryable status, got %d", status)
+	}
+	if *requests != 1 {
+		t.Errorf("Expected 1 request, got %d", *requests)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	server, requests := statusServer("1", 429)
+	defer server.Close()
+
+	policy := RetryPolicy{Attempts: 2, Delay: time.Millisecond, RetryableStatuses: DefaultRetryableStatuses}
+	client := NewClient(WithHost(server.URL), WithRetryPolicy(policy))
+	start := time.Now()
+	if _, _, _, err := client.get(context.Background(), "/", nil, nil); err != nil {
+		t.Fatalf("Expected request to succeed after retrying: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected the retry to wait for Retry-After, only waited %s", elapsed)
+	}
+	if *requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", *requests)
+	}
+
+	// the server's delay exceeds the max retry time, so the request isn't retried
+	server, requests = statusServer("30", 429)
+	defer server.Close()
+	policy.MaxElapsed = 5 * time.Second
+	client = NewClient(WithHost(server.URL), WithRetryPolicy(policy))
+	if status, _, _, err := client.get(context.Background(), "/", nil, nil); err == nil || status != 429 {
+		t.Fatalf("Expected request to fail without retrying, got %d", status)
+	}
+	if *requests != 1 {
+		t.Errorf("Expected 1 request, got %d", *requests)
+	}
+}
+
+func TestRetryReusesConnection(t *testing.T) {
+	var mutex sync.Mutex
+	connections := 0
+	requests := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		retry := requests <= 2
+		mutex.Unlock()
+
+		if retry {
+			// the connection is only reused once the body is consumed
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"messages":["Service unavailable"]}`))
+			return
+		}
+		okHandler(w, r)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mutex.Lock()
+			connections++
+			mutex.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	policy := RetryPolicy{Attempts: 3, Delay: time.Millisecond, RetryableStatuses: DefaultRetryableStatuses}
+	client := NewClient(WithHost(server.URL), WithRetryPolicy(policy))
+	if _, _, _, err := client.get(context.Background(), "/", nil, nil); err != nil {
+		t.Fatalf("Expected request to succeed after retries: %s", err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests)
+	}
+	if connections != 1 {
+		t.Errorf("Expected retries to reuse 1 connection, got %d connections", connections)
+	}
+}
+
+func TestRetryNotice(t *testing.T) {
+	defer func(delay time.Duration) { retryNoticeDelay = delay }(retryNoticeDelay)
+	retryNoticeDelay = 0
+
+	server, _ := statusServer("", 503)
+	defer server.Close()
+
+	var notices []string
+	policy := RetryPolicy{Attempts: 2, Delay: time.Millisecond, RetryableStatuses: DefaultRetryableStatuses}
+	client := NewClient(WithHost(server.URL), WithRetryPolicy(policy), WithRetryNotice(func(message string) {
+		notices = append(notices, message)
+	}))
+	if _, _, _, err := client.get(context.Background(), "/", nil, nil); err != nil {
+		t.Fatalf("Expected request to succeed after retrying: %s", err)
+	}
+	if len(notices) != 1 || notices[0] != "Request failed with HTTP 503, retrying" {
+		t.Errorf("Unexpected retry notices %q", notices)
+	}
+}
+
+func TestRunID(t *testing.T) {
+	var correlationIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		correlationIDs = append(correlationIDs, r.Header.Get("x-correlation-id"))
+		okHandler(w, r)
+	}))
+	defer server.Close()
+
+	for _, client := range []*Client{NewClient(WithHost(server.URL)), NewClient(WithHost(server.URL), WithRunID("run-id"))} {
+		if _, _, _, err := client.get(context.Background(), "/", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(correlationIDs) != 2 || correlationIDs[0] != "" || correlationIDs[1] != "run-id" {
+		t.Errorf("Unexpected correlation IDs %q", correlationIDs)
+	}
+}
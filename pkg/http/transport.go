@@ -0,0 +1,314 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyOptions the proxies used to connect to the API
+type ProxyOptions struct {
+	// HTTP the proxy URL used for http requests
+	HTTP string
+	// HTTPS the proxy URL used for https requests
+	HTTPS string
+	// NoProxy a comma-separated list of hosts, domains, IPs, and CIDR ranges that bypass the proxy
+	NoProxy string
+}
+
+// WithCACert trusts the certificates in the specified PEM bundle, in addition to the system's certificates
+func WithCACert(path string) Option {
+	return func(c *Client) { c.caCert = path }
+}
+
+// WithClientCertificate authenticates TLS connections using the specified PEM certificate and key (i.e. mutual TLS)
+func WithClientCertificate(certPath string, keyPath string) Option {
+	return func(c *Client) {
+		c.clientCert = certPath
+		c.clientKey = keyPath
+	}
+}
+
+// WithProxy routes requests through the specified proxies. Proxy URLs may use the http, https, or socks5 scheme.
+func WithProxy(proxy ProxyOptions) Option {
+	return func(c *Client) { c.proxy = proxy }
+}
+
+// transportKey the settings that determine a transport. clients with the same settings share a transport, and thus its connections.
+type transportKey struct {
+	verifyTLS  bool
+	caCert     string
+	clientCert string
+	clientKey  string
+	proxy      ProxyOptions
+	keepAlives bool
+}
+
+var (
+	transportsMutex sync.Mutex
+	// the transports used by the process's clients, which are reused for the life of the process
+	transports = map[transportKey]*http.Transport{}
+)
+
+// sharedTransport gets the transport for the client's settings, creating it if necessary
+func (c *Client) sharedTransport() (*http.Transport, error) {
+	key := transportKey{
+		verifyTLS:  c.verifyTLS,
+		caCert:     c.caCert,
+		clientCert: c.clientCert,
+		clientKey:  c.clientKey,
+		proxy:      c.proxy,
+		keepAlives: c.keepAlives,
+	}
+
+	transportsMutex.Lock()
+	defer transportsMutex.Unlock()
+
+	if transport, ok := transports[key]; ok {
+		return transport, nil
+	}
+
+	transport, err := c.newTransport()
+	if err != nil {
+		return nil, err
+	}
+	transports[key] = transport
+	return transport, nil
+}
+
+// newTransport creates the client's transport from its TLS and proxy settings
+func (c *Client) newTransport() (*http.Transport, error) {
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		DisableKeepAlives:     !c.keepAlives,
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if c.proxy != (ProxyOptions{}) {
+		proxy, err := proxyFunc(c.proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = proxy
+	}
+
+	return transport, nil
+}
+
+func (c *Client) tlsConfig() (*tls.Config, error) {
+	if c.verifyTLS && c.caCert == "" && c.clientCert == "" && c.clientKey == "" {
+		return nil, nil
+	}
+
+	// #nosec G402
+	config := &tls.Config{InsecureSkipVerify: !c.verifyTLS}
+
+	if c.caCert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			// the system pool is unavailable on some platforms (e.g. Windows)
+			pool = x509.NewCertPool()
+		}
+
+		bundle, err := ioutil.ReadFile(c.caCert) // #nosec G304
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA certificate bundle: %s", err)
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("Unable to parse CA certificate bundle %s", c.caCert)
+		}
+		config.RootCAs = pool
+	}
+
+	if c.clientCert != "" || c.clientKey != "" {
+		if c.clientCert == "" || c.clientKey == "" {
+			return nil, errors.New("Both a client certificate and client key must be specified")
+		}
+
+		cert, err := tls.LoadX509KeyPair(c.clientCert, c.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// proxyFunc creates a function that returns the proxy to use for a request
+func proxyFunc(options ProxyOptions) (func(*http.Request) (*url.URL, error), error) {
+	httpProxy, err := parseProxyURL(options.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	httpsProxy, err := parseProxyURL(options.HTTPS)
+	if err != nil {
+		return nil, err
+	}
+	noProxy := parseNoProxy(options.NoProxy)
+
+	return func(req *http.Request) (*url.URL, error) {
+		proxy := httpProxy
+		if req.URL.Scheme == "https" {
+			proxy = httpsProxy
+		}
+
+		if proxy == nil || noProxy.bypass(req.URL) {
+			return nil, nil
+		}
+		return proxy, nil
+	}, nil
+}
+
+func parseProxyURL(proxy string) (*url.URL, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+
+	// assume http if no scheme is specified (e.g. proxy.example.com:3128)
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid proxy URL: %s", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("Invalid proxy URL: unsupported scheme %s", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, errors.New("Invalid proxy URL: missing host")
+	}
+
+	return proxyURL, nil
+}
+
+// noProxyList the hosts that bypass the proxy
+type noProxyList struct {
+	all     bool
+	ranges  []*net.IPNet
+	ips     []net.IP
+	domains []noProxyDomain
+}
+
+type noProxyDomain struct {
+	name string
+	// the port to match. an empty port matches all ports
+	port string
+}
+
+func parseNoProxy(noProxy string) noProxyList {
+	var list noProxyList
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+
+		if entry == "*" {
+			list.all = true
+			continue
+		}
+
+		if _, ipRange, err := net.ParseCIDR(entry); err == nil {
+			list.ranges = append(list.ranges, ipRange)
+			continue
+		}
+
+		if ip := net.ParseIP(entry); ip != nil {
+			list.ips = append(list.ips, ip)
+			continue
+		}
+
+		host, port, err := net.SplitHostPort(entry)
+		if err != nil {
+			host = entry
+			port = ""
+		}
+		if ip := net.ParseIP(host); ip != nil && port == "" {
+			list.ips = append(list.ips, ip)
+			continue
+		}
+
+		// ".example.com" and "*.example.com" are treated the same as "example.com"
+		host = strings.TrimPrefix(strings.TrimPrefix(host, "*"), ".")
+		list.domains = append(list.domains, noProxyDomain{name: host, port: port})
+	}
+
+	return list
+}
+
+// bypass whether requests to the URL should bypass the proxy
+func (l noProxyList) bypass(u *url.URL) bool {
+	if l.all {
+		return true
+	}
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, other := range l.ips {
+			if ip.Equal(other) {
+				return true
+			}
+		}
+		for _, ipRange := range l.ranges {
+			if ipRange.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	for _, domain := range l.domains {
+		if domain.port != "" && domain.port != port {
+			continue
+		}
+		if host == domain.name || strings.HasSuffix(host, "."+domain.name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,289 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+	_, _ = w.Write([]byte(`{"success":true}`))
+}
+
+func noRetries() Option {
+	return WithRetryPolicy(RetryPolicy{Attempts: 1})
+}
+
+// writePEM writes a PEM block to a file in dir
+func writePEM(t *testing.T, dir string, name string, blockType string, bytes []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newClientCertificate creates a CA and a client certificate signed by it, returning the CA and the paths to the client cert and key
+func newClientCertificate(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath := writePEM(t, dir, "client.pem", "CERTIFICATE", clientDER)
+	keyPath := writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER)
+	return ca, certPath, keyPath
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "doppler-http-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(okHandler))
+	defer server.Close()
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	caPath := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	client := NewClient(WithHost(server.URL), noRetries())
+	if _, _, _, err := client.get(context.Background(), "/", nil, nil); err == nil {
+		t.Fatal("Expected request to fail without the server's CA")
+	}
+
+	client = NewClient(WithHost(server.URL), WithCACert(caPath), noRetries())
+	if _, _, _, err := client.get(context.Background(), "/", nil, nil); err != nil {
+		t.Fatalf("Expected request to succeed using the CA bundle: %s", err)
+	}
+
+	client = NewClient(WithHost(server.URL), WithCACert(filepath.Join(dir, "missing.pem")), noRetries())
+	if _, _, _, err := client.get(context.Background(), "/", nil, nil); err == nil {
+		t.Fatal("Expected request to fail with a missing CA bundle")
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	ca, certPath, keyPath := newClientCertificate(t, dir)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(okHandler))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caPath := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	client := NewClient(WithHost(server.URL), WithCACert(caPath), noRetries())
+	if _, _, _, err := client.get(context.Background(), "/", nil, nil); err == nil {
+		t.Fatal("Expected request to fail without a client certificate")
+	}
+
+	client = NewClient(WithHost(server.URL), WithCACert(caPath), WithClientCertificate(certPath, keyPath), noRetries())
+	if _, _, _, err := client.get(context.Background(), "/", nil, nil); err != nil {
+		t.Fatalf("Expected request to succeed using the client certificate: %s", err)
+	}
+
+	client = NewClient(WithHost(server.URL), WithCACert(caPath), WithClientCertificate(certPath, ""), noRetries())
+	if _, _, _, err := client.get(context.Background(), "/", nil, nil); err == nil {
+		t.Fatal("Expected request to fail without a client key")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// requests sent through a proxy use the absolute URL
+		proxied = append(proxied, r.URL.String())
+		okHandler(w, r)
+	}))
+	defer proxy.Close()
+
+	client := NewClient(WithHost("http://api.doppler.test"), WithProxy(ProxyOptions{HTTP: proxy.URL}), noRetries())
+	if _, _, _, err := client.get(context.Background(), "/v3/me", nil, nil); err != nil {
+		t.Fatalf("Expected request to succeed via the proxy: %s", err)
+	}
+	if len(proxied) != 1 || proxied[0] != "http://api.doppler.test/v3/me" {
+		t.Fatalf("Expected request to be sent via the proxy, got %v", proxied)
+	}
+
+	// the https proxy is only used for https requests
+	client = NewClient(WithHost("http://api.doppler.test"), WithProxy(ProxyOptions{HTTPS: proxy.URL}), noRetries())
+	if _, _, _, err := client.get(context.Background(), "/v3/me", nil, nil); err == nil {
+		t.Fatal("Expected request to bypass the https proxy")
+	}
+	if len(proxied) != 1 {
+		t.Fatalf("Expected request to bypass the https proxy, got %v", proxied)
+	}
+
+	client = NewClient(WithHost("http://api.doppler.test"), WithProxy(ProxyOptions{HTTP: proxy.URL, NoProxy: ".doppler.test"}), noRetries())
+	if _, _, _, err := client.get(context.Background(), "/v3/me", nil, nil); err == nil {
+		t.Fatal("Expected request to bypass the proxy")
+	}
+	if len(proxied) != 1 {
+		t.Fatalf("Expected request to bypass the proxy, got %v", proxied)
+	}
+
+	client = NewClient(WithProxy(ProxyOptions{HTTPS: "ftp://proxy.example.com"}), noRetries())
+	if _, _, _, err := client.get(context.Background(), "/v3/me", nil, nil); err == nil {
+		t.Fatal("Expected request to fail with an unsupported proxy scheme")
+	}
+}
+
+func TestNoProxy(t *testing.T) {
+	noProxy := parseNoProxy("localhost, .internal.example.com,10.0.0.0/8, 192.168.1.1,api.example.com:8443")
+
+	tests := []struct {
+		url    string
+		bypass bool
+	}{
+		{"http://localhost/", true},
+		{"https://vault.internal.example.com/", true},
+		{"https://internal.example.com/", true},
+		{"https://example.com/", false},
+		{"https://notinternal.example.com/", false},
+		{"http://10.1.2.3/", true},
+		{"http://11.1.2.3/", false},
+		{"http://192.168.1.1:8080/", true},
+		{"https://api.example.com:8443/", true},
+		{"https://api.example.com/", false},
+	}
+
+	for _, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bypass := noProxy.bypass(u); bypass != test.bypass {
+			t.Errorf("Expected bypass(%s) to be %t, got %t", test.url, test.bypass, bypass)
+		}
+	}
+
+	if !parseNoProxy("*").bypass(&url.URL{Host: "api.doppler.com"}) {
+		t.Error("Expected * to bypass all hosts")
+	}
+}
+
+func TestConnectionReuse(t *testing.T) {
+	var mutex sync.Mutex
+	connections := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(okHandler))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mutex.Lock()
+			connections++
+			mutex.Unlock()
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	caPath := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	tests := []struct {
+		keepAlives  bool
+		connections int
+	}{
+		{true, 1},
+		{false, 3},
+	}
+
+	for _, test := range tests {
+		mutex.Lock()
+		connections = 0
+		mutex.Unlock()
+
+		for i := 0; i < 3; i++ {
+			// each request uses a new client, as the CLI does
+			client := NewClient(WithHost(server.URL), WithCACert(caPath), WithKeepAlives(test.keepAlives), noRetries())
+			if _, _, _, err := client.get(context.Background(), "/", nil, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		mutex.Lock()
+		if connections != test.connections {
+			t.Errorf("Expected %d connections with keep alives %t, got %d", test.connections, test.keepAlives, connections)
+		}
+		mutex.Unlock()
+	}
+}
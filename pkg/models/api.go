@@ -107,4 +107,56 @@ type ConfigServiceToken struct {
 	Project     string `json:"project"`
 	Environment string `json:"environment"`
 	Config      string `json:"config"`
+	// ExpiresAt is empty for tokens that never expire
+	ExpiresAt string `json:"expires_at"`
+	Access    string `json:"access"`
+	// LastSeenAt is empty for tokens that have never been used
+	LastSeenAt string `json:"last_seen_at"`
+}
+
+// service token access levels
+const (
+	ServiceTokenAccessRead      = "read"
+	ServiceTokenAccessReadWrite = "read/write"
+)
+
+// token types
+const (
+	TokenTypeCLI     = "cli"
+	TokenTypeService = "service_token"
+)
+
+// TokenInfo the token used to authenticate, as described by the API
+type TokenInfo struct {
+	Type      string            `json:"type"`
+	Name      string            `json:"name"`
+	Slug      string            `json:"slug,omitempty"`
+	CreatedAt string            `json:"created_at,omitempty"`
+	Workplace WorkplaceSettings `json:"workplace"`
+	// Projects the projects the token can access
+	Projects []string `json:"projects"`
+	// Environment and Config are set for service tokens, which can only access a single config
+	Environment string `json:"environment,omitempty"`
+	Config      string `json:"config,omitempty"`
+}
+
+// AuthCode a code used to authorize the CLI via the dashboard
+type AuthCode struct {
+	Code    string `json:"code"`
+	AuthURL string `json:"auth_url"`
+	// VerificationURL a short URL where the code can be entered manually
+	VerificationURL string `json:"verification_url"`
+	// PollingInterval the min number of seconds between authorization attempts
+	PollingInterval int `json:"polling_interval"`
+	// ExpiresIn the number of seconds until the code expires
+	ExpiresIn int `json:"expires_in"`
+}
+
+// AuthToken the result of an authorization attempt
+type AuthToken struct {
+	Token        string `json:"token"`
+	Name         string `json:"name"`
+	DashboardURL string `json:"dashboard_url"`
+	// Error is set when the authorization was denied
+	Error string `json:"error"`
 }
@@ -19,18 +19,41 @@ import "time"
 
 // ConfigFile structure of the config file
 type ConfigFile struct {
-	Scoped       map[string]FileScopedOptions `yaml:"scoped"`
-	VersionCheck VersionCheck                 `yaml:"version-check"`
+	Scoped        map[string]FileScopedOptions `yaml:"scoped"`
+	VersionCheck  VersionCheck                 `yaml:"version-check"`
+	UpdateChannel string                       `yaml:"update-channel,omitempty"`
+	PinnedVersion string                       `yaml:"pinned-version,omitempty"`
+	Profiles      map[string]FileScopedOptions `yaml:"profiles,omitempty"`
+	ActiveProfile string                       `yaml:"active-profile,omitempty"`
+	ShellHook     ShellHookOptions             `yaml:"shell-hook,omitempty"`
+}
+
+// ShellHookOptions options for the shell hook
+type ShellHookOptions struct {
+	// Allowed directories whose secrets may be loaded into the shell
+	Allowed []string `yaml:"allowed,omitempty"`
 }
 
 // FileScopedOptions config options
 type FileScopedOptions struct {
-	Token          string `json:"token,omitempty" yaml:"token,omitempty"`
-	APIHost        string `json:"api-host,omitempty" yaml:"api-host,omitempty"`
-	DashboardHost  string `json:"dashboard-host,omitempty" yaml:"dashboard-host,omitempty"`
-	VerifyTLS      string `json:"verify-tls,omitempty" yaml:"verify-tls,omitempty"`
-	EnclaveProject string `json:"enclave.project,omitempty" yaml:"enclave.project,omitempty"`
-	EnclaveConfig  string `json:"enclave.config,omitempty" yaml:"enclave.config,omitempty"`
+	Token            string `json:"token,omitempty" yaml:"token,omitempty"`
+	APIHost          string `json:"api-host,omitempty" yaml:"api-host,omitempty"`
+	DashboardHost    string `json:"dashboard-host,omitempty" yaml:"dashboard-host,omitempty"`
+	VerifyTLS        string `json:"verify-tls,omitempty" yaml:"verify-tls,omitempty"`
+	EnclaveProject   string `json:"enclave.project,omitempty" yaml:"enclave.project,omitempty"`
+	EnclaveConfig    string `json:"enclave.config,omitempty" yaml:"enclave.config,omitempty"`
+	CACert           string `json:"ca-cert,omitempty" yaml:"ca-cert,omitempty"`
+	ClientCert       string `json:"client-cert,omitempty" yaml:"client-cert,omitempty"`
+	ClientKey        string `json:"client-key,omitempty" yaml:"client-key,omitempty"`
+	HTTPProxy        string `json:"http-proxy,omitempty" yaml:"http-proxy,omitempty"`
+	HTTPSProxy       string `json:"https-proxy,omitempty" yaml:"https-proxy,omitempty"`
+	NoProxy          string `json:"no-proxy,omitempty" yaml:"no-proxy,omitempty"`
+	RetryAttempts    string `json:"retry-attempts,omitempty" yaml:"retry-attempts,omitempty"`
+	RetryDelay       string `json:"retry-delay,omitempty" yaml:"retry-delay,omitempty"`
+	RetryMaxDelay    string `json:"retry-max-delay,omitempty" yaml:"retry-max-delay,omitempty"`
+	RetryMaxTime     string `json:"retry-max-time,omitempty" yaml:"retry-max-time,omitempty"`
+	RetryStatusCodes string `json:"retry-status-codes,omitempty" yaml:"retry-status-codes,omitempty"`
+	TokenHelper      string `json:"token-helper,omitempty" yaml:"token-helper,omitempty"`
 }
 
 // VersionCheck info about the last check for the latest cli version
@@ -39,14 +62,36 @@ type VersionCheck struct {
 	CheckedAt     time.Time `yaml:"checked-at,omitempty"`
 }
 
+// ProfileOptions the options that may be set by a profile
+var ProfileOptions = []string{
+	ConfigToken.String(),
+	ConfigAPIHost.String(),
+	ConfigDashboardHost.String(),
+	ConfigVerifyTLS.String(),
+	ConfigEnclaveProject.String(),
+	ConfigEnclaveConfig.String(),
+}
+
 // ScopedOptions options with their scope
 type ScopedOptions struct {
-	Token          ScopedOption `json:"token,omitempty" yaml:"token,omitempty"`
-	APIHost        ScopedOption `json:"api-host,omitempty" yaml:"api-host,omitempty"`
-	DashboardHost  ScopedOption `json:"dashboard-host,omitempty" yaml:"dashboard-host,omitempty"`
-	VerifyTLS      ScopedOption `json:"verify-tls,omitempty" yaml:"verify-tls,omitempty"`
-	EnclaveProject ScopedOption `json:"enclave.project,omitempty" yaml:"enclave.project,omitempty"`
-	EnclaveConfig  ScopedOption `json:"enclave.config,omitempty" yaml:"enclave.config,omitempty"`
+	Token            ScopedOption `json:"token,omitempty" yaml:"token,omitempty"`
+	APIHost          ScopedOption `json:"api-host,omitempty" yaml:"api-host,omitempty"`
+	DashboardHost    ScopedOption `json:"dashboard-host,omitempty" yaml:"dashboard-host,omitempty"`
+	VerifyTLS        ScopedOption `json:"verify-tls,omitempty" yaml:"verify-tls,omitempty"`
+	EnclaveProject   ScopedOption `json:"enclave.project,omitempty" yaml:"enclave.project,omitempty"`
+	EnclaveConfig    ScopedOption `json:"enclave.config,omitempty" yaml:"enclave.config,omitempty"`
+	CACert           ScopedOption `json:"ca-cert,omitempty" yaml:"ca-cert,omitempty"`
+	ClientCert       ScopedOption `json:"client-cert,omitempty" yaml:"client-cert,omitempty"`
+	ClientKey        ScopedOption `json:"client-key,omitempty" yaml:"client-key,omitempty"`
+	HTTPProxy        ScopedOption `json:"http-proxy,omitempty" yaml:"http-proxy,omitempty"`
+	HTTPSProxy       ScopedOption `json:"https-proxy,omitempty" yaml:"https-proxy,omitempty"`
+	NoProxy          ScopedOption `json:"no-proxy,omitempty" yaml:"no-proxy,omitempty"`
+	RetryAttempts    ScopedOption `json:"retry-attempts,omitempty" yaml:"retry-attempts,omitempty"`
+	RetryDelay       ScopedOption `json:"retry-delay,omitempty" yaml:"retry-delay,omitempty"`
+	RetryMaxDelay    ScopedOption `json:"retry-max-delay,omitempty" yaml:"retry-max-delay,omitempty"`
+	RetryMaxTime     ScopedOption `json:"retry-max-time,omitempty" yaml:"retry-max-time,omitempty"`
+	RetryStatusCodes ScopedOption `json:"retry-status-codes,omitempty" yaml:"retry-status-codes,omitempty"`
+	TokenHelper      ScopedOption `json:"token-helper,omitempty" yaml:"token-helper,omitempty"`
 }
 
 // ScopedOption value and its scope
@@ -64,10 +109,12 @@ const (
 	ConfigFileSource
 	EnvironmentSource
 	DefaultValueSource
+	ProfileSource
+	TokenHelperSource
 )
 
 func (s source) String() string {
-	return [...]string{"Flag", "Config File", "Environment", "Default Value"}[s]
+	return [...]string{"Flag", "Config File", "Environment", "Default Value", "Profile", "Token Helper"}[s]
 }
 
 var allConfigOptions = []string{
@@ -77,6 +124,18 @@ var allConfigOptions = []string{
 	"verify-tls",
 	"enclave.project",
 	"enclave.config",
+	"ca-cert",
+	"client-cert",
+	"client-key",
+	"http-proxy",
+	"https-proxy",
+	"no-proxy",
+	"retry-attempts",
+	"retry-delay",
+	"retry-max-delay",
+	"retry-max-time",
+	"retry-status-codes",
+	"token-helper",
 }
 
 type configOption int
@@ -89,6 +148,18 @@ const (
 	ConfigVerifyTLS
 	ConfigEnclaveProject
 	ConfigEnclaveConfig
+	ConfigCACert
+	ConfigClientCert
+	ConfigClientKey
+	ConfigHTTPProxy
+	ConfigHTTPSProxy
+	ConfigNoProxy
+	ConfigRetryAttempts
+	ConfigRetryDelay
+	ConfigRetryMaxDelay
+	ConfigRetryMaxTime
+	ConfigRetryStatusCodes
+	ConfigTokenHelper
 )
 
 func (s configOption) String() string {
@@ -103,37 +174,73 @@ func AllConfigOptions() []string {
 // Pairs get the pairs for the given config
 func Pairs(conf FileScopedOptions) map[string]string {
 	return map[string]string{
-		ConfigToken.String():          conf.Token,
-		ConfigAPIHost.String():        conf.APIHost,
-		ConfigDashboardHost.String():  conf.DashboardHost,
-		ConfigVerifyTLS.String():      conf.VerifyTLS,
-		ConfigEnclaveProject.String(): conf.EnclaveProject,
-		ConfigEnclaveConfig.String():  conf.EnclaveConfig,
+		ConfigToken.String():            conf.Token,
+		ConfigAPIHost.String():          conf.APIHost,
+		ConfigDashboardHost.String():    conf.DashboardHost,
+		ConfigVerifyTLS.String():        conf.VerifyTLS,
+		ConfigEnclaveProject.String():   conf.EnclaveProject,
+		ConfigEnclaveConfig.String():    conf.EnclaveConfig,
+		ConfigCACert.String():           conf.CACert,
+		ConfigClientCert.String():       conf.ClientCert,
+		ConfigClientKey.String():        conf.ClientKey,
+		ConfigHTTPProxy.String():        conf.HTTPProxy,
+		ConfigHTTPSProxy.String():       conf.HTTPSProxy,
+		ConfigNoProxy.String():          conf.NoProxy,
+		ConfigRetryAttempts.String():    conf.RetryAttempts,
+		ConfigRetryDelay.String():       conf.RetryDelay,
+		ConfigRetryMaxDelay.String():    conf.RetryMaxDelay,
+		ConfigRetryMaxTime.String():     conf.RetryMaxTime,
+		ConfigRetryStatusCodes.String(): conf.RetryStatusCodes,
+		ConfigTokenHelper.String():      conf.TokenHelper,
 	}
 }
 
 // ScopedPairs get the pairs for the given scoped config
 func ScopedPairs(conf *ScopedOptions) map[string]*ScopedOption {
 	return map[string]*ScopedOption{
-		ConfigToken.String():          &conf.Token,
-		ConfigAPIHost.String():        &conf.APIHost,
-		ConfigDashboardHost.String():  &conf.DashboardHost,
-		ConfigVerifyTLS.String():      &conf.VerifyTLS,
-		ConfigEnclaveProject.String(): &conf.EnclaveProject,
-		ConfigEnclaveConfig.String():  &conf.EnclaveConfig,
+		ConfigToken.String():            &conf.Token,
+		ConfigAPIHost.String():          &conf.APIHost,
+		ConfigDashboardHost.String():    &conf.DashboardHost,
+		ConfigVerifyTLS.String():        &conf.VerifyTLS,
+		ConfigEnclaveProject.String():   &conf.EnclaveProject,
+		ConfigEnclaveConfig.String():    &conf.EnclaveConfig,
+		ConfigCACert.String():           &conf.CACert,
+		ConfigClientCert.String():       &conf.ClientCert,
+		ConfigClientKey.String():        &conf.ClientKey,
+		ConfigHTTPProxy.String():        &conf.HTTPProxy,
+		ConfigHTTPSProxy.String():       &conf.HTTPSProxy,
+		ConfigNoProxy.String():          &conf.NoProxy,
+		ConfigRetryAttempts.String():    &conf.RetryAttempts,
+		ConfigRetryDelay.String():       &conf.RetryDelay,
+		ConfigRetryMaxDelay.String():    &conf.RetryMaxDelay,
+		ConfigRetryMaxTime.String():     &conf.RetryMaxTime,
+		ConfigRetryStatusCodes.String(): &conf.RetryStatusCodes,
+		ConfigTokenHelper.String():      &conf.TokenHelper,
 	}
 }
 
 // EnvPairs get the scoped config pairs for each environment variable
 func EnvPairs(conf *ScopedOptions) map[string]*ScopedOption {
 	return map[string]*ScopedOption{
-		"DOPPLER_TOKEN":          &conf.Token,
-		"DOPPLER_API_HOST":       &conf.APIHost,
-		"DOPPLER_DASHBOARD_HOST": &conf.DashboardHost,
-		"DOPPLER_VERIFY_TLS":     &conf.VerifyTLS,
-		"DOPPLER_PROJECT":        &conf.EnclaveProject,
-		"DOPPLER_CONFIG":         &conf.EnclaveConfig,
-		"ENCLAVE_PROJECT":        &conf.EnclaveProject, // deprecated, remove in v4
-		"ENCLAVE_CONFIG":         &conf.EnclaveConfig,  // deprecated, remove in v4
+		"DOPPLER_TOKEN":              &conf.Token,
+		"DOPPLER_API_HOST":           &conf.APIHost,
+		"DOPPLER_DASHBOARD_HOST":     &conf.DashboardHost,
+		"DOPPLER_VERIFY_TLS":         &conf.VerifyTLS,
+		"DOPPLER_PROJECT":            &conf.EnclaveProject,
+		"DOPPLER_CONFIG":             &conf.EnclaveConfig,
+		"DOPPLER_CA_CERT":            &conf.CACert,
+		"DOPPLER_CLIENT_CERT":        &conf.ClientCert,
+		"DOPPLER_CLIENT_KEY":         &conf.ClientKey,
+		"DOPPLER_HTTP_PROXY":         &conf.HTTPProxy,
+		"DOPPLER_HTTPS_PROXY":        &conf.HTTPSProxy,
+		"DOPPLER_NO_PROXY":           &conf.NoProxy,
+		"DOPPLER_RETRY_ATTEMPTS":     &conf.RetryAttempts,
+		"DOPPLER_RETRY_DELAY":        &conf.RetryDelay,
+		"DOPPLER_RETRY_MAX_DELAY":    &conf.RetryMaxDelay,
+		"DOPPLER_RETRY_MAX_TIME":     &conf.RetryMaxTime,
+		"DOPPLER_RETRY_STATUS_CODES": &conf.RetryStatusCodes,
+		"DOPPLER_TOKEN_HELPER":       &conf.TokenHelper,
+		"ENCLAVE_PROJECT":            &conf.EnclaveProject, // deprecated, remove in v4
+		"ENCLAVE_CONFIG":             &conf.EnclaveConfig,  // deprecated, remove in v4
 	}
 }
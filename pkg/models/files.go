@@ -15,11 +15,15 @@ limitations under the License.
 */
 package models
 
+import "time"
+
 // SecretsFileMetadata contains metadata about a secrets file
 type SecretsFileMetadata struct {
 	Version string `json:"version,omitempty" yaml:"version,omitempty"`
 	ETag    string `json:"etag,omitempty" yaml:"etag,omitempty"`
 	Hash    string `json:"hash,omitempty" yaml:"hash,omitempty"`
+	// VerifiedAt when the API last confirmed the fallback file's secrets are current, either by returning them or via a 304
+	VerifiedAt time.Time `json:"verified_at,omitempty" yaml:"verified_at,omitempty"`
 }
 
 // ParseSecretsFileMetadata parse secrets file metadata
@@ -38,3 +42,61 @@ func ParseSecretsFileMetadata(data map[string]interface{}) SecretsFileMetadata {
 
 	return parsedMetadata
 }
+
+// SecretsFileInfo info about an encrypted secrets file and its metadata file
+type SecretsFileInfo struct {
+	Path         string    `json:"path"`
+	Size         int64     `json:"size"`
+	ModifiedAt   time.Time `json:"modified_at"`
+	Hash         string    `json:"hash"`
+	MetadataPath string    `json:"metadata_path,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	MetadataHash string    `json:"metadata_hash,omitempty"`
+	HashMatches  bool      `json:"hash_matches"`
+}
+
+// FallbackIndex maps fallback files to the configuration they were written for
+type FallbackIndex struct {
+	Version string                        `json:"version"`
+	Files   map[string]FallbackIndexEntry `json:"files"`
+}
+
+// FallbackIndexEntry info about a fallback file and its metadata file
+type FallbackIndexEntry struct {
+	Path         string    `json:"path"`
+	MetadataPath string    `json:"metadata_path,omitempty"`
+	Project      string    `json:"project,omitempty"`
+	Config       string    `json:"config,omitempty"`
+	TokenScope   string    `json:"token_scope,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// FallbackFile a file in the fallback directory, along with its index entry (if any)
+type FallbackFile struct {
+	FallbackIndexEntry
+	Name             string    `json:"name"`
+	ModifiedAt       time.Time `json:"modified_at"`
+	Indexed          bool      `json:"indexed"`
+	HasMetadata      bool      `json:"has_metadata"`
+	OrphanedMetadata bool      `json:"orphaned_metadata"`
+}
+
+// CompletionCacheEntry shell completion values cached from the API
+type CompletionCacheEntry struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Values    []string  `json:"values"`
+}
+
+// TokenHelperOutput the JSON a token helper may print instead of a bare token
+type TokenHelperOutput struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	ExpiresIn int    `json:"expires_in,omitempty"`
+}
+
+// TokenHelperCacheEntry a token printed by a token helper, cached until it expires
+type TokenHelperCacheEntry struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
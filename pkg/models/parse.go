@@ -17,100 +17,9 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
 )
 
-// ParseWorkplaceSettings parse workplace settings
-func ParseWorkplaceSettings(info map[string]interface{}) WorkplaceSettings {
-	var workplaceInfo WorkplaceSettings
-
-	if info["id"] != nil {
-		workplaceInfo.ID = info["id"].(string)
-	}
-	if info["name"] != nil {
-		workplaceInfo.Name = info["name"].(string)
-	}
-	if info["billing_email"] != nil {
-		workplaceInfo.BillingEmail = info["billing_email"].(string)
-	}
-
-	return workplaceInfo
-}
-
-// ParseProjectInfo parse project info
-func ParseProjectInfo(info map[string]interface{}) ProjectInfo {
-	var projectInfo ProjectInfo
-
-	if info["id"] != nil {
-		projectInfo.ID = info["id"].(string)
-	}
-	if info["name"] != nil {
-		projectInfo.Name = info["name"].(string)
-	}
-	if info["description"] != nil {
-		projectInfo.Description = info["description"].(string)
-	}
-	if info["created_at"] != nil {
-		projectInfo.CreatedAt = info["created_at"].(string)
-	}
-
-	return projectInfo
-}
-
-// ParseEnvironmentInfo parse environment info
-func ParseEnvironmentInfo(info map[string]interface{}) EnvironmentInfo {
-	var environmentInfo EnvironmentInfo
-
-	if info["id"] != nil {
-		environmentInfo.ID = info["id"].(string)
-	}
-	if info["name"] != nil {
-		environmentInfo.Name = info["name"].(string)
-	}
-	if info["created_at"] != nil {
-		environmentInfo.CreatedAt = info["created_at"].(string)
-	}
-	if info["initial_fetch_at"] != nil {
-		environmentInfo.InitialFetchAt = info["initial_fetch_at"].(string)
-	}
-	if info["project"] != nil {
-		environmentInfo.Project = info["project"].(string)
-	}
-
-	return environmentInfo
-}
-
-// ParseConfigInfo parse config info
-func ParseConfigInfo(info map[string]interface{}) ConfigInfo {
-	var configInfo ConfigInfo
-
-	if info["name"] != nil {
-		configInfo.Name = info["name"].(string)
-	}
-	if info["root"] != nil {
-		configInfo.Root = info["root"].(bool)
-	}
-	if info["locked"] != nil {
-		configInfo.Locked = info["locked"].(bool)
-	}
-	if info["environment"] != nil {
-		configInfo.Environment = info["environment"].(string)
-	}
-	if info["project"] != nil {
-		configInfo.Project = info["project"].(string)
-	}
-	if info["created_at"] != nil {
-		configInfo.CreatedAt = info["created_at"].(string)
-	}
-	if info["initial_fetch_at"] != nil {
-		configInfo.InitialFetchAt = info["initial_fetch_at"].(string)
-	}
-	if info["last_fetch_at"] != nil {
-		configInfo.LastFetchAt = info["last_fetch_at"].(string)
-	}
-
-	return configInfo
-}
-
 // ParseConfigLog parse config log
 func ParseConfigLog(log map[string]interface{}) ConfigLog {
 	var parsedLog ConfigLog
@@ -209,54 +118,22 @@ func ParseActivityLog(log map[string]interface{}) ActivityLog {
 
 // ParseSecrets parse secrets
 func ParseSecrets(response []byte) (map[string]ComputedSecret, error) {
-	var result map[string]interface{}
+	var result struct {
+		Secrets map[string]ComputedSecret `json:"secrets"`
+	}
 	err := json.Unmarshal(response, &result)
 	if err != nil {
 		return nil, err
 	}
+	if result.Secrets == nil {
+		return nil, errors.New("API response is missing secrets")
+	}
 
 	computed := map[string]ComputedSecret{}
-	secrets := result["secrets"].(map[string]interface{})
-	for key, secret := range secrets {
-		computedSecret := ComputedSecret{Name: key}
-		val := secret.(map[string]interface{})
-		if val["raw"] != nil {
-			computedSecret.RawValue = val["raw"].(string)
-		}
-		if val["computed"] != nil {
-			computedSecret.ComputedValue = val["computed"].(string)
-		}
-		computed[key] = computedSecret
+	for key, secret := range result.Secrets {
+		secret.Name = key
+		computed[key] = secret
 	}
 
 	return computed, nil
 }
-
-// ParseConfigServiceToken parse config service token
-func ParseConfigServiceToken(token map[string]interface{}) ConfigServiceToken {
-	var parsedToken ConfigServiceToken
-
-	if token["name"] != nil {
-		parsedToken.Name = token["name"].(string)
-	}
-	if token["key"] != nil {
-		parsedToken.Token = token["key"].(string)
-	}
-	if token["slug"] != nil {
-		parsedToken.Slug = token["slug"].(string)
-	}
-	if token["project"] != nil {
-		parsedToken.Project = token["project"].(string)
-	}
-	if token["environment"] != nil {
-		parsedToken.Environment = token["environment"].(string)
-	}
-	if token["config"] != nil {
-		parsedToken.Config = token["config"].(string)
-	}
-	if token["created_at"] != nil {
-		parsedToken.CreatedAt = token["created_at"].(string)
-	}
-
-	return parsedToken
-}
@@ -22,4 +22,8 @@ type RepoConfig struct {
 		Config  string `yaml:"config"`
 		Project string `yaml:"project"`
 	} `yaml:"setup"`
+	Policy struct {
+		// MinimumCLIVersion the oldest CLI version that may be used with this repo (e.g. v3.20.0)
+		MinimumCLIVersion string `yaml:"minimum-cli-version"`
+	} `yaml:"policy"`
 }
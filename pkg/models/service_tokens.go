@@ -0,0 +1,82 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package models
+
+import "time"
+
+// ServiceTokenOldAge service tokens created longer ago than this are highlighted so they can be rotated
+var ServiceTokenOldAge = 90 * 24 * time.Hour
+
+// ServiceTokenUnusedAge service tokens that haven't been used for this long are highlighted so they can be revoked
+var ServiceTokenUnusedAge = 30 * 24 * time.Hour
+
+// service token statuses
+const (
+	ServiceTokenExpired = "expired"
+	ServiceTokenOld     = "old"
+	ServiceTokenUnused  = "unused"
+)
+
+// IsServiceTokenExpired whether the service token has an expiration that has passed
+func IsServiceTokenExpired(token ConfigServiceToken, now time.Time) bool {
+	if token.ExpiresAt == "" {
+		return false
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, token.ExpiresAt)
+	return err == nil && !now.Before(expiresAt)
+}
+
+// ServiceTokenStatuses the reasons the service token should be rotated or revoked, if any
+func ServiceTokenStatuses(token ConfigServiceToken, now time.Time) []string {
+	if IsServiceTokenExpired(token, now) {
+		return []string{ServiceTokenExpired}
+	}
+
+	var statuses []string
+	createdAt, err := time.Parse(time.RFC3339, token.CreatedAt)
+	if err == nil && now.Sub(createdAt) > ServiceTokenOldAge {
+		statuses = append(statuses, ServiceTokenOld)
+	}
+
+	// tokens that have never been used are compared against their creation date
+	lastSeenAt := createdAt
+	if token.LastSeenAt != "" {
+		lastSeenAt, err = time.Parse(time.RFC3339, token.LastSeenAt)
+	}
+	if err == nil && now.Sub(lastSeenAt) > ServiceTokenUnusedAge {
+		statuses = append(statuses, ServiceTokenUnused)
+	}
+
+	return statuses
+}
+
+// ServiceTokenLifetime how long the token was valid for when created, or 0 if it never expires
+func ServiceTokenLifetime(token ConfigServiceToken) time.Duration {
+	if token.ExpiresAt == "" {
+		return 0
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, token.CreatedAt)
+	if err != nil {
+		return 0
+	}
+	expiresAt, err := time.Parse(time.RFC3339, token.ExpiresAt)
+	if err != nil || !expiresAt.After(createdAt) {
+		return 0
+	}
+	return expiresAt.Sub(createdAt)
+}
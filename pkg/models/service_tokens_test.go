@@ -0,0 +1,57 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServiceTokenStatuses(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := now.Add(-24 * time.Hour).Format(time.RFC3339)
+	old := now.Add(-120 * 24 * time.Hour).Format(time.RFC3339)
+	tests := []struct {
+		name     string
+		token    ConfigServiceToken
+		statuses []string
+	}{
+		{name: "recently used", token: ConfigServiceToken{CreatedAt: recent, LastSeenAt: recent}},
+		{name: "never used", token: ConfigServiceToken{CreatedAt: old}, statuses: []string{ServiceTokenOld, ServiceTokenUnused}},
+		{name: "old but used", token: ConfigServiceToken{CreatedAt: old, LastSeenAt: recent}, statuses: []string{ServiceTokenOld}},
+		{name: "not yet expired", token: ConfigServiceToken{CreatedAt: recent, LastSeenAt: recent, ExpiresAt: now.Add(time.Hour).Format(time.RFC3339)}},
+		{name: "expired", token: ConfigServiceToken{CreatedAt: old, ExpiresAt: recent}, statuses: []string{ServiceTokenExpired}},
+	}
+
+	for _, test := range tests {
+		if statuses := ServiceTokenStatuses(test.token, now); !reflect.DeepEqual(statuses, test.statuses) {
+			t.Errorf("%s: expected statuses %v, got %v", test.name, test.statuses, statuses)
+		}
+	}
+}
+
+func TestServiceTokenLifetime(t *testing.T) {
+	token := ConfigServiceToken{CreatedAt: "2021-01-01T00:00:00Z", ExpiresAt: "2021-01-02T00:00:00Z"}
+	if lifetime := ServiceTokenLifetime(token); lifetime != 24*time.Hour {
+		t.Errorf("expected lifetime of 24h, got %s", lifetime)
+	}
+
+	token.ExpiresAt = ""
+	if lifetime := ServiceTokenLifetime(token); lifetime != 0 {
+		t.Errorf("expected no lifetime for a token that never expires, got %s", lifetime)
+	}
+}
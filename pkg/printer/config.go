@@ -34,23 +34,17 @@ func ScopedConfig(conf models.ScopedOptions, jsonFlag bool) {
 func ScopedConfigSource(conf models.ScopedOptions, jsonFlag bool, source bool) {
 	pairs := models.ScopedPairs(&conf)
 
-	if jsonFlag {
-		confMap := map[string]map[string]string{}
-
-		for name, pair := range pairs {
-			if *pair != (models.ScopedOption{}) {
-				scope := pair.Scope
-				value := pair.Value
-
-				if confMap[scope] == nil {
-					confMap[scope] = map[string]string{}
-				}
-				confMap[scope][name] = value
+	confMap := map[string]map[string]string{}
+	for name, pair := range pairs {
+		if *pair != (models.ScopedOption{}) {
+			scope := pair.Scope
+			value := pair.Value
+
+			if confMap[scope] == nil {
+				confMap[scope] = map[string]string{}
 			}
+			confMap[scope][name] = value
 		}
-
-		JSON(confMap)
-		return
 	}
 
 	var rows [][]string
@@ -76,7 +70,7 @@ func ScopedConfigSource(conf models.ScopedOptions, jsonFlag bool, source bool) {
 		headers = append(headers, "source")
 	}
 
-	Table(headers, rows, TableOptions())
+	Print(jsonFlag, confMap, headers, rows)
 }
 
 // ScopedConfigValues print scoped config value(s)
@@ -102,35 +96,20 @@ func ScopedConfigValues(conf models.ScopedOptions, args []string, values map[str
 		}
 	}
 
-	if jsonFlag {
-		filteredMap := map[string]string{}
-		for _, arg := range args {
-			if option, exists := values[arg]; exists {
-				filteredMap[arg] = option.Value
-			}
-		}
-
-		JSON(filteredMap)
-		return
-	}
-
+	filteredMap := map[string]string{}
 	var rows [][]string
 	for _, arg := range args {
 		if option, exists := values[arg]; exists {
+			filteredMap[arg] = option.Value
 			translatedArg := configuration.TranslateConfigOption(arg)
 			rows = append(rows, []string{translatedArg, option.Value, option.Scope})
 		}
 	}
-	Table([]string{"name", "value", "scope"}, rows, TableOptions())
+	Print(jsonFlag, filteredMap, []string{"name", "value", "scope"}, rows)
 }
 
 // Configs print configs
 func Configs(configs map[string]models.FileScopedOptions, jsonFlag bool) {
-	if jsonFlag {
-		JSON(configs)
-		return
-	}
-
 	var rows [][]string
 	for scope, conf := range configs {
 		pairs := models.Pairs(conf)
@@ -151,16 +130,11 @@ func Configs(configs map[string]models.FileScopedOptions, jsonFlag bool) {
 		return rows[a][0] < rows[b][0]
 	})
 
-	Table([]string{"name", "value", "scope"}, rows, TableOptions())
+	Print(jsonFlag, configs, []string{"name", "value", "scope"}, rows)
 }
 
 // ConfigOptionNames prints all supported config options
 func ConfigOptionNames(options []string, jsonFlag bool) {
-	if jsonFlag {
-		JSON(options)
-		return
-	}
-
 	translatedOptions := []string{}
 	for _, option := range options {
 		translatedOptions = append(translatedOptions, configuration.TranslateConfigOption(option))
@@ -172,5 +146,37 @@ func ConfigOptionNames(options []string, jsonFlag bool) {
 	for _, option := range translatedOptions {
 		rows = append(rows, []string{option})
 	}
-	Table([]string{"name"}, rows, TableOptions())
+	Print(jsonFlag, options, []string{"name"}, rows)
+}
+
+// Profiles print profiles. Tokens are never printed.
+func Profiles(profiles map[string]models.FileScopedOptions, active string, jsonFlag bool) {
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data := []map[string]interface{}{}
+	var rows [][]string
+	for _, name := range names {
+		profile := profiles[name]
+		isActive := name == active
+
+		profileData := map[string]interface{}{"name": name, "active": isActive}
+		for option, value := range models.Pairs(profile) {
+			if value != "" && option != models.ConfigToken.String() {
+				profileData[configuration.TranslateConfigOption(option)] = value
+			}
+		}
+		data = append(data, profileData)
+
+		activeMarker := ""
+		if isActive {
+			activeMarker = "*"
+		}
+		rows = append(rows, []string{name, activeMarker, profile.APIHost, profile.DashboardHost, profile.VerifyTLS, profile.EnclaveProject, profile.EnclaveConfig})
+	}
+
+	Print(jsonFlag, data, []string{"name", "active", "api host", "dashboard host", "verify tls", "project", "config"}, rows)
 }
@@ -0,0 +1,262 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package printer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// output formats
+const (
+	FormatTable    = "table"
+	FormatJSON     = "json"
+	FormatYAML     = "yaml"
+	FormatCSV      = "csv"
+	FormatTSV      = "tsv"
+	FormatTemplate = "template"
+)
+
+// OutputFormats all supported output formats
+var OutputFormats = []string{FormatTable, FormatJSON, FormatYAML, FormatCSV, FormatTSV, FormatTemplate + "=..."}
+
+// OutputOptions how command output is printed
+type OutputOptions struct {
+	Format string
+	// Template the template used by the template format
+	Template *template.Template
+	// Fields the fields to print, in order. All fields are printed when empty.
+	Fields []string
+}
+
+// Output the options used when printing command output, which are set via --output and --fields
+var Output = OutputOptions{Format: FormatTable}
+
+// ParseOutputOptions parses the output format (e.g. yaml or template={{.name}}) and the comma-separated fields to print
+func ParseOutputOptions(output string, fields string) (OutputOptions, error) {
+	options := OutputOptions{Format: FormatTable}
+
+	if output != "" {
+		parts := strings.SplitN(output, "=", 2)
+		options.Format = strings.ToLower(strings.TrimSpace(parts[0]))
+		switch options.Format {
+		case FormatTable, FormatJSON, FormatYAML, FormatCSV, FormatTSV:
+			if len(parts) > 1 {
+				return options, fmt.Errorf("The %s output format doesn't accept a value", options.Format)
+			}
+		case FormatTemplate:
+			if len(parts) < 2 || parts[1] == "" {
+				return options, errors.New("The template output format requires a template (e.g. template='{{.name}}')")
+			}
+			tmpl, err := template.New("output").Parse(parts[1])
+			if err != nil {
+				return options, fmt.Errorf("Invalid template: %s", err)
+			}
+			options.Template = tmpl
+		default:
+			return options, fmt.Errorf("Invalid output format %s. Valid formats are %s", options.Format, strings.Join(OutputFormats, ", "))
+		}
+	}
+
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			options.Fields = append(options.Fields, fieldName(field))
+		}
+	}
+
+	return options, nil
+}
+
+// fieldName the name used to select a column via --fields (e.g. "created at" -> "created_at")
+func fieldName(header string) string {
+	return strings.ReplaceAll(strings.ToLower(header), " ", "_")
+}
+
+// Print prints data in the output format. headers and rows are the data's tabular form,
+// which is used by the table, csv, and tsv formats, and when selecting --fields.
+func Print(jsonFlag bool, data interface{}, headers []string, rows [][]string) {
+	PrintWithTable(jsonFlag, data, headers, rows, nil)
+}
+
+// PrintWithTable is like Print, but uses printTable for the table format when all fields are printed
+func PrintWithTable(jsonFlag bool, data interface{}, headers []string, rows [][]string, printTable func()) {
+	format := Output.Format
+	if jsonFlag {
+		format = FormatJSON
+	}
+
+	if len(Output.Fields) > 0 {
+		var err error
+		if headers, rows, err = selectFields(headers, rows, Output.Fields); err != nil {
+			utils.HandleError(utils.NewError(utils.ErrorKindUsage, err))
+		}
+		data = fieldRecords(data, headers, rows)
+		printTable = nil
+	}
+
+	switch format {
+	case FormatJSON:
+		JSON(data)
+	case FormatYAML:
+		YAML(data)
+	case FormatCSV:
+		if err := writeCSV(os.Stdout, headers, rows); err != nil {
+			utils.HandleError(err)
+		}
+	case FormatTSV:
+		if err := writeTSV(os.Stdout, headers, rows); err != nil {
+			utils.HandleError(err)
+		}
+	case FormatTemplate:
+		executeTemplate(Output.Template, data)
+	default:
+		if printTable != nil {
+			printTable()
+		} else {
+			Table(headers, rows, TableOptions())
+		}
+	}
+}
+
+// selectFields the columns matching fields, in the order of fields
+func selectFields(headers []string, rows [][]string, fields []string) ([]string, [][]string, error) {
+	var names []string
+	indexes := map[string]int{}
+	for i, header := range headers {
+		names = append(names, fieldName(header))
+		indexes[fieldName(header)] = i
+	}
+
+	var selectedHeaders []string
+	var selected []int
+	for _, field := range fields {
+		i, ok := indexes[field]
+		if !ok {
+			return nil, nil, fmt.Errorf("Invalid field %s. Valid fields are %s", field, strings.Join(names, ", "))
+		}
+		selectedHeaders = append(selectedHeaders, headers[i])
+		selected = append(selected, i)
+	}
+
+	selectedRows := [][]string{}
+	for _, row := range rows {
+		var selectedRow []string
+		for _, i := range selected {
+			value := ""
+			if i < len(row) {
+				value = row[i]
+			}
+			selectedRow = append(selectedRow, value)
+		}
+		selectedRows = append(selectedRows, selectedRow)
+	}
+	return selectedHeaders, selectedRows, nil
+}
+
+// fieldRecords the rows as records keyed by field name. data that isn't a list produces a single record.
+func fieldRecords(data interface{}, headers []string, rows [][]string) interface{} {
+	records := []map[string]string{}
+	for _, row := range rows {
+		record := map[string]string{}
+		for i, header := range headers {
+			record[fieldName(header)] = row[i]
+		}
+		records = append(records, record)
+	}
+
+	kind := reflect.ValueOf(data).Kind()
+	if kind != reflect.Slice && kind != reflect.Array && len(records) == 1 {
+		return records[0]
+	}
+	return records
+}
+
+// generic converts data to its JSON representation (e.g. map[string]interface{}), so all formats use the same field names
+func generic(data interface{}) interface{} {
+	body, err := json.Marshal(data)
+	if err != nil {
+		utils.HandleError(err)
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		utils.HandleError(err)
+	}
+	return result
+}
+
+// YAML print object as yaml
+func YAML(structure interface{}) {
+	resp, err := yaml.Marshal(generic(structure))
+	if err != nil {
+		utils.HandleError(err)
+	}
+
+	fmt.Print(string(resp))
+}
+
+// fieldNames the headers' field names, which are used as the header row of csv and tsv output
+func fieldNames(headers []string) []string {
+	var names []string
+	for _, header := range headers {
+		names = append(names, fieldName(header))
+	}
+	return names
+}
+
+// writeCSV writes the rows as RFC 4180 CSV, quoting values as needed
+func writeCSV(out io.Writer, headers []string, rows [][]string) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(fieldNames(headers)); err != nil {
+		return err
+	}
+	return w.WriteAll(rows)
+}
+
+// tsvEscaper escapes characters that can't appear in a TSV value. values aren't quoted, unlike CSV.
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+// writeTSV writes the rows as tab-separated values. tabs, newlines, and backslashes in values are escaped (e.g. as \t).
+func writeTSV(out io.Writer, headers []string, rows [][]string) error {
+	lines := [][]string{fieldNames(headers)}
+	lines = append(lines, rows...)
+	for _, line := range lines {
+		var values []string
+		for _, value := range line {
+			values = append(values, tsvEscaper.Replace(value))
+		}
+		if _, err := fmt.Fprintln(out, strings.Join(values, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func executeTemplate(tmpl *template.Template, data interface{}) {
+	if err := tmpl.Execute(os.Stdout, generic(data)); err != nil {
+		utils.HandleError(utils.NewError(utils.ErrorKindUsage, err), "Unable to execute output template")
+	}
+}
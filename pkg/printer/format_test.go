@@ -0,0 +1,139 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package printer
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOutputOptions(t *testing.T) {
+	tests := []struct {
+		output string
+		fields string
+		format string
+		parsed []string
+	}{
+		{"", "", FormatTable, nil},
+		{"json", "", FormatJSON, nil},
+		{" YAML ", "", FormatYAML, nil},
+		{"csv", "name, Created At,,slug", FormatCSV, []string{"name", "created_at", "slug"}},
+		{"tsv", "name", FormatTSV, []string{"name"}},
+	}
+	for _, test := range tests {
+		options, err := ParseOutputOptions(test.output, test.fields)
+		if err != nil {
+			t.Errorf("ParseOutputOptions(%q, %q) failed: %s", test.output, test.fields, err)
+			continue
+		}
+		if options.Format != test.format || !reflect.DeepEqual(options.Fields, test.parsed) || options.Template != nil {
+			t.Errorf("ParseOutputOptions(%q, %q) = %+v", test.output, test.fields, options)
+		}
+	}
+
+	options, err := ParseOutputOptions("template={{.name}}={{.slug}}", "")
+	if err != nil || options.Format != FormatTemplate || options.Template == nil {
+		t.Fatalf("Expected a template, got %+v %v", options, err)
+	}
+	var out bytes.Buffer
+	if err := options.Template.Execute(&out, map[string]string{"name": "a", "slug": "b"}); err != nil || out.String() != "a=b" {
+		t.Errorf("Expected the template to include '=', got %q %v", out.String(), err)
+	}
+
+	for _, output := range []string{"xml", "json=1", "template", "template=", "template={{.name"} {
+		if _, err := ParseOutputOptions(output, ""); err == nil {
+			t.Errorf("Expected ParseOutputOptions(%q) to fail", output)
+		}
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	headers := []string{"Name", "Created At", "Slug"}
+	rows := [][]string{{"ci", "2020-01-01", "ci_slug"}, {"short"}}
+
+	selectedHeaders, selectedRows, err := selectFields(headers, rows, []string{"slug", "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selectedHeaders, []string{"Slug", "Name"}) {
+		t.Errorf("Unexpected headers %q", selectedHeaders)
+	}
+	// missing values are empty
+	if !reflect.DeepEqual(selectedRows, [][]string{{"ci_slug", "ci"}, {"", "short"}}) {
+		t.Errorf("Unexpected rows %q", selectedRows)
+	}
+
+	_, _, err = selectFields(headers, rows, []string{"name", "token"})
+	if err == nil {
+		t.Fatal("Expected an error for an unknown field")
+	}
+	if !strings.Contains(err.Error(), "token") || !strings.Contains(err.Error(), "name, created_at, slug") {
+		t.Errorf("Expected the error to list the valid fields, got %q", err)
+	}
+
+	// no rows still produces an empty list, rather than null
+	if _, selectedRows, err = selectFields(headers, nil, []string{"name"}); err != nil || selectedRows == nil || len(selectedRows) != 0 {
+		t.Errorf("Expected no rows, got %q %v", selectedRows, err)
+	}
+}
+
+func TestFieldRecords(t *testing.T) {
+	headers := []string{"Name", "Created At"}
+	rows := [][]string{{"ci", "2020-01-01"}}
+	record := map[string]string{"name": "ci", "created_at": "2020-01-01"}
+
+	// a single item produces a single record
+	if actual := fieldRecords(struct{}{}, headers, rows); !reflect.DeepEqual(actual, record) {
+		t.Errorf("Expected a single record, got %v", actual)
+	}
+
+	// a list produces a list, even with a single item
+	if actual := fieldRecords([]struct{}{{}}, headers, rows); !reflect.DeepEqual(actual, []map[string]string{record}) {
+		t.Errorf("Expected a list of records, got %v", actual)
+	}
+	if actual := fieldRecords([]struct{}{}, headers, nil); !reflect.DeepEqual(actual, []map[string]string{}) {
+		t.Errorf("Expected an empty list, got %v", actual)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var out bytes.Buffer
+	rows := [][]string{{"ci", "a,b"}, {"deploy", "line 1\nsaid \"hi\""}}
+	if err := writeCSV(&out, []string{"Name", "Created At"}, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "name,created_at\nci,\"a,b\"\ndeploy,\"line 1\nsaid \"\"hi\"\"\"\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestWriteTSV(t *testing.T) {
+	var out bytes.Buffer
+	rows := [][]string{{"ci", "a,b \"quoted\""}, {"deploy", "tab\there\nline\r\\n"}}
+	if err := writeTSV(&out, []string{"Name", "Created At"}, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	// values are never quoted, and each row is a single line
+	expected := "name\tcreated_at\nci\ta,b \"quoted\"\ndeploy\ttab\\there\\nline\\r\\\\n\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
@@ -80,23 +80,31 @@ func ConfigLogs(logs []models.ConfigLog, number int, jsonFlag bool) {
 	maxLogs := int(math.Min(float64(len(logs)), float64(number)))
 	logs = logs[0:maxLogs]
 
-	if jsonFlag {
-		JSON(logs)
-		return
-	}
-
+	var rows [][]string
 	for _, log := range logs {
-		ConfigLog(log, false, false)
+		rows = append(rows, configLogRow(log))
 	}
+	PrintWithTable(jsonFlag, logs, configLogHeaders, rows, func() {
+		for _, log := range logs {
+			printConfigLog(log, false)
+		}
+	})
+}
+
+var configLogHeaders = []string{"id", "text", "user", "email", "created at", "project", "environment", "config"}
+
+func configLogRow(log models.ConfigLog) []string {
+	return []string{log.ID, log.Text, log.User.Name, log.User.Email, log.CreatedAt, log.Project, log.Environment, log.Config}
 }
 
 // ConfigLog print config log
 func ConfigLog(log models.ConfigLog, jsonFlag bool, diff bool) {
-	if jsonFlag {
-		JSON(log)
-		return
-	}
+	PrintWithTable(jsonFlag, log, configLogHeaders, [][]string{configLogRow(log)}, func() {
+		printConfigLog(log, diff)
+	})
+}
 
+func printConfigLog(log models.ConfigLog, diff bool) {
 	dateTime, err := time.Parse(time.RFC3339, log.CreatedAt)
 
 	fmt.Println("Log " + log.ID)
@@ -132,23 +140,31 @@ func ActivityLogs(logs []models.ActivityLog, number int, jsonFlag bool) {
 	maxLogs := int(math.Min(float64(len(logs)), float64(number)))
 	logs = logs[0:maxLogs]
 
-	if jsonFlag {
-		JSON(logs)
-		return
-	}
-
+	var rows [][]string
 	for _, log := range logs {
-		ActivityLog(log, false, false)
+		rows = append(rows, activityLogRow(log))
 	}
+	PrintWithTable(jsonFlag, logs, activityLogHeaders, rows, func() {
+		for _, log := range logs {
+			printActivityLog(log)
+		}
+	})
+}
+
+var activityLogHeaders = []string{"id", "text", "user", "email", "created at", "project", "environment", "config"}
+
+func activityLogRow(log models.ActivityLog) []string {
+	return []string{log.ID, log.Text, log.User.Name, log.User.Email, log.CreatedAt, log.EnclaveProject, log.EnclaveEnvironment, log.EnclaveConfig}
 }
 
 // ActivityLog print activity log
 func ActivityLog(log models.ActivityLog, jsonFlag bool, diff bool) {
-	if jsonFlag {
-		JSON(log)
-		return
-	}
+	PrintWithTable(jsonFlag, log, activityLogHeaders, [][]string{activityLogRow(log)}, func() {
+		printActivityLog(log)
+	})
+}
 
+func printActivityLog(log models.ActivityLog) {
 	dateTime, err := time.Parse(time.RFC3339, log.CreatedAt)
 
 	fmt.Println("Log " + log.ID)
@@ -173,79 +189,49 @@ func JSON(structure interface{}) {
 
 // ConfigInfo print config
 func ConfigInfo(info models.ConfigInfo, jsonFlag bool) {
-	if jsonFlag {
-		JSON(info)
-		return
-	}
-
 	rows := [][]string{{info.Name, info.InitialFetchAt, info.LastFetchAt, info.CreatedAt, info.Environment, info.Project}}
-	Table([]string{"name", "initial fetch", "last fetch", "created at", "environment", "project"}, rows, TableOptions())
+	Print(jsonFlag, info, []string{"name", "initial fetch", "last fetch", "created at", "environment", "project"}, rows)
 }
 
 // ConfigsInfo print configs
 func ConfigsInfo(info []models.ConfigInfo, jsonFlag bool) {
-	if jsonFlag {
-		JSON(info)
-		return
-	}
-
 	var rows [][]string
 	for _, configInfo := range info {
 		rows = append(rows, []string{configInfo.Name, configInfo.InitialFetchAt, configInfo.LastFetchAt, configInfo.CreatedAt,
 			configInfo.Environment, configInfo.Project})
 	}
-	Table([]string{"name", "initial fetch", "last fetch", "created at", "environment", "project"}, rows, TableOptions())
+	Print(jsonFlag, info, []string{"name", "initial fetch", "last fetch", "created at", "environment", "project"}, rows)
 }
 
 // EnvironmentsInfo print environments
 func EnvironmentsInfo(info []models.EnvironmentInfo, jsonFlag bool) {
-	if jsonFlag {
-		JSON(info)
-		return
-	}
-
 	var rows [][]string
 	for _, environmentInfo := range info {
 		rows = append(rows, []string{environmentInfo.ID, environmentInfo.Name, environmentInfo.InitialFetchAt,
 			environmentInfo.CreatedAt, environmentInfo.Project})
 	}
-	Table([]string{"id", "name", "initial fetch", "created at", "project"}, rows, TableOptions())
+	Print(jsonFlag, info, []string{"id", "name", "initial fetch", "created at", "project"}, rows)
 }
 
 // EnvironmentInfo print environment
 func EnvironmentInfo(info models.EnvironmentInfo, jsonFlag bool) {
-	if jsonFlag {
-		JSON(info)
-		return
-	}
-
 	rows := [][]string{{info.ID, info.Name, info.InitialFetchAt, info.CreatedAt, info.Project}}
-	Table([]string{"id", "name", "initial fetch", "created at", "project"}, rows, TableOptions())
+	Print(jsonFlag, info, []string{"id", "name", "initial fetch", "created at", "project"}, rows)
 }
 
 // ProjectsInfo print info of multiple projects
 func ProjectsInfo(info []models.ProjectInfo, jsonFlag bool) {
-	if jsonFlag {
-		JSON(info)
-		return
-	}
-
 	var rows [][]string
 	for _, projectInfo := range info {
 		rows = append(rows, []string{projectInfo.ID, projectInfo.Name, projectInfo.Description, projectInfo.CreatedAt})
 	}
-	Table([]string{"id", "name", "description", "created at"}, rows, TableOptions())
+	Print(jsonFlag, info, []string{"id", "name", "description", "created at"}, rows)
 }
 
 // ProjectInfo print project info
 func ProjectInfo(info models.ProjectInfo, jsonFlag bool) {
-	if jsonFlag {
-		JSON(info)
-		return
-	}
-
 	rows := [][]string{{info.ID, info.Name, info.Description, info.CreatedAt}}
-	Table([]string{"id", "name", "description", "created at"}, rows, TableOptions())
+	Print(jsonFlag, info, []string{"id", "name", "description", "created at"}, rows)
 }
 
 // Secrets print secrets
@@ -270,19 +256,14 @@ func Secrets(secrets map[string]models.ComputedSecret, secretsToPrint []string,
 		}
 	}
 
-	if jsonFlag {
-		secretsMap := map[string]map[string]string{}
-		for _, name := range secretsToPrint {
-			if secrets[name] != (models.ComputedSecret{}) {
-				secretsMap[name] = map[string]string{"computed": secrets[name].ComputedValue}
-				if raw {
-					secretsMap[name]["raw"] = secrets[name].RawValue
-				}
+	secretsMap := map[string]map[string]string{}
+	for _, name := range secretsToPrint {
+		if secrets[name] != (models.ComputedSecret{}) {
+			secretsMap[name] = map[string]string{"computed": secrets[name].ComputedValue}
+			if raw {
+				secretsMap[name]["raw"] = secrets[name].RawValue
 			}
 		}
-
-		JSON(secretsMap)
-		return
 	}
 
 	var matchedSecrets []models.ComputedSecret
@@ -292,7 +273,8 @@ func Secrets(secrets map[string]models.ComputedSecret, secretsToPrint []string,
 		}
 	}
 
-	if plain {
+	// --json takes precedence over --plain
+	if plain && !jsonFlag {
 		vals := []string{}
 		for _, secret := range matchedSecrets {
 			if raw {
@@ -321,7 +303,7 @@ func Secrets(secrets map[string]models.ComputedSecret, secretsToPrint []string,
 		rows = append(rows, row)
 	}
 
-	Table(headers, rows, TableOptions())
+	Print(jsonFlag, secretsMap, headers, rows)
 }
 
 // SecretsNames print secrets names
@@ -332,32 +314,66 @@ func SecretsNames(secrets map[string]models.ComputedSecret, jsonFlag bool) {
 	}
 	sort.Strings(secretsNames)
 
-	if jsonFlag {
-		secretsMap := map[string]map[string]string{}
-		for _, name := range secretsNames {
-			secretsMap[name] = map[string]string{}
-		}
+	secretsMap := map[string]map[string]string{}
+	var rows [][]string
+	for _, name := range secretsNames {
+		secretsMap[name] = map[string]string{}
+		rows = append(rows, []string{name})
+	}
+	Print(jsonFlag, secretsMap, []string{"name"}, rows)
+}
 
-		JSON(secretsMap)
-		return
+// SecretsFileInfo print info about a secrets file
+func SecretsFileInfo(info models.SecretsFileInfo, jsonFlag bool) {
+	age := time.Now().Sub(info.ModifiedAt).Round(time.Second)
+	rows := [][]string{
+		{"path", info.Path},
+		{"size", fmt.Sprintf("%d bytes", info.Size)},
+		{"modified at", info.ModifiedAt.In(time.Local).String()},
+		{"age", age.String()},
+		{"hash", info.Hash},
+	}
+
+	if info.MetadataPath == "" {
+		rows = append(rows, []string{"metadata file", "none"})
+	} else {
+		rows = append(rows, []string{"metadata file", info.MetadataPath})
+		rows = append(rows, []string{"etag", info.ETag})
+		rows = append(rows, []string{"metadata hash", info.MetadataHash})
+		rows = append(rows, []string{"hash matches", fmt.Sprintf("%t", info.HashMatches)})
 	}
 
+	Print(jsonFlag, info, []string{"name", "value"}, rows)
+}
+
+// FallbackFiles print fallback files
+func FallbackFiles(files []models.FallbackFile, jsonFlag bool) {
 	var rows [][]string
-	for _, name := range secretsNames {
-		rows = append(rows, []string{name})
+	for _, file := range files {
+		project := file.Project
+		config := file.Config
+		if !file.Indexed {
+			project = "unknown"
+			config = "unknown"
+		}
+
+		fileType := "fallback"
+		if file.OrphanedMetadata {
+			fileType = "orphaned metadata"
+		} else if file.HasMetadata {
+			fileType = "fallback + metadata"
+		}
+
+		updatedAt := file.ModifiedAt.In(time.Local).Format(time.RFC3339)
+		rows = append(rows, []string{file.Name, fileType, project, config, file.TokenScope, file.ETag, updatedAt})
 	}
-	Table([]string{"name"}, rows, TableOptions())
+	Print(jsonFlag, files, []string{"file", "type", "project", "config", "token scope", "etag", "updated at"}, rows)
 }
 
 // Settings print settings
 func Settings(settings models.WorkplaceSettings, jsonFlag bool) {
-	if jsonFlag {
-		JSON(settings)
-		return
-	}
-
 	rows := [][]string{{settings.ID, settings.Name, settings.BillingEmail}}
-	Table([]string{"id", "name", "billing email"}, rows, TableOptions())
+	Print(jsonFlag, settings, []string{"id", "name", "billing email"}, rows)
 }
 
 // ConfigServiceTokensInfo print info of multiple config service tokens
@@ -365,26 +381,24 @@ func ConfigServiceTokensInfo(tokens []models.ConfigServiceToken, number int, jso
 	maxTokens := int(math.Min(float64(len(tokens)), float64(number)))
 	tokens = tokens[0:maxTokens]
 
-	if jsonFlag {
-		JSON(tokens)
-		return
-	}
-
 	rows := [][]string{}
 	for _, token := range tokens {
-		rows = append(rows, []string{token.Name, token.Slug, token.Project, token.Environment, token.Config, token.CreatedAt})
+		rows = append(rows, serviceTokenRow(token))
 	}
-	Table([]string{"name", "slug", "project", "environment", "config", "created at"}, rows, TableOptions())
+	Print(jsonFlag, tokens, serviceTokenHeaders, rows)
+}
+
+var serviceTokenHeaders = []string{"name", "slug", "project", "environment", "config", "access", "created at", "expires at", "last seen at", "status"}
+
+// serviceTokenRow the token's status flags tokens that are expired, old, or unused so they can be rotated or revoked
+func serviceTokenRow(token models.ConfigServiceToken) []string {
+	status := strings.Join(models.ServiceTokenStatuses(token, time.Now()), ", ")
+	return []string{token.Name, token.Slug, token.Project, token.Environment, token.Config, token.Access, token.CreatedAt, token.ExpiresAt, token.LastSeenAt, status}
 }
 
 // ConfigServiceTokenInfo print config service token info
 func ConfigServiceTokenInfo(token models.ConfigServiceToken, jsonFlag bool) {
-	if jsonFlag {
-		JSON(token)
-		return
-	}
-
-	ConfigServiceTokensInfo([]models.ConfigServiceToken{token}, 1, false)
+	Print(jsonFlag, token, serviceTokenHeaders, [][]string{serviceTokenRow(token)})
 }
 
 // ConfigServiceToken print config service token and its info
@@ -400,13 +414,8 @@ func ConfigServiceToken(token models.ConfigServiceToken, jsonFlag bool, plain bo
 		return
 	}
 
-	if jsonFlag {
-		JSON(token)
-		return
-	}
-
-	rows := [][]string{{token.Name, token.Token, token.Slug, token.Project, token.Environment, token.Config, token.CreatedAt}}
-	Table([]string{"name", "token", "slug", "project", "environment", "config", "created at"}, rows, TableOptions())
+	rows := [][]string{{token.Name, token.Token, token.Slug, token.Project, token.Environment, token.Config, token.Access, token.CreatedAt, token.ExpiresAt}}
+	Print(jsonFlag, token, []string{"name", "token", "slug", "project", "environment", "config", "access", "created at", "expires at"}, rows)
 }
 
 // ChangeLog print change log
@@ -445,3 +454,38 @@ func ChangeLog(changes map[string]models.ChangeLog, max int, jsonFlag bool) {
 		}
 	}
 }
+
+// TokenInfo print the token's info, along with where the token was configured. The token is masked.
+func TokenInfo(info models.TokenInfo, token models.ScopedOption, jsonFlag bool) {
+	maskedToken := utils.MaskToken(token.Value)
+
+	data := map[string]interface{}{
+		"type":       info.Type,
+		"name":       info.Name,
+		"slug":       info.Slug,
+		"created_at": info.CreatedAt,
+		"workplace":  info.Workplace,
+		"projects":   info.Projects,
+		"token":      maskedToken,
+		"scope":      token.Scope,
+		"source":     token.Source,
+	}
+	if info.Type == models.TokenTypeService {
+		data["environment"] = info.Environment
+		data["config"] = info.Config
+	}
+
+	headers := []string{"token", "type", "name", "workplace", "projects", "config", "created at", "scope", "source"}
+	row := []string{maskedToken, info.Type, info.Name, info.Workplace.Name, strings.Join(info.Projects, ", "), info.Config, info.CreatedAt, token.Scope, token.Source}
+
+	PrintWithTable(jsonFlag, data, headers, [][]string{row}, func() {
+		// one row per field, since a single row with every field is too wide
+		var rows [][]string
+		for i, header := range headers {
+			if row[i] != "" {
+				rows = append(rows, []string{header, row[i]})
+			}
+		}
+		Table([]string{"name", "value"}, rows, TableOptions())
+	})
+}
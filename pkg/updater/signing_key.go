@@ -0,0 +1,42 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package updater
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// ReleaseSigningKeyFingerprint the fingerprint of the GPG key that signs each release's checksums.txt (see INSTALL.md)
+const ReleaseSigningKeyFingerprint = "B70BD7FCA460C4A3D0EEB965D3D593D50EE79DEC"
+
+// releaseSigningKey the base64-encoded armored public key, which is set at build time by .goreleaser.yml via
+// -X github.com/DopplerHQ/cli/pkg/updater.releaseSigningKey=$(gpg --armor --export <fingerprint> | base64).
+// The key is encoded as ldflags values can't contain newlines.
+var releaseSigningKey = ""
+
+// ReleaseSigningKey the armored public GPG key that signs each release's checksums.txt.
+// Updates are refused while the key is unset, as the downloaded release can't be verified.
+var ReleaseSigningKey = decodeSigningKey(releaseSigningKey)
+
+func decodeSigningKey(encoded string) string {
+	// whitespace is ignored so the output of base64 can be used as is
+	key, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return ""
+	}
+	return string(key)
+}
@@ -0,0 +1,325 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package updater
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"golang.org/x/crypto/openpgp"
+)
+
+const checksumsFile = "checksums.txt"
+const signatureFile = "checksums.txt.sig"
+
+// Options configures where releases are downloaded from and installed to
+type Options struct {
+	// Host the host serving releases. Defaults to http.DefaultReleaseHost.
+	Host string
+	// SigningKey the armored public key that signs releases. Defaults to ReleaseSigningKey.
+	SigningKey string
+	// OS the release's OS. Defaults to the host OS.
+	OS string
+	// Arch the release's architecture. Defaults to the host architecture.
+	Arch string
+	// BinaryPath the binary to replace. Defaults to the running executable.
+	BinaryPath string
+}
+
+func (o Options) withDefaults() (Options, error) {
+	if o.Host == "" {
+		o.Host = http.DefaultReleaseHost
+	}
+	if o.SigningKey == "" {
+		o.SigningKey = ReleaseSigningKey
+	}
+	if o.OS == "" {
+		o.OS = runtime.GOOS
+	}
+	if o.Arch == "" {
+		o.Arch = runtime.GOARCH
+	}
+	if o.BinaryPath == "" {
+		executable, err := os.Executable()
+		if err != nil {
+			return o, err
+		}
+		// replace the binary itself, rather than a symlink to it
+		if o.BinaryPath, err = filepath.EvalSymlinks(executable); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+// ArchiveName the name of the release archive for the OS and architecture, as named by goreleaser
+func ArchiveName(tag string, goos string, goarch string) string {
+	osName := goos
+	if goos == "darwin" {
+		osName = "macOS"
+	}
+
+	arch := goarch
+	switch goarch {
+	case "386":
+		arch = "i386"
+	case "arm":
+		// armv6 binaries also run on armv7
+		arch = "armv6"
+	}
+
+	extension := "tar.gz"
+	if goos == "windows" {
+		extension = "zip"
+	}
+
+	return fmt.Sprintf("doppler_%s_%s_%s.%s", strings.TrimPrefix(tag, "v"), osName, arch, extension)
+}
+
+// BackupPath the location of the previous binary, which is kept for rollback
+func BackupPath(binaryPath string) string {
+	if strings.HasSuffix(binaryPath, ".exe") {
+		return strings.TrimSuffix(binaryPath, ".exe") + ".previous.exe"
+	}
+	return binaryPath + ".previous"
+}
+
+// Install downloads the release, verifies its checksum and signature, and replaces the binary.
+// The previous binary is kept at BackupPath.
+func Install(ctx context.Context, tag string, options Options) error {
+	options, err := options.withDefaults()
+	if err != nil {
+		return err
+	}
+	if options.SigningKey == "" {
+		return errors.New("This build of the CLI doesn't include a release signing key, so updates can't be verified")
+	}
+
+	utils.LogDebug(fmt.Sprintf("Downloading %s of release %s", checksumsFile, tag))
+	checksums, apiErr := http.DownloadReleaseAsset(ctx, options.Host, tag, checksumsFile)
+	if !apiErr.IsNil() {
+		return apiErr.Unwrap()
+	}
+	signature, apiErr := http.DownloadReleaseAsset(ctx, options.Host, tag, signatureFile)
+	if !apiErr.IsNil() {
+		return apiErr.Unwrap()
+	}
+	if err := VerifySignature(checksums, signature, options.SigningKey); err != nil {
+		return err
+	}
+
+	name := ArchiveName(tag, options.OS, options.Arch)
+	expected, ok := parseChecksums(checksums)[name]
+	if !ok {
+		return fmt.Errorf("Release %s doesn't include %s", tag, name)
+	}
+
+	utils.LogDebug(fmt.Sprintf("Downloading %s", name))
+	archive, apiErr := http.DownloadReleaseAsset(ctx, options.Host, tag, name)
+	if !apiErr.IsNil() {
+		return apiErr.Unwrap()
+	}
+	sum := sha256.Sum256(archive)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return fmt.Errorf("Checksum of %s is %s, expected %s", name, actual, expected)
+	}
+	utils.LogDebug("Verified release checksum and signature")
+
+	binaryName := "doppler"
+	if options.OS == "windows" {
+		binaryName = "doppler.exe"
+	}
+	binary, err := extractFile(archive, name, binaryName)
+	if err != nil {
+		return err
+	}
+
+	return replaceBinary(options.BinaryPath, binary, tag, options.OS == runtime.GOOS && options.Arch == runtime.GOARCH)
+}
+
+// Rollback restores the binary that was replaced by the last update
+func Rollback(options Options) error {
+	options, err := options.withDefaults()
+	if err != nil {
+		return err
+	}
+
+	backup := BackupPath(options.BinaryPath)
+	if !utils.Exists(backup) {
+		return errors.New("There is no previous version of the CLI to roll back to")
+	}
+
+	if utils.IsWindows() {
+		// the running executable can't be replaced, but it can be renamed
+		discarded := options.BinaryPath + ".discarded"
+		_ = os.Remove(discarded)
+		if err := os.Rename(options.BinaryPath, discarded); err != nil {
+			return err
+		}
+	}
+	return os.Rename(backup, options.BinaryPath)
+}
+
+// VerifySignature verifies the detached signature (armored or binary) of the data
+func VerifySignature(data []byte, signature []byte, armoredKey string) error {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKey))
+	if err != nil {
+		return fmt.Errorf("Unable to read release signing key: %s", err)
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(signature))
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(signature))
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid release signature: %s", err)
+	}
+	return nil
+}
+
+// parseChecksums parses the output of sha256sum (i.e. "<hash>  <file name>" lines)
+func parseChecksums(checksums []byte) map[string]string {
+	parsed := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			parsed[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+		}
+	}
+	return parsed
+}
+
+// extractFile reads a file from the root of a .tar.gz or .zip archive
+func extractFile(archive []byte, archiveName string, name string) ([]byte, error) {
+	if strings.HasSuffix(archiveName, ".zip") {
+		reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range reader.File {
+			if file.Name == name {
+				contents, err := file.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer contents.Close()
+				return ioutil.ReadAll(contents)
+			}
+		}
+		return nil, fmt.Errorf("%s doesn't contain %s", archiveName, name)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s doesn't contain %s", archiveName, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg && header.Name == name {
+			return ioutil.ReadAll(reader)
+		}
+	}
+}
+
+// replaceBinary writes the new binary alongside the current one, then swaps them, keeping the current binary as a backup
+func replaceBinary(path string, binary []byte, tag string, verify bool) error {
+	dir := filepath.Dir(path)
+	temp, err := ioutil.TempFile(dir, ".doppler-update-*")
+	if err != nil {
+		return fmt.Errorf("Unable to write to %s: %w", dir, err)
+	}
+	tempPath := temp.Name()
+	defer os.Remove(tempPath)
+
+	_, err = temp.Write(binary)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// #nosec G302
+	if err := os.Chmod(tempPath, 0755); err != nil {
+		return err
+	}
+
+	if verify {
+		// ensure the new binary runs before installing it
+		out, err := exec.Command(tempPath, "--version").Output() // #nosec G204
+		if err != nil {
+			return fmt.Errorf("Unable to run the downloaded binary: %s", err)
+		}
+		utils.LogDebug(fmt.Sprintf("Downloaded binary reports version %s", strings.TrimSpace(string(out))))
+	}
+
+	backup := BackupPath(path)
+	if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if utils.IsWindows() {
+		// the running executable can't be replaced, but it can be renamed
+		if err := os.Rename(path, backup); err != nil {
+			return err
+		}
+		return os.Rename(tempPath, path)
+	}
+
+	// keep a hard link to the current binary, so that the binary is replaced atomically
+	if err := os.Link(path, backup); err != nil {
+		if err := copyFile(path, backup); err != nil {
+			return fmt.Errorf("Unable to back up the current binary: %w", err)
+		}
+	}
+	utils.LogDebug(fmt.Sprintf("Installing %s to %s", tag, path))
+	return os.Rename(tempPath, path)
+}
+
+func copyFile(src string, dst string) error {
+	contents, err := ioutil.ReadFile(src) // #nosec G304
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, contents, 0755) // #nosec G306
+}
@@ -0,0 +1,271 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package updater
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+const tag = "v9.9.9"
+
+// release a local release server's files
+type release struct {
+	files map[string][]byte
+	key   string
+}
+
+func newSigningKey(t *testing.T) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity("Test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return entity, armored.String()
+}
+
+func newRelease(t *testing.T, binary string) release {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "doppler", Mode: 0755, Size: int64(len(binary)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(binary)); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	gz.Close()
+
+	name := ArchiveName(tag, runtime.GOOS, runtime.GOARCH)
+	sum := sha256.Sum256(archive.Bytes())
+	checksums := fmt.Sprintf("%s  %s\n%s  other.tar.gz\n", hex.EncodeToString(sum[:]), name, strings.Repeat("0", 64))
+
+	entity, key := newSigningKey(t)
+	var signature bytes.Buffer
+	if err := openpgp.DetachSign(&signature, entity, strings.NewReader(checksums), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return release{
+		files: map[string][]byte{
+			name:          archive.Bytes(),
+			checksumsFile: []byte(checksums),
+			signatureFile: signature.Bytes(),
+		},
+		key: key,
+	}
+}
+
+func (r release) serve() *httptest.Server {
+	return httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, req *nethttp.Request) {
+		prefix := "/DopplerHQ/cli/releases/download/" + tag + "/"
+		if contents, ok := r.files[strings.TrimPrefix(req.URL.Path, prefix)]; ok && strings.HasPrefix(req.URL.Path, prefix) {
+			_, _ = w.Write(contents)
+			return
+		}
+		w.WriteHeader(nethttp.StatusNotFound)
+	}))
+}
+
+func installedBinary(t *testing.T) (string, func()) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test release's binary is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "updater")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "doppler")
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\necho v1.0.0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func readFile(t *testing.T, path string) string {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(contents)
+}
+
+func TestInstallAndRollback(t *testing.T) {
+	path, cleanup := installedBinary(t)
+	defer cleanup()
+
+	r := newRelease(t, "#!/bin/sh\necho v9.9.9\n")
+	server := r.serve()
+	defer server.Close()
+
+	options := Options{Host: server.URL, SigningKey: r.key, BinaryPath: path}
+	if err := Install(context.Background(), tag, options); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(readFile(t, path), "v9.9.9") {
+		t.Error("Expected the binary to be replaced")
+	}
+	if !strings.Contains(readFile(t, BackupPath(path)), "v1.0.0") {
+		t.Error("Expected the previous binary to be kept")
+	}
+
+	if err := Rollback(options); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(readFile(t, path), "v1.0.0") {
+		t.Error("Expected the previous binary to be restored")
+	}
+	if err := Rollback(options); err == nil {
+		t.Error("Expected an error when there's no previous binary")
+	}
+}
+
+func TestInstallRejectsInvalidRelease(t *testing.T) {
+	path, cleanup := installedBinary(t)
+	defer cleanup()
+
+	tests := map[string]func(r *release){
+		"tampered archive": func(r *release) {
+			name := ArchiveName(tag, runtime.GOOS, runtime.GOARCH)
+			r.files[name] = append(r.files[name], 0)
+		},
+		"tampered checksums": func(r *release) {
+			r.files[checksumsFile] = append(r.files[checksumsFile], []byte("0  extra\n")...)
+		},
+		"unknown signing key": func(r *release) {
+			_, r.key = newSigningKey(t)
+		},
+		"missing signature": func(r *release) {
+			delete(r.files, signatureFile)
+		},
+		"missing archive": func(r *release) {
+			delete(r.files, ArchiveName(tag, runtime.GOOS, runtime.GOARCH))
+		},
+	}
+	for name, tamper := range tests {
+		r := newRelease(t, "#!/bin/sh\necho v9.9.9\n")
+		tamper(&r)
+		server := r.serve()
+
+		err := Install(context.Background(), tag, Options{Host: server.URL, SigningKey: r.key, BinaryPath: path})
+		server.Close()
+		if err == nil {
+			t.Errorf("Expected an error for a release with a %s", name)
+		}
+		if !strings.Contains(readFile(t, path), "v1.0.0") {
+			t.Fatalf("Expected the binary to be unchanged for a release with a %s", name)
+		}
+	}
+}
+
+func TestInstallRequiresSigningKey(t *testing.T) {
+	defaultKey := ReleaseSigningKey
+	ReleaseSigningKey = ""
+	defer func() { ReleaseSigningKey = defaultKey }()
+
+	err := Install(context.Background(), tag, Options{Host: "http://127.0.0.1:0", BinaryPath: "doppler"})
+	if err == nil || !strings.Contains(err.Error(), "signing key") {
+		t.Errorf("Expected an error when no signing key is available, got %v", err)
+	}
+}
+
+// TestReleaseSigningKey verifies the key injected at build time. scripts/release/release.sh runs this with the
+// release's ldflags before publishing.
+func TestReleaseSigningKey(t *testing.T) {
+	if releaseSigningKey == "" {
+		if os.Getenv("DOPPLER_REQUIRE_RELEASE_SIGNING_KEY") == "true" {
+			t.Fatal("Expected the release signing key to be set via ldflags")
+		}
+		t.Skip("The release signing key is only set for release builds")
+	}
+
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(ReleaseSigningKey))
+	if err != nil {
+		t.Fatalf("Unable to parse the release signing key: %v", err)
+	}
+	if len(keyring) != 1 {
+		t.Fatalf("Expected 1 key, got %d", len(keyring))
+	}
+	if fingerprint := fmt.Sprintf("%X", keyring[0].PrimaryKey.Fingerprint); fingerprint != ReleaseSigningKeyFingerprint {
+		t.Errorf("Expected key %s, got %s", ReleaseSigningKeyFingerprint, fingerprint)
+	}
+}
+
+func TestDecodeSigningKey(t *testing.T) {
+	_, key := newSigningKey(t)
+	encoded := base64.StdEncoding.EncodeToString([]byte(key))
+	// base64 wraps its output at 76 characters
+	wrapped := ""
+	for len(encoded) > 76 {
+		wrapped += encoded[:76] + "\n"
+		encoded = encoded[76:]
+	}
+	wrapped += encoded + "\n"
+
+	decoded := decodeSigningKey(wrapped)
+	if decoded != key {
+		t.Fatal("Expected the decoded key to match the armored key")
+	}
+	if _, err := openpgp.ReadArmoredKeyRing(strings.NewReader(decoded)); err != nil {
+		t.Errorf("Unable to parse the decoded key: %v", err)
+	}
+
+	if decodeSigningKey("") != "" || decodeSigningKey("not base64!") != "" {
+		t.Error("Expected an empty key for invalid input")
+	}
+}
+
+func TestArchiveName(t *testing.T) {
+	tests := map[[2]string]string{
+		{"darwin", "amd64"}:  "doppler_9.9.9_macOS_amd64.tar.gz",
+		{"linux", "386"}:     "doppler_9.9.9_linux_i386.tar.gz",
+		{"linux", "arm"}:     "doppler_9.9.9_linux_armv6.tar.gz",
+		{"windows", "amd64"}: "doppler_9.9.9_windows_amd64.zip",
+	}
+	for platform, expected := range tests {
+		if name := ArchiveName(tag, platform[0], platform[1]); name != expected {
+			t.Errorf("Got %s for %s/%s, expected %s", name, platform[0], platform[1], expected)
+		}
+	}
+}
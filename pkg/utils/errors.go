@@ -0,0 +1,129 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// ErrorKind a category of error. Each kind has a stable name and exit code that scripts can rely on.
+type ErrorKind struct {
+	// Name identifies the kind in JSON output (e.g. "unauthorized")
+	Name string
+	// ExitCode the code the CLI exits with
+	ExitCode int
+}
+
+// The kinds of errors. Exit codes must not change, as scripts depend on them.
+var (
+	ErrorKindGeneral        = ErrorKind{Name: "error", ExitCode: 1}
+	ErrorKindUsage          = ErrorKind{Name: "invalid_usage", ExitCode: 2}
+	ErrorKindNetwork        = ErrorKind{Name: "network_error", ExitCode: 3}
+	ErrorKindTimeout        = ErrorKind{Name: "timeout", ExitCode: 4}
+	ErrorKindUnauthorized   = ErrorKind{Name: "unauthorized", ExitCode: 5}
+	ErrorKindForbidden      = ErrorKind{Name: "forbidden", ExitCode: 6}
+	ErrorKindNotFound       = ErrorKind{Name: "not_found", ExitCode: 7}
+	ErrorKindConflict       = ErrorKind{Name: "conflict", ExitCode: 8}
+	ErrorKindRateLimited    = ErrorKind{Name: "rate_limited", ExitCode: 9}
+	ErrorKindServer         = ErrorKind{Name: "server_error", ExitCode: 10}
+	ErrorKindInvalidRequest = ErrorKind{Name: "invalid_request", ExitCode: 11}
+	ErrorKindFallback       = ErrorKind{Name: "fallback_failed", ExitCode: 12}
+	// ErrorKindCanceled matches the conventional exit code of a process terminated by SIGINT
+	ErrorKindCanceled = ErrorKind{Name: "canceled", ExitCode: 130}
+)
+
+// ErrorKindForStatus the kind of error indicated by an HTTP status code
+func ErrorKindForStatus(status int) ErrorKind {
+	switch {
+	case status == http.StatusUnauthorized:
+		return ErrorKindUnauthorized
+	case status == http.StatusForbidden:
+		return ErrorKindForbidden
+	case status == http.StatusNotFound:
+		return ErrorKindNotFound
+	case status == http.StatusConflict || status == http.StatusLocked:
+		return ErrorKindConflict
+	case status == http.StatusTooManyRequests:
+		return ErrorKindRateLimited
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return ErrorKindTimeout
+	case status >= 500:
+		return ErrorKindServer
+	case status >= 400:
+		return ErrorKindInvalidRequest
+	default:
+		return ErrorKindGeneral
+	}
+}
+
+// Error an error of a specific kind. Errors from API responses also include the response's status and request ID.
+// API and controller errors wrap an Error, so its details are available via errors.As.
+type Error struct {
+	Err  error
+	Kind ErrorKind
+	// Status the HTTP status code of the API response, if any
+	Status int
+	// RequestID the ID the API assigned to the request, if any
+	RequestID string
+}
+
+// Error the error's message
+func (e *Error) Error() string { return e.Err.Error() }
+
+// Unwrap get the original error
+func (e *Error) Unwrap() error { return e.Err }
+
+// NewError creates an error of the specified kind
+func NewError(kind ErrorKind, err error) error {
+	return &Error{Err: err, Kind: kind}
+}
+
+// ErrorDetails gets the kind and API details of an error. Errors without a kind are classified by their cause.
+func ErrorDetails(e error) Error {
+	var detailed *Error
+	if errors.As(e, &detailed) {
+		return *detailed
+	}
+
+	details := Error{Err: e, Kind: ErrorKindGeneral}
+	switch {
+	case errors.Is(e, context.Canceled):
+		details.Kind = ErrorKindCanceled
+	case errors.Is(e, context.DeadlineExceeded):
+		details.Kind = ErrorKindTimeout
+	case isNetworkError(e):
+		var netErr net.Error
+		if errors.As(e, &netErr) && netErr.Timeout() {
+			details.Kind = ErrorKindTimeout
+		} else {
+			details.Kind = ErrorKindNetwork
+		}
+	}
+	return details
+}
+
+// isNetworkError whether the error occurred while performing an HTTP request or connecting to a server.
+// net.Error can't be used to detect these, as it's also implemented by other errors (e.g. syscall.Errno).
+func isNetworkError(e error) bool {
+	var urlErr *url.Error
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(e, &urlErr) || errors.As(e, &opErr) || errors.As(e, &dnsErr)
+}
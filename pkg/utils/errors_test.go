@@ -0,0 +1,69 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestErrorKindForStatus(t *testing.T) {
+	tests := map[int]ErrorKind{
+		401: ErrorKindUnauthorized,
+		403: ErrorKindForbidden,
+		404: ErrorKindNotFound,
+		409: ErrorKindConflict,
+		422: ErrorKindInvalidRequest,
+		429: ErrorKindRateLimited,
+		500: ErrorKindServer,
+		504: ErrorKindTimeout,
+	}
+	for status, expected := range tests {
+		if kind := ErrorKindForStatus(status); kind != expected {
+			t.Errorf("Got %s for HTTP %d, expected %s", kind.Name, status, expected.Name)
+		}
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	apiErr := &Error{Err: errors.New("Invalid auth token"), Kind: ErrorKindUnauthorized, Status: 401, RequestID: "abc"}
+	details := ErrorDetails(fmt.Errorf("wrapped: %w", apiErr))
+	if details.Kind != ErrorKindUnauthorized || details.Status != 401 || details.RequestID != "abc" {
+		t.Errorf("Got %+v, expected the wrapped error's details", details)
+	}
+
+	tests := []struct {
+		err      error
+		expected ErrorKind
+	}{
+		{errors.New("unknown"), ErrorKindGeneral},
+		{&os.PathError{Op: "stat", Path: "/nonexistent", Err: syscall.ENOENT}, ErrorKindGeneral},
+		{context.Canceled, ErrorKindCanceled},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), ErrorKindTimeout},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrorKindNetwork},
+		{NewError(ErrorKindUsage, errors.New("invalid flag")), ErrorKindUsage},
+	}
+	for _, test := range tests {
+		if kind := ErrorDetails(test.err).Kind; kind != test.expected {
+			t.Errorf("Got %s for '%s', expected %s", kind.Name, test.err, test.expected.Name)
+		}
+	}
+}
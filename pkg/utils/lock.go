@@ -0,0 +1,59 @@
+/*
+Copyright © 2020 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"fmt"
+	"os"
+)
+
+// FileLock an advisory lock held on a file
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile acquires an advisory lock on the specified path, blocking until the lock is available.
+// The file is created if it doesn't exist. Exclusive locks should be used by writers and shared locks by readers.
+func LockFile(path string, exclusive bool) (*FileLock, error) {
+	// #nosec G304
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	LogDebug(fmt.Sprintf("Acquiring lock on %s", path))
+	if err := lockFile(file, exclusive); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &FileLock{file: file}, nil
+}
+
+// Unlock releases the lock
+func (l *FileLock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	LogDebug(fmt.Sprintf("Releasing lock on %s", l.file.Name()))
+	err := unlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
@@ -0,0 +1,43 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright © 2020 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lock the maximum range so the entire file is covered regardless of its size
+const allBytes = ^uint32(0)
+
+func lockFile(file *os.File, exclusive bool) error {
+	var flags uint32
+	if exclusive {
+		flags = windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+
+	overlapped := new(windows.Overlapped)
+	return windows.LockFileEx(windows.Handle(file.Fd()), flags, 0, allBytes, allBytes, overlapped)
+}
+
+func unlockFile(file *os.File) error {
+	overlapped := new(windows.Overlapped)
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, allBytes, allBytes, overlapped)
+}
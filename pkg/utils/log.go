@@ -19,12 +19,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 
 	"gopkg.in/gookit/color.v1"
 )
 
 // Log info message to stdout
 func Log(info string) {
+	LogEntry(LogLevelInfo, info)
 	if CanLogInfo() {
 		fmt.Println(info)
 	}
@@ -32,6 +34,7 @@ func Log(info string) {
 
 // LogWarning message to stdout
 func LogWarning(s string) {
+	LogEntry(LogLevelWarning, s)
 	if CanLogInfo() {
 		fmt.Println(color.Yellow.Render("Warning:"), s)
 	}
@@ -39,6 +42,7 @@ func LogWarning(s string) {
 
 // LogError prints an error message to stderr
 func LogError(e error) {
+	logErrorEntry(LogLevelError, e)
 	if CanLogInfo() {
 		printError(e)
 	}
@@ -52,13 +56,23 @@ func CanLogInfo() bool {
 
 // LogDebug prints a debug message to stdout
 func LogDebug(s string) {
+	LogDebugFields(s)
+}
+
+// LogDebugFields prints a debug message and its fields to stdout
+func LogDebugFields(s string, fields ...LogField) {
+	LogEntry(LogLevelDebug, s, fields...)
 	if CanLogDebug() {
+		if formatted := formatLogfmt(nonEmptyFields(fields)); formatted != "" {
+			s = s + " " + formatted
+		}
 		fmt.Println(color.Blue.Render("Debug:"), s)
 	}
 }
 
 // LogDebugError prints an error message to stderr when in debug mode
 func LogDebugError(e error) {
+	logErrorEntry(LogLevelDebug, e)
 	if CanLogDebug() {
 		printError(e)
 	}
@@ -69,15 +83,37 @@ func CanLogDebug() bool {
 	return Debug
 }
 
-// HandleError prints the error and exits with code 1
+// HandleError prints the error and exits with the exit code of the error's kind
 func HandleError(e error, messages ...string) {
-	ErrExit(e, 1, messages...)
+	ErrExit(e, ErrorDetails(e).Kind.ExitCode, messages...)
 }
 
 // ErrExit prints the error and exits with the specified code
 func ErrExit(e error, exitCode int, messages ...string) {
+	message := ""
+	if len(messages) > 0 {
+		message = messages[0]
+	}
+	logErrorEntry(LogLevelError, e, LogField{Key: "message", Value: message}, LogField{Key: "exit_code", Value: strconv.Itoa(exitCode)})
+
 	if OutputJSON {
-		resp, err := json.Marshal(map[string]string{"error": e.Error()})
+		details := ErrorDetails(e)
+		output := map[string]interface{}{
+			"error":     e.Error(),
+			"code":      details.Kind.Name,
+			"exit_code": exitCode,
+		}
+		if len(messages) > 0 && messages[0] != "" {
+			output["message"] = messages[0]
+		}
+		if details.Status != 0 {
+			output["http_status"] = details.Status
+		}
+		if details.RequestID != "" {
+			output["request_id"] = details.RequestID
+		}
+
+		resp, err := json.Marshal(output)
 		if err != nil {
 			panic(err)
 		}
@@ -99,6 +135,26 @@ func ErrExit(e error, exitCode int, messages ...string) {
 	os.Exit(exitCode)
 }
 
+// logErrorEntry writes a structured log entry containing the error and its details
+func logErrorEntry(level LogLevel, e error, fields ...LogField) {
+	details := ErrorDetails(e)
+	fields = append(fields, LogField{Key: "code", Value: details.Kind.Name}, LogField{Key: "request_id", Value: details.RequestID})
+	if details.Status != 0 {
+		fields = append(fields, LogField{Key: "http_status", Value: strconv.Itoa(details.Status)})
+	}
+	LogEntry(level, e.Error(), fields...)
+}
+
+func nonEmptyFields(fields []LogField) []LogField {
+	var nonEmpty []LogField
+	for _, field := range fields {
+		if field.Value != "" {
+			nonEmpty = append(nonEmpty, field)
+		}
+	}
+	return nonEmpty
+}
+
 func printError(e error) {
 	fmt.Fprintln(os.Stderr, color.Red.Render("Doppler Error:"), e)
 }
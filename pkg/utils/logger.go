@@ -0,0 +1,284 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LogLevel the severity of a log entry
+type LogLevel int
+
+// The log levels, from least to most severe
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarning
+	LogLevelError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+func (l LogLevel) String() string {
+	if int(l) < len(logLevelNames) {
+		return logLevelNames[l]
+	}
+	return strconv.Itoa(int(l))
+}
+
+// ParseLogLevel parses a log level name (e.g. "debug")
+func ParseLogLevel(value string) (LogLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(value, name) {
+			return LogLevel(i), nil
+		}
+	}
+	if strings.EqualFold(value, "warning") {
+		return LogLevelWarning, nil
+	}
+	return LogLevelInfo, fmt.Errorf("invalid log level %q. Valid levels are %s", value, strings.Join(logLevelNames, ", "))
+}
+
+// The formats of structured log entries
+const (
+	LogFormatLogfmt = "logfmt"
+	LogFormatJSON   = "json"
+)
+
+// LogFileStderr the log file path that writes structured log entries to stderr
+const LogFileStderr = "-"
+
+// RunID identifies the current invocation of the CLI. It's included in each structured log entry and sent with each API request.
+var RunID = newRunID()
+
+func newRunID() string {
+	// UUID() can't be used, as it logs on failure
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return RandomBase64String(22)
+	}
+	return id.String()
+}
+
+// LogField a key/value pair attached to a structured log entry
+type LogField struct {
+	Key   string
+	Value string
+}
+
+// LogFileOptions configures the structured log file
+type LogFileOptions struct {
+	// Path the log file, or LogFileStderr
+	Path string
+	// Level the min level of entries to write
+	Level LogLevel
+	// Format LogFormatLogfmt or LogFormatJSON
+	Format string
+	// MaxSize the size in bytes at which the file is rotated. A MaxSize of 0 disables rotation.
+	MaxSize int64
+	// MaxBackups the number of rotated files to keep
+	MaxBackups int
+}
+
+// DefaultLogFileMaxSize the size at which log files are rotated, unless otherwise specified
+const DefaultLogFileMaxSize = 10 * 1024 * 1024
+
+// DefaultLogFileMaxBackups the number of rotated log files kept, unless otherwise specified
+const DefaultLogFileMaxBackups = 3
+
+var logSink struct {
+	mutex   sync.Mutex
+	options LogFileOptions
+	writer  io.Writer
+	file    *os.File
+	size    int64
+	// values to redact from log entries
+	redacted []string
+}
+
+var ansiEscapeSequence = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// OpenLogFile writes structured log entries to a file, in addition to the CLI's normal output
+func OpenLogFile(options LogFileOptions) error {
+	if options.Format == "" {
+		options.Format = LogFormatLogfmt
+	}
+	if options.Format != LogFormatLogfmt && options.Format != LogFormatJSON {
+		return fmt.Errorf("invalid log format %q. Valid formats are %s, %s", options.Format, LogFormatLogfmt, LogFormatJSON)
+	}
+
+	logSink.mutex.Lock()
+	defer logSink.mutex.Unlock()
+
+	closeLogFile()
+	logSink.options = options
+	if options.Path == LogFileStderr {
+		logSink.writer = os.Stderr
+		return nil
+	}
+	return openLogFile()
+}
+
+// CloseLogFile stops writing structured log entries
+func CloseLogFile() {
+	logSink.mutex.Lock()
+	defer logSink.mutex.Unlock()
+	closeLogFile()
+}
+
+func openLogFile() error {
+	// log entries may contain sensitive information, so restrict access to the current user
+	file, err := os.OpenFile(logSink.options.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // #nosec G304
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	logSink.file = file
+	logSink.writer = file
+	logSink.size = info.Size()
+	return nil
+}
+
+func closeLogFile() {
+	if logSink.file != nil {
+		logSink.file.Close()
+	}
+	logSink.file = nil
+	logSink.writer = nil
+	logSink.size = 0
+}
+
+// rotateLogFile renames the log file to <path>.1, shifting existing backups and removing the oldest
+func rotateLogFile() error {
+	path := logSink.options.Path
+	closeLogFile()
+
+	backups := logSink.options.MaxBackups
+	if backups <= 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return openLogFile()
+	}
+
+	_ = os.Remove(fmt.Sprintf("%s.%d", path, backups))
+	for i := backups - 1; i > 0; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+	if err := os.Rename(path, path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return openLogFile()
+}
+
+// RedactFromLogs replaces the values with RedactedValue in all subsequent structured log entries
+func RedactFromLogs(values ...string) {
+	logSink.mutex.Lock()
+	defer logSink.mutex.Unlock()
+
+	for _, value := range values {
+		if len(value) >= MinRedactedLength {
+			logSink.redacted = append(logSink.redacted, value)
+		}
+	}
+	// replace longer values first, in case one value contains another
+	sort.SliceStable(logSink.redacted, func(i, j int) bool {
+		return len(logSink.redacted[i]) > len(logSink.redacted[j])
+	})
+}
+
+func redactLogValue(value string) string {
+	for _, redacted := range logSink.redacted {
+		value = strings.ReplaceAll(value, redacted, RedactedValue)
+	}
+	return value
+}
+
+// LogEntry writes a structured log entry to the log file, if one is open
+func LogEntry(level LogLevel, message string, fields ...LogField) {
+	logSink.mutex.Lock()
+	defer logSink.mutex.Unlock()
+
+	if logSink.writer == nil || level < logSink.options.Level {
+		return
+	}
+
+	all := []LogField{
+		{Key: "time", Value: time.Now().UTC().Format(time.RFC3339Nano)},
+		{Key: "level", Value: level.String()},
+		{Key: "run_id", Value: RunID},
+		{Key: "msg", Value: ansiEscapeSequence.ReplaceAllString(message, "")},
+	}
+	for _, field := range fields {
+		if field.Value != "" {
+			all = append(all, field)
+		}
+	}
+	for i := range all {
+		all[i].Value = redactLogValue(all[i].Value)
+	}
+
+	var line []byte
+	if logSink.options.Format == LogFormatJSON {
+		entry := map[string]string{}
+		for _, field := range all {
+			entry[field.Key] = field.Value
+		}
+		line, _ = json.Marshal(entry)
+	} else {
+		line = []byte(formatLogfmt(all))
+	}
+	line = append(line, '\n')
+
+	if logSink.file != nil && logSink.options.MaxSize > 0 && logSink.size+int64(len(line)) > logSink.options.MaxSize {
+		if err := rotateLogFile(); err != nil {
+			fmt.Fprintln(os.Stderr, "Unable to rotate log file:", err)
+			return
+		}
+	}
+	if n, err := logSink.writer.Write(line); err == nil {
+		logSink.size += int64(n)
+	}
+}
+
+// formatLogfmt formats the fields as space-separated key=value pairs, quoting values as necessary
+func formatLogfmt(fields []LogField) string {
+	var pairs []string
+	for _, field := range fields {
+		value := field.Value
+		if value == "" || strings.ContainsAny(value, " =\"\t\r\n\\") {
+			value = strconv.Quote(value)
+		}
+		pairs = append(pairs, field.Key+"="+value)
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func logFile(t *testing.T, options LogFileOptions) (string, func()) {
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.Path = filepath.Join(dir, "doppler.log")
+	if err := OpenLogFile(options); err != nil {
+		t.Fatal(err)
+	}
+
+	return options.Path, func() {
+		CloseLogFile()
+		logSink.redacted = nil
+		os.RemoveAll(dir)
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(contents)), "\n")
+}
+
+func TestLogfmt(t *testing.T) {
+	path, cleanup := logFile(t, LogFileOptions{Level: LogLevelInfo})
+	defer cleanup()
+
+	LogEntry(LogLevelDebug, "filtered")
+	LogEntry(LogLevelWarning, "Request failed", LogField{Key: "request_id", Value: "abc"}, LogField{Key: "empty", Value: ""})
+
+	lines := readLines(t, path)
+	if len(lines) != 1 {
+		t.Fatalf("Got %d lines, expected 1", len(lines))
+	}
+	for _, expected := range []string{"level=warn", "run_id=" + RunID, `msg="Request failed"`, "request_id=abc"} {
+		if !strings.Contains(lines[0], expected) {
+			t.Errorf("Got '%s', expected it to contain '%s'", lines[0], expected)
+		}
+	}
+	if strings.Contains(lines[0], "empty=") {
+		t.Errorf("Got '%s', expected empty fields to be omitted", lines[0])
+	}
+}
+
+func TestLogJSONRedaction(t *testing.T) {
+	path, cleanup := logFile(t, LogFileOptions{Level: LogLevelDebug, Format: LogFormatJSON})
+	defer cleanup()
+
+	RedactFromLogs("secret-value", "secret", "abc")
+	LogEntry(LogLevelInfo, "\x1b[32mvalue is secret-value\x1b[0m", LogField{Key: "other", Value: "secret and abc"})
+
+	var entry map[string]string
+	if err := json.Unmarshal([]byte(readLines(t, path)[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "value is ***" {
+		t.Errorf("Got '%s', expected the value and color codes to be removed", entry["msg"])
+	}
+	// values shorter than the min length aren't redacted
+	if entry["other"] != "*** and abc" {
+		t.Errorf("Got '%s', expected '*** and abc'", entry["other"])
+	}
+}
+
+func TestLogRotation(t *testing.T) {
+	path, cleanup := logFile(t, LogFileOptions{Level: LogLevelDebug, MaxSize: 300, MaxBackups: 2})
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		LogEntry(LogLevelInfo, strings.Repeat("x", 100))
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 300 {
+			t.Errorf("Got size %d for %s, expected at most 300", info.Size(), name)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected only 2 backups to be kept")
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// qrQuietZone the number of light modules surrounding the code, as required by the QR spec
+const qrQuietZone = 4
+
+// QRCode renders the text as a QR code that can be printed to a terminal. Each line of output holds two rows of
+// modules. Light modules are drawn as blocks so the code can be scanned from terminals with a dark background.
+func QRCode(text string) (string, error) {
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for y := -qrQuietZone; y < code.Size+qrQuietZone; y += 2 {
+		for x := -qrQuietZone; x < code.Size+qrQuietZone; x++ {
+			top := !code.Black(x, y)
+			bottom := y+1 < code.Size+qrQuietZone && !code.Black(x, y+1)
+			switch {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"rsc.io/qr"
+)
+
+func TestQRCode(t *testing.T) {
+	text := "https://dashboard.doppler.com/workplace/auth/cli?code=ABC-123"
+	rendered, err := QRCode(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each line holds two rows of modules, including the quiet zone on every side
+	width := code.Size + 2*qrQuietZone
+	lines := strings.Split(strings.TrimSuffix(rendered, "\n"), "\n")
+	if len(lines) != (width+1)/2 {
+		t.Fatalf("Expected %d lines, got %d", (width+1)/2, len(lines))
+	}
+
+	for i, line := range lines {
+		blocks := []rune(line)
+		if len(blocks) != width {
+			t.Fatalf("Expected line %d to be %d blocks wide, got %d", i, width, len(blocks))
+		}
+
+		for x, block := range blocks {
+			top := !code.Black(x-qrQuietZone, 2*i-qrQuietZone)
+			// the bottom row of an odd-height code is left blank
+			y := 2*i + 1 - qrQuietZone
+			bottom := y < code.Size+qrQuietZone && !code.Black(x-qrQuietZone, y)
+
+			expected := ' '
+			switch {
+			case top && bottom:
+				expected = '█'
+			case top:
+				expected = '▀'
+			case bottom:
+				expected = '▄'
+			}
+			if block != expected {
+				t.Fatalf("Expected %q at line %d column %d, got %q", expected, i, x, block)
+			}
+		}
+	}
+
+	// the quiet zone is light, so the first lines are solid
+	if lines[0] != strings.Repeat("█", width) {
+		t.Errorf("Expected a light quiet zone, got %q", lines[0])
+	}
+}
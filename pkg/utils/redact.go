@@ -0,0 +1,204 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// MinRedactedLength values shorter than this aren't redacted, as they'd likely match unrelated text
+const MinRedactedLength = 4
+
+// RedactedValue replaces redacted values
+const RedactedValue = "***"
+
+// RedactedForms the values to redact for each secret value. When encoded is set, the values' base64 and URL-encoded forms are included.
+func RedactedForms(values []string, encoded bool) []string {
+	var forms []string
+	for _, value := range values {
+		if len(value) < MinRedactedLength {
+			continue
+		}
+		forms = append(forms, value)
+		if encoded {
+			forms = append(forms,
+				base64.StdEncoding.EncodeToString([]byte(value)),
+				base64.RawStdEncoding.EncodeToString([]byte(value)),
+				base64.URLEncoding.EncodeToString([]byte(value)),
+				base64.RawURLEncoding.EncodeToString([]byte(value)),
+				url.QueryEscape(value),
+				url.PathEscape(value),
+			)
+		}
+	}
+	return forms
+}
+
+// redactionTrie a trie of the values to redact
+type redactionTrie struct {
+	children map[byte]*redactionTrie
+	// whether a value ends at this node
+	terminal bool
+}
+
+func newRedactionTrie(values []string) *redactionTrie {
+	root := &redactionTrie{children: map[byte]*redactionTrie{}}
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		node := root
+		for i := 0; i < len(value); i++ {
+			child, ok := node.children[value[i]]
+			if !ok {
+				child = &redactionTrie{children: map[byte]*redactionTrie{}}
+				node.children[value[i]] = child
+			}
+			node = child
+		}
+		node.terminal = true
+	}
+	return root
+}
+
+// match finds the longest value at the start of data, returning its length (or 0 if none).
+// incomplete is set when data ends partway through a value, so a longer match may follow.
+func (t *redactionTrie) match(data []byte) (length int, incomplete bool) {
+	node := t
+	for i := 0; i < len(data); i++ {
+		child, ok := node.children[data[i]]
+		if !ok {
+			return length, false
+		}
+		node = child
+		if node.terminal {
+			length = i + 1
+		}
+	}
+	return length, len(node.children) > 0
+}
+
+// RedactorFlushDelay how long data that may be the start of a value is buffered before it's written anyway,
+// so a prompt or progress line that happens to end like a value isn't held indefinitely
+const RedactorFlushDelay = 100 * time.Millisecond
+
+// Redactor an io.WriteCloser that replaces values with RedactedValue before writing to the underlying writer.
+// Values split across writes are redacted, unless the writes are more than RedactorFlushDelay apart. Only data that
+// may be the start of a value is buffered, so memory use is bounded by the length of the longest value.
+// Close flushes any buffered data.
+type Redactor struct {
+	mutex      sync.Mutex
+	writer     io.Writer
+	trie       *redactionTrie
+	pending    []byte
+	flushDelay time.Duration
+	flushTimer *time.Timer
+	// writes increments with each write, so a timer that fires during a write doesn't flush the write's pending data
+	writes int
+	err    error
+}
+
+// NewRedactor creates a Redactor that redacts the values from data written to w
+func NewRedactor(w io.Writer, values []string) *Redactor {
+	return &Redactor{writer: w, trie: newRedactionTrie(values), flushDelay: RedactorFlushDelay}
+}
+
+// Write redacts and writes the data, buffering any data that may be the start of a value
+func (r *Redactor) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// an error from a delayed flush is returned by the next write
+	if r.err != nil {
+		err := r.err
+		r.err = nil
+		return 0, err
+	}
+
+	r.stopFlushTimer()
+	r.writes++
+	if err := r.redact(append(r.pending, p...), false); err != nil {
+		return 0, err
+	}
+	if len(r.pending) > 0 && r.flushDelay > 0 {
+		writes := r.writes
+		r.flushTimer = time.AfterFunc(r.flushDelay, func() { r.flush(writes) })
+	}
+	return len(p), nil
+}
+
+// Close writes any buffered data
+func (r *Redactor) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.stopFlushTimer()
+	if err := r.redact(r.pending, true); err != nil {
+		return err
+	}
+	return r.err
+}
+
+// flush writes the buffered data once no more data has been written for the flush delay
+func (r *Redactor) flush(writes int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if writes != r.writes {
+		return
+	}
+	if err := r.redact(r.pending, true); err != nil {
+		r.err = err
+	}
+}
+
+func (r *Redactor) stopFlushTimer() {
+	if r.flushTimer != nil {
+		r.flushTimer.Stop()
+		r.flushTimer = nil
+	}
+}
+
+// redact writes the data, keeping any incomplete value at its end as pending unless final is set
+func (r *Redactor) redact(data []byte, final bool) error {
+	var out bytes.Buffer
+	i := 0
+	for i < len(data) {
+		length, incomplete := r.trie.match(data[i:])
+		if incomplete && !final {
+			break
+		}
+		if length > 0 {
+			out.WriteString(RedactedValue)
+			i += length
+		} else {
+			out.WriteByte(data[i])
+			i++
+		}
+	}
+
+	r.pending = append([]byte{}, data[i:]...)
+	if out.Len() == 0 {
+		return nil
+	}
+	_, err := r.writer.Write(out.Bytes())
+	return err
+}
@@ -18,14 +18,17 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
 	"os/user"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -105,7 +108,7 @@ func Cwd() string {
 }
 
 // RunCommand runs the specified command
-func RunCommand(command []string, env []string, inFile *os.File, outFile *os.File, errFile *os.File) (int, error) {
+func RunCommand(command []string, env []string, inFile *os.File, outFile io.Writer, errFile io.Writer) (int, error) {
 	cmd := exec.Command(command[0], command[1:]...) // #nosec G204
 	cmd.Env = env
 	cmd.Stdin = inFile
@@ -116,7 +119,7 @@ func RunCommand(command []string, env []string, inFile *os.File, outFile *os.Fil
 }
 
 // RunCommandString runs the specified command string
-func RunCommandString(command string, env []string, inFile *os.File, outFile *os.File, errFile *os.File) (int, error) {
+func RunCommandString(command string, env []string, inFile *os.File, outFile io.Writer, errFile io.Writer) (int, error) {
 	shell := [2]string{"sh", "-c"}
 	if IsWindows() {
 		shell = [2]string{"cmd", "/C"}
@@ -140,6 +143,15 @@ func RunCommandString(command string, env []string, inFile *os.File, outFile *os
 	return execCommand(cmd)
 }
 
+// childProcesses the number of running child processes started by RunCommand and RunCommandString
+var childProcesses int32
+
+// IsChildProcessRunning whether a child process started by RunCommand or RunCommandString is running.
+// the child receives the same interrupts as the CLI, and is responsible for handling them.
+func IsChildProcessRunning() bool {
+	return atomic.LoadInt32(&childProcesses) > 0
+}
+
 func execCommand(cmd *exec.Cmd) (int, error) {
 	// signal handling logic adapted from aws-vault https://github.com/99designs/aws-vault/
 	sigChan := make(chan os.Signal, 1)
@@ -148,6 +160,8 @@ func execCommand(cmd *exec.Cmd) (int, error) {
 	if err := cmd.Start(); err != nil {
 		return 1, err
 	}
+	atomic.AddInt32(&childProcesses, 1)
+	defer atomic.AddInt32(&childProcesses, -1)
 
 	// catch and ignore all signals
 	// no need to manually send them to the subprocess since it's in the same process group
@@ -177,7 +191,7 @@ func execCommand(cmd *exec.Cmd) (int, error) {
 func RequireValue(name string, value string) {
 	value = strings.TrimSpace(value)
 	if value == "" {
-		HandleError(fmt.Errorf("you must provide a %s", name))
+		HandleError(NewError(ErrorKindUsage, fmt.Errorf("you must provide a %s", name)))
 	}
 }
 
@@ -194,7 +208,7 @@ func GetBool(value string, def bool) bool {
 func GetBoolFlag(cmd *cobra.Command, flag string) bool {
 	b, err := strconv.ParseBool(cmd.Flag(flag).Value.String())
 	if err != nil {
-		HandleError(err)
+		HandleError(NewError(ErrorKindUsage, err))
 	}
 	return b
 }
@@ -228,7 +242,7 @@ func GetPathFlagIfChanged(cmd *cobra.Command, flag string, def string) string {
 
 	path, err := ParsePath(cmd.Flag(flag).Value.String())
 	if err != nil {
-		HandleError(err, "Unable to parse path")
+		HandleError(NewError(ErrorKindUsage, err), "Unable to parse path")
 	}
 	return path
 }
@@ -237,7 +251,7 @@ func GetPathFlagIfChanged(cmd *cobra.Command, flag string, def string) string {
 func GetIntFlag(cmd *cobra.Command, flag string, bits int) int {
 	number, err := strconv.ParseInt(cmd.Flag(flag).Value.String(), 10, bits)
 	if err != nil {
-		HandleError(err)
+		HandleError(NewError(ErrorKindUsage, err))
 	}
 
 	return int(number)
@@ -247,7 +261,7 @@ func GetIntFlag(cmd *cobra.Command, flag string, bits int) int {
 func GetDurationFlag(cmd *cobra.Command, flag string) time.Duration {
 	value, err := time.ParseDuration(cmd.Flag(flag).Value.String())
 	if err != nil {
-		HandleError(err)
+		HandleError(NewError(ErrorKindUsage, err))
 	}
 	return value
 }
@@ -262,6 +276,35 @@ func GetDurationFlagIfChanged(cmd *cobra.Command, flag string, def time.Duration
 	return GetDurationFlag(cmd, flag)
 }
 
+// ParseTime parses an absolute time (RFC 3339 or YYYY-MM-DD) or a duration before now (e.g. 24h)
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %s; use RFC 3339 (e.g. 2020-01-02T15:04:05Z), a date (e.g. 2020-01-02), or a duration (e.g. 24h)", value)
+}
+
+// GetTimeFlag gets the flag's time. returns the zero time if the flag is unspecified
+func GetTimeFlag(cmd *cobra.Command, flag string) time.Time {
+	value := cmd.Flag(flag).Value.String()
+	if value == "" {
+		return time.Time{}
+	}
+
+	t, err := ParseTime(value, time.Now())
+	if err != nil {
+		HandleError(NewError(ErrorKindUsage, err), fmt.Sprintf("Invalid --%s", flag))
+	}
+	return t
+}
+
 // GetFilePath verify a file path and name are provided
 func GetFilePath(fullPath string) (string, error) {
 	if fullPath == "" {
@@ -303,6 +346,35 @@ func ConfirmationPrompt(message string, defaultValue bool) bool {
 	return confirm
 }
 
+// ConfirmationPromptStderr prompt user to confirm yes/no, writing the prompt to stderr.
+// useful when stdout is being captured (e.g. by a shell)
+func ConfirmationPromptStderr(message string, defaultValue bool) bool {
+	confirm := false
+	prompt := &survey.Confirm{
+		Message: message,
+		Default: defaultValue,
+	}
+
+	err := survey.AskOne(prompt, &confirm, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+	if err != nil {
+		if err == terminal.InterruptErr {
+			Log("Exiting")
+			os.Exit(1)
+		}
+		HandleError(err)
+	}
+	return confirm
+}
+
+// IsTerminal whether the file is an interactive terminal
+func IsTerminal(file *os.File) bool {
+	stat, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return (stat.Mode() & os.ModeCharDevice) != 0
+}
+
 // SelectPrompt prompt user to select from a list of options
 func SelectPrompt(message string, options []string, defaultOption string) string {
 	prompt := &survey.Select{
@@ -382,3 +454,22 @@ func UUID() (string, error) {
 
 	return uuid.String(), nil
 }
+
+// MaskToken hides all but the token's prefix (e.g. dp.st.) and last 4 characters
+func MaskToken(token string) string {
+	if len(token) < 12 {
+		return strings.Repeat("*", len(token))
+	}
+
+	// only Doppler's token type prefix is left visible; other tokens (e.g. JWTs) may contain sensitive data in their prefix
+	prefix := dopplerTokenPrefix.FindString(token)
+	suffix := token[len(token)-4:]
+	stars := len(token) - len(prefix) - len(suffix)
+	if stars <= 0 {
+		// the prefix and suffix would reveal the whole token
+		return strings.Repeat("*", len(token))
+	}
+	return prefix + strings.Repeat("*", stars) + suffix
+}
+
+var dopplerTokenPrefix = regexp.MustCompile(`^dp\.[a-z]+\.`)
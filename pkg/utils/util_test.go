@@ -136,3 +136,26 @@ func TestGetFilePath(t *testing.T) {
 		t.Error(fmt.Sprintf("Got %s, expected %s", path, "/root"))
 	}
 }
+
+func TestMaskToken(t *testing.T) {
+	tests := map[string]string{
+		"dp.st.dev.abcdefghij": "dp.st.**********ghij",
+		"dp.ct.abcdefghij":     "dp.ct.******ghij",
+		"abcdefghijklmnop":     "************mnop",
+		"short":                "*****",
+		"aaaaaaaaaa.b.c":       "**********.b.c",
+		"dp.st.abcd":           "**********",
+		"dp.st.abcdefg":        "dp.st.***defg",
+		"dp.longtype.abcd":     "****************",
+		"dp.longtype.abcde":    "dp.longtype.*bcde",
+		"dp.averylongtype.ab":  "*******************",
+		"eyJhbGciOi.eyJzdWIiOiIxMjM0In0.c2lnbmF0dXJl": "***************************************dXJl",
+		"": "",
+	}
+
+	for token, expected := range tests {
+		if masked := MaskToken(token); masked != expected {
+			t.Error(fmt.Sprintf("Got %s, expected %s", masked, expected))
+		}
+	}
+}
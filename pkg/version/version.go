@@ -24,16 +24,53 @@ import (
 // ProgramVersion the current version of this program
 var ProgramVersion = "dev"
 
+// release channels from which the CLI can be updated
+const (
+	// StableChannel releases
+	StableChannel = "stable"
+	// BetaChannel prereleases and releases
+	BetaChannel = "beta"
+)
+
+// ReleaseChannels all supported release channels
+var ReleaseChannels = []string{StableChannel, BetaChannel}
+
+// IsValidChannel whether the release channel is supported
+func IsValidChannel(channel string) bool {
+	for _, c := range ReleaseChannels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
 // Version semver
 type Version struct {
 	Major int16
 	Minor int16
 	Patch int16
+	// Prerelease dot-separated prerelease identifiers (e.g. beta.1)
+	Prerelease string
+	// Build dot-separated build metadata, which is ignored when comparing versions
+	Build string
 }
 
-// Unwrap get the original error
+// String the version in its canonical form (e.g. v1.2.3-beta.1)
 func (v Version) String() string {
-	return fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+	s := fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// IsPrerelease whether the version is a prerelease (e.g. v1.2.3-beta.1)
+func (v Version) IsPrerelease() bool {
+	return v.Prerelease != ""
 }
 
 // IsDevelopment whether the CLI is running in development mode (not a released version)
@@ -67,32 +104,103 @@ func CompareVersions(a Version, b Version) int {
 		return 1
 	}
 
+	return comparePrereleases(a.Prerelease, b.Prerelease)
+}
+
+// comparePrereleases compares prerelease identifiers per semver precedence rules, using the same return values as CompareVersions
+func comparePrereleases(a string, b string) int {
+	// a release has higher precedence than any of its prereleases
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+	if b == "" {
+		return 1
+	}
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.ParseUint(aParts[i], 10, 64)
+		bNum, bErr := strconv.ParseUint(bParts[i], 10, 64)
+		aIsNum := aErr == nil
+		bIsNum := bErr == nil
+
+		switch {
+		case aIsNum && bIsNum:
+			if aNum > bNum {
+				return -1
+			}
+			if bNum > aNum {
+				return 1
+			}
+		// numeric identifiers have lower precedence than alphanumeric ones
+		case aIsNum:
+			return 1
+		case bIsNum:
+			return -1
+		default:
+			if aParts[i] > bParts[i] {
+				return -1
+			}
+			if bParts[i] > aParts[i] {
+				return 1
+			}
+		}
+	}
+
+	// a larger set of identifiers has higher precedence when all preceding identifiers are equal
+	if len(aParts) > len(bParts) {
+		return -1
+	}
+	if len(bParts) > len(aParts) {
+		return 1
+	}
 	return 0
 }
 
-// ParseVersion from a string
+// ParseVersion from a string (e.g. v1.2.3, 1.2.3-beta.1, or 1.2.3+build.5)
 func ParseVersion(s string) (Version, error) {
+	original := s
 	if strings.HasPrefix(s, "v") {
 		s = s[1:]
 	}
+
+	var v Version
+	if i := strings.Index(s, "+"); i != -1 {
+		v.Build = s[i+1:]
+		s = s[:i]
+		if !validIdentifiers(v.Build, false) {
+			return Version{}, fmt.Errorf("Invalid version %s", original)
+		}
+	}
+	if i := strings.Index(s, "-"); i != -1 {
+		v.Prerelease = s[i+1:]
+		s = s[:i]
+		if !validIdentifiers(v.Prerelease, true) {
+			return Version{}, fmt.Errorf("Invalid version %s", original)
+		}
+	}
+
 	parts := strings.Split(s, ".")
 	if len(parts) != 3 {
-		return Version{}, fmt.Errorf("Invalid version %s", s)
+		return Version{}, fmt.Errorf("Invalid version %s", original)
 	}
 
-	var v Version
 	var major int64
 	var minor int64
 	var patch int64
 	var err error
-	if major, err = strconv.ParseInt(parts[0], 10, 16); err != nil {
-		return Version{}, fmt.Errorf("Invalid version %s", s)
+	if major, err = strconv.ParseInt(parts[0], 10, 16); err != nil || major < 0 {
+		return Version{}, fmt.Errorf("Invalid version %s", original)
 	}
-	if minor, err = strconv.ParseInt(parts[1], 10, 16); err != nil {
-		return Version{}, fmt.Errorf("Invalid version %s", s)
+	if minor, err = strconv.ParseInt(parts[1], 10, 16); err != nil || minor < 0 {
+		return Version{}, fmt.Errorf("Invalid version %s", original)
 	}
-	if patch, err = strconv.ParseInt(parts[2], 10, 16); err != nil {
-		return Version{}, fmt.Errorf("Invalid version %s", s)
+	if patch, err = strconv.ParseInt(parts[2], 10, 16); err != nil || patch < 0 {
+		return Version{}, fmt.Errorf("Invalid version %s", original)
 	}
 
 	v.Major = int16(major)
@@ -101,6 +209,32 @@ func ParseVersion(s string) (Version, error) {
 	return v, nil
 }
 
+// validIdentifiers whether s is a valid dot-separated list of semver identifiers
+func validIdentifiers(s string, prerelease bool) bool {
+	for _, identifier := range strings.Split(s, ".") {
+		if identifier == "" {
+			return false
+		}
+
+		numeric := true
+		for _, c := range identifier {
+			if c >= '0' && c <= '9' {
+				continue
+			}
+			numeric = false
+			if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && c != '-' {
+				return false
+			}
+		}
+
+		// numeric prerelease identifiers can't have leading zeroes
+		if prerelease && numeric && len(identifier) > 1 && identifier[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
 // Normalize prepends a 'v' to a version (e.g. 1.0.0 -> v1.0.0)
 func Normalize(version string) string {
 	version = strings.TrimSpace(version)
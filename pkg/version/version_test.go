@@ -0,0 +1,92 @@
+/*
+Copyright © 2019 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	valid := map[string]Version{
+		"1.2.3":               {Major: 1, Minor: 2, Patch: 3},
+		"v1.2.3":              {Major: 1, Minor: 2, Patch: 3},
+		"v1.2.3-beta.1":       {Major: 1, Minor: 2, Patch: 3, Prerelease: "beta.1"},
+		"v1.2.3-rc-1+build.5": {Major: 1, Minor: 2, Patch: 3, Prerelease: "rc-1", Build: "build.5"},
+		"v1.2.3+001":          {Major: 1, Minor: 2, Patch: 3, Build: "001"},
+	}
+	for s, expected := range valid {
+		v, err := ParseVersion(s)
+		if err != nil || v != expected {
+			t.Errorf("Got %+v (%v) for %s, expected %+v", v, err, s, expected)
+		}
+	}
+
+	invalid := []string{"", "1.2", "1.2.3.4", "v1.2.x", "1.2.3-", "1.2.3-beta..1", "1.2.3-01", "1.2.3+", "1.2.3-beta_1", "-1.2.3"}
+	for _, s := range invalid {
+		if _, err := ParseVersion(s); err == nil {
+			t.Errorf("Expected an error for %s", s)
+		}
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	for _, s := range []string{"v1.2.3", "v1.2.3-beta.1", "v1.2.3-beta.1+build.5"} {
+		v, err := ParseVersion(s)
+		if err != nil || v.String() != s {
+			t.Errorf("Got %s, expected %s", v.String(), s)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	// in ascending order of precedence, per the semver spec
+	ordered := []string{
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"v1.0.0",
+		"v1.0.1",
+		"v1.1.0",
+		"v2.0.0-beta",
+		"v2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := ParseVersion(ordered[i])
+			b, _ := ParseVersion(ordered[j])
+
+			expected := 0
+			if i > j {
+				expected = -1
+			} else if i < j {
+				expected = 1
+			}
+			if compare := CompareVersions(a, b); compare != expected {
+				t.Errorf("Got %d comparing %s to %s, expected %d", compare, ordered[i], ordered[j], expected)
+			}
+		}
+	}
+
+	a, _ := ParseVersion("v1.0.0+build.1")
+	b, _ := ParseVersion("v1.0.0+build.2")
+	if compare := CompareVersions(a, b); compare != 0 {
+		t.Errorf("Expected build metadata to be ignored, got %d", compare)
+	}
+}
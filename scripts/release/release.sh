@@ -18,6 +18,16 @@ echo "Using Docker config $DOCKER_CONFIG"
 echo "$DOCKER_HUB_TOKEN" | docker login -u "$DOCKER_HUB_USER" --password-stdin "$DOCKER_REGISTRY"
 echo "$GOOGLE_CREDS" | docker login -u "$GCR_USER" --password-stdin "$GCR_REGISTRY"
 
+# embed the public key so 'doppler update' can verify releases
+RELEASE_SIGNING_KEY=$(gpg --armor --export B70BD7FCA460C4A3D0EEB965D3D593D50EE79DEC | base64 | tr -d '\n')
+if [ -z "$RELEASE_SIGNING_KEY" ]; then
+  echo "Unable to export the release signing key"
+  exit 1
+fi
+export RELEASE_SIGNING_KEY
+DOPPLER_REQUIRE_RELEASE_SIGNING_KEY=true go test ./pkg/updater -run TestReleaseSigningKey -count=1 \
+  -ldflags "-X github.com/DopplerHQ/cli/pkg/updater.releaseSigningKey=$RELEASE_SIGNING_KEY"
+
 # pull in latest docker images
 docker pull alpine
 docker pull node:lts-alpine
@@ -8,6 +8,18 @@ export DOPPLER_BINARY="$DIR/doppler"
 export DOPPLER_PROJECT="cli"
 export DOPPLER_CONFIG="prd_e2e_tests"
 
+# Without a token, run against a local fake of the Doppler API, which requires a development build (make build)
+if [ -z "${DOPPLER_TOKEN:-}" ]; then
+  FAKE_API_ADDRESS="127.0.0.1:${FAKE_API_PORT:-8089}"
+  "$DOPPLER_BINARY" dev fake-api --address "$FAKE_API_ADDRESS" --state "$DIR/tests/fixtures/fake-api.json" > /dev/null &
+  FAKE_API_PID=$!
+  trap 'kill "$FAKE_API_PID" 2> /dev/null || true' EXIT
+  sleep 1
+
+  export DOPPLER_API_HOST="http://$FAKE_API_ADDRESS"
+  export DOPPLER_TOKEN="dp.ct.fake"
+fi
+
 # Run tests
 "$DIR/tests/secrets-download-fallback.sh"
 "$DIR/tests/run-fallback.sh"